@@ -10,17 +10,15 @@
 // decoding. However it's possible to use a Context object to set the desired
 // encoding and decoding rules as well other options.
 //
-// Restrictions:
-//
-// - BER allows STRING types, such as OCTET STRING and BIT STRING, to be
-// encoded as constructed types containing inner elements that should be
-// concatenated to form the complete string. The package does not support that,
-// but in the future decoding of constructed strings should be included.
+// BER allows STRING types, such as OCTET STRING and BIT STRING, to be encoded
+// as constructed types containing inner segments that are concatenated to form
+// the complete string. Constructed strings are accepted when decoding BER and
+// CER, and CER encodes strings longer than 1000 octets in that form. See
+// EncodingRules.SegmentSize().
 package asn1
 
 // TODO add a mechanism for extendability
 // TODO proper checking of the constructed flag
-// TODO support for constructed encoding and decoding of string types in BER
 
 import (
 	"fmt"
@@ -219,6 +219,8 @@ func TestUtf8String(t *testing.T) {
 }
 
 func TestOctetString(t *testing.T) {
+	type Bytes []byte
+	type Byte byte
 	tests := []testCase{
 		{
 			[]byte{},
@@ -244,6 +246,14 @@ func TestOctetString(t *testing.T) {
 			[...]byte{0x01, 0x02, 0x03},
 			[]byte{0x04, 0x03, 0x01, 0x02, 0x03},
 		},
+		{
+			Bytes{0x01, 0x02},
+			[]byte{0x04, 0x02, 0x01, 0x02},
+		},
+		{
+			[2]Byte{0x01, 0x02},
+			[]byte{0x04, 0x02, 0x01, 0x02},
+		},
 	}
 	ctx := NewContext()
 	testEncodeDecode(t, ctx, "", tests...)
@@ -725,6 +735,56 @@ func TestArraySlice(t *testing.T) {
 	testEncodeDecode(t, ctx, "", testCases...)
 }
 
+func TestElementOptions(t *testing.T) {
+	ctx := NewContext()
+	testEncodeDecode(t, ctx, "elem:tag:0", testCase{
+		[]int{0, 1},
+		[]byte{0x30, 0x06, 0x80, 0x01, 0x00, 0x80, 0x01, 0x01},
+	})
+	testEncodeDecode(t, ctx, "set,elem:tag:1,elem:explicit,elem:ia5", testCase{
+		[]string{"a", "b"},
+		[]byte{0x31, 0x0a, 0xa1, 0x03, 0x16, 0x01, 0x61, 0xa1, 0x03, 0x16, 0x01, 0x62},
+	})
+	testEncodeDecode(t, ctx, "elem:elem:tag:2", testCase{
+		[][]bool{{true}},
+		[]byte{0x30, 0x05, 0x30, 0x03, 0x82, 0x01, 0xff},
+	})
+
+	ctx.AddChoice("item", []Choice{
+		{reflect.TypeOf(int(0)), "tag:0"},
+		{reflect.TypeOf(""), "tag:1"},
+	})
+	type Type struct {
+		Items []interface{} `asn1:"elem:choice:item"`
+	}
+	testEncodeDecode(t, ctx, "", testCase{
+		Type{[]interface{}{1, "a"}},
+		[]byte{0x30, 0x08, 0x30, 0x06, 0x80, 0x01, 0x01, 0x81, 0x01, 0x61},
+	})
+
+	// Alternatives of a choice with element options
+	ctx.AddChoice("list", []Choice{
+		{reflect.TypeOf([]int{}), "tag:0,elem:tag:1"},
+	})
+	testEncodeDecode(t, ctx, "choice:list", testCase{
+		[]int{5},
+		[]byte{0xa0, 0x03, 0x81, 0x01, 0x05},
+	})
+
+	for _, options := range []string{"elem", "elem:optional", "elem:name:a", "elem:tag:-1"} {
+		if _, err := ctx.EncodeWithOptions([]int{1}, options); err == nil {
+			t.Errorf("Options %q should have failed.", options)
+		}
+	}
+	opts, err := parseOptions("size:1..2,elem:tag:0,elem:elem:set")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := opts.String(); s != "size:1..2,elem:tag:0,elem:elem:set" {
+		t.Fatalf("Unexpected options: %s", s)
+	}
+}
+
 func TestPointerInterface(t *testing.T) {
 	type I interface{}
 	type Type struct {
@@ -0,0 +1,211 @@
+package asn1
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"unicode/utf8"
+)
+
+// jsonNode is the JSON representation of an element used by BerToJson and
+// JsonToBer.
+type jsonNode struct {
+	Class string `json:"class"`
+	Tag   uint   `json:"tag"`
+	// Name of universal tags, only informative
+	Type string `json:"type,omitempty"`
+	// Identifier and length octets, only present when they differ from the
+	// minimal encoding
+	Header     string `json:"header,omitempty"`
+	Indefinite bool   `json:"indefinite,omitempty"`
+	// Content octets of primitive elements. It's not present in constructed
+	// elements.
+	Content *string `json:"content,omitempty"`
+	// Decoded value of primitive universal elements, only informative
+	Value    json.RawMessage `json:"value,omitempty"`
+	Children []*jsonNode     `json:"children,omitempty"`
+}
+
+// jsonClasses has the names of the classes used in JSON, which are the same
+// names used in path expressions.
+var jsonClasses = map[uint]string{
+	ClassUniversal:       "UNIVERSAL",
+	ClassApplication:     "APPLICATION",
+	ClassContextSpecific: "CONTEXT",
+	ClassPrivate:         "PRIVATE",
+}
+
+// BerToJson converts the first element in data to JSON, without any knowledge
+// of its type, and returns the remaining bytes. Each element is converted to
+// an object with a stable layout:
+//
+//	{"class":"UNIVERSAL","tag":16,"type":"SEQUENCE","children":[
+//		{"class":"UNIVERSAL","tag":2,"type":"INTEGER","content":"05","value":5},
+//		{"class":"CONTEXT","tag":0,"content":"6869"}
+//	]}
+//
+// Constructed elements have their children, while primitive elements have
+// their content octets in hexadecimal. Universal types such as BOOLEAN,
+// INTEGER, ENUMERATED, OBJECT IDENTIFIER and character strings also have their
+// decoded value, when possible. The fields "indefinite" and "header" are
+// present if the element uses the indefinite length form or an identifier
+// and length that are not the minimal ones, so the conversion is lossless.
+//
+// See JsonToBer() for the reverse conversion.
+func BerToJson(data []byte) (js []byte, rest []byte, err error) {
+	node, rest, err := Parse(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	js, err = json.Marshal(newJsonNode(node, data))
+	if err != nil {
+		return nil, nil, err
+	}
+	return js, rest, nil
+}
+
+// newJsonNode converts a node parsed from data.
+func newJsonNode(node *Node, data []byte) *jsonNode {
+	js := &jsonNode{
+		Class:      jsonClasses[node.Class],
+		Tag:        node.Tag,
+		Indefinite: node.Indefinite,
+	}
+	if node.Class == ClassUniversal {
+		js.Type = universalNames[node.Tag]
+	}
+	header := data[node.Offset : node.Offset+node.HeaderLen]
+	minimal := getHeader(node.Class, node.Tag, node.Constructed, node.Indefinite, len(node.Content))
+	if !bytes.Equal(header, minimal) {
+		js.Header = strings.ToUpper(hex.EncodeToString(header))
+	}
+	if node.Constructed {
+		js.Children = []*jsonNode{}
+		for _, child := range node.Children {
+			js.Children = append(js.Children, newJsonNode(child, data))
+		}
+		return js
+	}
+	content := strings.ToUpper(hex.EncodeToString(node.Content))
+	js.Content = &content
+	if node.Class == ClassUniversal {
+		js.Value = getJsonValue(node)
+	}
+	return js
+}
+
+// getJsonValue returns the decoded value of a primitive universal element, or
+// nil if it cannot be decoded.
+func getJsonValue(node *Node) json.RawMessage {
+	var value interface{}
+	switch node.Tag {
+	case tagBoolean:
+		if b, err := node.AsBool(); err == nil {
+			value = b
+		}
+	case tagInteger, tagEnumerated:
+		if n, err := node.AsBigInt(); err == nil {
+			return json.RawMessage(n.String())
+		}
+	case tagOid:
+		if oid, err := node.AsOid(); err == nil {
+			value = strings.TrimPrefix(oid.String(), ".")
+		}
+	case tagUtf8String, tagNumericString, tagPrintableString, tagT61String,
+		tagIA5String, tagUtcTime, tagGeneralizedTime, tagVisibleString:
+		if utf8.Valid(node.Content) {
+			value = string(node.Content)
+		}
+	}
+	if value == nil {
+		return nil
+	}
+	js, err := json.Marshal(value)
+	if err != nil {
+		return nil
+	}
+	return js
+}
+
+// getHeader returns the minimal identifier and length octets of an element.
+func getHeader(class, tag uint, constructed, indefinite bool, length int) []byte {
+	header, _ := encodeIdentifier(&rawValue{Class: class, Tag: tag, Constructed: constructed})
+	if indefinite {
+		return append(header, 0x80)
+	}
+	return append(header, encodeLength(uint(length))...)
+}
+
+// JsonToBer converts an element in the JSON format produced by BerToJson back
+// to its encoding. The original bytes are rebuilt exactly.
+//
+// Only the fields "class", "tag", "header", "indefinite", "content" and
+// "children" are used. The other ones are informative and they are ignored.
+func JsonToBer(js []byte) ([]byte, error) {
+	node := &jsonNode{}
+	if err := json.Unmarshal(js, node); err != nil {
+		return nil, parseError("invalid JSON: %s", err)
+	}
+	data, err := node.encode()
+	if err != nil {
+		return nil, err
+	}
+	// Check if the given headers are consistent
+	if _, rest, err := Parse(data); err != nil || len(rest) > 0 {
+		return nil, parseError("invalid element headers")
+	}
+	return data, nil
+}
+
+// encode returns the encoding of the JSON node.
+func (js *jsonNode) encode() ([]byte, error) {
+	class := uint(0)
+	found := false
+	for c, name := range jsonClasses {
+		if name == js.Class {
+			class, found = c, true
+		}
+	}
+	if !found {
+		return nil, parseError("invalid class '%s'", js.Class)
+	}
+
+	content := []byte{}
+	constructed := js.Content == nil
+	if constructed {
+		for _, child := range js.Children {
+			data, err := child.encode()
+			if err != nil {
+				return nil, err
+			}
+			content = append(content, data...)
+		}
+	} else {
+		if len(js.Children) > 0 {
+			return nil, parseError("primitive element (%d,%d) has children", class, js.Tag)
+		}
+		if js.Indefinite {
+			return nil, parseError("primitive element (%d,%d) has indefinite length", class, js.Tag)
+		}
+		var err error
+		content, err = hex.DecodeString(*js.Content)
+		if err != nil {
+			return nil, parseError("invalid content '%s'", *js.Content)
+		}
+	}
+
+	header := getHeader(class, js.Tag, constructed, js.Indefinite, len(content))
+	if js.Header != "" {
+		var err error
+		header, err = hex.DecodeString(js.Header)
+		if err != nil {
+			return nil, parseError("invalid header '%s'", js.Header)
+		}
+	}
+	data := append(header, content...)
+	if js.Indefinite {
+		data = append(data, 0x00, 0x00)
+	}
+	return data, nil
+}
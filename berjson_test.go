@@ -0,0 +1,61 @@
+package asn1
+
+import (
+	"testing"
+)
+
+func TestBerToJson(t *testing.T) {
+	data := []byte{
+		// SEQ INDEFINITE
+		0x30, 0x80,
+		// INTEGER -1
+		0x02, 0x01, 0xff,
+		// [0] IMPLICIT OCTET STRING with a long form length
+		0x80, 0x81, 0x02, 0x68, 0x69,
+		// [APPLICATION 1] { OID 1.2, UTF8String "ok", NULL }
+		0x61, 0x09, 0x06, 0x01, 0x2a, 0x0c, 0x02, 0x6f, 0x6b, 0x05, 0x00,
+		// EOC
+		0x00, 0x00,
+		// Next element
+		0x01, 0x01, 0xff,
+	}
+	js, rest, err := BerToJson(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkEqual(t, rest, []byte{0x01, 0x01, 0xff})
+	expected := `{"class":"UNIVERSAL","tag":16,"type":"SEQUENCE","indefinite":true,"children":[` +
+		`{"class":"UNIVERSAL","tag":2,"type":"INTEGER","content":"FF","value":-1},` +
+		`{"class":"CONTEXT","tag":0,"header":"808102","content":"6869"},` +
+		`{"class":"APPLICATION","tag":1,"children":[` +
+		`{"class":"UNIVERSAL","tag":6,"type":"OBJECT IDENTIFIER","content":"2A","value":"1.2"},` +
+		`{"class":"UNIVERSAL","tag":12,"type":"UTF8String","content":"6F6B","value":"ok"},` +
+		`{"class":"UNIVERSAL","tag":5,"type":"NULL","content":""}]}]}`
+	checkEqual(t, string(js), expected)
+
+	// The reverse conversion rebuilds the same bytes
+	ber, err := JsonToBer(js)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkEqual(t, ber, data[:len(data)-3])
+
+	// Informative fields are not needed
+	ber, err = JsonToBer([]byte(`{"class":"PRIVATE","tag":40,"children":[{"class":"UNIVERSAL","tag":1,"content":"00"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkEqual(t, ber, []byte{0xff, 0x28, 0x03, 0x01, 0x01, 0x00})
+
+	for _, invalid := range []string{
+		`{"class":"OTHER","tag":1,"content":"00"}`,
+		`{"class":"UNIVERSAL","tag":1,"content":"0"}`,
+		`{"class":"UNIVERSAL","tag":4,"content":"","indefinite":true}`,
+		`{"class":"UNIVERSAL","tag":4,"header":"0402","content":"00"}`,
+		`[]`,
+	} {
+		if _, err = JsonToBer([]byte(invalid)); err == nil {
+			t.Fatalf("Converting %s should have failed.", invalid)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package asn1
+
+import (
+	"bytes"
+	"sort"
+)
+
+// Ambiguity is a transformation that Canonicalize could not decide without
+// the type of an element.
+type Ambiguity struct {
+	// Offset of the element in the original data
+	Offset int
+	Reason string
+}
+
+// Canonicalize converts the BER elements in data to DER, without any knowledge
+// of their types. The following transformations are applied:
+//
+//	- Indefinite lengths are replaced by definite ones;
+//	- Lengths and tags are encoded in their minimal form;
+//	- Universal strings in the constructed form are flattened;
+//	- BOOLEAN values are normalized to 0xff or 0x00;
+//	- Unused bits of BIT STRINGs are cleared;
+//	- Components of SETs are sorted, by their tags or by their encodings.
+//
+// Some transformations depend on the types of the elements and they are
+// reported as ambiguities, along with the offset of the element. A SET whose
+// components have different tags may be a SET, sorted by tags, or a SET OF,
+// sorted by encodings. When both orders differ, the components are sorted by
+// their tags. Implicitly tagged values are never changed, since they may be
+// constructed strings, BOOLEANs or SETs, but the ones that look like
+// constructed strings are reported. DEFAULT values cannot be found and are
+// kept.
+func Canonicalize(data []byte) (der []byte, ambiguities []Ambiguity, err error) {
+	der = []byte{}
+	ambiguities = []Ambiguity{}
+	for offset := 0; offset < len(data); {
+		node, err := parseNode(data[offset:], offset)
+		if err != nil {
+			return nil, nil, err
+		}
+		offset += node.Len()
+		found, err := canonicalizeNode(node)
+		if err != nil {
+			return nil, nil, err
+		}
+		ambiguities = append(ambiguities, found...)
+		encoded, err := node.EncodeDefinite()
+		if err != nil {
+			return nil, nil, err
+		}
+		der = append(der, encoded...)
+	}
+	return der, ambiguities, nil
+}
+
+// canonicalizeNode converts a node and its children to the canonical form and
+// returns the ambiguities found.
+func canonicalizeNode(node *Node) ([]Ambiguity, error) {
+	ambiguities := []Ambiguity{}
+	node.Indefinite = false
+	for _, child := range node.Children {
+		found, err := canonicalizeNode(child)
+		if err != nil {
+			return nil, err
+		}
+		ambiguities = append(ambiguities, found...)
+	}
+
+	if node.Class != ClassUniversal {
+		if node.Constructed && isSegmentList(node.Children) {
+			ambiguities = append(ambiguities, Ambiguity{node.Offset,
+				"implicitly tagged value may be a constructed string"})
+		}
+		return ambiguities, nil
+	}
+
+	// Flatten constructed strings
+	if node.Constructed && isStringTag(node.Tag) {
+		raw, err := node.raw(false)
+		if err != nil {
+			return nil, err
+		}
+		content, err := nodeContext.getContent(raw, expectedElement{stringTag: node.Tag})
+		if err != nil {
+			return nil, err
+		}
+		node.Constructed = false
+		node.Content = content
+		node.Children = nil
+	}
+
+	switch {
+	case node.Tag == tagBoolean && !node.Constructed && len(node.Content) == 1:
+		if node.Content[0] != 0 {
+			node.Content = []byte{0xff}
+		}
+	case node.Tag == tagBitString && !node.Constructed && len(node.Content) > 1:
+		unused := node.Content[0]
+		if unused > 0 && unused < 8 {
+			content := append([]byte{}, node.Content...)
+			content[len(content)-1] &= 0xff << unused
+			node.Content = content
+		}
+	case node.Tag == tagSet && node.Constructed:
+		ambiguous, err := sortSet(node.Children)
+		if err != nil {
+			return nil, err
+		}
+		if ambiguous {
+			ambiguities = append(ambiguities, Ambiguity{node.Offset,
+				"SET components sorted by tags, but a SET OF would be sorted by encodings"})
+		}
+	}
+	return ambiguities, nil
+}
+
+// isSegmentList checks if the nodes look like the segments of a constructed
+// string.
+func isSegmentList(nodes []*Node) bool {
+	if len(nodes) == 0 {
+		return false
+	}
+	for _, node := range nodes {
+		if node.Class != ClassUniversal || node.Constructed ||
+			(node.Tag != tagOctetString && node.Tag != tagBitString) ||
+			node.Tag != nodes[0].Tag {
+			return false
+		}
+	}
+	return true
+}
+
+// sortSet sorts the components of a SET or SET OF. The components are sorted
+// by their encodings if any tag is repeated, otherwise they are sorted by their
+// tags. It returns true if the latter order differs from the order of the
+// encodings.
+func sortSet(nodes []*Node) (ambiguous bool, err error) {
+	encodings := map[*Node][]byte{}
+	tags := map[[2]uint]bool{}
+	for _, node := range nodes {
+		encodings[node], err = node.EncodeDefinite()
+		if err != nil {
+			return false, err
+		}
+		tags[[2]uint{node.Class, node.Tag}] = true
+	}
+	byEncoding := func(i, j int) bool {
+		return bytes.Compare(encodings[nodes[i]], encodings[nodes[j]]) < 0
+	}
+	if len(tags) < len(nodes) {
+		// Only a SET OF accepts repeated tags
+		sort.SliceStable(nodes, byEncoding)
+		return false, nil
+	}
+	sort.SliceStable(nodes, func(i, j int) bool {
+		return isTagLessThan(nodes[i].Class, nodes[i].Tag, nodes[j].Class, nodes[j].Tag)
+	})
+	return !sort.SliceIsSorted(nodes, byEncoding), nil
+}
@@ -0,0 +1,58 @@
+package asn1
+
+import (
+	"testing"
+)
+
+func TestCanonicalize(t *testing.T) {
+	data := []byte{
+		// SEQ INDEFINITE
+		0x30, 0x80,
+		// BOOLEAN with a long form length
+		0x01, 0x81, 0x01, 0x01,
+		// Constructed OCTET STRING
+		0x24, 0x80, 0x04, 0x01, 0x61, 0x04, 0x01, 0x62, 0x00, 0x00,
+		// BIT STRING with a dirty unused bit
+		0x03, 0x02, 0x01, 0xff,
+		// SET OF { INTEGER 2, INTEGER 1 }
+		0x31, 0x06, 0x02, 0x01, 0x02, 0x02, 0x01, 0x01,
+		// SET { [1] 5, [0] { NULL } }
+		0x31, 0x07, 0x81, 0x01, 0x05, 0xa0, 0x02, 0x05, 0x00,
+		// [2] { OCTET STRING "c" }
+		0xa2, 0x03, 0x04, 0x01, 0x63,
+		// EOC
+		0x00, 0x00,
+		// Second element: multi-octet tag that could fit in one octet
+		0x9f, 0x05, 0x00,
+	}
+	der, ambiguities, err := Canonicalize(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkEqual(t, der, []byte{
+		0x30, 0x21,
+		0x01, 0x01, 0xff,
+		0x04, 0x02, 0x61, 0x62,
+		0x03, 0x02, 0x01, 0xfe,
+		0x31, 0x06, 0x02, 0x01, 0x01, 0x02, 0x01, 0x02,
+		0x31, 0x07, 0xa0, 0x02, 0x05, 0x00, 0x81, 0x01, 0x05,
+		0xa2, 0x03, 0x04, 0x01, 0x63,
+		0x85, 0x00,
+	})
+	checkEqual(t, ambiguities, []Ambiguity{
+		{28, "SET components sorted by tags, but a SET OF would be sorted by encodings"},
+		{37, "implicitly tagged value may be a constructed string"},
+	})
+
+	// DER is not changed
+	again, ambiguities, err := Canonicalize(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkEqual(t, again, der)
+	checkEqual(t, len(ambiguities), 2)
+
+	if _, _, err = Canonicalize([]byte{0x24, 0x03, 0x02, 0x01, 0x01}); err == nil {
+		t.Fatal("Invalid segments should have failed.")
+	}
+}
@@ -0,0 +1,1044 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/PromonLogicalis/asn1/syntax"
+)
+
+// generator converts the assignments of ASN.1 modules to Go declarations.
+type generator struct {
+	// Package name
+	pkg string
+	// Name of the Context variable with the CHOICE and ENUMERATED types
+	context string
+	// Use *big.Int for INTEGER types without bounds
+	bigInt bool
+
+	modules []*syntax.Module
+	files   map[*syntax.Module]string
+	types   map[string]typeDef
+	values  map[string]valueDef
+
+	// Go declarations, statements of the init function and imported
+	// packages
+	decls   []string
+	inits   []string
+	imports map[string]bool
+	// Go names already declared
+	names map[string]bool
+}
+
+// typeDef is a type assignment and its module.
+type typeDef struct {
+	assignment *syntax.TypeAssignment
+	module     *syntax.Module
+}
+
+// valueDef is a value assignment and its module.
+type valueDef struct {
+	assignment *syntax.ValueAssignment
+	module     *syntax.Module
+}
+
+// tagOption is the tag of a Go field.
+type tagOption struct {
+	// Empty for context-specific tags, "application" or "universal"
+	class    string
+	number   int64
+	explicit bool
+}
+
+// fieldType is the Go type of an ASN.1 type and the options needed to encode
+// it.
+type fieldType struct {
+	// Go type expression, like "int64" or "[]Extension"
+	expr string
+	tag  *tagOption
+	// Universal tag of character strings without a string type option
+	universal int
+	// Options given by the type, like "set" or "enum:Color"
+	opts       []string
+	extensible bool
+	valueRange string
+	size       string
+	// The type is a CHOICE or ANY, whose tags are always explicit
+	choice bool
+	// The type is an INTEGER or ENUMERATED mapped to a Go integer
+	integer bool
+	// The builtin type found after references, tags and constraints
+	base syntax.Type
+	// Options of the elements of SEQUENCE OF and SET OF types
+	elem []string
+}
+
+// specialTypes are the Go types recognized by their identity, which cannot be
+// used to define new types.
+var specialTypes = map[string]bool{
+	"asn1.Oid": true, "asn1.BitString": true, "asn1.Null": true, "*big.Int": true,
+}
+
+// stringOptions are the string type options of character strings.
+var stringOptions = map[string]string{
+	"UTF8String":      "utf8",
+	"NumericString":   "numeric",
+	"PrintableString": "printable",
+	"IA5String":       "ia5",
+	"VisibleString":   "visible",
+	"ISO646String":    "visible",
+}
+
+// universalTags are the universal tags of character strings and time types
+// without a string type option. Their values are kept as Go strings without
+// any conversion.
+var universalTags = map[string]int{
+	"ObjectDescriptor": 7,
+	"TeletexString":    20,
+	"T61String":        20,
+	"VideotexString":   21,
+	"UTCTime":          23,
+	"GeneralizedTime":  24,
+	"GraphicString":    25,
+	"GeneralString":    27,
+	"UniversalString":  28,
+	"BMPString":        30,
+}
+
+// oidRoots are the names of the first arc of OBJECT IDENTIFIER values.
+var oidRoots = map[string]int64{
+	"itu-t": 0, "ccitt": 0, "iso": 1, "joint-iso-itu-t": 2, "joint-iso-ccitt": 2,
+}
+
+// newGenerator returns a generator for the given modules, indexed by their
+// file names.
+func newGenerator(pkg string, modules []*syntax.Module, files map[*syntax.Module]string) *generator {
+	g := &generator{
+		pkg:     pkg,
+		context: "Context",
+		modules: modules,
+		files:   files,
+		types:   map[string]typeDef{},
+		values:  map[string]valueDef{},
+		imports: map[string]bool{},
+		names:   map[string]bool{},
+	}
+	for _, m := range modules {
+		for _, a := range m.Assignments {
+			switch a := a.(type) {
+			case *syntax.TypeAssignment:
+				if _, found := g.types[a.Name]; !found {
+					g.types[a.Name] = typeDef{a, m}
+				}
+			case *syntax.ValueAssignment:
+				if _, found := g.values[a.Name]; !found {
+					g.values[a.Name] = valueDef{a, m}
+				}
+			}
+		}
+	}
+	return g
+}
+
+// errorf returns an error at a position of a module.
+func (g *generator) errorf(m *syntax.Module, pos syntax.Pos, format string, args ...interface{}) error {
+	return fmt.Errorf("%s:%s: %s", g.files[m], pos, fmt.Sprintf(format, args...))
+}
+
+// goName converts an ASN.1 reference or identifier to an exported Go name.
+func goName(name string) string {
+	parts := strings.Split(name, "-")
+	for i, part := range parts {
+		if part != "" {
+			parts[i] = strings.ToUpper(part[:1]) + part[1:]
+		}
+	}
+	return strings.Join(parts, "")
+}
+
+// declare reserves a Go name.
+func (g *generator) declare(m *syntax.Module, pos syntax.Pos, name string) error {
+	if g.names[name] || name == g.context {
+		return g.errorf(m, pos, "Go name %s is already used", name)
+	}
+	g.names[name] = true
+	return nil
+}
+
+// reserve reserves a place for a declaration and returns its index, so a type
+// comes before the types of its components.
+func (g *generator) reserve() int {
+	g.decls = append(g.decls, "")
+	return len(g.decls) - 1
+}
+
+// generate returns the formatted Go source.
+func (g *generator) generate() ([]byte, error) {
+	for _, m := range g.modules {
+		for _, a := range m.Assignments {
+			var err error
+			switch a := a.(type) {
+			case *syntax.TypeAssignment:
+				err = g.generateType(a, m)
+			case *syntax.ValueAssignment:
+				err = g.generateValue(a, m)
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	files := []string{}
+	for _, m := range g.modules {
+		files = append(files, g.files[m])
+	}
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated by asn1gen from %s. DO NOT EDIT.\n\n", strings.Join(files, ", "))
+	fmt.Fprintf(&buf, "package %s\n\n", g.pkg)
+	if len(g.inits) > 0 {
+		g.imports["github.com/PromonLogicalis/asn1"] = true
+	}
+	if len(g.imports) > 0 {
+		// Standard packages come first
+		std, others := []string{}, []string{}
+		for imp := range g.imports {
+			if strings.Contains(imp, ".") {
+				others = append(others, strconv.Quote(imp))
+			} else {
+				std = append(std, strconv.Quote(imp))
+			}
+		}
+		sort.Strings(std)
+		sort.Strings(others)
+		groups := []string{}
+		for _, group := range [][]string{std, others} {
+			if len(group) > 0 {
+				groups = append(groups, strings.Join(group, "\n"))
+			}
+		}
+		fmt.Fprintf(&buf, "import (\n%s\n)\n\n", strings.Join(groups, "\n\n"))
+	}
+	if len(g.inits) > 0 {
+		fmt.Fprintf(&buf, "// %s has the CHOICE and ENUMERATED types registered.\n", g.context)
+		fmt.Fprintf(&buf, "var %s = asn1.NewContext()\n\n", g.context)
+	}
+	for _, decl := range g.decls {
+		buf.WriteString(decl)
+		buf.WriteString("\n")
+	}
+	if len(g.inits) > 0 {
+		fmt.Fprintf(&buf, "func init() {\n%s}\n", strings.Join(g.inits, ""))
+	}
+	return format.Source(buf.Bytes())
+}
+
+// generateType generates the declarations of a type assignment.
+func (g *generator) generateType(a *syntax.TypeAssignment, m *syntax.Module) error {
+	name := goName(a.Name)
+	if g.names[name] {
+		return g.errorf(m, a.Pos, "Go name %s is already used", name)
+	}
+	ft, err := g.resolve(a.Type, name, a.Name, m, true)
+	if err != nil {
+		return err
+	}
+	// Constructed types are declared by resolve
+	if !g.names[name] {
+		if err = g.declare(m, a.Pos, name); err != nil {
+			return err
+		}
+		equal := ""
+		if specialTypes[ft.expr] {
+			equal = "= "
+		}
+		g.decls = append(g.decls, fmt.Sprintf("// %s is the ASN.1 type %s.\ntype %s %s%s\n",
+			name, a.Name, name, equal, ft.expr))
+	}
+	if opts := ft.options(); len(opts) > 0 {
+		if err = g.declare(m, a.Pos, name+"Options"); err != nil {
+			return err
+		}
+		g.decls = append(g.decls, fmt.Sprintf(
+			"// %sOptions are the options to encode and decode values of %s.\nconst %sOptions = %q\n",
+			name, name, name, strings.Join(opts, ",")))
+	}
+	return nil
+}
+
+// generateValue generates the declaration of an OBJECT IDENTIFIER, INTEGER,
+// BOOLEAN or character string value. Other values are ignored.
+func (g *generator) generateValue(a *syntax.ValueAssignment, m *syntax.Module) error {
+	base, err := g.getBaseType(a.Type, m)
+	if err != nil {
+		return err
+	}
+	name := goName(a.Name)
+	decl := ""
+	switch t := base.(type) {
+	case *syntax.BuiltinType:
+		switch {
+		case t.Name == "OBJECT IDENTIFIER":
+			oid, err := g.getOid(a.Value, m, 0)
+			if err != nil {
+				return err
+			}
+			arcs := []string{}
+			for _, arc := range oid {
+				arcs = append(arcs, strconv.FormatInt(arc, 10))
+			}
+			g.imports["github.com/PromonLogicalis/asn1"] = true
+			decl = fmt.Sprintf("var %s = asn1.Oid{%s}", name, strings.Join(arcs, ", "))
+		case t.Name == "BOOLEAN":
+			b, ok := a.Value.(*syntax.BooleanValue)
+			if !ok {
+				return g.errorf(m, a.Value.Position(), "invalid BOOLEAN value")
+			}
+			decl = fmt.Sprintf("const %s = %t", name, b.Value)
+		case stringOptions[t.Name] != "" || universalTags[t.Name] != 0:
+			s, ok := a.Value.(*syntax.StringValue)
+			if !ok || s.Kind != 'C' {
+				return nil
+			}
+			decl = fmt.Sprintf("const %s = %q", name, s.Text)
+		}
+	case *syntax.IntegerType:
+		n, err := g.getInt(a.Value, m, 0)
+		if err != nil {
+			return err
+		}
+		decl = fmt.Sprintf("const %s = %s", name, n)
+	}
+	if decl == "" {
+		return nil
+	}
+	if err = g.declare(m, a.Pos, name); err != nil {
+		return err
+	}
+	g.decls = append(g.decls, fmt.Sprintf("// %s is the ASN.1 value %s.\n%s\n", name, a.Name, decl))
+	return nil
+}
+
+/*
+ * Types
+ */
+
+// lookupType returns the assignment of a referenced type.
+func (g *generator) lookupType(t *syntax.ReferencedType, m *syntax.Module) (typeDef, error) {
+	def, found := g.types[t.Name]
+	if !found {
+		return def, g.errorf(m, t.Pos, "undefined type %s", t.Name)
+	}
+	return def, nil
+}
+
+// getBaseType returns the builtin type of a type, without references, tags
+// and constraints.
+func (g *generator) getBaseType(typ syntax.Type, m *syntax.Module) (syntax.Type, error) {
+	for depth := 0; depth < 100; depth++ {
+		switch t := typ.(type) {
+		case *syntax.TaggedType:
+			typ = t.Type
+		case *syntax.ConstrainedType:
+			typ = t.Type
+		case *syntax.ReferencedType:
+			def, err := g.lookupType(t, m)
+			if err != nil {
+				return nil, err
+			}
+			typ, m = def.assignment.Type, def.module
+		default:
+			return typ, nil
+		}
+	}
+	return nil, g.errorf(m, typ.Position(), "circular type definition")
+}
+
+// resolve returns the Go type of an ASN.1 type. The name is the Go name used by
+// inline SEQUENCE, SET, CHOICE, ENUMERATED and INTEGER types with named
+// numbers, which are declared if define is set. The path is the ASN.1 name of
+// the type, like "Record.items".
+func (g *generator) resolve(typ syntax.Type, name, path string, m *syntax.Module, define bool) (*fieldType, error) {
+	switch t := typ.(type) {
+	case *syntax.TaggedType:
+		inner, err := g.resolve(t.Type, name, path, m, define)
+		if err != nil {
+			return nil, err
+		}
+		tag := tagOption{explicit: t.Mode == syntax.ExplicitTags || inner.choice}
+		switch t.Class {
+		case syntax.Application:
+			tag.class = "application"
+		case syntax.Universal:
+			tag.class = "universal"
+		case syntax.Private:
+			return nil, g.errorf(m, t.Pos, "PRIVATE tags are not supported")
+		}
+		n, err := g.getInt(t.Number, m, 0)
+		if err != nil {
+			return nil, err
+		}
+		tag.number = n.Int64()
+		if err = inner.addTag(tag); err != nil {
+			return nil, g.errorf(m, t.Pos, "%s", err)
+		}
+		return inner, nil
+
+	case *syntax.ConstrainedType:
+		inner, err := g.resolve(t.Type, name, path, m, define)
+		if err != nil {
+			return nil, err
+		}
+		g.applyConstraint(inner, t.Constraint, m)
+		return inner, nil
+
+	case *syntax.ReferencedType:
+		def, err := g.lookupType(t, m)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := def.assignment.Type.(*syntax.ReferencedType); ok && path == def.assignment.Name {
+			return nil, g.errorf(m, t.Pos, "circular type definition")
+		}
+		inner, err := g.resolve(def.assignment.Type, goName(def.assignment.Name),
+			def.assignment.Name, def.module, false)
+		if err != nil {
+			return nil, err
+		}
+		inner.expr = goName(def.assignment.Name)
+		return inner, nil
+	}
+	return g.resolveBase(typ, name, path, m, define)
+}
+
+// resolveBase returns the Go type of a builtin type.
+func (g *generator) resolveBase(typ syntax.Type, name, path string, m *syntax.Module, define bool) (*fieldType, error) {
+	ft := &fieldType{opts: []string{}, base: typ}
+	switch t := typ.(type) {
+	case *syntax.BuiltinType:
+		switch {
+		case t.Name == "BOOLEAN":
+			ft.expr = "bool"
+		case t.Name == "NULL":
+			ft.expr = "asn1.Null"
+		case t.Name == "OCTET STRING":
+			ft.expr = "[]byte"
+		case t.Name == "OBJECT IDENTIFIER":
+			ft.expr = "asn1.Oid"
+		case stringOptions[t.Name] != "":
+			ft.expr = "string"
+			ft.opts = append(ft.opts, stringOptions[t.Name])
+		case universalTags[t.Name] != 0:
+			ft.expr = "string"
+			ft.universal = universalTags[t.Name]
+		default:
+			return nil, g.errorf(m, t.Pos, "type %s is not supported", t.Name)
+		}
+
+	case *syntax.IntegerType:
+		ft.integer = true
+		ft.expr = "int64"
+		if g.bigInt {
+			ft.expr = "*big.Int"
+			ft.integer = false
+		}
+		if len(t.NamedNumbers) > 0 {
+			ft.expr = name
+			ft.integer = true
+			if define {
+				if err := g.defineInteger(name, path, t, m); err != nil {
+					return nil, err
+				}
+			}
+		}
+
+	case *syntax.BitStringType:
+		ft.expr = "asn1.BitString"
+		if define {
+			if err := g.defineNamedBits(name, path, t, m); err != nil {
+				return nil, err
+			}
+		}
+
+	case *syntax.EnumeratedType:
+		ft.expr = name
+		ft.integer = true
+		ft.opts = append(ft.opts, "enum:"+name)
+		ft.extensible = t.Extensible
+		if define {
+			if err := g.defineEnumerated(name, path, t, m); err != nil {
+				return nil, err
+			}
+		}
+
+	case *syntax.SequenceType:
+		ft.expr = name
+		if t.Set {
+			ft.opts = append(ft.opts, "set")
+		}
+		ft.extensible = t.Extensible
+		if define {
+			if err := g.defineStruct(name, path, t, m); err != nil {
+				return nil, err
+			}
+		}
+
+	case *syntax.ChoiceType:
+		ft.expr = name
+		ft.choice = true
+		ft.opts = append(ft.opts, "choice:"+name)
+		ft.extensible = t.Extensible
+		if define {
+			if err := g.defineChoice(name, path, t, m); err != nil {
+				return nil, err
+			}
+		}
+
+	case *syntax.SequenceOfType:
+		elemName := name + "Elem"
+		if t.ElementName != "" {
+			elemName = name + goName(t.ElementName)
+		}
+		elem, err := g.resolve(t.Element, elemName, path+".*", m, define)
+		if err != nil {
+			return nil, err
+		}
+		ft.expr = "[]" + elem.expr
+		ft.elem = elem.options()
+		if t.Set {
+			ft.opts = append(ft.opts, "set")
+		}
+		if t.Constraint != nil {
+			g.applyConstraint(ft, t.Constraint, m)
+		}
+
+	case *syntax.AnyType:
+		ft.expr = "interface{}"
+		ft.choice = true
+		ft.opts = append(ft.opts, "choice:"+path)
+	}
+
+	if strings.Contains(ft.expr, "asn1.") {
+		g.imports["github.com/PromonLogicalis/asn1"] = true
+	}
+	if strings.Contains(ft.expr, "big.") {
+		g.imports["math/big"] = true
+	}
+	return ft, nil
+}
+
+// addTag adds an outer tag to the type. Implicit tags replace the existing
+// tag, which makes them explicit if the replaced tag was explicit.
+func (ft *fieldType) addTag(tag tagOption) error {
+	if tag.explicit {
+		if ft.tag != nil || ft.universal != 0 {
+			return fmt.Errorf("explicit tags over tagged types are not supported")
+		}
+		ft.tag = &tag
+		return nil
+	}
+	if ft.tag != nil && ft.tag.explicit {
+		tag.explicit = true
+	}
+	ft.tag = &tag
+	ft.universal = 0
+	return nil
+}
+
+// options returns the field options of the type.
+func (ft *fieldType) options() []string {
+	opts := []string{}
+	if ft.tag != nil {
+		opts = append(opts, fmt.Sprintf("tag:%d", ft.tag.number))
+		if ft.tag.class != "" {
+			opts = append(opts, ft.tag.class)
+		}
+		if ft.tag.explicit {
+			opts = append(opts, "explicit")
+		}
+	} else if ft.universal != 0 {
+		opts = append(opts, fmt.Sprintf("tag:%d", ft.universal), "universal")
+	}
+	opts = append(opts, ft.opts...)
+	if ft.extensible {
+		opts = append(opts, "extensible")
+	}
+	if ft.valueRange != "" {
+		opts = append(opts, "range:"+ft.valueRange)
+	}
+	if ft.size != "" {
+		opts = append(opts, "size:"+ft.size)
+	}
+	for _, opt := range ft.elem {
+		opts = append(opts, "elem:"+opt)
+	}
+	return opts
+}
+
+// applyConstraint sets the range or the size of a type from a constraint with a
+// single element. Constraints of the outer types replace the inner ones and
+// the constraints that cannot be represented are ignored.
+func (g *generator) applyConstraint(ft *fieldType, c *syntax.Constraint, m *syntax.Module) {
+	if len(c.Elements) != 1 {
+		return
+	}
+	if size, ok := c.Elements[0].(*syntax.SizeConstraint); ok {
+		if len(size.Constraint.Elements) != 1 {
+			return
+		}
+		switch ft.base.(type) {
+		case *syntax.BuiltinType, *syntax.BitStringType, *syntax.SequenceOfType:
+			if bounds := g.getBounds(size.Constraint.Elements[0], m); bounds != "" {
+				ft.size = bounds
+				ft.extensible = ft.extensible || c.Extensible || size.Constraint.Extensible
+			}
+		}
+		return
+	}
+	if _, ok := ft.base.(*syntax.IntegerType); ok {
+		if bounds := g.getBounds(c.Elements[0], m); bounds != "" {
+			ft.valueRange = bounds
+			ft.extensible = ft.extensible || c.Extensible
+			if ft.expr == "*big.Int" && !strings.Contains(bounds, "MIN") && !strings.Contains(bounds, "MAX") {
+				ft.expr = "int64"
+				ft.integer = true
+			}
+		}
+	}
+}
+
+// getBounds returns a single value or a range in the format of the options
+// "range" and "size", or an empty string if it cannot be represented.
+func (g *generator) getBounds(e syntax.ConstraintElement, m *syntax.Module) string {
+	limit := func(v syntax.Value, unbounded string, exclusive bool, delta int64) string {
+		if v == nil {
+			return unbounded
+		}
+		n, err := g.getInt(v, m, 0)
+		if err != nil || !n.IsInt64() {
+			return ""
+		}
+		if exclusive {
+			return strconv.FormatInt(n.Int64()+delta, 10)
+		}
+		return n.String()
+	}
+	switch e := e.(type) {
+	case *syntax.SingleValue:
+		return limit(e.Value, "", false, 0)
+	case *syntax.ValueRange:
+		lower := limit(e.Lower, "MIN", e.LowerExclusive, 1)
+		upper := limit(e.Upper, "MAX", e.UpperExclusive, -1)
+		if lower == "" || upper == "" {
+			return ""
+		}
+		return lower + ".." + upper
+	}
+	return ""
+}
+
+// getComponents returns the components of a SEQUENCE, SET or CHOICE followed
+// by its extension additions, with "COMPONENTS OF" replaced by the root
+// components of the referenced type. It also returns the number of root
+// components and if automatic tags must be used.
+func (g *generator) getComponents(components, additions []*syntax.Component, m *syntax.Module) ([]*syntax.Component, int, bool, error) {
+	all := []*syntax.Component{}
+	automatic := m.TagDefault == syntax.AutomaticTags
+	for i, c := range append(append([]*syntax.Component{}, components...), additions...) {
+		if !c.ComponentsOf {
+			if _, tagged := c.Type.(*syntax.TaggedType); tagged {
+				automatic = false
+			}
+			all = append(all, c)
+			continue
+		}
+		base, err := g.getBaseType(c.Type, m)
+		if err != nil {
+			return nil, 0, false, err
+		}
+		seq, ok := base.(*syntax.SequenceType)
+		if !ok || i >= len(components) {
+			return nil, 0, false, g.errorf(m, c.Pos, "invalid COMPONENTS OF")
+		}
+		all = append(all, seq.Components...)
+	}
+	return all, len(all) - len(additions), automatic, nil
+}
+
+// getFieldOptions returns the options of a component.
+func (g *generator) getFieldOptions(c *syntax.Component, ft *fieldType, index int, automatic, addition bool, m *syntax.Module) ([]string, string, error) {
+	if automatic {
+		if err := ft.addTag(tagOption{number: int64(index), explicit: ft.choice}); err != nil {
+			return nil, "", g.errorf(m, c.Pos, "%s", err)
+		}
+	}
+	opts := ft.options()
+	comment := ""
+	switch {
+	case c.Optional:
+		opts = append(opts, "optional")
+	case c.Default != nil:
+		n, err := g.getDefault(c.Default, ft, m)
+		if err == nil && ft.integer {
+			opts = append(opts, "default:"+n.String())
+		} else {
+			opts = append(opts, "optional")
+			comment = " // DEFAULT " + formatValue(c.Default)
+		}
+	}
+	if addition {
+		opts = append(opts, "addition")
+	}
+	return append(opts, "name:"+c.Name), comment, nil
+}
+
+// defineStruct declares the Go struct of a SEQUENCE or SET.
+func (g *generator) defineStruct(name, path string, t *syntax.SequenceType, m *syntax.Module) error {
+	if err := g.declare(m, t.Pos, name); err != nil {
+		return err
+	}
+	index := g.reserve()
+	components, root, automatic, err := g.getComponents(t.Components, t.Additions, m)
+	if err != nil {
+		return err
+	}
+	fields := []string{}
+	for i, c := range components {
+		fieldName := goName(c.Name)
+		ft, err := g.resolve(c.Type, name+fieldName, path+"."+c.Name, m, true)
+		if err != nil {
+			return err
+		}
+		opts, comment, err := g.getFieldOptions(c, ft, i, automatic, i >= root, m)
+		if err != nil {
+			return err
+		}
+		fields = append(fields, fmt.Sprintf("\t%s %s `asn1:\"%s\"`%s\n",
+			fieldName, ft.expr, strings.Join(opts, ","), comment))
+	}
+	g.decls[index] = fmt.Sprintf("// %s is the ASN.1 type %s.\ntype %s struct {\n%s}\n",
+		name, path, name, strings.Join(fields, ""))
+	return nil
+}
+
+// defineChoice declares the interface type of a CHOICE and registers its
+// alternatives. Alternatives with the same Go type are given new types.
+func (g *generator) defineChoice(name, path string, t *syntax.ChoiceType, m *syntax.Module) error {
+	if err := g.declare(m, t.Pos, name); err != nil {
+		return err
+	}
+	g.decls = append(g.decls, fmt.Sprintf(
+		"// %s is the ASN.1 type %s. Its values have the types registered in %s.\ntype %s interface{}\n",
+		name, path, g.context, name))
+	alternatives, root, automatic, err := g.getComponents(t.Alternatives, t.Additions, m)
+	if err != nil {
+		return err
+	}
+	types := make([]*fieldType, len(alternatives))
+	count := map[string]int{}
+	for i, c := range alternatives {
+		types[i], err = g.resolve(c.Type, name+goName(c.Name), path+"."+c.Name, m, true)
+		if err != nil {
+			return err
+		}
+		if types[i].choice {
+			return g.errorf(m, c.Pos, "CHOICE and ANY alternatives are not supported")
+		}
+		count[types[i].expr]++
+	}
+
+	entries := []string{}
+	for i, c := range alternatives {
+		ft := types[i]
+		if count[ft.expr] > 1 {
+			if specialTypes[ft.expr] {
+				return g.errorf(m, c.Pos, "alternatives with the same type %s are not supported", ft.expr)
+			}
+			altName := name + goName(c.Name)
+			if err = g.declare(m, c.Pos, altName); err != nil {
+				return err
+			}
+			g.decls = append(g.decls, fmt.Sprintf("// %s is the alternative %s of %s.\ntype %s %s\n",
+				altName, c.Name, name, altName, ft.expr))
+			ft.expr = altName
+		}
+		opts, _, err := g.getFieldOptions(c, ft, i, automatic, i >= root, m)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, fmt.Sprintf("\t\t{Type: reflect.TypeOf((*%s)(nil)).Elem(), Options: %q},\n",
+			ft.expr, strings.Join(opts, ",")))
+	}
+	g.imports["reflect"] = true
+	g.inits = append(g.inits, fmt.Sprintf(
+		"\tif err := %s.AddChoice(%q, []asn1.Choice{\n%s\t}); err != nil {\n\t\tpanic(err)\n\t}\n",
+		g.context, name, strings.Join(entries, "")))
+	return nil
+}
+
+// enumItem is a named value of an ENUMERATED type.
+type enumItem struct {
+	name     string
+	value    int64
+	addition bool
+}
+
+// getEnumItems returns the values of an ENUMERATED type. Root items without a
+// number take the smallest values not used and additions without a number
+// take the value after the largest one.
+func (g *generator) getEnumItems(t *syntax.EnumeratedType, m *syntax.Module) ([]enumItem, error) {
+	items := []enumItem{}
+	used := map[int64]bool{}
+	for _, item := range t.Items {
+		if item.Value != nil {
+			n, err := g.getInt(item.Value, m, 0)
+			if err != nil {
+				return nil, err
+			}
+			used[n.Int64()] = true
+		}
+	}
+	next := int64(0)
+	last := int64(-1)
+	for i, item := range append(append([]*syntax.NamedNumber{}, t.Items...), t.Additions...) {
+		addition := i >= len(t.Items)
+		var value int64
+		switch {
+		case item.Value != nil:
+			n, err := g.getInt(item.Value, m, 0)
+			if err != nil {
+				return nil, err
+			}
+			value = n.Int64()
+			if addition && value <= last {
+				return nil, g.errorf(m, item.Pos, "extension addition %s must be greater than %d", item.Name, last)
+			}
+		case addition:
+			value = last + 1
+		default:
+			for used[next] {
+				next++
+			}
+			value = next
+			used[value] = true
+		}
+		if value > last {
+			last = value
+		}
+		items = append(items, enumItem{item.Name, value, addition})
+	}
+	return items, nil
+}
+
+// defineEnumerated declares the Go type and the constants of an ENUMERATED
+// type and registers its values.
+func (g *generator) defineEnumerated(name, path string, t *syntax.EnumeratedType, m *syntax.Module) error {
+	if err := g.declare(m, t.Pos, name); err != nil {
+		return err
+	}
+	items, err := g.getEnumItems(t, m)
+	if err != nil {
+		return err
+	}
+	consts := []string{}
+	entries := []string{}
+	for _, item := range items {
+		constName := name + goName(item.name)
+		if err = g.declare(m, t.Pos, constName); err != nil {
+			return err
+		}
+		consts = append(consts, fmt.Sprintf("\t%s %s = %d\n", constName, name, item.value))
+		addition := ""
+		if item.addition {
+			addition = ", Addition: true"
+		}
+		entries = append(entries, fmt.Sprintf("\t\t{Name: %q, Value: %d%s},\n", item.name, item.value, addition))
+	}
+	g.decls = append(g.decls, fmt.Sprintf("// %s is the ASN.1 type %s.\ntype %s int\n\n// Values of %s\nconst (\n%s)\n",
+		name, path, name, name, strings.Join(consts, "")))
+	g.inits = append(g.inits, fmt.Sprintf(
+		"\tif err := %s.AddEnum(%q, []asn1.Enum{\n%s\t}); err != nil {\n\t\tpanic(err)\n\t}\n",
+		g.context, name, strings.Join(entries, "")))
+	return nil
+}
+
+// defineInteger declares the Go type and the constants of an INTEGER with
+// named numbers.
+func (g *generator) defineInteger(name, path string, t *syntax.IntegerType, m *syntax.Module) error {
+	if err := g.declare(m, t.Pos, name); err != nil {
+		return err
+	}
+	consts, err := g.getNamedNumbers(name, name, t.NamedNumbers, m)
+	if err != nil {
+		return err
+	}
+	g.decls = append(g.decls, fmt.Sprintf("// %s is the ASN.1 type %s.\ntype %s int64\n\n// Values of %s\nconst (\n%s)\n",
+		name, path, name, name, consts))
+	return nil
+}
+
+// defineNamedBits declares the indexes of the named bits of a BIT STRING.
+func (g *generator) defineNamedBits(name, path string, t *syntax.BitStringType, m *syntax.Module) error {
+	if len(t.NamedBits) == 0 {
+		return nil
+	}
+	consts, err := g.getNamedNumbers(name, "", t.NamedBits, m)
+	if err != nil {
+		return err
+	}
+	g.decls = append(g.decls, fmt.Sprintf("// Named bits of %s\nconst (\n%s)\n", path, consts))
+	return nil
+}
+
+// getNamedNumbers returns the declarations of named numbers as constants of a
+// given type, or untyped if typeName is empty.
+func (g *generator) getNamedNumbers(prefix, typeName string, numbers []*syntax.NamedNumber, m *syntax.Module) (string, error) {
+	consts := ""
+	for _, number := range numbers {
+		n, err := g.getInt(number.Value, m, 0)
+		if err != nil {
+			return "", err
+		}
+		constName := prefix + goName(number.Name)
+		if err = g.declare(m, number.Pos, constName); err != nil {
+			return "", err
+		}
+		if typeName == "" {
+			consts += fmt.Sprintf("\t%s = %s\n", constName, n)
+		} else {
+			consts += fmt.Sprintf("\t%s %s = %s\n", constName, typeName, n)
+		}
+	}
+	return consts, nil
+}
+
+/*
+ * Values
+ */
+
+// getInt returns the value of an INTEGER value or of a reference to one.
+func (g *generator) getInt(v syntax.Value, m *syntax.Module, depth int) (*big.Int, error) {
+	switch v := v.(type) {
+	case *syntax.NumberValue:
+		return v.Value, nil
+	case *syntax.ReferenceValue:
+		def, found := g.values[v.Name]
+		if !found {
+			return nil, g.errorf(m, v.Pos, "undefined value %s", v.Name)
+		}
+		if depth > 100 {
+			return nil, g.errorf(m, v.Pos, "circular value definition")
+		}
+		return g.getInt(def.assignment.Value, def.module, depth+1)
+	}
+	return nil, g.errorf(m, v.Position(), "expected an integer value")
+}
+
+// getDefault returns the value of a DEFAULT INTEGER or ENUMERATED value, which
+// may be a named number of its type.
+func (g *generator) getDefault(v syntax.Value, ft *fieldType, m *syntax.Module) (*big.Int, error) {
+	if ref, ok := v.(*syntax.ReferenceValue); ok {
+		switch t := ft.base.(type) {
+		case *syntax.IntegerType:
+			for _, number := range t.NamedNumbers {
+				if number.Name == ref.Name {
+					return g.getInt(number.Value, m, 0)
+				}
+			}
+		case *syntax.EnumeratedType:
+			items, err := g.getEnumItems(t, m)
+			if err != nil {
+				return nil, err
+			}
+			for _, item := range items {
+				if item.name == ref.Name {
+					return big.NewInt(item.value), nil
+				}
+			}
+		}
+	}
+	return g.getInt(v, m, 0)
+}
+
+// getOid returns the arcs of an OBJECT IDENTIFIER value. The first component
+// may be a reference to another value or the name of a root arc.
+func (g *generator) getOid(v syntax.Value, m *syntax.Module, depth int) ([]int64, error) {
+	if depth > 100 {
+		return nil, g.errorf(m, v.Position(), "circular value definition")
+	}
+	var components []*syntax.OidComponent
+	switch v := v.(type) {
+	case *syntax.ObjectIdentifierValue:
+		components = v.Components
+	case *syntax.ReferenceValue:
+		components = []*syntax.OidComponent{{Pos: v.Pos, Name: v.Name}}
+	default:
+		return nil, g.errorf(m, v.Position(), "expected an OBJECT IDENTIFIER value")
+	}
+	arcs := []int64{}
+	for i, c := range components {
+		switch {
+		case c.Number != nil:
+			arcs = append(arcs, c.Number.Int64())
+		case i == 0 && g.values[c.Name].assignment != nil:
+			def := g.values[c.Name]
+			base, err := g.getBaseType(def.assignment.Type, def.module)
+			if err != nil {
+				return nil, err
+			}
+			if b, ok := base.(*syntax.BuiltinType); !ok || b.Name != "OBJECT IDENTIFIER" {
+				return nil, g.errorf(m, c.Pos, "%s is not an OBJECT IDENTIFIER", c.Name)
+			}
+			prefix, err := g.getOid(def.assignment.Value, def.module, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			arcs = append(arcs, prefix...)
+		case i == 0 && c.Name != "":
+			root, found := oidRoots[c.Name]
+			if !found {
+				return nil, g.errorf(m, c.Pos, "undefined value %s", c.Name)
+			}
+			arcs = append(arcs, root)
+		default:
+			def, found := g.values[c.Name]
+			if !found {
+				return nil, g.errorf(m, c.Pos, "undefined value %s", c.Name)
+			}
+			n, err := g.getInt(def.assignment.Value, def.module, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			arcs = append(arcs, n.Int64())
+		}
+	}
+	return arcs, nil
+}
+
+// formatValue returns a short representation of a value for comments.
+func formatValue(v syntax.Value) string {
+	switch v := v.(type) {
+	case *syntax.NumberValue:
+		return v.Value.String()
+	case *syntax.BooleanValue:
+		if v.Value {
+			return "TRUE"
+		}
+		return "FALSE"
+	case *syntax.NullValue:
+		return "NULL"
+	case *syntax.StringValue:
+		if v.Kind == 'C' {
+			return strconv.Quote(v.Text)
+		}
+		return fmt.Sprintf("'%s'%c", v.Text, v.Kind)
+	case *syntax.ReferenceValue:
+		return v.Name
+	}
+	return "{...}"
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/PromonLogicalis/asn1/syntax"
+)
+
+// generate parses a module and returns the generated source.
+func generate(src string) (string, error) {
+	modules, err := syntax.Parse("test.asn", []byte(src))
+	if err != nil {
+		return "", err
+	}
+	files := map[*syntax.Module]string{modules[0]: "test.asn"}
+	out, err := newGenerator("types", modules, files).generate()
+	return string(out), err
+}
+
+func TestGenerate(t *testing.T) {
+	src := `Test DEFINITIONS AUTOMATIC TAGS ::= BEGIN
+id-test OBJECT IDENTIFIER ::= { iso(1) 2 3 }
+Record ::= SEQUENCE {
+    id     INTEGER (0..255),
+    color  ENUMERATED { red, green, ... } DEFAULT green,
+    value  CHOICE { text UTF8String, number INTEGER },
+    items  SET OF Item OPTIONAL,
+    ...,
+    extra  BOOLEAN OPTIONAL
+}
+Item ::= SEQUENCE { name OCTET STRING }
+Tagged ::= [APPLICATION 5] IMPLICIT Item
+END
+`
+	expected := `// Code generated by asn1gen from test.asn. DO NOT EDIT.
+
+package types
+
+import (
+	"reflect"
+
+	"github.com/PromonLogicalis/asn1"
+)
+
+// Context has the CHOICE and ENUMERATED types registered.
+var Context = asn1.NewContext()
+
+// IdTest is the ASN.1 value id-test.
+var IdTest = asn1.Oid{1, 2, 3}
+
+// Record is the ASN.1 type Record.
+type Record struct {
+	Id    int64       ` + "`" + `asn1:"tag:0,range:0..255,name:id"` + "`" + `
+	Color RecordColor ` + "`" + `asn1:"tag:1,enum:RecordColor,extensible,default:1,name:color"` + "`" + `
+	Value RecordValue ` + "`" + `asn1:"tag:2,explicit,choice:RecordValue,name:value"` + "`" + `
+	Items []Item      ` + "`" + `asn1:"tag:3,set,optional,name:items"` + "`" + `
+	Extra bool        ` + "`" + `asn1:"tag:4,optional,addition,name:extra"` + "`" + `
+}
+
+// RecordColor is the ASN.1 type Record.color.
+type RecordColor int
+
+// Values of RecordColor
+const (
+	RecordColorRed   RecordColor = 0
+	RecordColorGreen RecordColor = 1
+)
+
+// RecordValue is the ASN.1 type Record.value. Its values have the types registered in Context.
+type RecordValue interface{}
+
+// RecordOptions are the options to encode and decode values of Record.
+const RecordOptions = "extensible"
+
+// Item is the ASN.1 type Item.
+type Item struct {
+	Name []byte ` + "`" + `asn1:"tag:0,name:name"` + "`" + `
+}
+
+// Tagged is the ASN.1 type Tagged.
+type Tagged Item
+
+// TaggedOptions are the options to encode and decode values of Tagged.
+const TaggedOptions = "tag:5,application"
+
+func init() {
+	if err := Context.AddEnum("RecordColor", []asn1.Enum{
+		{Name: "red", Value: 0},
+		{Name: "green", Value: 1},
+	}); err != nil {
+		panic(err)
+	}
+	if err := Context.AddChoice("RecordValue", []asn1.Choice{
+		{Type: reflect.TypeOf((*string)(nil)).Elem(), Options: "tag:0,utf8,name:text"},
+		{Type: reflect.TypeOf((*int64)(nil)).Elem(), Options: "tag:1,name:number"},
+	}); err != nil {
+		panic(err)
+	}
+}
+`
+	out, err := generate(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != expected {
+		t.Fatalf("Unexpected output:\n%s", out)
+	}
+}
+
+func TestGenerateErrors(t *testing.T) {
+	testCases := []struct {
+		assignments string
+		expected    string
+	}{
+		{"A ::= B", "test.asn:1:31: undefined type B"},
+		{"A ::= REAL", "test.asn:1:31: type REAL is not supported"},
+		{"A ::= [PRIVATE 1] INTEGER", "test.asn:1:31: PRIVATE tags are not supported"},
+		{"A ::= [0] EXPLICIT [1] INTEGER", "test.asn:1:31: explicit tags over tagged types are not supported"},
+		{"A ::= CHOICE { a CHOICE { b INTEGER } }", "test.asn:1:40: CHOICE and ANY alternatives are not supported"},
+		{"A ::= CHOICE { a OBJECT IDENTIFIER, b OBJECT IDENTIFIER }", "test.asn:1:40: alternatives with the same type asn1.Oid"},
+		{"A-b ::= INTEGER AB ::= INTEGER", "test.asn:1:41: Go name AB is already used"},
+		{"a INTEGER ::= b", "test.asn:1:39: undefined value b"},
+	}
+	for _, test := range testCases {
+		src := "M DEFINITIONS ::= BEGIN " + test.assignments + " END"
+		_, err := generate(src)
+		if err == nil || !strings.HasPrefix(err.Error(), test.expected) {
+			t.Errorf("%s:\nExpected: %s\nGot:      %v", test.assignments, test.expected, err)
+		}
+	}
+}
+
+func TestGenerateRoundTrip(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping the compilation of generated code in short mode")
+	}
+	goTool, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go tool not found")
+	}
+	src := `PKIX DEFINITIONS IMPLICIT TAGS ::= BEGIN
+Name ::= CHOICE { rdnSequence RDNSequence }
+RDNSequence ::= SEQUENCE OF RelativeDistinguishedName
+RelativeDistinguishedName ::= SET SIZE (1..MAX) OF AttributeTypeAndValue
+AttributeTypeAndValue ::= SEQUENCE { type OBJECT IDENTIFIER, value DirectoryString }
+DirectoryString ::= CHOICE { printableString PrintableString, utf8String UTF8String }
+Party ::= CHOICE { imsi [0] OCTET STRING, msisdn [1] OCTET STRING }
+Record ::= SEQUENCE {
+    name     Name,
+    parties  SEQUENCE OF Party,
+    colors   SEQUENCE OF ENUMERATED { red, green },
+    labels   SEQUENCE OF [APPLICATION 2] IA5String
+}
+END
+`
+	program := `package main
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/PromonLogicalis/asn1"
+)
+
+func main() {
+	record := Record{
+		Name: RDNSequence{{{Type: asn1.Oid{2, 5, 4, 3}, Value: DirectoryStringUtf8String("test")}}},
+		Parties: []Party{PartyImsi{1, 2, 3}, PartyMsisdn{4}},
+		Colors:  []RecordColorsElem{RecordColorsElemGreen},
+		Labels:  []string{"a"},
+	}
+	data, err := Context.Encode(record)
+	if err != nil {
+		panic(err)
+	}
+	decoded := Record{}
+	if _, err = Context.Decode(data, &decoded); err != nil {
+		panic(err)
+	}
+	if !reflect.DeepEqual(record, decoded) {
+		panic(fmt.Sprintf("decoded %#v", decoded))
+	}
+	fmt.Printf("%X", data)
+}
+`
+	modules, err := syntax.Parse("pkix.asn", []byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[*syntax.Module]string{modules[0]: "pkix.asn"}
+	out, err := newGenerator("main", modules, files).generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(out), `const RDNSequenceOptions = "elem:set,elem:size:1..MAX"`) {
+		t.Fatalf("Missing element options:\n%s", out)
+	}
+
+	// Directories starting with "_" are ignored by the go tool patterns
+	dir, err := ioutil.TempDir(".", "_roundtrip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err = ioutil.WriteFile(filepath.Join(dir, "types.go"), out, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(filepath.Join(dir, "main.go"), []byte(program), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command(goTool, "run", ".")
+	cmd.Dir = dir
+	result, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("%v:\n%s\n%s", err, result, out)
+	}
+	expected := "" +
+		// SEQUENCE { SEQUENCE { SET { SEQUENCE { OID 2.5.4.3, UTF8String "test" } } }
+		"3025" + "300F" + "310D" + "300B" + "0603550403" + "0C0474657374" +
+		// SEQUENCE { [0] 010203, [1] 04 }
+		"3008" + "8003010203" + "810104" +
+		// SEQUENCE { ENUMERATED 1 }
+		"30030A0101" +
+		// SEQUENCE { [APPLICATION 2] "a" }
+		"30034201" + "61"
+	if string(result) != expected {
+		t.Fatalf("Unexpected encoding.\n Expected: %s\n Got:      %s", expected, result)
+	}
+}
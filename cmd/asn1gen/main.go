@@ -0,0 +1,97 @@
+// Command asn1gen generates Go types from ASN.1 modules, to be used with the
+// package github.com/PromonLogicalis/asn1.
+//
+// Usage:
+//
+//	asn1gen [-p package] [-o file] [-context name] [-bigint] file.asn...
+//
+// The types of all the modules in the given files are written to a single Go
+// file:
+//
+//   - SEQUENCE and SET types are converted to structs, whose fields have the
+//     tags and options of their components;
+//   - CHOICE types are converted to interfaces and their alternatives are
+//     registered with Context.AddChoice by an init function;
+//   - SEQUENCE OF and SET OF types are converted to slices, whose elements
+//     have their tags and options given by the option "elem";
+//   - ENUMERATED types are converted to integer types with constants for their
+//     values, which are registered with Context.AddEnum;
+//   - INTEGER types with named numbers and named bits of BIT STRING types are
+//     converted to constants;
+//   - Values of OBJECT IDENTIFIER types are converted to asn1.Oid variables and
+//     values of INTEGER, BOOLEAN and character string types to constants.
+//
+// The options of a type assignment, like its tag or its string type, are
+// given by a constant named after the type, like RecordOptions, which must be
+// used by EncodeWithOptions and DecodeWithOptions.
+//
+// ANY types are converted to interface{} fields with a CHOICE named after the
+// field, like "AlgorithmIdentifier.parameters", whose types must be registered
+// by the user. Types imported from modules not given, PRIVATE tags and
+// explicit tags over other tags cannot be represented and are reported as
+// errors. DEFAULT values other than integers are converted to OPTIONAL fields.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/PromonLogicalis/asn1/syntax"
+)
+
+func main() {
+	pkg := flag.String("p", "asn1types", "name of the generated package")
+	output := flag.String("o", "", "output file (default standard output)")
+	context := flag.String("context", "Context", "name of the Context variable")
+	bigInt := flag.Bool("bigint", false, "use *big.Int for INTEGER types without bounds")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: asn1gen [flags] file.asn...\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	src, err := run(flag.Args(), *pkg, *context, *bigInt)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if *output == "" {
+		_, err = os.Stdout.Write(src)
+	} else {
+		err = ioutil.WriteFile(*output, src, 0644)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// run parses the given files and returns the generated source.
+func run(filenames []string, pkg, context string, bigInt bool) ([]byte, error) {
+	modules := []*syntax.Module{}
+	files := map[*syntax.Module]string{}
+	for _, filename := range filenames {
+		src, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return nil, err
+		}
+		parsed, err := syntax.Parse(filename, src)
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range parsed {
+			files[m] = filename
+		}
+		modules = append(modules, parsed...)
+	}
+	g := newGenerator(pkg, modules, files)
+	g.context = context
+	g.bigInt = bigInt
+	return g.generate()
+}
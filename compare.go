@@ -0,0 +1,83 @@
+package asn1
+
+import (
+	"bytes"
+)
+
+// comparisonRules are the rules used to compare values. They are the same as
+// DER, but the indefinite length form is never used.
+type comparisonRules struct {
+	DistinguishedEncodingRules
+}
+
+func (comparisonRules) IndefiniteLength(requested bool) bool { return false }
+
+// Compare compares two values by their DER encodings, using the choices and
+// enums registered in ctx. The result is 0 if both values have the same
+// encoding, -1 if the encoding of a comes first in the order used by DER for
+// the components of a SET OF and +1 otherwise.
+//
+// Differences that do not affect the encoding are ignored, like nil and empty
+// slices, the order of the components of a SET and missing DEFAULT values. The
+// values may have different Go types, as long as their encodings are the same.
+// A new Context is used if ctx is nil.
+func Compare(ctx *Context, a, b interface{}) (int, error) {
+	if ctx == nil {
+		ctx = NewContext()
+	}
+	c := *ctx
+	c.SetRules(comparisonRules{}, nil)
+	derA, err := c.Encode(a)
+	if err != nil {
+		return 0, err
+	}
+	derB, err := c.Encode(b)
+	if err != nil {
+		return 0, err
+	}
+	return bytes.Compare(derA, derB), nil
+}
+
+// Equal checks if two values have the same DER encoding.
+//
+// See Compare() for further details.
+func Equal(ctx *Context, a, b interface{}) (bool, error) {
+	result, err := Compare(ctx, a, b)
+	return result == 0, err
+}
+
+// CompareBer compares two encoded elements without any knowledge of their
+// types. Both elements are converted to DER by Canonicalize(), ignoring any
+// ambiguity, and the result is the same as in Compare().
+func CompareBer(a, b []byte) (int, error) {
+	canonicalA, err := getCanonicalBer(a)
+	if err != nil {
+		return 0, err
+	}
+	canonicalB, err := getCanonicalBer(b)
+	if err != nil {
+		return 0, err
+	}
+	return bytes.Compare(canonicalA, canonicalB), nil
+}
+
+// EqualBer checks if two encoded elements are the same.
+//
+// See CompareBer() for further details.
+func EqualBer(a, b []byte) (bool, error) {
+	result, err := CompareBer(a, b)
+	return result == 0, err
+}
+
+// getCanonicalBer returns the DER form of a single element.
+func getCanonicalBer(data []byte) ([]byte, error) {
+	_, rest, err := Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) > 0 {
+		return nil, parseError("unexpected data after the element")
+	}
+	der, _, err := Canonicalize(data)
+	return der, err
+}
@@ -0,0 +1,76 @@
+package asn1
+
+import (
+	"testing"
+)
+
+func TestCompare(t *testing.T) {
+	type Record struct {
+		Id      int
+		Items   []int    `asn1:"set"`
+		Names   []string `asn1:"indefinite"`
+		Version int      `asn1:"default:1"`
+	}
+	testCases := []struct {
+		a, b     interface{}
+		expected int
+	}{
+		{1, int64(1), 0},
+		{1, 2, -1},
+		{[]byte{1}, "\x01", 0},
+		{Record{Id: 1, Items: []int{1, 2}}, Record{Id: 1, Items: []int{2, 1}, Names: []string{}}, 0},
+		{Record{Id: 1, Version: 1}, Record{Id: 1}, 0},
+		{Record{Id: 2}, Record{Id: 1}, 1},
+		// Shorter encodings come first
+		{Record{Id: 2}, Record{Id: 1, Items: []int{1}}, -1},
+	}
+	for _, test := range testCases {
+		result, err := Compare(nil, test.a, test.b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result != test.expected {
+			t.Fatalf("Comparing %#v and %#v returned %d, expected %d", test.a, test.b, result, test.expected)
+		}
+		equal, err := Equal(nil, test.a, test.b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkEqual(t, equal, test.expected == 0)
+	}
+	if _, err := Equal(nil, 1, struct{ C chan int }{}); err == nil {
+		t.Fatal("Comparing invalid types should have failed.")
+	}
+}
+
+func TestCompareBer(t *testing.T) {
+	der := []byte{0x30, 0x06, 0x02, 0x01, 0x01, 0x04, 0x01, 0x61}
+	testCases := []struct {
+		a, b     []byte
+		expected int
+	}{
+		{der, der, 0},
+		// Indefinite length
+		{der, []byte{0x30, 0x80, 0x02, 0x01, 0x01, 0x04, 0x01, 0x61, 0x00, 0x00}, 0},
+		// Long form length
+		{der, []byte{0x30, 0x81, 0x08, 0x02, 0x01, 0x01, 0x04, 0x82, 0x00, 0x01, 0x61}, 0},
+		// Constructed string
+		{der, []byte{0x30, 0x08, 0x02, 0x01, 0x01, 0x24, 0x03, 0x04, 0x01, 0x61}, 0},
+		{der, []byte{0x30, 0x06, 0x02, 0x01, 0x02, 0x04, 0x01, 0x61}, -1},
+	}
+	for _, test := range testCases {
+		result, err := CompareBer(test.a, test.b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkEqual(t, result, test.expected)
+		equal, err := EqualBer(test.a, test.b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkEqual(t, equal, test.expected == 0)
+	}
+	if _, err := EqualBer(der, append(der, 0x00)); err == nil {
+		t.Fatal("Comparing data with extra bytes should have failed.")
+	}
+}
@@ -0,0 +1,445 @@
+package asn1
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// ChangeKind is the kind of a Change.
+type ChangeKind int
+
+// Kinds of changes
+const (
+	// A component of a SEQUENCE or SET is only found in the new schema
+	ComponentAdded ChangeKind = iota
+	// A component of a SEQUENCE or SET is only found in the old schema
+	ComponentRemoved
+	// The components of a SEQUENCE are in a different order
+	ComponentMoved
+	// A component became OPTIONAL or mandatory, or its DEFAULT value changed
+	PresenceChanged
+	// An alternative of a CHOICE is only found in the new schema
+	AlternativeAdded
+	// An alternative of a CHOICE is only found in the old schema
+	AlternativeRemoved
+	// A value of an ENUMERATED is only found in the new schema
+	EnumAdded
+	// A value of an ENUMERATED is only found in the old schema
+	EnumRemoved
+	// A value of an ENUMERATED has a different number
+	EnumChanged
+	// The tags of a type changed
+	TagChanged
+	// The kind of a type changed, like from INTEGER to ENUMERATED or from
+	// UTF8String to IA5String
+	TypeChanged
+	// A range or size constraint changed
+	ConstraintChanged
+	// An extension marker was added or removed, or a component, alternative
+	// or enumerated value was moved into or out of the extension
+	ExtensibilityChanged
+)
+
+// Change is a difference between two versions of a schema.
+//
+// The flags Backward and Forward tell if the change keeps data compatible
+// between versions when encoded with BER or DER. Components, alternatives and
+// enumerated values that are only found in one of the versions are compatible
+// only when they are extension additions of an extensible type, since
+// decoders ignore unknown extensions. PER and OER also encode the structure of
+// the root of types and the bounds of constraints, so changes outside the
+// extension, changes of extensibility and changes of constraints break them in
+// both directions.
+type Change struct {
+	Kind ChangeKind
+	// Path of the type, with the names of the components and alternatives,
+	// like "Record.items[].name". Elements of SEQUENCE OF and SET OF are
+	// written as "[]".
+	Path    string
+	Message string
+	// Backward is set if data encoded with the old schema can be decoded with
+	// the new one
+	Backward bool
+	// Forward is set if data encoded with the new schema can be decoded with
+	// the old one
+	Forward bool
+}
+
+// Changes is a list of changes between two versions of a schema.
+type Changes []Change
+
+// Compatible returns true for each direction in which all changes are
+// compatible.
+func (changes Changes) Compatible() (backward, forward bool) {
+	backward, forward = true, true
+	for _, c := range changes {
+		backward = backward && c.Backward
+		forward = forward && c.Forward
+	}
+	return
+}
+
+// String returns a report with one change per line. Each line starts with
+// "=" for compatible changes, "<" if only the new schema decodes old data,
+// ">" if only the old schema decodes new data and "!" for incompatible
+// changes:
+//
+//	= Record.extra: optional component added in the extension
+//	< Record.id: range widened from 0..100 to 0..1000
+//	> Record.body.oid: alternative removed outside the extension
+//	! Record.name: tag changed from [1] to [2]
+func (changes Changes) String() string {
+	lines := []string{}
+	for _, c := range changes {
+		mark := "!"
+		switch {
+		case c.Backward && c.Forward:
+			mark = "="
+		case c.Backward:
+			mark = "<"
+		case c.Forward:
+			mark = ">"
+		}
+		lines = append(lines, fmt.Sprintf("%s %s: %s", mark, c.Path, c.Message))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// CheckCompatibility compares two versions of a schema and returns their
+// differences, classified by kind and compatibility. Components, alternatives
+// and enumerated values are matched by name.
+//
+// Schemas of Go types are returned by (*Context).Describe() and the ones of
+// ASN.1 modules are registered by (*Context).LoadModule():
+//
+//	prev, _ := ctx.Describe(reflect.TypeOf(RecordV1{}))
+//	next, _ := ctx.Describe(reflect.TypeOf(RecordV2{}))
+//	changes, err := asn1.CheckCompatibility(prev, next)
+//	if backward, forward := changes.Compatible(); !forward {
+//		fmt.Println("Old peers cannot decode the new version:")
+//		fmt.Println(changes)
+//	}
+//
+// Named types referenced by both versions are compared once, at the first
+// path where they are found.
+//
+func CheckCompatibility(prev, next *Schema) (Changes, error) {
+	for _, s := range []*Schema{prev, next} {
+		if err := checkSchema(s, map[*Schema]bool{}); err != nil {
+			return nil, err
+		}
+	}
+	c := &compatChecker{visited: map[[2]*Schema]bool{}}
+	c.compareSchemas(prev, next, nil, nil, prev.TypeName())
+	return c.changes, nil
+}
+
+// compatChecker compares two schemas.
+type compatChecker struct {
+	changes Changes
+	visited map[[2]*Schema]bool
+}
+
+func (c *compatChecker) add(kind ChangeKind, path string, backward, forward bool, format string, args ...interface{}) {
+	c.changes = append(c.changes, Change{
+		Kind:     kind,
+		Path:     path,
+		Message:  fmt.Sprintf(format, args...),
+		Backward: backward,
+		Forward:  forward,
+	})
+}
+
+// compareSchemas compares two types. The options of the components that use
+// them, if any, are applied over the options of the schemas. Both schemas are
+// expected to be valid.
+func (c *compatChecker) compareSchemas(a, b *Schema, aOpts, bOpts *fieldOptions, path string) {
+	aTags, aUniversal, _ := getSchemaTags(a, aOpts)
+	bTags, bUniversal, _ := getSchemaTags(b, bOpts)
+	if a.Kind != b.Kind || aUniversal != bUniversal {
+		c.add(TypeChanged, path, false, false, "type changed from %s to %s",
+			describeKind(a, aUniversal), describeKind(b, bUniversal))
+		return
+	}
+	if !reflect.DeepEqual(aTags, bTags) {
+		c.add(TagChanged, path, false, false, "tag changed from %s to %s",
+			describeTags(aTags), describeTags(bTags))
+	}
+	key := [2]*Schema{a, b}
+	if c.visited[key] {
+		return
+	}
+	c.visited[key] = true
+
+	aSchemaOpts, _ := a.options()
+	bSchemaOpts, _ := b.options()
+	c.compareBounds(path, "range", aSchemaOpts.valueRange, bSchemaOpts.valueRange,
+		aSchemaOpts.extensible, bSchemaOpts.extensible)
+	c.compareBounds(path, "size", aSchemaOpts.size, bSchemaOpts.size,
+		aSchemaOpts.extensible, bSchemaOpts.extensible)
+
+	switch a.Kind {
+	case SequenceKind, SetKind, ChoiceKind, EnumeratedKind:
+		if aSchemaOpts.extensible != bSchemaOpts.extensible {
+			message := "extension marker added"
+			if aSchemaOpts.extensible {
+				message = "extension marker removed"
+			}
+			c.add(ExtensibilityChanged, path, true, true, "%s", message)
+		}
+	}
+
+	switch a.Kind {
+	case SequenceKind, SetKind, ChoiceKind:
+		c.compareComponents(a, b, aSchemaOpts.extensible, bSchemaOpts.extensible, path)
+	case SequenceOfKind, SetOfKind:
+		c.compareSchemas(a.Element, b.Element, nil, nil, path+"[]")
+	case EnumeratedKind:
+		c.compareEnums(a, b, aSchemaOpts.extensible, bSchemaOpts.extensible, path)
+	}
+}
+
+// compareBounds compares two range or size constraints. Values outside the
+// root of an extensible constraint are accepted.
+func (c *compatChecker) compareBounds(path, name string, a, b *bounds, aExt, bExt bool) {
+	if reflect.DeepEqual(a, b) && (a == nil || aExt == bExt) {
+		return
+	}
+	backward := containsBounds(b, a) || bExt
+	forward := containsBounds(a, b) || aExt
+	change := "changed"
+	if backward && !forward {
+		change = "widened"
+	} else if forward && !backward {
+		change = "narrowed"
+	}
+	c.add(ConstraintChanged, path, backward, forward, "%s %s from %s to %s", name, change,
+		describeBounds(a, aExt), describeBounds(b, bExt))
+}
+
+// compareComponents compares the components of SEQUENCE and SET types, or the
+// alternatives of CHOICE types.
+func (c *compatChecker) compareComponents(a, b *Schema, aExt, bExt bool, path string) {
+	choice := a.Kind == ChoiceKind
+	aOpts := getComponentOptions(a)
+	bOpts := getComponentOptions(b)
+	// Components found in the old version, in their order, then the added ones
+	for i, ac := range a.Components {
+		o := aOpts[i]
+		if j := b.component(ac.Name); j >= 0 {
+			c.compareComponent(ac, b.Components[j], o, bOpts[j], path+"."+ac.Name)
+			continue
+		}
+		if choice {
+			c.add(AlternativeRemoved, path+"."+ac.Name, bExt && o.addition, true,
+				"alternative removed%s", describeExtension(o.addition))
+			continue
+		}
+		c.add(ComponentRemoved, path+"."+ac.Name, bExt && o.addition, o.optional || o.defaultValue != nil,
+			"%s component removed%s", describePresence(o), describeExtension(o.addition))
+	}
+	for i, bc := range b.Components {
+		if a.component(bc.Name) >= 0 {
+			continue
+		}
+		o := bOpts[i]
+		if choice {
+			c.add(AlternativeAdded, path+"."+bc.Name, true, aExt && o.addition,
+				"alternative added%s", describeExtension(o.addition))
+			continue
+		}
+		c.add(ComponentAdded, path+"."+bc.Name, o.optional || o.defaultValue != nil, aExt && o.addition,
+			"%s component added%s", describePresence(o), describeExtension(o.addition))
+	}
+	if a.Kind == SequenceKind {
+		c.compareOrder(a, b, path)
+	}
+}
+
+// compareComponent compares a component or an alternative found in both
+// versions.
+func (c *compatChecker) compareComponent(ac, bc Component, aOpts, bOpts *fieldOptions, path string) {
+	if aOpts.addition != bOpts.addition {
+		message := "moved into the extension"
+		if aOpts.addition {
+			message = "moved out of the extension"
+		}
+		c.add(ExtensibilityChanged, path, true, true, "%s", message)
+	}
+	aAbsent := aOpts.optional || aOpts.defaultValue != nil
+	bAbsent := bOpts.optional || bOpts.defaultValue != nil
+	if aAbsent != bAbsent {
+		c.add(PresenceChanged, path, bAbsent, aAbsent, "%s component became %s",
+			describePresence(aOpts), describePresence(bOpts))
+	} else if aAbsent && !reflect.DeepEqual(aOpts.defaultValue, bOpts.defaultValue) {
+		// Absent values have a different meaning
+		c.add(PresenceChanged, path, false, false, "DEFAULT changed from %s to %s",
+			describeDefault(ac.Schema, aOpts), describeDefault(bc.Schema, bOpts))
+	}
+	c.compareSchemas(ac.Schema, bc.Schema, aOpts, bOpts, path)
+}
+
+// compareOrder reports the components of a SEQUENCE found in both versions
+// that are in a different order, which are the ones left out of the longest
+// common subsequence of their names.
+func (c *compatChecker) compareOrder(a, b *Schema, path string) {
+	common := func(s, other *Schema) []string {
+		names := []string{}
+		for _, comp := range s.Components {
+			if other.component(comp.Name) >= 0 {
+				names = append(names, comp.Name)
+			}
+		}
+		return names
+	}
+	aNames, bNames := common(a, b), common(b, a)
+	n := len(aNames)
+	// lengths[i][j] is the length of the longest common subsequence of
+	// aNames[i:] and bNames[j:]
+	lengths := make([][]int, n+1)
+	for i := range lengths {
+		lengths[i] = make([]int, n+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := n - 1; j >= 0; j-- {
+			if aNames[i] == bNames[j] {
+				lengths[i][j] = lengths[i+1][j+1] + 1
+			} else if lengths[i+1][j] > lengths[i][j+1] {
+				lengths[i][j] = lengths[i+1][j]
+			} else {
+				lengths[i][j] = lengths[i][j+1]
+			}
+		}
+	}
+	for i, j := 0, 0; j < n; {
+		switch {
+		case i < n && aNames[i] == bNames[j]:
+			i++
+			j++
+		case i < n && lengths[i+1][j] >= lengths[i][j+1]:
+			i++
+		default:
+			c.add(ComponentMoved, path+"."+bNames[j], false, false, "component moved")
+			j++
+		}
+	}
+}
+
+// compareEnums compares the values of ENUMERATED types.
+func (c *compatChecker) compareEnums(a, b *Schema, aExt, bExt bool, path string) {
+	find := func(s *Schema, name string) *Enum {
+		for i := range s.Enum {
+			if s.Enum[i].Name == name {
+				return &s.Enum[i]
+			}
+		}
+		return nil
+	}
+	for _, e := range a.Enum {
+		if find(b, e.Name) == nil {
+			c.add(EnumRemoved, path, bExt && e.Addition, true,
+				"value %s removed%s", e.Name, describeExtension(e.Addition))
+		}
+	}
+	for _, e := range b.Enum {
+		old := find(a, e.Name)
+		switch {
+		case old == nil:
+			c.add(EnumAdded, path, true, aExt && e.Addition,
+				"value %s added%s", e.Name, describeExtension(e.Addition))
+		case old.Value != e.Value:
+			c.add(EnumChanged, path, false, false,
+				"value %s changed from %d to %d", e.Name, old.Value, e.Value)
+		case old.Addition != e.Addition:
+			message := "moved into the extension"
+			if old.Addition {
+				message = "moved out of the extension"
+			}
+			c.add(ExtensibilityChanged, path, true, true, "value %s %s", e.Name, message)
+		}
+	}
+}
+
+// getComponentOptions returns the parsed options of the components of a
+// valid schema.
+func getComponentOptions(s *Schema) []*fieldOptions {
+	opts := make([]*fieldOptions, len(s.Components))
+	for i, c := range s.Components {
+		opts[i], _ = c.options()
+	}
+	return opts
+}
+
+// containsBounds checks if the limits of outer contain the ones of inner. A
+// nil constraint has no limits.
+func containsBounds(outer, inner *bounds) bool {
+	if outer == nil {
+		return true
+	}
+	if inner == nil {
+		return outer.lower == nil && outer.upper == nil
+	}
+	if outer.lower != nil && (inner.lower == nil || *inner.lower < *outer.lower) {
+		return false
+	}
+	if outer.upper != nil && (inner.upper == nil || *inner.upper > *outer.upper) {
+		return false
+	}
+	return true
+}
+
+func describeKind(s *Schema, universal uint) string {
+	if s.Kind == StringKind {
+		return universalNames[universal]
+	}
+	return s.Kind.String()
+}
+
+func describeTags(tags []schemaTag) string {
+	if len(tags) == 0 {
+		return "none"
+	}
+	s := []string{}
+	for _, t := range tags {
+		tag := int(t.tag)
+		s = append(s, strings.TrimSpace(formatTag(&fieldOptions{
+			tag:         &tag,
+			universal:   t.class == ClassUniversal,
+			application: t.class == ClassApplication,
+		})))
+	}
+	return strings.Join(s, " ")
+}
+
+func describeBounds(b *bounds, extensible bool) string {
+	if b == nil {
+		return "none"
+	}
+	return formatBounds(b, extensible)
+}
+
+func describePresence(opts *fieldOptions) string {
+	if opts.optional || opts.defaultValue != nil {
+		return "optional"
+	}
+	return "mandatory"
+}
+
+func describeDefault(s *Schema, opts *fieldOptions) string {
+	if opts.defaultValue == nil {
+		return "none"
+	}
+	def, err := s.getDefault(opts)
+	if err != nil {
+		return fmt.Sprint(*opts.defaultValue)
+	}
+	return fmt.Sprint(def)
+}
+
+func describeExtension(addition bool) string {
+	if addition {
+		return " in the extension"
+	}
+	return " outside the extension"
+}
@@ -0,0 +1,127 @@
+package asn1
+
+import (
+	"reflect"
+	"testing"
+)
+
+type compatRecordV1 struct {
+	Id    int         `asn1:"range:0..100"`
+	Name  string      `asn1:"tag:0,utf8"`
+	Kind  int         `asn1:"tag:1,enum:Kind"`
+	Body  interface{} `asn1:"choice:Body"`
+	Items []int       `asn1:"tag:2,optional,size:1..10"`
+	Old   bool        `asn1:"tag:3,optional"`
+	Flag  bool        `asn1:"tag:4"`
+}
+
+type compatRecordV2 struct {
+	Id    int         `asn1:"range:0..1000"`
+	Name  string      `asn1:"tag:5,utf8,optional"`
+	Kind  int         `asn1:"tag:1,enum:Kind2"`
+	Body  interface{} `asn1:"choice:Body2"`
+	Items []int       `asn1:"tag:2,optional,size:1..5"`
+	Flag  int         `asn1:"tag:4"`
+	New   bool        `asn1:"tag:6,optional"`
+	Extra bool        `asn1:"tag:7,optional,addition"`
+}
+
+func TestCheckCompatibility(t *testing.T) {
+	ctx := NewContext()
+	ctx.AddEnum("Kind", []Enum{{Name: "a", Value: 0}, {Name: "b", Value: 1}})
+	ctx.AddEnum("Kind2", []Enum{{Name: "a", Value: 0}, {Name: "b", Value: 2}, {Name: "c", Value: 3, Addition: true}})
+	ctx.AddChoice("Body", []Choice{
+		{Type: reflect.TypeOf(0), Options: "tag:0"},
+		{Type: reflect.TypeOf(""), Options: "tag:1"},
+	})
+	ctx.AddChoice("Body2", []Choice{
+		{Type: reflect.TypeOf(0), Options: "tag:0"},
+		{Type: reflect.TypeOf(true), Options: "tag:2"},
+	})
+	prev, err := ctx.Describe(reflect.TypeOf(compatRecordV1{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	next, err := ctx.Describe(reflect.TypeOf(compatRecordV2{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	changes, err := CheckCompatibility(prev, next)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `< CompatRecordV1.id: range widened from 0..100 to 0..1000
+< CompatRecordV1.name: mandatory component became optional
+! CompatRecordV1.name: tag changed from [0] to [5]
+= CompatRecordV1.kind: extension marker added
+! CompatRecordV1.kind: value b changed from 1 to 2
+< CompatRecordV1.kind: value c added in the extension
+> CompatRecordV1.body.octet-string: alternative removed outside the extension
+< CompatRecordV1.body.boolean: alternative added outside the extension
+> CompatRecordV1.items: size narrowed from 1..10 to 1..5
+> CompatRecordV1.old: optional component removed outside the extension
+! CompatRecordV1.flag: type changed from BOOLEAN to INTEGER
+< CompatRecordV1.new: optional component added outside the extension
+< CompatRecordV1.extra: optional component added in the extension`
+	if changes.String() != expected {
+		t.Fatalf("Invalid changes.\nExpected:\n%s\nGot:\n%s", expected, changes)
+	}
+	if backward, forward := changes.Compatible(); backward || forward {
+		t.Errorf("Expected incompatible changes")
+	}
+	if changes, _ = CheckCompatibility(prev, prev); len(changes) != 0 {
+		t.Errorf("Unexpected changes:\n%s", changes)
+	}
+}
+
+const compatModules = `
+V1 DEFINITIONS AUTOMATIC TAGS ::= BEGIN
+Message ::= SEQUENCE {
+    id INTEGER,
+    body CHOICE { text UTF8String, ... },
+    ...
+}
+END
+
+V2 DEFINITIONS AUTOMATIC TAGS ::= BEGIN
+Message2 ::= SEQUENCE {
+    id INTEGER,
+    body CHOICE { text UTF8String, ..., data OCTET STRING },
+    ...,
+    extra BOOLEAN OPTIONAL
+}
+Message3 ::= SEQUENCE {
+    body CHOICE { text UTF8String },
+    id INTEGER
+}
+END
+`
+
+func TestCheckCompatibilityModules(t *testing.T) {
+	ctx := NewContext()
+	if err := ctx.LoadModule("test.asn", []byte(compatModules)); err != nil {
+		t.Fatal(err)
+	}
+	changes, err := CheckCompatibility(ctx.Schema("Message"), ctx.Schema("Message2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `= Message.body.data: alternative added in the extension
+= Message.extra: optional component added in the extension`
+	if changes.String() != expected {
+		t.Fatalf("Invalid changes.\nExpected:\n%s\nGot:\n%s", expected, changes)
+	}
+	if backward, forward := changes.Compatible(); !backward || !forward {
+		t.Errorf("Expected compatible changes")
+	}
+
+	changes, _ = CheckCompatibility(ctx.Schema("Message"), ctx.Schema("Message3"))
+	expected = `= Message: extension marker removed
+! Message.id: tag changed from [0] to [1]
+! Message.body: tag changed from [1] to [0]
+= Message.body: extension marker removed
+! Message.id: component moved`
+	if changes.String() != expected {
+		t.Fatalf("Invalid changes.\nExpected:\n%s\nGot:\n%s", expected, changes)
+	}
+}
@@ -4,7 +4,9 @@ import (
 	"fmt"
 	"io/ioutil"
 	"log"
+	"math/big"
 	"reflect"
+	"sort"
 )
 
 // Context keeps options that affect the ASN.1 encoding and decoding
@@ -13,7 +15,7 @@ import (
 //
 //	ctx := ber.NewContext()
 //	// Set options, ex:
-//	ctx.SetDer(true, true)
+//	ctx.SetRules(asn1.DER, asn1.DER)
 //	// And call decode or encode functions
 //	bytes, err := ctx.EncodeWithOptions(value, "explicit,application,tag:5")
 //	...
@@ -21,10 +23,16 @@ import (
 type Context struct {
 	log     *log.Logger
 	choices map[string][]choiceEntry
-	der     struct {
-		encoding bool
-		decoding bool
+	enums   map[string][]Enum
+	schemas map[string]*Schema
+	rules   struct {
+		encoding EncodingRules
+		decoding EncodingRules
 	}
+	// Record the fields of the encoded raw values, see EncodeWithTrace
+	trace bool
+	// Records the fields of the decoded values, see DecodeWithTrace
+	decodeTrace *decodeTrace
 }
 
 // Choice represents one option available for a CHOICE element.
@@ -33,11 +41,22 @@ type Choice struct {
 	Options string
 }
 
+// Enum represents one named value of an ENUMERATED type. Values with the flag
+// Addition set are extension additions, which are placed after the extension
+// marker of the type.
+type Enum struct {
+	Name     string
+	Value    int
+	Addition bool
+}
+
 // Internal register with information about the each CHOICE.
 type choiceEntry struct {
 	expectedElement
 	typ  reflect.Type
 	opts *fieldOptions
+	// Options as given to AddChoice
+	options string
 }
 
 // NewContext creates and initializes a new context. The returned Context does
@@ -47,7 +66,9 @@ func NewContext() *Context {
 	ctx := &Context{}
 	ctx.log = defaultLogger()
 	ctx.choices = make(map[string][]choiceEntry)
-	ctx.SetDer(true, false)
+	ctx.enums = make(map[string][]Enum)
+	ctx.schemas = make(map[string]*Schema)
+	ctx.SetRules(DER, BER)
 	return ctx
 }
 
@@ -95,6 +116,27 @@ func (ctx *Context) getChoiceByTag(choice string, class, tag uint) (entry choice
 	return
 }
 
+// getChoiceAlternatives returns the root alternatives of a choice sorted in the
+// canonical order of their tags, followed by the extension additions in the
+// order they were registered.
+func (ctx *Context) getChoiceAlternatives(choice string) (root, additions []choiceEntry, err error) {
+	entries, err := ctx.getChoices(choice)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		if entry.opts.addition {
+			additions = append(additions, entry)
+		} else {
+			root = append(root, entry)
+		}
+	}
+	sort.SliceStable(root, func(i, j int) bool {
+		return isTagLessThan(root[i].class, root[i].tag, root[j].class, root[j].tag)
+	})
+	return
+}
+
 // addChoiceEntry adds a single choice to the list associated to a given name.
 func (ctx *Context) addChoiceEntry(choice string, entry choiceEntry) error {
 	for _, current := range ctx.choices[choice] {
@@ -188,6 +230,7 @@ func (ctx *Context) AddChoice(choice string, entries []Choice) error {
 			expectedElement: elem,
 			typ:             e.Type,
 			opts:            opts,
+			options:         e.Options,
 		})
 		if err != nil {
 			return err
@@ -196,6 +239,95 @@ func (ctx *Context) AddChoice(choice string, entries []Choice) error {
 	return nil
 }
 
+// AddEnum registers the named values of an ENUMERATED type.
+//
+// The string enum refers to an enumeration name used by an integer element via
+// the option "enum". Elements marked with "enum" are encoded with the
+// ENUMERATED universal tag. Encoding rules that rely on the list of values,
+// such as PER, also use the registered values and the flag "extensible" of
+// the element:
+//
+//	type Color int
+//	type Pixel struct {
+//		Color Color `asn1:"enum:color,extensible"`
+//	}
+//	ctx.AddEnum("color", []asn1.Enum{
+//		{Name: "red", Value: 0},
+//		{Name: "green", Value: 1},
+//		{Name: "blue", Value: 2, Addition: true},
+//	})
+//
+func (ctx *Context) AddEnum(enum string, entries []Enum) error {
+	for _, e := range entries {
+		for _, current := range ctx.enums[enum] {
+			if current.Name == e.Name || current.Value == e.Value {
+				return fmt.Errorf(
+					"enumeration value already registered: %s{%s(%d)}",
+					enum, e.Name, e.Value)
+			}
+		}
+		ctx.enums[enum] = append(ctx.enums[enum], e)
+	}
+	return nil
+}
+
+// getEnum returns the values registered for a given enumeration name.
+func (ctx *Context) getEnum(enum string) ([]Enum, error) {
+	entries := ctx.enums[enum]
+	if entries == nil {
+		return nil, syntaxError("invalid enum '%s'", enum)
+	}
+	return entries, nil
+}
+
+// getEnumValues returns the root values of an enumeration sorted by value,
+// followed by the extension additions in the order they were registered.
+func (ctx *Context) getEnumValues(enum string) (root, additions []Enum, err error) {
+	entries, err := ctx.getEnum(enum)
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		if e.Addition {
+			additions = append(additions, e)
+		} else {
+			root = append(root, e)
+		}
+	}
+	sort.SliceStable(root, func(i, j int) bool {
+		return root[i].Value < root[j].Value
+	})
+	return
+}
+
+// getEnumName returns the name of an enumerated value.
+func (ctx *Context) getEnumName(enum string, n *big.Int) (string, error) {
+	entries, err := ctx.getEnum(enum)
+	if err != nil {
+		return "", err
+	}
+	for _, e := range entries {
+		if n.IsInt64() && int64(e.Value) == n.Int64() {
+			return e.Name, nil
+		}
+	}
+	return "", syntaxError("invalid value %s for enum '%s'", n, enum)
+}
+
+// getEnumValue returns the value of an enumerated name.
+func (ctx *Context) getEnumValue(enum string, name string) (*big.Int, error) {
+	entries, err := ctx.getEnum(enum)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		if e.Name == name {
+			return big.NewInt(int64(e.Value)), nil
+		}
+	}
+	return nil, parseError("invalid name '%s' for enum '%s'", name, enum)
+}
+
 // defaultLogger returns the default Logger. It's used to initialize a new context
 // or when the logger is set to nil.
 func defaultLogger() *log.Logger {
@@ -210,8 +342,27 @@ func (ctx *Context) SetLogger(logger *log.Logger) {
 	ctx.log = logger
 }
 
-// SetDer sets DER mode for encofing and decoding.
+// SetRules sets the encoding rules used for encoding and decoding. A nil value
+// keeps the current rules.
+func (ctx *Context) SetRules(encoding EncodingRules, decoding EncodingRules) {
+	if encoding != nil {
+		ctx.rules.encoding = encoding
+	}
+	if decoding != nil {
+		ctx.rules.decoding = decoding
+	}
+}
+
+// SetDer sets DER mode for encofing and decoding. BER is used when DER mode is
+// not set.
+//
+// Deprecated: use SetRules instead.
 func (ctx *Context) SetDer(encoding bool, decoding bool) {
-	ctx.der.encoding = encoding
-	ctx.der.decoding = decoding
+	rules := func(der bool) EncodingRules {
+		if der {
+			return DER
+		}
+		return BER
+	}
+	ctx.SetRules(rules(encoding), rules(decoding))
 }
@@ -2,7 +2,7 @@ package asn1
 
 import (
 	"bytes"
-	"io"
+	"fmt"
 	"reflect"
 	"sort"
 )
@@ -12,6 +12,8 @@ type expectedElement struct {
 	class   uint
 	tag     uint
 	decoder decoderFunction
+	// Universal tag of string types, which accept the constructed form
+	stringTag uint
 }
 
 // Expected values for fields
@@ -19,6 +21,10 @@ type expectedFieldElement struct {
 	expectedElement
 	value reflect.Value
 	opts  *fieldOptions
+	// Name of the struct field
+	name string
+	// Options of the struct field, as given in its tag
+	options string
 }
 
 // Decode parses the given data into obj. The argument obj should be a reference
@@ -105,14 +111,16 @@ func (ctx *Context) Decode(data []byte, obj interface{}) (rest []byte, err error
 // A missing element that is marked with "default" is set to the given default
 // value during decoding.
 //
-// A zero value marked with "default" is suppressed from output  when encoding
-// is set to DER or is encoded with the given default value when encoding is set
-// to BER.
+// A zero value marked with "default" is suppressed from output when encoding
+// is set to DER or CER, which also suppress values equal to the default, or is
+// encoded with the given default value when encoding is set to BER. See
+// EncodingRules.OmitDefault().
 //
 //	indefinite
 //
 // This option is used only during encoding and causes a constructed element to
-// be encoded using the indefinite form.
+// be encoded using the indefinite form. The final decision is made by the
+// encoding rules, see EncodingRules.IndefiniteLength().
 //
 //	choice
 //
@@ -132,6 +140,37 @@ func (ctx *Context) Decode(data []byte, obj interface{}) (rest []byte, err error
 // Similarly, a struct marked with "set" always enforces that same order when
 // decoding in DER.
 //
+//	enum
+//
+// Indicates that an integer is encoded and decoded as an ENUMERATED type whose
+// values are defined by (*Context).AddEnum().
+//
+//	utf8, numeric, printable, ia5 and visible
+//
+// Indicates that a string is encoded and decoded as a UTF8String,
+// NumericString, PrintableString, IA5String or VisibleString instead of an
+// OCTET STRING.
+//
+//	name
+//
+// Defines the name of a struct field or of a CHOICE alternative (ie:
+// "name:serialNumber"). It's ignored by BER and DER, but used by textual
+// encoding rules such as XER, JER and GSER instead of the Go field or type
+// name.
+//
+//	elem
+//
+// Prefixes an option that applies to the elements of an array or slice
+// instead of the SEQUENCE OF itself (ie: "size:1..8,elem:range:0..15" or
+// "elem:choice:Item,elem:extensible"). It can be nested for lists of lists
+// (ie: "elem:elem:set"). The options "optional", "default", "addition" and
+// "name" cannot be given to elements.
+//
+// The options "range", "size", "extensible" and "addition" define constraints
+// and extension markers. They are ignored by BER and DER, but are used by
+// encoding rules such as PER. See (*Context).EncodeAper() for further
+// details.
+//
 func (ctx *Context) DecodeWithOptions(data []byte, obj interface{}, options string) (rest []byte, err error) {
 
 	opts, err := parseOptions(options)
@@ -163,16 +202,13 @@ func (ctx *Context) DecodeWithOptions(data []byte, obj interface{}, options stri
 }
 
 // Main decode function
-func (ctx *Context) decode(reader io.Reader, value reflect.Value, opts *fieldOptions) error {
+func (ctx *Context) decode(reader *bytes.Buffer, value reflect.Value, opts *fieldOptions) error {
 
 	// Parse an Asn.1 element
-	raw, err := decodeRawValue(reader)
+	raw, err := ctx.readRawValue(reader)
 	if err != nil {
 		return err
 	}
-	if ctx.der.decoding && raw.Indefinite {
-		return parseError("indefinite length form is not supported by DER mode")
-	}
 
 	elem, err := ctx.getExpectedElement(raw, value.Type(), opts)
 	if err != nil {
@@ -186,7 +222,74 @@ func (ctx *Context) decode(reader io.Reader, value reflect.Value, opts *fieldOpt
 			elem.class, elem.tag, raw.Class, raw.Tag)
 	}
 
-	return elem.decoder(raw.Content, value)
+	content, err := ctx.getContent(raw, elem)
+	if err != nil {
+		return err
+	}
+	defer ctx.decodeTrace.enter(raw)()
+	return elem.decoder(content, value)
+}
+
+// readRawValue reads an Asn.1 element and checks its length form. The position
+// of the element is recorded when decoding with a Trace.
+func (ctx *Context) readRawValue(reader *bytes.Buffer) (*rawValue, error) {
+	remaining := reader.Len()
+	raw, err := decodeRawValue(reader)
+	if err != nil {
+		return nil, err
+	}
+	err = ctx.rules.decoding.CheckLength(raw.Constructed, raw.Indefinite)
+	if err != nil {
+		return nil, err
+	}
+	ctx.decodeTrace.locate(raw, remaining, reader.Len())
+	return raw, nil
+}
+
+// getContent returns the content of a raw value. Strings in constructed form
+// have their segments joined.
+func (ctx *Context) getContent(raw *rawValue, elem expectedElement) ([]byte, error) {
+	if !raw.Constructed || elem.stringTag == 0 {
+		return raw.Content, nil
+	}
+	rules := ctx.rules.decoding
+	if rules.Strict() && rules.SegmentSize() == 0 {
+		return nil, parseError("constructed strings are not supported by the decoding rules")
+	}
+	segmentTag := uint(tagOctetString)
+	if elem.stringTag == tagBitString {
+		segmentTag = tagBitString
+	}
+	content := []byte{}
+	unused := byte(0)
+	reader := bytes.NewBuffer(raw.Content)
+	for reader.Len() > 0 {
+		segment, err := decodeRawValue(reader)
+		if err != nil {
+			return nil, err
+		}
+		if segment.Class != ClassUniversal || segment.Tag != segmentTag {
+			return nil, parseError("invalid segment (%d,%d) in constructed string",
+				segment.Class, segment.Tag)
+		}
+		data, err := ctx.getContent(segment, expectedElement{stringTag: segmentTag})
+		if err != nil {
+			return nil, err
+		}
+		if segmentTag == tagBitString {
+			// Only the last segment may have unused bits
+			if len(data) == 0 || unused != 0 {
+				return nil, parseError("invalid segment in constructed BIT STRING")
+			}
+			unused = data[0]
+			data = data[1:]
+		}
+		content = append(content, data...)
+	}
+	if segmentTag == tagBitString {
+		content = append([]byte{unused}, content...)
+	}
+	return content, nil
 }
 
 // getExpectedElement returns the expected element for a given type. raw is only
@@ -202,17 +305,18 @@ func (ctx *Context) getExpectedElement(raw *rawValue, elemType reflect.Type, opt
 
 	// Modify the expected tag and decoder function based on the given options
 	if opts.tag != nil {
-		elem.class = classContextSpecific
+		elem.class = ClassContextSpecific
 		elem.tag = uint(*opts.tag)
 	}
 	if opts.universal {
-		elem.class = classUniversal
+		elem.class = ClassUniversal
 	}
 	if opts.application {
-		elem.class = classApplication
+		elem.class = ClassApplication
 	}
 
 	if opts.explicit {
+		elem.stringTag = 0
 		elem.decoder = func(data []byte, value reflect.Value) error {
 			// Unset previous flags
 			opts.explicit = false
@@ -235,10 +339,20 @@ func (ctx *Context) getExpectedElement(raw *rawValue, elemType reflect.Type, opt
 
 		// Get the decoder for the new value
 		elem.class, elem.tag = raw.Class, raw.Tag
+		elem.stringTag = entry.stringTag
 		elem.decoder = func(data []byte, value reflect.Value) error {
+			ctx.decodeTrace.setChoice(ctx.getChoiceIdentifier(entry), entry.options)
+			// The decoder of the alternative is created with a copy of its
+			// options, since explicit tags change them, and with ctx, which
+			// may differ from the Context that registered the choice
+			nestedOpts := *entry.opts
+			nested, err := ctx.getExpectedElement(raw, entry.typ, &nestedOpts)
+			if err != nil {
+				return err
+			}
 			// Allocate a new value and set to the current one
 			nestedValue := reflect.New(entry.typ).Elem()
-			err = entry.decoder(data, nestedValue)
+			err = nested.decoder(data, nestedValue)
 			if err != nil {
 				return err
 			}
@@ -257,7 +371,7 @@ func (ctx *Context) getExpectedElement(raw *rawValue, elemType reflect.Type, opt
 // getUniversalTag maps an type to a Asn.1 universal type.
 func (ctx *Context) getUniversalTag(objType reflect.Type, opts *fieldOptions) (elem expectedElement, err error) {
 
-	elem.class = classUniversal
+	elem.class = ClassUniversal
 
 	// Special types:
 	switch objType {
@@ -286,6 +400,23 @@ func (ctx *Context) getUniversalTag(objType reflect.Type, opts *fieldOptions) (e
 		}
 		elem.tag = tagSet
 	}
+	if opts.enum != nil {
+		if elem.tag != tagInteger {
+			err = syntaxError(
+				"'enum' cannot be used with Go type '%s'", objType)
+		}
+		elem.tag = tagEnumerated
+	}
+	if opts.stringTag != nil {
+		if objType.Kind() != reflect.String {
+			err = syntaxError(
+				"string types cannot be used with Go type '%s'", objType)
+		}
+		elem.tag = uint(*opts.stringTag)
+	}
+	if isStringTag(elem.tag) {
+		elem.stringTag = elem.tag
+	}
 	return
 }
 
@@ -322,7 +453,9 @@ func (ctx *Context) getUniversalTagByKind(objType reflect.Type, opts *fieldOptio
 			elem.decoder = ctx.decodeOctetString
 		} else {
 			elem.tag = tagSequence
-			elem.decoder = ctx.decodeArray
+			elem.decoder = func(data []byte, value reflect.Value) error {
+				return ctx.decodeArray(data, value, opts)
+			}
 		}
 
 	case reflect.Slice:
@@ -331,7 +464,9 @@ func (ctx *Context) getUniversalTagByKind(objType reflect.Type, opts *fieldOptio
 			elem.decoder = ctx.decodeOctetString
 		} else {
 			elem.tag = tagSequence
-			elem.decoder = ctx.decodeSlice
+			elem.decoder = func(data []byte, value reflect.Value) error {
+				return ctx.decodeSlice(data, value, opts)
+			}
 		}
 	}
 	return
@@ -344,7 +479,9 @@ func (ctx *Context) getExpectedFieldElements(value reflect.Value) ([]expectedFie
 		if value.CanSet() {
 			// Get field and options
 			field := value.Field(i)
-			opts, err := parseOptions(value.Type().Field(i).Tag.Get(tagKey))
+			name := value.Type().Field(i).Name
+			options := value.Type().Field(i).Tag.Get(tagKey)
+			opts, err := parseOptions(options)
 			if err != nil {
 				return nil, err
 			}
@@ -360,7 +497,7 @@ func (ctx *Context) getExpectedFieldElements(value reflect.Value) ([]expectedFie
 					return nil, err
 				}
 				expectedValues = append(expectedValues,
-					expectedFieldElement{elem, field, opts})
+					expectedFieldElement{elem, field, opts, name, options})
 			} else {
 				entries, err := ctx.getChoices(*opts.choice)
 				if err != nil {
@@ -374,7 +511,7 @@ func (ctx *Context) getExpectedFieldElements(value reflect.Value) ([]expectedFie
 						return nil, err
 					}
 					expectedValues = append(expectedValues,
-						expectedFieldElement{elem, field, opts})
+						expectedFieldElement{elem, field, opts, name, options})
 				}
 			}
 		}
@@ -389,7 +526,7 @@ func (ctx *Context) getRawValuesFromBytes(data []byte, max int) ([]*rawValue, er
 	reader := bytes.NewBuffer(data)
 	for i := 0; i < max; i++ {
 		// Parse an Asn.1 element
-		raw, err := decodeRawValue(reader)
+		raw, err := ctx.readRawValue(reader)
 		if err != nil {
 			return nil, err
 		}
@@ -417,7 +554,14 @@ func (ctx *Context) matchExpectedValues(eValues []expectedFieldElement, rValues
 		if rIndex < len(rValues) {
 			raw := rValues[rIndex]
 			if e.class == raw.Class && e.tag == raw.Tag {
-				err := e.decoder(raw.Content, e.value)
+				content, err := ctx.getContent(raw, e.expectedElement)
+				if err != nil {
+					return err
+				}
+				ctx.decodeTrace.setNext("."+e.name, e.options)
+				leave := ctx.decodeTrace.enter(raw)
+				err = e.decoder(content, e.value)
+				leave()
 				if err != nil {
 					return err
 				}
@@ -479,8 +623,8 @@ func (ctx *Context) decodeStruct(data []byte, value reflect.Value) error {
 // Decode a struct as an Asn.1 Set.
 //
 // The order doesn't matter for set. However DER dictates that a Set should be
-// encoded in the ascending order of the tags. So when decoding with DER, we
-// simply do not sort the raw values and use them in their natural order.
+// encoded in the ascending order of the tags. So when decoding with strict
+// rules, such as DER and CER, we simply do not sort the raw values and use them in their natural order.
 func (ctx *Context) decodeStructAsSet(data []byte, value reflect.Value) error {
 
 	// Get the expected values
@@ -505,7 +649,7 @@ func (ctx *Context) decodeStructAsSet(data []byte, value reflect.Value) error {
 	if err != nil {
 		return err
 	}
-	if !ctx.der.decoding {
+	if !ctx.rules.decoding.Strict() {
 		sort.Sort(rawValueSlice(rawValues))
 	}
 
@@ -513,13 +657,14 @@ func (ctx *Context) decodeStructAsSet(data []byte, value reflect.Value) error {
 }
 
 // decodeSlice decodes a SET(OF) as a slice
-func (ctx *Context) decodeSlice(data []byte, value reflect.Value) error {
+func (ctx *Context) decodeSlice(data []byte, value reflect.Value, opts *fieldOptions) error {
 	slice := reflect.New(value.Type()).Elem()
-	var err error
-	for len(data) > 0 {
+	reader := bytes.NewBuffer(data)
+	for i := 0; reader.Len() > 0; i++ {
 		elem := reflect.New(value.Type().Elem()).Elem()
-		data, err = ctx.DecodeWithOptions(data, elem.Addr().Interface(), "")
-		if err != nil {
+		elemOpts := opts.elemOptions()
+		ctx.decodeTrace.setNext(fmt.Sprintf("[%d]", i), elemOpts.String())
+		if err := ctx.decode(reader, elem, elemOpts); err != nil {
 			return err
 		}
 		slice.Set(reflect.Append(slice, elem))
@@ -529,20 +674,21 @@ func (ctx *Context) decodeSlice(data []byte, value reflect.Value) error {
 }
 
 // decodeArray decodes a SET(OF) as an array
-func (ctx *Context) decodeArray(data []byte, value reflect.Value) error {
-	var err error
+func (ctx *Context) decodeArray(data []byte, value reflect.Value, opts *fieldOptions) error {
+	reader := bytes.NewBuffer(data)
 	for i := 0; i < value.Len(); i++ {
-		if len(data) == 0 {
+		if reader.Len() == 0 {
 			return parseError("missing elements")
 		}
 		elem := reflect.New(value.Type().Elem()).Elem()
-		data, err = ctx.DecodeWithOptions(data, elem.Addr().Interface(), "")
-		if err != nil {
+		elemOpts := opts.elemOptions()
+		ctx.decodeTrace.setNext(fmt.Sprintf("[%d]", i), elemOpts.String())
+		if err := ctx.decode(reader, elem, elemOpts); err != nil {
 			return err
 		}
 		value.Index(i).Set(elem)
 	}
-	if len(data) > 0 {
+	if reader.Len() > 0 {
 		return parseError("too many elements")
 	}
 	return nil
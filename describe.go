@@ -0,0 +1,138 @@
+package asn1
+
+import (
+	"reflect"
+)
+
+// Describe returns the schema of a Go type, as used to encode and decode it.
+// The struct tags of the type and the choices and enums registered in ctx are
+// converted the same way as ExportModule() does:
+//
+//	s, err := ctx.Describe(reflect.TypeOf(Record{}))
+//	for _, c := range s.Components {
+//		props, _ := c.Properties()
+//		tags, _ := c.Tags()
+//		fmt.Println(c.Name, c.Schema.TypeName(), props.Optional, tags)
+//	}
+//
+// Named struct types, choices and enums are named schemas, shared by all
+// components that use them. The returned schema can also be registered with
+// AddSchema() and used by DecodeValue() and EncodeValue().
+//
+func (ctx *Context) Describe(t reflect.Type) (*Schema, error) {
+	e := &exporter{ctx: ctx, structs: map[reflect.Type]*Schema{}, names: map[string]interface{}{}}
+	s, err := e.getTypeSchema(t)
+	if err != nil {
+		return nil, err
+	}
+	if err = checkSchema(s, map[*Schema]bool{}); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Tag is the class and number of a tag.
+type Tag struct {
+	// Class of the tag, like ClassUniversal or ClassContextSpecific
+	Class  uint
+	Number uint
+}
+
+// Bounds holds the limits of a range or size constraint. A nil limit stands
+// for MIN or MAX.
+type Bounds struct {
+	Lower *int64
+	Upper *int64
+}
+
+// Properties holds the parsed options of a schema or component.
+type Properties struct {
+	// Tag given by the options, nil if the type keeps its own tag
+	Tag      *Tag
+	Explicit bool
+	// Flags of components
+	Optional bool
+	Addition bool
+	// DEFAULT value of a component, a *big.Int or the name of an ENUMERATED
+	// value
+	Default interface{}
+	// Constraints of the schema
+	Extensible bool
+	Range      *Bounds
+	Size       *Bounds
+}
+
+// Properties returns the parsed options of the schema.
+func (s *Schema) Properties() (Properties, error) {
+	opts, err := s.options()
+	if err != nil {
+		return Properties{}, err
+	}
+	return getProperties(s, opts)
+}
+
+// Properties returns the parsed options of the component.
+func (c Component) Properties() (Properties, error) {
+	opts, err := c.options()
+	if err != nil {
+		return Properties{}, err
+	}
+	return getProperties(c.Schema, opts)
+}
+
+// getProperties converts the options of a schema or component.
+func getProperties(s *Schema, opts *fieldOptions) (props Properties, err error) {
+	if opts.tag != nil {
+		props.Tag = &Tag{Class: ClassContextSpecific, Number: uint(*opts.tag)}
+		if opts.universal {
+			props.Tag.Class = ClassUniversal
+		}
+		if opts.application {
+			props.Tag.Class = ClassApplication
+		}
+	}
+	props.Explicit = opts.explicit
+	props.Optional = opts.optional
+	props.Addition = opts.addition
+	if opts.defaultValue != nil {
+		if props.Default, err = s.getDefault(opts); err != nil {
+			return
+		}
+	}
+	props.Extensible = opts.extensible
+	if opts.valueRange != nil {
+		props.Range = &Bounds{opts.valueRange.lower, opts.valueRange.upper}
+	}
+	if opts.size != nil {
+		props.Size = &Bounds{opts.size.lower, opts.size.upper}
+	}
+	return
+}
+
+// Tags returns the tags used to encode the schema, from the outermost to the
+// innermost. All tags but the innermost one are explicit. Untagged CHOICE and
+// ANY types have no tags, since their tags depend on the value.
+func (s *Schema) Tags() ([]Tag, error) {
+	tags, _, err := getSchemaTags(s, nil)
+	return convertTags(tags), err
+}
+
+// Tags returns the tags used to encode the component, in the same order as
+// (*Schema).Tags().
+func (c Component) Tags() ([]Tag, error) {
+	opts, err := c.options()
+	if err != nil {
+		return nil, err
+	}
+	tags, _, err := getSchemaTags(c.Schema, opts)
+	return convertTags(tags), err
+}
+
+// convertTags converts internal tags to the public type.
+func convertTags(tags []schemaTag) []Tag {
+	converted := make([]Tag, len(tags))
+	for i, t := range tags {
+		converted[i] = Tag{t.class, t.tag}
+	}
+	return converted
+}
@@ -0,0 +1,109 @@
+package asn1
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+)
+
+type describeRecord struct {
+	Id    int         `asn1:"tag:0,explicit,range:0..100,extensible"`
+	Color int         `asn1:"tag:1,enum:Color,default:1"`
+	Name  string      `asn1:"application,tag:2,optional,utf8,size:1..64"`
+	Body  interface{} `asn1:"choice:Body"`
+	Items []int       `asn1:"set"`
+	Pair  struct {
+		A bool
+		B []byte `asn1:"tag:0"`
+	} `asn1:"set"`
+	Extra int `asn1:"tag:3,optional,addition"`
+}
+
+func TestDescribe(t *testing.T) {
+	ctx := NewContext()
+	ctx.AddEnum("Color", []Enum{{Name: "red", Value: 0}, {Name: "green", Value: 1}})
+	ctx.AddChoice("Body", []Choice{
+		{Type: reflect.TypeOf(""), Options: "tag:2,ia5"},
+		{Type: reflect.TypeOf(Oid{}), Options: ""},
+	})
+	s, err := ctx.Describe(reflect.TypeOf(&describeRecord{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Name != "DescribeRecord" || s.Kind != SequenceKind || len(s.Components) != 7 {
+		t.Fatalf("Invalid schema: %+v", s)
+	}
+	names := []string{"id", "color", "name", "body", "items", "pair", "extra"}
+	kinds := []Kind{IntegerKind, EnumeratedKind, StringKind, ChoiceKind, SetOfKind, SetKind, IntegerKind}
+	for i, c := range s.Components {
+		if c.Name != names[i] || c.Schema.Kind != kinds[i] {
+			t.Errorf("Invalid component %d: %s %s", i, c.Name, c.Schema.Kind)
+		}
+	}
+
+	// Component properties
+	id, _ := s.Components[0].Properties()
+	idSchema, _ := s.Components[0].Schema.Properties()
+	if *id.Tag != (Tag{ClassContextSpecific, 0}) || !id.Explicit || id.Optional ||
+		!idSchema.Extensible || *idSchema.Range.Lower != 0 || *idSchema.Range.Upper != 100 {
+		t.Errorf("Invalid properties of id: %+v %+v", id, idSchema)
+	}
+	color, _ := s.Components[1].Properties()
+	if color.Default != "green" || color.Explicit || s.Components[1].Schema.Name != "Color" ||
+		len(s.Components[1].Schema.Enum) != 2 {
+		t.Errorf("Invalid properties of color: %+v", color)
+	}
+	name, _ := s.Components[2].Properties()
+	nameSchema, _ := s.Components[2].Schema.Properties()
+	if *name.Tag != (Tag{ClassApplication, 2}) || !name.Optional || nameSchema.Size.Lower == nil ||
+		s.Components[2].Schema.TypeName() != "UTF8String" {
+		t.Errorf("Invalid properties of name: %+v %+v", name, nameSchema)
+	}
+	extra, _ := s.Components[6].Properties()
+	if !extra.Addition || !extra.Optional {
+		t.Errorf("Invalid properties of extra: %+v", extra)
+	}
+
+	// Choice alternatives
+	body := s.Components[3].Schema
+	if body.Name != "Body" || len(body.Components) != 2 ||
+		body.Components[0].Name != "ia5String" || body.Components[1].Schema.Kind != OidKind {
+		t.Errorf("Invalid choice: %+v", body)
+	}
+
+	// Tags from the outermost to the innermost
+	expectedTags := [][]Tag{
+		{{ClassContextSpecific, 0}, {ClassUniversal, tagInteger}},
+		{{ClassContextSpecific, 1}},
+		{{ClassApplication, 2}},
+		{},
+		{{ClassUniversal, tagSet}},
+	}
+	for i, expected := range expectedTags {
+		tags, err := s.Components[i].Tags()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(tags, expected) {
+			t.Errorf("Component %s: expected tags %v, got %v", names[i], expected, tags)
+		}
+	}
+
+	// The schema decodes the encoding of the Go type
+	if err = ctx.AddSchema(s); err != nil {
+		t.Fatal(err)
+	}
+	obj := describeRecord{Id: 5, Name: "x", Body: "y", Items: []int{1}, Extra: 7}
+	data, err := ctx.Encode(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, _, err := ctx.DecodeValue(data, "DescribeRecord")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Child("id").Data.(*big.Int).Int64() != 5 || v.Child("color").Data != "green" ||
+		v.Child("body").Children[0].Data != "y" || v.Child("extra").Data.(*big.Int).Int64() != 7 {
+		t.Fatalf("Invalid decoded value: %+v", v)
+	}
+}
@@ -0,0 +1,321 @@
+package asn1
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// DiffKind is the kind of a Difference.
+type DiffKind int
+
+// Kinds of differences
+const (
+	// The element is only found in the second encoding
+	Added DiffKind = iota
+	// The element is only found in the first encoding
+	Removed
+	// The element is found in both encodings, with different tags or values
+	Changed
+)
+
+// Difference is an element that differs between two encodings.
+type Difference struct {
+	Kind DiffKind
+	// Path of the element. Without a Go type, it's a path expression with the
+	// indexes of the element and its ancestors, like "0/2/1", that can be used
+	// by Select(). Otherwise it's the path of the Go field, like
+	// "Record.Items[0].Flag".
+	Path string
+	// Element in the first and in the second encoding. A is nil for added
+	// elements and B is nil for removed ones.
+	A, B *Node
+}
+
+// Differences is a list of differences between two encodings.
+type Differences []Difference
+
+// String returns a report with one difference per line, suitable for test
+// failure messages:
+//
+//	~ Record.Id: INTEGER 7 -> INTEGER 8
+//	- Record.Items[1]: INTEGER 2
+//	+ Record.Name: UTF8String 'x'
+func (diffs Differences) String() string {
+	d := dumper{opts: &DefaultDumpOptions}
+	lines := []string{}
+	for _, diff := range diffs {
+		switch diff.Kind {
+		case Added:
+			lines = append(lines, fmt.Sprintf("+ %s: %s", diff.Path, d.describe(diff.B)))
+		case Removed:
+			lines = append(lines, fmt.Sprintf("- %s: %s", diff.Path, d.describe(diff.A)))
+		default:
+			lines = append(lines, fmt.Sprintf("~ %s: %s -> %s", diff.Path,
+				d.describe(diff.A), d.describe(diff.B)))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// describe returns a single line description of a node.
+func (d *dumper) describe(node *Node) string {
+	name := getTagName(node.Class, node.Tag)
+	if node.Constructed {
+		if len(node.Children) == 1 {
+			return name + " {1 element}"
+		}
+		return fmt.Sprintf("%s {%d elements}", name, len(node.Children))
+	}
+	tag := uint(0)
+	if node.Class == ClassUniversal {
+		tag = node.Tag
+	}
+	if value := d.getValue(node, tag); value != "" {
+		return name + " " + value
+	}
+	return name
+}
+
+// Diff compares the elements in two encodings, without any knowledge of their
+// types, and returns the elements that were added, removed or changed.
+//
+// The children of constructed elements are aligned to find the smallest set
+// of changes, so an element inserted in a SEQUENCE is reported as added
+// instead of changing all the elements that follow it. Elements with the same
+// content but a different length form are reported as changed.
+func Diff(a, b []byte) (Differences, error) {
+	return diff(a, b, nil, nil)
+}
+
+// DiffAs works similarly to Diff, but uses the type of obj to identify the
+// elements by the paths of their Go fields. The fields of a SEQUENCE or SET
+// are compared by their names and the elements of SEQUENCE OF values are
+// aligned as in Diff. The choices and enums of ctx are used, or the ones of a
+// new Context if ctx is nil.
+//
+// The elements in a and b are identified by the type name. If any of them has
+// more than one element, their index is added, like "Record[1].Id".
+func DiffAs(a, b []byte, obj interface{}, ctx *Context) (Differences, error) {
+	if ctx == nil {
+		ctx = NewContext()
+	}
+	value := reflect.ValueOf(obj)
+	if !value.IsValid() {
+		return nil, syntaxError("invalid nil object")
+	}
+	objType := value.Type()
+	if objType.Kind() == reflect.Ptr && objType != bigIntType {
+		objType = objType.Elem()
+	}
+	return diff(a, b, objType, ctx)
+}
+
+// differ compares two trees of nodes. A nil ctx is used when the type is not
+// known.
+type differ struct {
+	ctx   *Context
+	a, b  []byte
+	diffs Differences
+}
+
+// diff parses and compares two encodings.
+func diff(a, b []byte, objType reflect.Type, ctx *Context) (Differences, error) {
+	nodesA, err := parseNodes(a)
+	if err != nil {
+		return nil, err
+	}
+	nodesB, err := parseNodes(b)
+	if err != nil {
+		return nil, err
+	}
+	d := differ{ctx: ctx, a: a, b: b, diffs: Differences{}}
+	path := func(i int) string {
+		if objType == nil {
+			return fmt.Sprint(i)
+		}
+		if len(nodesA) > 1 || len(nodesB) > 1 {
+			return fmt.Sprintf("%s[%d]", objType.Name(), i)
+		}
+		return objType.Name()
+	}
+	err = d.diffLists(nodesA, nodesB, objType, &fieldOptions{}, path)
+	if err != nil {
+		return nil, err
+	}
+	return d.diffs, nil
+}
+
+// parseNodes parses all the elements in data.
+func parseNodes(data []byte) ([]*Node, error) {
+	nodes := []*Node{}
+	for offset := 0; offset < len(data); {
+		node, err := parseNode(data[offset:], offset)
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, node)
+		offset += node.Len()
+	}
+	return nodes, nil
+}
+
+// getBytes returns the original bytes of a node.
+func getBytes(data []byte, node *Node) []byte {
+	return data[node.Offset : node.Offset+node.Len()]
+}
+
+// add adds a difference to the list.
+func (d *differ) add(kind DiffKind, path string, a, b *Node) {
+	d.diffs = append(d.diffs, Difference{kind, path, a, b})
+}
+
+// diffNodes compares two nodes. The type of the nodes is nil if it's not
+// known.
+func (d *differ) diffNodes(a, b *Node, objType reflect.Type, opts *fieldOptions, path string) error {
+	if bytes.Equal(getBytes(d.a, a), getBytes(d.b, b)) {
+		return nil
+	}
+	if a.Class != b.Class || a.Tag != b.Tag || !a.Constructed || !b.Constructed {
+		d.add(Changed, path, a, b)
+		return nil
+	}
+
+	if objType == nil {
+		return d.diffLists(a.Children, b.Children, nil, nil, func(i int) string {
+			return fmt.Sprintf("%s/%d", path, i)
+		})
+	}
+
+	if opts.explicit {
+		if len(a.Children) != 1 || len(b.Children) != 1 {
+			d.add(Changed, path, a, b)
+			return nil
+		}
+		inner := *opts
+		inner.explicit = false
+		inner.tag = nil
+		inner.universal = false
+		inner.application = false
+		return d.diffNodes(a.Children[0], b.Children[0], objType, &inner, path)
+	}
+
+	if opts.choice != nil {
+		entry, err := d.ctx.getChoiceByTag(*opts.choice, a.Class, a.Tag)
+		if err != nil {
+			return d.diffNodes(a, b, nil, nil, path)
+		}
+		return d.diffNodes(a, b, entry.typ, entry.opts, path)
+	}
+
+	elem, err := d.ctx.getUniversalTag(objType, opts)
+	if err != nil || (elem.tag != tagSequence && elem.tag != tagSet) {
+		return d.diffNodes(a, b, nil, nil, path)
+	}
+	if objType.Kind() != reflect.Struct {
+		return d.diffLists(a.Children, b.Children, objType.Elem(), opts.elemOptions(), func(i int) string {
+			return fmt.Sprintf("%s[%d]", path, i)
+		})
+	}
+	return d.diffFields(a.Children, b.Children, objType, opts.set, path)
+}
+
+// diffFields compares the components of a SEQUENCE or SET by their fields.
+// Components that do not match any field are compared by their indexes.
+func (d *differ) diffFields(a, b []*Node, objType reflect.Type, set bool, path string) error {
+	matchesA, err := d.ctx.matchFields(a, objType, set)
+	if err != nil {
+		return err
+	}
+	matchesB, err := d.ctx.matchFields(b, objType, set)
+	if err != nil {
+		return err
+	}
+	fieldsB := map[string]*Node{}
+	unknownB := []*Node{}
+	for _, m := range matchesB {
+		if m.elem == nil {
+			unknownB = append(unknownB, m.node)
+		} else {
+			fieldsB[m.elem.name] = m.node
+		}
+	}
+
+	unknownA := []*Node{}
+	for _, m := range matchesA {
+		if m.elem == nil {
+			unknownA = append(unknownA, m.node)
+			continue
+		}
+		fieldPath := path + "." + m.elem.name
+		nodeB := fieldsB[m.elem.name]
+		switch {
+		case m.node == nil && nodeB == nil:
+		case m.node == nil:
+			d.add(Added, fieldPath, nil, nodeB)
+		case nodeB == nil:
+			d.add(Removed, fieldPath, m.node, nil)
+		default:
+			err = d.diffNodes(m.node, nodeB, m.elem.value.Type(), m.elem.opts, fieldPath)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return d.diffLists(unknownA, unknownB, nil, nil, func(i int) string {
+		return fmt.Sprintf("%s/%d", path, i)
+	})
+}
+
+// diffLists aligns two lists of nodes of the same type and compares them. The
+// function path returns the path of the node at an index, which is the index
+// in the first list for removed and changed nodes and the index in the second
+// list for added ones.
+func (d *differ) diffLists(a, b []*Node, objType reflect.Type, opts *fieldOptions, path func(int) string) error {
+	// Longest common subsequence, where equal nodes count more than nodes
+	// with the same tag
+	score := func(i, j int) int {
+		if bytes.Equal(getBytes(d.a, a[i]), getBytes(d.b, b[j])) {
+			return 2
+		}
+		if a[i].Class == b[j].Class && a[i].Tag == b[j].Tag {
+			return 1
+		}
+		return 0
+	}
+	best := make([][]int, len(a)+1)
+	for i := range best {
+		best[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			best[i][j] = best[i+1][j]
+			if best[i][j+1] > best[i][j] {
+				best[i][j] = best[i][j+1]
+			}
+			if s := score(i, j); s > 0 && best[i+1][j+1]+s > best[i][j] {
+				best[i][j] = best[i+1][j+1] + s
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && score(i, j) > 0 && best[i][j] == best[i+1][j+1]+score(i, j):
+			if err := d.diffNodes(a[i], b[j], objType, opts, path(i)); err != nil {
+				return err
+			}
+			i++
+			j++
+		case i < len(a) && (j == len(b) || best[i][j] == best[i+1][j]):
+			d.add(Removed, path(i), a[i], nil)
+			i++
+		default:
+			d.add(Added, path(j), nil, b[j])
+			j++
+		}
+	}
+	return nil
+}
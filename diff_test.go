@@ -0,0 +1,87 @@
+package asn1
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	a := []byte{
+		// SEQ { INTEGER 1, INTEGER 2, INTEGER 3, SEQ { BOOLEAN TRUE } }
+		0x30, 0x0e, 0x02, 0x01, 0x01, 0x02, 0x01, 0x02, 0x02, 0x01, 0x03,
+		0x30, 0x03, 0x01, 0x01, 0xff,
+	}
+	b := []byte{
+		// SEQ { INTEGER 1, INTEGER 3, SEQ { BOOLEAN FALSE }, NULL }
+		0x30, 0x0d, 0x02, 0x01, 0x01, 0x02, 0x01, 0x03,
+		0x30, 0x03, 0x01, 0x01, 0x00, 0x05, 0x00,
+		// Second element
+		0x05, 0x00,
+	}
+	diffs, err := Diff(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkEqual(t, diffs.String(), ""+
+		"- 0/1: INTEGER 2\n"+
+		"~ 0/3/0: BOOLEAN TRUE -> BOOLEAN FALSE\n"+
+		"+ 0/3: NULL\n"+
+		"+ 1: NULL")
+	checkEqual(t, diffs[1].A.Offset, 13)
+	checkEqual(t, diffs[1].B.Offset, 10)
+
+	// The paths can be used by Select
+	matches, err := Select(a, diffs[1].Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkEqual(t, matches[0].Node, diffs[1].A)
+
+	diffs, err = Diff(a, a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkEqual(t, len(diffs), 0)
+}
+
+func TestDiffAs(t *testing.T) {
+	type Record struct {
+		Id    int
+		Name  string      `asn1:"optional,utf8"`
+		Value interface{} `asn1:"choice:value"`
+		Items []int       `asn1:"tag:2,explicit"`
+	}
+	ctx := NewContext()
+	ctx.AddChoice("value", []Choice{
+		{reflect.TypeOf(""), "tag:1,explicit,utf8,name:text"},
+		{reflect.TypeOf(int(0)), "tag:3"},
+	})
+	a, err := ctx.Encode(Record{Id: 7, Value: "abc", Items: []int{1, 2, 3}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ctx.Encode(Record{Id: 8, Name: "x", Value: "abd", Items: []int{1, 3}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	diffs, err := DiffAs(a, b, &Record{}, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkEqual(t, diffs.String(), ""+
+		"~ Record.Id: INTEGER 7 -> INTEGER 8\n"+
+		"+ Record.Name: UTF8String 'x'\n"+
+		"~ Record.Value: UTF8String 'abc' -> UTF8String 'abd'\n"+
+		"- Record.Items[1]: INTEGER 2")
+
+	// A different alternative
+	b, err = ctx.Encode(Record{Id: 7, Value: 5, Items: []int{1, 2, 3}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	diffs, err = DiffAs(a, b, Record{}, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkEqual(t, diffs.String(), "~ Record.Value: [1] {1 element} -> [3] 05")
+}
@@ -0,0 +1,539 @@
+package asn1
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// DumpOptions controls the output of Dump.
+type DumpOptions struct {
+	// Names of OBJECT IDENTIFIERs indexed by their dotted representation
+	// (ie: "2.5.4.3"). They are used before the names known by the package.
+	OidNames map[string]string
+	// Try to decode the content of OCTET STRINGs and BIT STRINGs, which often
+	// encapsulate other elements.
+	Encapsulated bool
+	// Maximum number of octets shown for binary values. Zero shows all of
+	// them.
+	MaxBytes int
+}
+
+// DefaultDumpOptions is used by Dump when no options are given.
+var DefaultDumpOptions = DumpOptions{Encapsulated: true, MaxBytes: 16}
+
+// Dump prints the elements in data as an indented tree, similar to the output
+// of dumpasn1 or openssl asn1parse. No knowledge of the types is needed.
+//
+// Each line shows the offset of the element, the length of its header, the
+// length of its content ("inf" for indefinite lengths), its tag and its value.
+// Constructed elements have their children enclosed in braces:
+//
+//	 0  2   13: SEQUENCE {
+//	 2  2    3:   OBJECT IDENTIFIER commonName (2.5.4.3)
+//	 7  2    3:   PrintableString 'Bob'
+//	12  2    2:   [0] 01 02
+//	          : }
+//
+// If opts is nil, DefaultDumpOptions is used.
+func Dump(w io.Writer, data []byte, opts *DumpOptions) error {
+	if opts == nil {
+		opts = &DefaultDumpOptions
+	}
+	d := dumper{w: w, opts: opts}
+	for offset := 0; offset < len(data); {
+		node, err := parseNode(data[offset:], offset)
+		if err != nil {
+			return err
+		}
+		if err = d.dump(node, "", 0); err != nil {
+			return err
+		}
+		offset += node.Len()
+	}
+	return nil
+}
+
+type dumper struct {
+	w    io.Writer
+	opts *DumpOptions
+	// Context used by DumpAs
+	ctx *Context
+}
+
+// universalNames has the names of the universal tags.
+var universalNames = map[uint]string{
+	tagBoolean:         "BOOLEAN",
+	tagInteger:         "INTEGER",
+	tagBitString:       "BIT STRING",
+	tagOctetString:     "OCTET STRING",
+	tagNull:            "NULL",
+	tagOid:             "OBJECT IDENTIFIER",
+	7:                  "ObjectDescriptor",
+	8:                  "EXTERNAL",
+	9:                  "REAL",
+	tagEnumerated:      "ENUMERATED",
+	11:                 "EMBEDDED PDV",
+	tagUtf8String:      "UTF8String",
+	13:                 "RELATIVE-OID",
+	tagSequence:        "SEQUENCE",
+	tagSet:             "SET",
+	tagNumericString:   "NumericString",
+	tagPrintableString: "PrintableString",
+	tagT61String:       "T61String",
+	21:                 "VideotexString",
+	tagIA5String:       "IA5String",
+	tagUtcTime:         "UTCTime",
+	tagGeneralizedTime: "GeneralizedTime",
+	25:                 "GraphicString",
+	tagVisibleString:   "VisibleString",
+	27:                 "GeneralString",
+	28:                 "UniversalString",
+	30:                 "BMPString",
+}
+
+// oidNames has the names of some common OBJECT IDENTIFIERs.
+var oidNames = map[string]string{
+	"1.2.840.113549.1.1.1":   "rsaEncryption",
+	"1.2.840.113549.1.1.5":   "sha1WithRSAEncryption",
+	"1.2.840.113549.1.1.11":  "sha256WithRSAEncryption",
+	"1.2.840.113549.1.9.1":   "emailAddress",
+	"1.2.840.10045.2.1":      "ecPublicKey",
+	"1.2.840.10045.4.3.2":    "ecdsaWithSHA256",
+	"1.3.6.1.5.5.7.3.1":      "serverAuth",
+	"1.3.6.1.5.5.7.3.2":      "clientAuth",
+	"2.5.4.3":                "commonName",
+	"2.5.4.6":                "countryName",
+	"2.5.4.7":                "localityName",
+	"2.5.4.8":                "stateOrProvinceName",
+	"2.5.4.10":               "organizationName",
+	"2.5.4.11":               "organizationalUnitName",
+	"2.5.29.14":              "subjectKeyIdentifier",
+	"2.5.29.15":              "keyUsage",
+	"2.5.29.17":              "subjectAltName",
+	"2.5.29.19":              "basicConstraints",
+	"2.5.29.35":              "authorityKeyIdentifier",
+	"2.5.29.37":              "extKeyUsage",
+	"2.16.840.1.101.3.4.2.1": "sha256",
+}
+
+// getTagName returns the name of a tag, like "SEQUENCE" or "[APPLICATION 1]".
+func getTagName(class, tag uint) string {
+	switch class {
+	case ClassUniversal:
+		if name, ok := universalNames[tag]; ok {
+			return name
+		}
+		return fmt.Sprintf("[UNIVERSAL %d]", tag)
+	case ClassApplication:
+		return fmt.Sprintf("[APPLICATION %d]", tag)
+	case ClassPrivate:
+		return fmt.Sprintf("[PRIVATE %d]", tag)
+	}
+	return fmt.Sprintf("[%d]", tag)
+}
+
+// getOidName returns the name of an OBJECT IDENTIFIER or an empty string.
+func (d *dumper) getOidName(oid Oid) string {
+	key := strings.TrimPrefix(oid.String(), ".")
+	if name, ok := d.opts.OidNames[key]; ok {
+		return name
+	}
+	return oidNames[key]
+}
+
+// printf prints a line with the information of the node.
+func (d *dumper) printf(node *Node, depth int, format string, args ...interface{}) error {
+	length := fmt.Sprint(len(node.Content))
+	if node.Indefinite {
+		length = "inf"
+	}
+	_, err := fmt.Fprintf(d.w, "%5d %2d %4s: %s%s\n", node.Offset, node.HeaderLen,
+		length, strings.Repeat("  ", depth), fmt.Sprintf(format, args...))
+	return err
+}
+
+// note prints a line that is not related to any node, like a closing brace.
+func (d *dumper) note(depth int, text string) error {
+	_, err := fmt.Fprintf(d.w, "%13s: %s%s\n", "", strings.Repeat("  ", depth), text)
+	return err
+}
+
+// close prints the closing brace of a constructed node.
+func (d *dumper) close(depth int) error {
+	return d.note(depth, "}")
+}
+
+// getLabel returns the prefix of a line for the given label.
+func getLabel(label string) string {
+	if label == "" {
+		return ""
+	}
+	return label + ": "
+}
+
+// dump prints a node and its children. The label, if any, is shown before the
+// tag of the node.
+func (d *dumper) dump(node *Node, label string, depth int) error {
+	name := getLabel(label) + getTagName(node.Class, node.Tag)
+	if node.Constructed {
+		if err := d.printf(node, depth, "%s {", name); err != nil {
+			return err
+		}
+		for _, child := range node.Children {
+			if err := d.dump(child, "", depth+1); err != nil {
+				return err
+			}
+		}
+		return d.close(depth)
+	}
+
+	// Encapsulated elements
+	if d.opts.Encapsulated && node.Class == ClassUniversal {
+		var children []*Node
+		switch node.Tag {
+		case tagOctetString:
+			children = parseEncapsulated(node.Content, node.Offset+node.HeaderLen)
+		case tagBitString:
+			if len(node.Content) > 0 && node.Content[0] == 0 {
+				children = parseEncapsulated(node.Content[1:], node.Offset+node.HeaderLen+1)
+			}
+		}
+		if children != nil {
+			if err := d.printf(node, depth, "%s, encapsulates {", name); err != nil {
+				return err
+			}
+			for _, child := range children {
+				if err := d.dump(child, "", depth+1); err != nil {
+					return err
+				}
+			}
+			return d.close(depth)
+		}
+	}
+
+	tag := uint(0)
+	if node.Class == ClassUniversal {
+		tag = node.Tag
+	}
+	value := d.getValue(node, tag)
+	if value == "" {
+		return d.printf(node, depth, "%s", name)
+	}
+	return d.printf(node, depth, "%s %s", name, value)
+}
+
+// getValue returns the textual representation of the value of a primitive
+// node, which is interpreted as the given universal tag. Values that cannot be
+// decoded are shown in hexadecimal.
+func (d *dumper) getValue(node *Node, tag uint) string {
+	switch tag {
+	case tagBoolean:
+		if b, err := node.AsBool(); err == nil {
+			return strings.ToUpper(fmt.Sprint(b))
+		}
+	case tagInteger, tagEnumerated:
+		if n, err := node.AsBigInt(); err == nil {
+			return n.String()
+		}
+	case tagNull:
+		if len(node.Content) == 0 {
+			return ""
+		}
+	case tagOid:
+		if oid, err := node.AsOid(); err == nil {
+			dotted := strings.TrimPrefix(oid.String(), ".")
+			if name := d.getOidName(oid); name != "" {
+				return fmt.Sprintf("%s (%s)", name, dotted)
+			}
+			return dotted
+		}
+	case tagBitString:
+		if len(node.Content) > 0 && node.Content[0] < 8 {
+			return fmt.Sprintf("%s (%d unused bits)", d.getHex(node.Content[1:]), node.Content[0])
+		}
+	case tagUtf8String, tagNumericString, tagPrintableString, tagT61String,
+		tagIA5String, tagUtcTime, tagGeneralizedTime, tagVisibleString:
+		return quoteString(node.Content)
+	}
+	return d.getHex(node.Content)
+}
+
+// quoteString returns a string value in single quotes. Quotes, control
+// characters and invalid UTF-8 are escaped as in Go strings, so that values
+// from untrusted data keep each element in a single line.
+func quoteString(data []byte) string {
+	s := strconv.Quote(string(data))
+	s = strings.Replace(s[1:len(s)-1], `\"`, `"`, -1)
+	return "'" + strings.Replace(s, "'", `\'`, -1) + "'"
+}
+
+// getHex returns the hexadecimal representation of data, limited to the
+// maximum number of octets of the options.
+func (d *dumper) getHex(data []byte) string {
+	suffix := ""
+	if d.opts.MaxBytes > 0 && len(data) > d.opts.MaxBytes {
+		data = data[:d.opts.MaxBytes]
+		suffix = " ..."
+	}
+	s := strings.ToUpper(hex.EncodeToString(data))
+	parts := []string{}
+	for i := 0; i < len(s); i += 2 {
+		parts = append(parts, s[i:i+2])
+	}
+	return strings.Join(parts, " ") + suffix
+}
+
+// parseEncapsulated parses data if it entirely consists of elements that are
+// constructed or have a known universal tag. Otherwise it returns nil.
+func parseEncapsulated(data []byte, offset int) []*Node {
+	nodes := []*Node{}
+	for len(data) > 0 {
+		node, err := parseNode(data, offset)
+		if err != nil {
+			return nil
+		}
+		if !node.Constructed {
+			if _, ok := universalNames[node.Tag]; !ok || node.Class != ClassUniversal {
+				return nil
+			}
+		}
+		nodes = append(nodes, node)
+		offset += node.Len()
+		data = data[node.Len():]
+	}
+	if len(nodes) == 0 {
+		return nil
+	}
+	return nodes
+}
+
+// DumpAs works similarly to Dump, but uses the type of obj to label the
+// elements in data with the path of the Go field that holds them. CHOICE
+// fields show the chosen alternative, ENUMERATED values show their names and
+// missing fields are shown as absent or defaulted:
+//
+//	0  2   10: Record: SEQUENCE {
+//	2  2    1:   Record.Id: INTEGER 7
+//	         :   Record.Name (absent)
+//	5  2    5:   Record.Value (Text): [1] {
+//	7  2    3:     UTF8String 'abc'
+//	         :   }
+//	         : }
+//
+// The argument obj is only used for its type and it's not modified. Elements
+// that do not match the type are shown as in Dump. The choices and enums of
+// ctx are used, or the ones of a new Context if ctx is nil.
+func DumpAs(w io.Writer, data []byte, obj interface{}, ctx *Context) error {
+	if ctx == nil {
+		ctx = NewContext()
+	}
+	value := reflect.ValueOf(obj)
+	if !value.IsValid() {
+		return syntaxError("invalid nil object")
+	}
+	objType := value.Type()
+	if objType.Kind() == reflect.Ptr && objType != bigIntType {
+		objType = objType.Elem()
+	}
+	d := dumper{w: w, opts: &DefaultDumpOptions, ctx: ctx}
+	for offset := 0; offset < len(data); {
+		node, err := parseNode(data[offset:], offset)
+		if err != nil {
+			return err
+		}
+		err = d.dumpAs(node, objType, &fieldOptions{}, objType.Name(), objType.Name(), 0)
+		if err != nil {
+			return err
+		}
+		offset += node.Len()
+	}
+	return nil
+}
+
+// dumpAs prints a node as a value of the given type. The path is used to build
+// the labels of the children, while label is shown before the node.
+func (d *dumper) dumpAs(node *Node, objType reflect.Type, opts *fieldOptions, path, label string, depth int) error {
+	if opts.explicit {
+		if !node.Constructed || len(node.Children) != 1 {
+			return d.dump(node, label, depth)
+		}
+		name := getLabel(label) + getTagName(node.Class, node.Tag)
+		if err := d.printf(node, depth, "%s {", name); err != nil {
+			return err
+		}
+		inner := *opts
+		inner.explicit = false
+		inner.tag = nil
+		inner.universal = false
+		inner.application = false
+		if err := d.dumpAs(node.Children[0], objType, &inner, path, "", depth+1); err != nil {
+			return err
+		}
+		return d.close(depth)
+	}
+
+	if opts.choice != nil {
+		entry, err := d.ctx.getChoiceByTag(*opts.choice, node.Class, node.Tag)
+		if err != nil {
+			return d.dump(node, label, depth)
+		}
+		label = strings.TrimSpace(fmt.Sprintf("%s (%s)", label, d.ctx.getChoiceIdentifier(entry)))
+		return d.dumpAs(node, entry.typ, entry.opts, path, label, depth)
+	}
+
+	elem, err := d.ctx.getUniversalTag(objType, opts)
+	if err != nil {
+		return d.dump(node, label, depth)
+	}
+	name := getLabel(label) + getTagName(node.Class, node.Tag)
+	if node.Class != ClassUniversal {
+		name += " " + universalNames[elem.tag]
+	}
+
+	switch {
+	case elem.tag == tagSequence || elem.tag == tagSet:
+		if !node.Constructed {
+			return d.dump(node, label, depth)
+		}
+		if err := d.printf(node, depth, "%s {", name); err != nil {
+			return err
+		}
+		if objType.Kind() == reflect.Struct {
+			err = d.dumpFields(node.Children, objType, opts.set, path, depth+1)
+		} else {
+			for i, child := range node.Children {
+				childPath := fmt.Sprintf("%s[%d]", path, i)
+				err = d.dumpAs(child, objType.Elem(), opts.elemOptions(), childPath, childPath, depth+1)
+				if err != nil {
+					break
+				}
+			}
+		}
+		if err != nil {
+			return err
+		}
+		return d.close(depth)
+
+	case opts.enum != nil && !node.Constructed:
+		n, err := node.AsBigInt()
+		if err != nil {
+			return d.dump(node, label, depth)
+		}
+		if enumName, err := d.ctx.getEnumName(*opts.enum, n); err == nil {
+			return d.printf(node, depth, "%s %s (%s)", name, enumName, n)
+		}
+		return d.printf(node, depth, "%s %s", name, n)
+
+	case node.Class != ClassUniversal && !node.Constructed:
+		value := d.getValue(node, elem.tag)
+		if value == "" {
+			return d.printf(node, depth, "%s", name)
+		}
+		return d.printf(node, depth, "%s %s", name, value)
+	}
+	return d.dump(node, label, depth)
+}
+
+// dumpFields prints the components of a SEQUENCE or SET as the fields of a
+// struct type. Fields that are not found are printed as absent, defaulted or
+// missing, and children that do not match any field are printed as unknown.
+func (d *dumper) dumpFields(children []*Node, objType reflect.Type, set bool, path string, depth int) error {
+	matches, err := d.ctx.matchFields(children, objType, set)
+	if err != nil {
+		return err
+	}
+	for _, m := range matches {
+		if m.elem == nil {
+			if err := d.dump(m.node, "(unknown)", depth); err != nil {
+				return err
+			}
+			continue
+		}
+		fieldPath := path + "." + m.elem.name
+		switch {
+		case m.node != nil:
+			err = d.dumpAs(m.node, m.elem.value.Type(), m.elem.opts, fieldPath, fieldPath, depth)
+		case m.elem.opts.defaultValue != nil:
+			err = d.note(depth, fmt.Sprintf("%s (defaulted to %d)", fieldPath, *m.elem.opts.defaultValue))
+		case m.elem.opts.optional || m.elem.opts.choice != nil:
+			err = d.note(depth, fieldPath+" (absent)")
+		default:
+			err = d.note(depth, fieldPath+" (missing)")
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fieldMatch is a component of a SEQUENCE or SET matched to a struct field.
+// Fields that are not found have no node, and components that do not match any
+// field have no element.
+type fieldMatch struct {
+	elem *expectedFieldElement
+	node *Node
+}
+
+// matchFields matches the components of a SEQUENCE or SET to the fields of a
+// struct type, in the same way they are decoded. Each field is returned once.
+// The components of a SEQUENCE are returned in their order, with missing
+// fields in the place they would be found, while the missing fields of a SET
+// come after its components.
+func (ctx *Context) matchFields(children []*Node, objType reflect.Type, set bool) ([]fieldMatch, error) {
+	elements, err := ctx.getExpectedFieldElements(reflect.New(objType).Elem())
+	if err != nil {
+		return nil, err
+	}
+	found := map[string]bool{}
+	matchesNode := func(e expectedFieldElement, node *Node) bool {
+		return !found[e.name] && e.class == node.Class && e.tag == node.Tag
+	}
+	matches := []fieldMatch{}
+	i := 0
+	if set {
+		// Components of a SET may be in any order
+		for ; i < len(children); i++ {
+			j := 0
+			for j < len(elements) && !matchesNode(elements[j], children[i]) {
+				j++
+			}
+			if j == len(elements) {
+				break
+			}
+			found[elements[j].name] = true
+			matches = append(matches, fieldMatch{&elements[j], children[i]})
+		}
+		for j, e := range elements {
+			if !found[e.name] {
+				found[e.name] = true
+				matches = append(matches, fieldMatch{&elements[j], nil})
+			}
+		}
+	} else {
+		for j, e := range elements {
+			if found[e.name] {
+				continue
+			}
+			if i < len(children) && matchesNode(e, children[i]) {
+				found[e.name] = true
+				matches = append(matches, fieldMatch{&elements[j], children[i]})
+				i++
+				continue
+			}
+			// Choices have one element for each alternative
+			if j+1 == len(elements) || elements[j+1].name != e.name {
+				found[e.name] = true
+				matches = append(matches, fieldMatch{&elements[j], nil})
+			}
+		}
+	}
+	for ; i < len(children); i++ {
+		matches = append(matches, fieldMatch{nil, children[i]})
+	}
+	return matches, nil
+}
@@ -0,0 +1,158 @@
+package asn1
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func testDump(t *testing.T, data []byte, opts *DumpOptions, expected string) {
+	buf := bytes.Buffer{}
+	if err := Dump(&buf, data, opts); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != expected {
+		t.Fatalf("Unexpected dump.\n Expected:\n%s\n Got:\n%s", expected, buf.String())
+	}
+}
+
+func TestDump(t *testing.T) {
+	data := []byte{
+		0x30, 0x15,
+		0x06, 0x03, 0x55, 0x04, 0x03,
+		0x13, 0x03, 0x42, 0x6f, 0x62,
+		0x80, 0x02, 0x01, 0x02,
+		0xa1, 0x80, 0x01, 0x01, 0xff, 0x00, 0x00,
+		// A second element
+		0x05, 0x00,
+	}
+	testDump(t, data, nil, ""+
+		"    0  2   21: SEQUENCE {\n"+
+		"    2  2    3:   OBJECT IDENTIFIER commonName (2.5.4.3)\n"+
+		"    7  2    3:   PrintableString 'Bob'\n"+
+		"   12  2    2:   [0] 01 02\n"+
+		"   16  2  inf:   [1] {\n"+
+		"   18  2    1:     BOOLEAN TRUE\n"+
+		"             :   }\n"+
+		"             : }\n"+
+		"   23  2    0: NULL\n")
+
+	// Custom OID names
+	testDump(t, data[2:7], &DumpOptions{OidNames: map[string]string{"2.5.4.3": "cn"}},
+		"    0  2    3: OBJECT IDENTIFIER cn (2.5.4.3)\n")
+
+	// Strings are escaped to keep one element per line
+	testDump(t, []byte{0x0c, 0x07, 'a', '\n', 0x1b, '\'', '"', '\\', 0xff}, nil,
+		`    0  2    7: UTF8String 'a\n\x1b\'"\\\xff'`+"\n")
+
+	if err := Dump(&bytes.Buffer{}, []byte{0x30, 0x05, 0x01}, nil); err == nil {
+		t.Fatal("Dumping invalid data should have failed.")
+	}
+}
+
+func TestDumpEncapsulated(t *testing.T) {
+	data := []byte{
+		// OCTET STRING { SEQUENCE { BOOLEAN TRUE } }
+		0x04, 0x05, 0x30, 0x03, 0x01, 0x01, 0xff,
+		// BIT STRING { INTEGER 5 }
+		0x03, 0x04, 0x00, 0x02, 0x01, 0x05,
+		// OCTET STRING that is not BER
+		0x04, 0x02, 0x01, 0x05,
+	}
+	testDump(t, data, nil, ""+
+		"    0  2    5: OCTET STRING, encapsulates {\n"+
+		"    2  2    3:   SEQUENCE {\n"+
+		"    4  2    1:     BOOLEAN TRUE\n"+
+		"             :   }\n"+
+		"             : }\n"+
+		"    7  2    4: BIT STRING, encapsulates {\n"+
+		"   10  2    1:   INTEGER 5\n"+
+		"             : }\n"+
+		"   13  2    2: OCTET STRING 01 05\n")
+
+	testDump(t, data[:7], &DumpOptions{MaxBytes: 2}, ""+
+		"    0  2    5: OCTET STRING 30 03 ...\n")
+	testDump(t, data[7:13], &DumpOptions{}, ""+
+		"    0  2    4: BIT STRING 02 01 05 (0 unused bits)\n")
+}
+
+func testDumpAs(t *testing.T, ctx *Context, data []byte, obj interface{}, expected string) {
+	buf := bytes.Buffer{}
+	if err := DumpAs(&buf, data, obj, ctx); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != expected {
+		t.Fatalf("Unexpected dump.\n Expected:\n%s\n Got:\n%s", expected, buf.String())
+	}
+}
+
+func TestDumpAs(t *testing.T) {
+	type Item struct {
+		Flag bool
+	}
+	type Record struct {
+		Id      int
+		Name    string      `asn1:"optional,utf8"`
+		Version int         `asn1:"tag:0,default:1"`
+		Color   int         `asn1:"enum:color"`
+		Value   interface{} `asn1:"choice:value"`
+		Items   []Item      `asn1:"tag:2,explicit"`
+	}
+	ctx := NewContext()
+	ctx.AddEnum("color", []Enum{{Name: "red", Value: 0}, {Name: "green", Value: 1}})
+	ctx.AddChoice("value", []Choice{
+		{reflect.TypeOf(""), "tag:1,explicit,utf8,name:text"},
+		{reflect.TypeOf(int(0)), "tag:3"},
+	})
+	data, err := ctx.Encode(Record{Id: 7, Version: 2, Color: 1, Value: "abc", Items: []Item{{true}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	testDumpAs(t, ctx, data, &Record{}, ""+
+		"    0  2   25: Record: SEQUENCE {\n"+
+		"    2  2    1:   Record.Id: INTEGER 7\n"+
+		"             :   Record.Name (absent)\n"+
+		"    5  2    1:   Record.Version: [0] INTEGER 2\n"+
+		"    8  2    1:   Record.Color: ENUMERATED green (1)\n"+
+		"   11  2    5:   Record.Value (text): [1] {\n"+
+		"   13  2    3:     UTF8String 'abc'\n"+
+		"             :   }\n"+
+		"   18  2    7:   Record.Items: [2] {\n"+
+		"   20  2    5:     SEQUENCE {\n"+
+		"   22  2    3:       Record.Items[0]: SEQUENCE {\n"+
+		"   24  2    1:         Record.Items[0].Flag: BOOLEAN TRUE\n"+
+		"             :       }\n"+
+		"             :     }\n"+
+		"             :   }\n"+
+		"             : }\n")
+
+	data, err = ctx.Encode(Record{Id: 7, Version: 1, Value: 5, Items: []Item{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	testDumpAs(t, ctx, data, &Record{}, ""+
+		"    0  2   13: Record: SEQUENCE {\n"+
+		"    2  2    1:   Record.Id: INTEGER 7\n"+
+		"             :   Record.Name (absent)\n"+
+		"             :   Record.Version (defaulted to 1)\n"+
+		"    5  2    1:   Record.Color: ENUMERATED red (0)\n"+
+		"    8  2    1:   Record.Value (INTEGER): [3] INTEGER 5\n"+
+		"   11  2    2:   Record.Items: [2] {\n"+
+		"   13  2    0:     SEQUENCE {\n"+
+		"             :     }\n"+
+		"             :   }\n"+
+		"             : }\n")
+
+	// Elements that do not match the type
+	testDumpAs(t, ctx, data, Item{}, ""+
+		"    0  2   13: Item: SEQUENCE {\n"+
+		"             :   Item.Flag (missing)\n"+
+		"    2  2    1:   (unknown): INTEGER 7\n"+
+		"    5  2    1:   (unknown): ENUMERATED 0\n"+
+		"    8  2    1:   (unknown): [3] 05\n"+
+		"   11  2    2:   (unknown): [2] {\n"+
+		"   13  2    0:     SEQUENCE {\n"+
+		"             :     }\n"+
+		"             :   }\n"+
+		"             : }\n")
+}
@@ -1,6 +1,8 @@
 package asn1
 
 import (
+	"bytes"
+	"fmt"
 	"reflect"
 	"sort"
 	"unicode"
@@ -46,7 +48,10 @@ func (ctx *Context) encode(value reflect.Value, opts *fieldOptions) (*rawValue,
 	// If a value is missing the default value is used
 	empty := isEmpty(value)
 	if opts.defaultValue != nil {
-		if empty && !ctx.der.encoding {
+		if ctx.rules.encoding.OmitDefault() && isAbsent(value, opts) {
+			return nil, nil
+		}
+		if empty {
 			defaultValue, err := ctx.newDefaultValue(value.Type(), opts)
 			if err != nil {
 				return nil, err
@@ -56,8 +61,17 @@ func (ctx *Context) encode(value reflect.Value, opts *fieldOptions) (*rawValue,
 		}
 	}
 
-	// Encode data
-	raw, err := ctx.encodeValue(value, opts)
+	// Encode data. The content of choices depends on the options of the
+	// chosen alternative, like "set" or "elem".
+	valueOpts := opts
+	if opts.choice != nil && value.IsValid() {
+		entry, err := ctx.getChoiceByType(*opts.choice, value.Type())
+		if err != nil {
+			return nil, err
+		}
+		valueOpts = entry.opts
+	}
+	raw, err := ctx.encodeValue(value, valueOpts)
 	if err != nil {
 		return nil, err
 	}
@@ -80,6 +94,8 @@ func (ctx *Context) encodeValue(value reflect.Value, opts *fieldOptions) (raw *r
 
 	raw = &rawValue{}
 	encoder := encoderFunction(nil)
+	// Constructed types are encoded from the raw values of their children
+	children := (func(reflect.Value) ([]*rawValue, error))(nil)
 
 	// Special types:
 	objType := value.Type()
@@ -120,9 +136,9 @@ func (ctx *Context) encodeValue(value reflect.Value, opts *fieldOptions) (raw *r
 		case reflect.Struct:
 			raw.Tag = tagSequence
 			raw.Constructed = true
-			encoder = ctx.encodeStruct
+			children = ctx.encodeStruct
 			if opts.set {
-				encoder = ctx.encodeStructAsSet
+				children = ctx.encodeStructAsSet
 			}
 
 		case reflect.Array, reflect.Slice:
@@ -132,11 +148,33 @@ func (ctx *Context) encodeValue(value reflect.Value, opts *fieldOptions) (raw *r
 			} else {
 				raw.Tag = tagSequence
 				raw.Constructed = true
-				encoder = ctx.encodeSlice
+				children = func(value reflect.Value) ([]*rawValue, error) {
+					return ctx.encodeSlice(value, opts)
+				}
+				if opts.set {
+					children = func(value reflect.Value) ([]*rawValue, error) {
+						return ctx.encodeSliceAsSet(value, opts)
+					}
+				}
 			}
 		}
 	}
 
+	if ctx.trace {
+		raw.trace = &rawTrace{}
+	}
+	if children != nil {
+		values, err := children(value)
+		if err != nil {
+			return nil, err
+		}
+		var entries Trace
+		raw.Content, entries, err = joinRawValues(values)
+		if ctx.trace {
+			raw.trace.children = entries
+		}
+		return raw, err
+	}
 	if encoder == nil {
 		return nil, syntaxError("invalid Go type: %s", value.Type())
 	}
@@ -149,12 +187,38 @@ func (ctx *Context) applyOptions(value reflect.Value, raw *rawValue, opts *field
 
 	// Change sequence to set
 	if opts.set {
-		if raw.Class != classUniversal || raw.Tag != tagSequence {
+		if raw.Class != ClassUniversal || raw.Tag != tagSequence {
 			return nil, syntaxError("Go type '%s' does not accept the flag 'set'", value.Type())
 		}
 		raw.Tag = tagSet
 	}
 
+	// Change integer to enumerated
+	if opts.enum != nil {
+		if raw.Class != ClassUniversal || raw.Tag != tagInteger {
+			return nil, syntaxError("Go type '%s' does not accept the flag 'enum'", value.Type())
+		}
+		raw.Tag = tagEnumerated
+	}
+
+	// Change octet string to a character string type
+	if opts.stringTag != nil {
+		if value.Kind() != reflect.String {
+			return nil, syntaxError("Go type '%s' does not accept string types", value.Type())
+		}
+		raw.Tag = uint(*opts.stringTag)
+	}
+
+	// Split long strings in segments
+	if size := ctx.rules.encoding.SegmentSize(); size > 0 {
+		if raw.Class == ClassUniversal && !raw.Constructed &&
+			isStringTag(raw.Tag) && len(raw.Content) > size {
+			trace := raw.trace
+			raw = segmentString(raw, size)
+			raw.trace = trace
+		}
+	}
+
 	// Check if this type is an Asn.1 choice
 	if opts.choice != nil {
 		entry, err := ctx.getChoiceByType(*opts.choice, value.Type())
@@ -162,8 +226,15 @@ func (ctx *Context) applyOptions(value reflect.Value, raw *rawValue, opts *field
 			return nil, err
 		}
 		raw, err = ctx.applyOptions(value, raw, entry.opts)
+		if err != nil {
+			return nil, err
+		}
 		raw.Class = entry.class
 		raw.Tag = entry.tag
+		if raw.trace != nil {
+			raw.trace.entry.Choice = ctx.getChoiceIdentifier(entry)
+			raw.trace.entry.Options = entry.options
+		}
 	}
 
 	// Add an enclosing tag
@@ -173,26 +244,32 @@ func (ctx *Context) applyOptions(value reflect.Value, raw *rawValue, opts *field
 				"invalid flag 'explicit' without tag on Go type '%s'",
 				value.Type())
 		}
+		raw.Indefinite = raw.Constructed && ctx.rules.encoding.IndefiniteLength(raw.Indefinite)
 		content, err := raw.encode()
 		if err != nil {
 			return nil, err
 		}
+		inner := raw
 		raw = &rawValue{}
 		raw.Constructed = true
 		raw.Content = content
+		if inner.trace != nil {
+			// The inner element has no entry of its own
+			raw.trace = &rawTrace{children: inner.getTrace(0)[1:]}
+		}
 	}
 
 	// Change tag
 	if opts.tag != nil {
-		raw.Class = classContextSpecific
+		raw.Class = ClassContextSpecific
 		raw.Tag = uint(*opts.tag)
 	}
 	// Change class
 	if opts.universal {
-		raw.Class = classUniversal
+		raw.Class = ClassUniversal
 	}
 	if opts.application {
-		raw.Class = classApplication
+		raw.Class = ClassApplication
 	}
 
 	// Use the indefinite length encoding
@@ -202,8 +279,9 @@ func (ctx *Context) applyOptions(value reflect.Value, raw *rawValue, opts *field
 				"invalid flag 'indefinite' on Go type: %s",
 				value.Type())
 		}
-		raw.Indefinite = true
 	}
+	raw.Indefinite = raw.Constructed &&
+		ctx.rules.encoding.IndefiniteLength(opts.indefinite || raw.Indefinite)
 
 	return raw, nil
 }
@@ -242,6 +320,9 @@ func (ctx *Context) getRawValuesFromFields(value reflect.Value) ([]*rawValue, er
 			if err != nil {
 				return nil, err
 			}
+			if raw != nil && raw.trace != nil {
+				raw.trace.setField("."+fieldStruct.Name, tag)
+			}
 			children = append(children, raw)
 		}
 	}
@@ -250,52 +331,98 @@ func (ctx *Context) getRawValuesFromFields(value reflect.Value) ([]*rawValue, er
 
 // encodeRawValues is a helper function to encode raw value in sequence.
 func (ctx *Context) encodeRawValues(values ...*rawValue) ([]byte, error) {
-	content := []byte{}
-	for _, raw := range values {
-		buf, err := raw.encode()
-		if err != nil {
-			return nil, err
-		}
-		content = append(content, buf...)
-	}
-	return content, nil
+	content, _, err := joinRawValues(values)
+	return content, err
 }
 
-// encodeStruct encodes structs fields in order.
-func (ctx *Context) encodeStruct(value reflect.Value) ([]byte, error) {
-	// Encode each child to a raw value
-	children, err := ctx.getRawValuesFromFields(value)
-	if err != nil {
-		return nil, err
-	}
-	return ctx.encodeRawValues(children...)
+// encodeStruct returns the raw values of the struct fields in order.
+func (ctx *Context) encodeStruct(value reflect.Value) ([]*rawValue, error) {
+	return ctx.getRawValuesFromFields(value)
 }
 
-// encodeStructAsSet works similarly to encodeStruct, but in Der mode the
-// fields are encoded in ascending order of their tags.
-func (ctx *Context) encodeStructAsSet(value reflect.Value) ([]byte, error) {
+// encodeStructAsSet works similarly to encodeStruct, but the fields are
+// sorted in ascending order of their tags if the encoding rules require it.
+func (ctx *Context) encodeStructAsSet(value reflect.Value) ([]*rawValue, error) {
 	// Encode each child to a raw value
 	children, err := ctx.getRawValuesFromFields(value)
 	if err != nil {
 		return nil, err
 	}
 	// Sort if necessary
-	if ctx.der.encoding {
+	if ctx.rules.encoding.SortSet() {
 		sort.Sort(rawValueSlice(children))
 	}
-	return ctx.encodeRawValues(children...)
+	return children, nil
 }
 
-// encodeSlice encodes a slice or array as a sequence of values.
-func (ctx *Context) encodeSlice(value reflect.Value) ([]byte, error) {
-	content := []byte{}
+// encodeSlice returns the raw values of the elements of a slice or array,
+// using the element options of opts.
+func (ctx *Context) encodeSlice(value reflect.Value, opts *fieldOptions) ([]*rawValue, error) {
+	children := []*rawValue{}
 	for i := 0; i < value.Len(); i++ {
-		itemValue := value.Index(i)
-		childBytes, err := ctx.EncodeWithOptions(itemValue.Interface(), "")
+		elemOpts := opts.elemOptions()
+		raw, err := ctx.encode(value.Index(i), elemOpts)
 		if err != nil {
 			return nil, err
 		}
-		content = append(content, childBytes...)
+		if raw != nil && raw.trace != nil {
+			raw.trace.setField(fmt.Sprintf("[%d]", i), elemOpts.String())
+		}
+		children = append(children, raw)
+	}
+	return children, nil
+}
+
+// encodeSliceAsSet works similarly to encodeSlice, but the elements are
+// sorted in ascending order of their encodings if the encoding rules require
+// it.
+func (ctx *Context) encodeSliceAsSet(value reflect.Value, opts *fieldOptions) ([]*rawValue, error) {
+	children, err := ctx.encodeSlice(value, opts)
+	if err != nil || !ctx.rules.encoding.SortSet() {
+		return children, err
+	}
+	encodings := make(map[*rawValue][]byte, len(children))
+	for _, raw := range children {
+		if encodings[raw], err = raw.encode(); err != nil {
+			return nil, err
+		}
+	}
+	sort.SliceStable(children, func(i, j int) bool {
+		return bytes.Compare(encodings[children[i]], encodings[children[j]]) < 0
+	})
+	return children, nil
+}
+
+// segmentString converts a primitive string into the constructed form, with
+// segments of up to size octets.
+func segmentString(raw *rawValue, size int) *rawValue {
+	segmented := &rawValue{Class: raw.Class, Tag: raw.Tag, Constructed: true}
+	segmentTag := uint(tagOctetString)
+	data, unused := raw.Content, byte(0)
+	if raw.Tag == tagBitString {
+		// Each segment has its own unused bits octet
+		segmentTag = tagBitString
+		data, unused = raw.Content[1:], raw.Content[0]
+		size--
+	}
+	for len(data) > 0 {
+		n := size
+		if n > len(data) {
+			n = len(data)
+		}
+		segment := &rawValue{Class: ClassUniversal, Tag: segmentTag}
+		if segmentTag == tagBitString {
+			// Only the last segment may have unused bits
+			bits := byte(0)
+			if n == len(data) {
+				bits = unused
+			}
+			segment.Content = append(segment.Content, bits)
+		}
+		segment.Content = append(segment.Content, data[:n]...)
+		buf, _ := segment.encode()
+		segmented.Content = append(segmented.Content, buf...)
+		data = data[n:]
 	}
-	return content, nil
+	return segmented
 }
@@ -11,7 +11,7 @@ func Example() {
 	ctx := asn1.NewContext()
 
 	// Use BER for encoding and decoding.
-	ctx.SetDer(false, false)
+	ctx.SetRules(asn1.BER, asn1.BER)
 
 	// Add a CHOICE
 	ctx.AddChoice("value", []asn1.Choice{
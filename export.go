@@ -0,0 +1,507 @@
+package asn1
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// ExportModule returns an ASN.1 module, in the notation defined by X.680, with
+// the definitions of the given Go types. The types are described the same way
+// they are encoded, using their struct tags and the choices and enums
+// registered in ctx:
+//
+//	type Record struct {
+//		Id    int         `asn1:"range:0..100"`
+//		Name  string      `asn1:"tag:0,optional,utf8"`
+//		Body  interface{} `asn1:"choice:Body"`
+//		Items []int       `asn1:"set"`
+//	}
+//	module, err := ctx.ExportModule("Records", reflect.TypeOf(Record{}))
+//
+// Produces:
+//
+//	Records DEFINITIONS IMPLICIT TAGS ::= BEGIN
+//
+//	Record ::= SEQUENCE {
+//	    id INTEGER (0..100),
+//	    name [0] UTF8String OPTIONAL,
+//	    body Body,
+//	    items SET OF INTEGER
+//	}
+//
+//	Body ::= CHOICE {
+//	    ...
+//	}
+//
+//	END
+//
+// Each given type is written as a type assignment named after the Go type.
+// Named struct types used by them, registered choices and enums are also
+// written as type assignments, while other types are written in place. Go
+// strings without a string type option are written as OCTET STRING and
+// interfaces that are not choices as ANY. Identifiers are converted as in
+// FormatValueNotation(), and the names of types start with an upper case
+// letter.
+//
+// An error is returned if a type cannot be represented, such as maps and
+// floats, or if its tags are not valid in ASN.1, like an implicit tag over a
+// CHOICE.
+//
+func (ctx *Context) ExportModule(name string, types ...reflect.Type) ([]byte, error) {
+	e := &exporter{ctx: ctx, structs: map[reflect.Type]*Schema{}, names: map[string]interface{}{}}
+	for _, t := range types {
+		s, err := e.getTypeSchema(t)
+		if err != nil {
+			return nil, err
+		}
+		if s.Name == "" {
+			return nil, syntaxError("Go type '%s' has no name", t)
+		}
+	}
+	visited := make(map[*Schema]bool)
+	for _, s := range e.schemas {
+		if err := checkSchema(s, visited); err != nil {
+			return nil, err
+		}
+	}
+	return formatModule(name, e.schemas)
+}
+
+// exporter converts Go types to schemas.
+type exporter struct {
+	ctx *Context
+	// Named schemas in the order they were created
+	schemas []*Schema
+	// Schemas of the named struct types
+	structs map[reflect.Type]*Schema
+	// Go types, choices and enums by the name of their schema
+	names map[string]interface{}
+}
+
+// addName registers a named schema, returning an error if its name is already
+// used by another definition.
+func (e *exporter) addName(name string, key interface{}, s *Schema) error {
+	if current, ok := e.names[name]; ok && current != key {
+		return syntaxError("name '%s' is used by more than one type", name)
+	}
+	e.names[name] = key
+	e.schemas = append(e.schemas, s)
+	return nil
+}
+
+// getTypeSchema returns the schema of a Go type that is not used by a
+// component. Named Go types have named schemas.
+func (e *exporter) getTypeSchema(t reflect.Type) (*Schema, error) {
+	for t.Kind() == reflect.Ptr && t != bigIntType {
+		t = t.Elem()
+	}
+	s, _, err := e.getSchema(t, &fieldOptions{})
+	if err != nil {
+		return nil, err
+	}
+	if s.Name == "" && t.Name() != "" {
+		s.Name = getTypeReference(t.Name())
+		if err = e.addName(s.Name, t, s); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// getSchema returns the schema of a Go type and the options of the component
+// that uses it. Options that are properties of the type, such as constraints,
+// are moved to the schema.
+func (e *exporter) getSchema(t reflect.Type, opts *fieldOptions) (s *Schema, compOpts *fieldOptions, err error) {
+	for t.Kind() == reflect.Ptr && t != bigIntType {
+		t = t.Elem()
+	}
+	compOpts = &fieldOptions{
+		universal:    opts.universal,
+		application:  opts.application,
+		explicit:     opts.explicit,
+		indefinite:   opts.indefinite,
+		optional:     opts.optional,
+		addition:     opts.addition,
+		tag:          opts.tag,
+		defaultValue: opts.defaultValue,
+	}
+	typeOpts := &fieldOptions{
+		extensible: opts.extensible,
+		stringTag:  opts.stringTag,
+		valueRange: opts.valueRange,
+		size:       opts.size,
+	}
+	s = &Schema{}
+	switch t {
+	case bigIntType:
+		s.Kind = IntegerKind
+	case bitStringType:
+		s.Kind = BitStringKind
+	case oidType:
+		s.Kind = OidKind
+	case nullType:
+		s.Kind = NullKind
+	default:
+		s, err = e.getGenericSchema(t, opts)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if s.Name == "" {
+		s.Options = typeOpts.String()
+	} else if typeOpts.extensible {
+		s.Options = "extensible"
+	}
+	return s, compOpts, nil
+}
+
+// getGenericSchema returns the schema of a Go type based on its kind.
+func (e *exporter) getGenericSchema(t reflect.Type, opts *fieldOptions) (s *Schema, err error) {
+	s = &Schema{}
+	switch t.Kind() {
+	case reflect.Bool:
+		s.Kind = BooleanKind
+
+	case reflect.String:
+		s.Kind = StringKind
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		s.Kind = IntegerKind
+		if opts.enum != nil {
+			s, err = e.getEnumSchema(*opts.enum)
+		}
+
+	case reflect.Struct:
+		s.Kind = SequenceKind
+		if opts.set {
+			s.Kind = SetKind
+		}
+		if t.Name() != "" && t.PkgPath() != "" {
+			s, err = e.getStructSchema(t, s.Kind)
+		} else {
+			s.Components, err = e.getComponents(t)
+		}
+
+	case reflect.Array, reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			s.Kind = OctetStringKind
+			break
+		}
+		s.Kind = SequenceOfKind
+		if opts.set {
+			s.Kind = SetOfKind
+		}
+		var elemOpts *fieldOptions
+		s.Element, elemOpts, err = e.getSchema(t.Elem(), opts.elemOptions())
+		if err == nil && elemOpts.tag != nil {
+			s.Element, err = getTaggedSchema(s.Element, elemOpts)
+		}
+
+	case reflect.Interface:
+		s.Kind = AnyKind
+		if opts.choice != nil {
+			s, err = e.getChoiceSchema(*opts.choice)
+		}
+
+	default:
+		err = syntaxError("Go type '%s' cannot be exported", t)
+	}
+	return
+}
+
+// getTaggedSchema returns an anonymous copy of a schema with the tag of the
+// given options, since elements of SEQUENCE OF and SET OF have no component
+// to hold their tags.
+func getTaggedSchema(s *Schema, tag *fieldOptions) (*Schema, error) {
+	opts, err := s.options()
+	if err != nil {
+		return nil, err
+	}
+	if opts.tag != nil || s.Kind == ChoiceKind || s.Kind == AnyKind {
+		return nil, syntaxError("tagged elements of %s are not supported", s.TypeName())
+	}
+	opts.tag, opts.universal, opts.application, opts.explicit = tag.tag, tag.universal, tag.application, tag.explicit
+	tagged := *s
+	tagged.Name = ""
+	tagged.Options = opts.String()
+	return &tagged, nil
+}
+
+// getStructSchema returns the schema of a named struct type.
+func (e *exporter) getStructSchema(t reflect.Type, kind Kind) (*Schema, error) {
+	if s, ok := e.structs[t]; ok {
+		if s.Kind != kind {
+			return nil, syntaxError("Go type '%s' is used both as SEQUENCE and SET", t)
+		}
+		return s, nil
+	}
+	s := &Schema{Name: getTypeReference(t.Name()), Kind: kind}
+	if err := e.addName(s.Name, t, s); err != nil {
+		return nil, err
+	}
+	// Cached before the components to allow recursive types
+	e.structs[t] = s
+	var err error
+	s.Components, err = e.getComponents(t)
+	return s, err
+}
+
+// getComponents returns the components of a struct type.
+func (e *exporter) getComponents(t reflect.Type) ([]Component, error) {
+	fields, err := getStructFields(t)
+	if err != nil {
+		return nil, err
+	}
+	components := []Component{}
+	for _, field := range fields {
+		s, opts, err := e.getSchema(field.Type, field.opts)
+		if err != nil {
+			return nil, err
+		}
+		components = append(components, Component{
+			Name:    getIdentifier(getFieldIdentifier(field)),
+			Schema:  s,
+			Options: opts.String(),
+		})
+	}
+	return components, nil
+}
+
+// getChoiceSchema returns the schema of a registered choice.
+func (e *exporter) getChoiceSchema(choice string) (*Schema, error) {
+	name := getTypeReference(choice)
+	if key, ok := e.names[name]; ok && key == "choice:"+choice {
+		return e.schemaByName(name), nil
+	}
+	entries, err := e.ctx.getChoices(choice)
+	if err != nil {
+		return nil, err
+	}
+	s := &Schema{Name: name, Kind: ChoiceKind}
+	if err = e.addName(name, "choice:"+choice, s); err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		alt, opts, err := e.getSchema(entry.typ, entry.opts)
+		if err != nil {
+			return nil, err
+		}
+		s.Components = append(s.Components, Component{
+			Name:    getIdentifier(e.ctx.getChoiceIdentifier(entry)),
+			Schema:  alt,
+			Options: opts.String(),
+		})
+		if entry.opts.addition {
+			s.Options = "extensible"
+		}
+	}
+	return s, nil
+}
+
+// getEnumSchema returns the schema of a registered enum.
+func (e *exporter) getEnumSchema(enum string) (*Schema, error) {
+	name := getTypeReference(enum)
+	if key, ok := e.names[name]; ok && key == "enum:"+enum {
+		return e.schemaByName(name), nil
+	}
+	root, additions, err := e.ctx.getEnumValues(enum)
+	if err != nil {
+		return nil, err
+	}
+	s := &Schema{Name: name, Kind: EnumeratedKind, Enum: append(root, additions...)}
+	if len(additions) > 0 {
+		s.Options = "extensible"
+	}
+	return s, e.addName(name, "enum:"+enum, s)
+}
+
+// schemaByName returns a schema already created.
+func (e *exporter) schemaByName(name string) *Schema {
+	for _, s := range e.schemas {
+		if s.Name == name {
+			return s
+		}
+	}
+	return nil
+}
+
+// getTypeReference converts a name to an ASN.1 type reference, which starts
+// with an upper case letter and has only letters, digits and single hyphens.
+func getTypeReference(name string) string {
+	runes := []rune(name)
+	for i, r := range runes {
+		if r > unicode.MaxASCII || !(unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			runes[i] = '-'
+		}
+	}
+	name = strings.Trim(string(runes), "-")
+	for strings.Contains(name, "--") {
+		name = strings.Replace(name, "--", "-", -1)
+	}
+	if name == "" || !unicode.IsLetter(rune(name[0])) {
+		name = "T" + name
+	}
+	return strings.ToUpper(name[:1]) + name[1:]
+}
+
+/*
+ * Module notation
+ */
+
+// formatModule writes the type assignments of the given schemas as an ASN.1
+// module.
+func formatModule(name string, schemas []*Schema) ([]byte, error) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%s DEFINITIONS IMPLICIT TAGS ::= BEGIN\n\n", getTypeReference(name))
+	for _, s := range schemas {
+		text, err := formatSchema(s, "")
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(&buf, "%s ::= %s\n\n", s.Name, text)
+	}
+	buf.WriteString("END\n")
+	return buf.Bytes(), nil
+}
+
+// formatTag returns the tag of a type in the ASN.1 notation, like
+// "[APPLICATION 1] EXPLICIT ", or an empty string if no tag is given.
+func formatTag(opts *fieldOptions) string {
+	if opts.tag == nil {
+		return ""
+	}
+	class := ""
+	if opts.universal {
+		class = "UNIVERSAL "
+	} else if opts.application {
+		class = "APPLICATION "
+	}
+	tag := fmt.Sprintf("[%s%d] ", class, *opts.tag)
+	if opts.explicit {
+		tag += "EXPLICIT "
+	}
+	return tag
+}
+
+// formatSchema returns the definition of a schema in the ASN.1 notation. The
+// lines of constructed types are prefixed with indent.
+func formatSchema(s *Schema, indent string) (string, error) {
+	opts, err := s.options()
+	if err != nil {
+		return "", err
+	}
+	text := formatTag(opts)
+	switch s.Kind {
+	case StringKind:
+		if opts.stringTag != nil {
+			text += universalNames[uint(*opts.stringTag)]
+		} else {
+			text += OctetStringKind.String()
+		}
+
+	case EnumeratedKind:
+		values := []string{}
+		extended := false
+		for _, e := range s.Enum {
+			if e.Addition && !extended {
+				values = append(values, "...")
+				extended = true
+			}
+			values = append(values, fmt.Sprintf("%s(%d)", e.Name, e.Value))
+		}
+		if opts.extensible && !extended {
+			values = append(values, "...")
+		}
+		text += "ENUMERATED { " + strings.Join(values, ", ") + " }"
+
+	case SequenceKind, SetKind, ChoiceKind:
+		components, err := formatComponents(s, opts.extensible, indent+"    ")
+		if err != nil {
+			return "", err
+		}
+		text += s.Kind.String() + " {" + components + "}"
+
+	case SequenceOfKind, SetOfKind:
+		element, err := formatReference(s.Element, indent)
+		if err != nil {
+			return "", err
+		}
+		kind := strings.TrimSuffix(s.Kind.String(), " OF")
+		if opts.size != nil {
+			kind += " (SIZE (" + formatBounds(opts.size, opts.extensible) + "))"
+		}
+		return text + kind + " OF " + element, nil
+
+	default:
+		text += s.Kind.String()
+	}
+	if s.Kind == IntegerKind && opts.valueRange != nil {
+		text += " (" + formatBounds(opts.valueRange, opts.extensible) + ")"
+	}
+	if opts.size != nil {
+		text += " (SIZE (" + formatBounds(opts.size, opts.extensible) + "))"
+	}
+	return text, nil
+}
+
+// formatReference returns the name of a named schema or the definition of an
+// anonymous one.
+func formatReference(s *Schema, indent string) (string, error) {
+	if s.Name != "" {
+		return s.Name, nil
+	}
+	return formatSchema(s, indent)
+}
+
+// formatComponents returns the components of a SEQUENCE, SET or CHOICE, one
+// per line. Extension additions are written after the extension marker.
+func formatComponents(s *Schema, extensible bool, indent string) (string, error) {
+	var root, additions []string
+	for _, c := range s.Components {
+		opts, err := c.options()
+		if err != nil {
+			return "", err
+		}
+		text, err := formatReference(c.Schema, indent)
+		if err != nil {
+			return "", err
+		}
+		text = c.Name + " " + formatTag(opts) + text
+		if opts.optional {
+			text += " OPTIONAL"
+		} else if opts.defaultValue != nil {
+			value := strconv.Itoa(*opts.defaultValue)
+			if name, ok := c.Schema.getEnumName(int64(*opts.defaultValue)); ok && c.Schema.Kind == EnumeratedKind {
+				value = name
+			}
+			text += " DEFAULT " + value
+		}
+		if opts.addition {
+			additions = append(additions, text)
+		} else {
+			root = append(root, text)
+		}
+	}
+	lines := root
+	if extensible || len(additions) > 0 {
+		lines = append(append(lines, "..."), additions...)
+	}
+	if len(lines) == 0 {
+		return "", nil
+	}
+	outer := strings.TrimSuffix(indent, "    ")
+	return "\n" + indent + strings.Join(lines, ",\n"+indent) + "\n" + outer, nil
+}
+
+// formatBounds returns the limits of a constraint in the ASN.1 notation.
+func formatBounds(b *bounds, extensible bool) string {
+	if extensible {
+		return b.String() + ", ..."
+	}
+	return b.String()
+}
@@ -0,0 +1,126 @@
+package asn1
+
+import (
+	"reflect"
+	"testing"
+)
+
+type exportItem struct {
+	Flag  bool         `asn1:"tag:0,explicit"`
+	Data  []byte       `asn1:"size:1..8,extensible"`
+	Bits  BitString    `asn1:"optional"`
+	Next  []exportItem `asn1:"tag:1,optional"`
+	Extra int          `asn1:"tag:2,optional,addition"`
+}
+
+const expectedExportModule = `Test DEFINITIONS IMPLICIT TAGS ::= BEGIN
+
+TestRecord ::= SEQUENCE {
+    id INTEGER (0..100),
+    version [0] INTEGER DEFAULT 1,
+    color Color DEFAULT green,
+    name [1] UTF8String OPTIONAL,
+    body Body,
+    items SET OF INTEGER
+}
+
+Color ::= ENUMERATED { red(0), green(1), ..., blue(2) }
+
+Body ::= CHOICE {
+    ia5String [2] IA5String,
+    octet-string [3] OCTET STRING,
+    object-identifier OBJECT IDENTIFIER,
+    ...,
+    exportItem [4] ExportItem
+}
+
+ExportItem ::= SEQUENCE {
+    flag [0] EXPLICIT BOOLEAN,
+    data OCTET STRING (SIZE (1..8, ...)),
+    bits BIT STRING OPTIONAL,
+    next [1] SEQUENCE OF ExportItem OPTIONAL,
+    ...,
+    extra [2] INTEGER OPTIONAL
+}
+
+TestTree ::= SEQUENCE {
+    label UTF8String,
+    weight [0] INTEGER DEFAULT 1,
+    children [1] SEQUENCE OF TestTree OPTIONAL
+}
+
+END
+`
+
+func TestExportModule(t *testing.T) {
+	ctx := NewContext()
+	ctx.AddEnum("Color", []Enum{{Name: "red", Value: 0}, {Name: "green", Value: 1}, {Name: "blue", Value: 2, Addition: true}})
+	ctx.AddChoice("Body", []Choice{
+		{Type: reflect.TypeOf(""), Options: "tag:2,ia5"},
+		{Type: reflect.TypeOf([]byte{}), Options: "tag:3"},
+		{Type: reflect.TypeOf(Oid{}), Options: ""},
+		{Type: reflect.TypeOf(exportItem{}), Options: "tag:4,addition"},
+	})
+	module, err := ctx.ExportModule("Test", reflect.TypeOf(testRecord{}), reflect.TypeOf(&testTree{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(module) != expectedExportModule {
+		t.Fatalf("Invalid module.\nExpected:\n%s\nGot:\n%s", expectedExportModule, module)
+	}
+
+	// The exported module describes the encoding of the Go types
+	if err = ctx.LoadModule("test.asn", module); err != nil {
+		t.Fatal(err)
+	}
+	obj := testRecord{Id: 7, Color: 2, Body: exportItem{Data: []byte{1}, Extra: 5, Next: []exportItem{{Data: []byte{2}}}}}
+	data, err := ctx.Encode(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, _, err := ctx.DecodeValue(data, "TestRecord")
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoded, err := ctx.EncodeValue(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !isBytesEqual(encoded, data) {
+		t.Fatalf("Failed to encode value.\n Expected: %#v.\n Got:      %#v", data, encoded)
+	}
+}
+
+type exportMap struct {
+	A map[string]int
+}
+
+type exportUnknownChoice struct {
+	A interface{} `asn1:"choice:other"`
+}
+
+type exportImplicitChoice struct {
+	A interface{} `asn1:"tag:0,choice:choice"`
+}
+
+type exportConflict struct {
+	A exportItem
+	B exportItem `asn1:"set"`
+}
+
+func TestExportModuleErrors(t *testing.T) {
+	ctx := NewContext()
+	ctx.AddChoice("choice", []Choice{{Type: reflect.TypeOf(0)}, {Type: reflect.TypeOf(true)}})
+	invalidTypes := []reflect.Type{
+		reflect.TypeOf(struct{ A int }{}),
+		reflect.TypeOf(exportMap{}),
+		reflect.TypeOf(exportUnknownChoice{}),
+		reflect.TypeOf(exportImplicitChoice{}),
+		reflect.TypeOf(exportConflict{}),
+	}
+	for _, typ := range invalidTypes {
+		if _, err := ctx.ExportModule("Test", typ); err == nil {
+			t.Errorf("%s: expected error", typ)
+		}
+	}
+}
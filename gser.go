@@ -0,0 +1,774 @@
+package asn1
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// EncodeGser returns the Generic String Encoding Rules (RFC 3641) encoding of
+// obj using additional options. Values are represented as follows:
+//
+//	ASN.1 type             | GSER representation
+//	-----------------------|-------------------------------------------
+//	BOOLEAN                | TRUE or FALSE
+//	INTEGER                | decimal number
+//	ENUMERATED             | identifier of the value
+//	OCTET STRING           | '0AFF'H
+//	BIT STRING             | '101'B
+//	OBJECT IDENTIFIER      | dotted numbers (1.2.840)
+//	NULL                   | NULL
+//	Character strings      | "quoted ""text"""
+//	SEQUENCE and SET       | { id1 value1, id2 value2 }
+//	SEQUENCE OF and SET OF | { value1, value2 }
+//	CHOICE                 | id:value
+//
+// Component identifiers are taken from the Go struct fields, or from the option
+// "name" when it's given. CHOICE alternatives are identified by the option
+// "name" given in (*Context).AddChoice() or by their Go type. Identifiers are
+// converted to start with a lower case letter, as required by RFC 3641, so the
+// field "SerialNumber" and the type "OCTET_STRING" are written as
+// "serialNumber" and "octet-string". Components that are omitted in BER, like
+// empty optional values and values equal to their DEFAULT, are also omitted in
+// GSER.
+func (ctx *Context) EncodeGser(obj interface{}, options string) ([]byte, error) {
+	opts, err := parseOptions(options)
+	if err != nil {
+		return nil, err
+	}
+	// Return nil if the ignore tag is given
+	if opts == nil {
+		return nil, nil
+	}
+	e := &gserEncoder{ctx: ctx}
+	if err = e.encodeValue(reflect.ValueOf(obj), opts); err != nil {
+		return nil, err
+	}
+	return e.buf.Bytes(), nil
+}
+
+// DecodeGser parses the given GSER data into obj using additional options. The
+// argument obj should be a reference to the value that will hold the parsed
+// data.
+//
+// Besides the representations produced by EncodeGser, BIT STRINGs are also
+// accepted as hexadecimal strings ('A'H) and any amount of white space is
+// accepted between tokens.
+//
+// See (*Context).EncodeGser() for further details.
+func (ctx *Context) DecodeGser(data []byte, obj interface{}, options string) (rest []byte, err error) {
+	opts, err := parseOptions(options)
+	if err != nil {
+		return nil, err
+	}
+	// Return nil if the ignore tag is given
+	if opts == nil {
+		return
+	}
+
+	value := reflect.ValueOf(obj)
+	switch value.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		value = value.Elem()
+	}
+
+	if !value.CanSet() {
+		return nil, syntaxError("go type '%s' is read-only", value.Type())
+	}
+
+	d := &gserDecoder{data: data, ctx: ctx}
+	if err = d.decodeValue(value, opts); err != nil {
+		return nil, err
+	}
+	return data[d.pos:], nil
+}
+
+// getIdentifier converts a name to an ASN.1 identifier, which starts with a
+// lower case letter and has no underscores.
+func getIdentifier(name string) string {
+	name = strings.Replace(name, "_", "-", -1)
+	if strings.ToUpper(name) == name {
+		return strings.ToLower(name)
+	}
+	runes := []rune(name)
+	// Length of the leading upper case letters and digits
+	n := 0
+	for n < len(runes) && (unicode.IsUpper(runes[n]) || unicode.IsDigit(runes[n])) {
+		n++
+	}
+	// The last upper case letter starts the next word, like in "UTF8String"
+	if n > 1 && n < len(runes) && unicode.IsLower(runes[n]) && unicode.IsUpper(runes[n-1]) {
+		n--
+	}
+	for i := 0; i < n; i++ {
+		runes[i] = unicode.ToLower(runes[i])
+	}
+	return string(runes)
+}
+
+/*
+ * Encoder
+ */
+
+type gserEncoder struct {
+	buf bytes.Buffer
+	ctx *Context
+	// Use the ASN.1 value notation instead of GSER
+	notation bool
+}
+
+// Main GSER encode function
+func (e *gserEncoder) encodeValue(value reflect.Value, opts *fieldOptions) error {
+
+	if opts.choice != nil {
+		return e.encodeChoice(value, opts)
+	}
+
+	// Skip the interface type
+	value = getActualType(value)
+	if !value.IsValid() {
+		return syntaxError("nil value cannot be encoded")
+	}
+
+	// Special types:
+	switch value.Type() {
+	case bigIntType:
+		e.buf.WriteString(getBigInt(value).String())
+		return nil
+	case bitStringType:
+		bs := value.Interface().(BitString)
+		e.buf.WriteByte('\'')
+		for i := 0; i < bs.BitLength; i++ {
+			e.buf.WriteString(strconv.Itoa(bs.At(i)))
+		}
+		e.buf.WriteString("'B")
+		return nil
+	case oidType:
+		if e.notation {
+			e.buf.WriteString("{")
+			for _, n := range value.Interface().(Oid) {
+				e.buf.WriteString(" " + strconv.FormatUint(uint64(n), 10))
+			}
+			e.buf.WriteString(" }")
+			return nil
+		}
+		e.buf.WriteString(strings.TrimPrefix(value.Interface().(Oid).String(), "."))
+		return nil
+	case nullType:
+		e.buf.WriteString("NULL")
+		return nil
+	}
+
+	// Generic types:
+	switch value.Kind() {
+	case reflect.Bool:
+		if value.Bool() {
+			e.buf.WriteString("TRUE")
+		} else {
+			e.buf.WriteString("FALSE")
+		}
+		return nil
+
+	case reflect.String:
+		if opts.stringTag == nil {
+			e.writeHex([]byte(value.String()))
+			return nil
+		}
+		e.buf.WriteByte('"')
+		e.buf.WriteString(strings.Replace(value.String(), `"`, `""`, -1))
+		e.buf.WriteByte('"')
+		return nil
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if opts.enum != nil {
+			name, err := e.ctx.getEnumName(*opts.enum, getBigInt(value))
+			if err != nil {
+				return err
+			}
+			e.buf.WriteString(name)
+			return nil
+		}
+		e.buf.WriteString(getBigInt(value).String())
+		return nil
+
+	case reflect.Struct:
+		return e.encodeStruct(value, opts)
+
+	case reflect.Array, reflect.Slice:
+		if value.Type().Elem().Kind() == reflect.Uint8 {
+			data, err := e.ctx.encodeOctetString(value)
+			if err != nil {
+				return err
+			}
+			e.writeHex(data)
+			return nil
+		}
+		return e.encodeSequenceOf(value, opts)
+	}
+	return syntaxError("invalid Go type: %s", value.Type())
+}
+
+// writeHex writes a hexadecimal string ('0AFF'H).
+func (e *gserEncoder) writeHex(data []byte) {
+	e.buf.WriteByte('\'')
+	e.buf.WriteString(strings.ToUpper(hex.EncodeToString(data)))
+	e.buf.WriteString("'H")
+}
+
+func (e *gserEncoder) encodeStruct(value reflect.Value, opts *fieldOptions) error {
+	root, additions, err := e.ctx.getComponents(value.Type(), opts.set)
+	if err != nil {
+		return err
+	}
+	e.buf.WriteString("{")
+	first := true
+	for _, field := range append(root, additions...) {
+		fieldValue := value.Field(field.Index[0])
+		if isAbsent(fieldValue, field.opts) {
+			continue
+		}
+		if !first {
+			e.buf.WriteString(",")
+		}
+		first = false
+		e.buf.WriteString(" " + getIdentifier(getFieldIdentifier(field)) + " ")
+		if err := e.encodeValue(fieldValue, field.opts); err != nil {
+			return err
+		}
+	}
+	e.buf.WriteString(" }")
+	return nil
+}
+
+func (e *gserEncoder) encodeSequenceOf(value reflect.Value, opts *fieldOptions) error {
+	e.buf.WriteString("{")
+	for i := 0; i < value.Len(); i++ {
+		if i > 0 {
+			e.buf.WriteString(",")
+		}
+		e.buf.WriteString(" ")
+		if err := e.encodeValue(value.Index(i), opts.elemOptions()); err != nil {
+			return err
+		}
+	}
+	e.buf.WriteString(" }")
+	return nil
+}
+
+func (e *gserEncoder) encodeChoice(value reflect.Value, opts *fieldOptions) error {
+	value = getActualType(value)
+	if !value.IsValid() {
+		return syntaxError("nil value for choice '%s'", *opts.choice)
+	}
+	entry, err := e.ctx.getChoiceByType(*opts.choice, value.Type())
+	if err != nil {
+		return err
+	}
+	separator := ":"
+	if e.notation {
+		separator = " : "
+	}
+	e.buf.WriteString(getIdentifier(e.ctx.getChoiceIdentifier(entry)) + separator)
+	return e.encodeValue(value, entry.opts)
+}
+
+/*
+ * Decoder
+ */
+
+type gserDecoder struct {
+	data []byte
+	pos  int
+	ctx  *Context
+	// Use the ASN.1 value notation instead of GSER
+	notation bool
+}
+
+// skipSpaces advances over white space, and over comments if the value
+// notation is used.
+func (d *gserDecoder) skipSpaces() {
+	for d.pos < len(d.data) {
+		switch {
+		case isGserSpace(d.data[d.pos]):
+			d.pos++
+		case d.notation && bytes.HasPrefix(d.data[d.pos:], []byte("--")):
+			// Comments end at the end of the line or at the next "--"
+			d.pos += 2
+			for d.pos < len(d.data) && d.data[d.pos] != '\n' {
+				if bytes.HasPrefix(d.data[d.pos:], []byte("--")) {
+					d.pos += 2
+					break
+				}
+				d.pos++
+			}
+		case d.notation && bytes.HasPrefix(d.data[d.pos:], []byte("/*")):
+			end := bytes.Index(d.data[d.pos+2:], []byte("*/"))
+			if end < 0 {
+				d.pos = len(d.data)
+			} else {
+				d.pos += end + 4
+			}
+		default:
+			return
+		}
+	}
+}
+
+// peek returns the next non white space character or zero at the end of the
+// data.
+func (d *gserDecoder) peek() byte {
+	d.skipSpaces()
+	if d.pos >= len(d.data) {
+		return 0
+	}
+	return d.data[d.pos]
+}
+
+// expect consumes the given character.
+func (d *gserDecoder) expect(c byte) error {
+	if d.peek() != c {
+		return d.error("expected '%c'", c)
+	}
+	d.pos++
+	return nil
+}
+
+// readToken reads a sequence of characters accepted by the given function.
+func (d *gserDecoder) readToken(accept func(c byte) bool) string {
+	d.skipSpaces()
+	start := d.pos
+	for d.pos < len(d.data) && accept(d.data[d.pos]) {
+		d.pos++
+	}
+	return string(d.data[start:d.pos])
+}
+
+// readIdentifier reads an identifier or a keyword.
+func (d *gserDecoder) readIdentifier() (string, error) {
+	id := d.readToken(func(c byte) bool {
+		return c == '-' || c == '_' || c >= '0' && c <= '9' ||
+			c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+	})
+	if id == "" || id[0] == '-' || id[0] >= '0' && id[0] <= '9' {
+		return "", d.error("expected identifier")
+	}
+	return id, nil
+}
+
+// readInteger reads a decimal number.
+func (d *gserDecoder) readInteger() (*big.Int, error) {
+	start := d.pos
+	s := d.readToken(func(c byte) bool {
+		return c == '-' || c >= '0' && c <= '9'
+	})
+	n, ok := new(big.Int).SetString(s, 10)
+	if !ok {
+		d.pos = start
+		return nil, d.error("expected integer")
+	}
+	return n, nil
+}
+
+// readQuoted reads a string enclosed by the given quote, where two quotes
+// represent a single one.
+func (d *gserDecoder) readQuoted(quote byte) (string, error) {
+	if err := d.expect(quote); err != nil {
+		return "", err
+	}
+	buf := bytes.Buffer{}
+	for d.pos < len(d.data) {
+		c := d.data[d.pos]
+		d.pos++
+		if c == quote {
+			if d.pos < len(d.data) && d.data[d.pos] == quote && quote == '"' {
+				buf.WriteByte(c)
+				d.pos++
+				continue
+			}
+			return buf.String(), nil
+		}
+		buf.WriteByte(c)
+	}
+	return "", d.error("unterminated string")
+}
+
+// readBinary reads a hexadecimal or binary string and returns its content and
+// its suffix ('H' or 'B').
+func (d *gserDecoder) readBinary() (string, byte, error) {
+	s, err := d.readQuoted('\'')
+	if err != nil {
+		return "", 0, err
+	}
+	if d.pos >= len(d.data) || d.data[d.pos] != 'H' && d.data[d.pos] != 'B' {
+		return "", 0, d.error("expected 'H' or 'B'")
+	}
+	d.pos++
+	return s, d.data[d.pos-1], nil
+}
+
+// error returns a parse error with the current position. The value notation
+// uses the line and column instead of the offset.
+func (d *gserDecoder) error(format string, args ...interface{}) error {
+	if d.notation {
+		line := bytes.Count(d.data[:d.pos], []byte("\n")) + 1
+		column := d.pos - bytes.LastIndexByte(d.data[:d.pos], '\n')
+		return parseError("line %d, column %d: %s", line, column, fmt.Sprintf(format, args...))
+	}
+	return parseError("offset %d: %s", d.pos, fmt.Sprintf(format, args...))
+}
+
+func isGserSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n'
+}
+
+// Main GSER decode function
+func (d *gserDecoder) decodeValue(value reflect.Value, opts *fieldOptions) error {
+
+	if opts.choice != nil {
+		return d.decodeChoice(value, opts)
+	}
+
+	// Allocate pointers
+	if value.Kind() == reflect.Ptr && value.Type() != bigIntType {
+		ptr := reflect.New(value.Type().Elem())
+		if err := d.decodeValue(ptr.Elem(), opts); err != nil {
+			return err
+		}
+		value.Set(ptr)
+		return nil
+	}
+
+	// Special types:
+	switch value.Type() {
+	case bigIntType:
+		n, err := d.readInteger()
+		if err != nil {
+			return err
+		}
+		value.Set(reflect.ValueOf(n))
+		return nil
+	case bitStringType:
+		return d.decodeBitString(value)
+	case oidType:
+		if d.notation {
+			return d.decodeOidComponents(value)
+		}
+		s := d.readToken(func(c byte) bool {
+			return c == '.' || c >= '0' && c <= '9'
+		})
+		oid, err := parseOid(s)
+		if err != nil || len(oid) == 0 {
+			return d.error("expected OBJECT IDENTIFIER")
+		}
+		value.Set(reflect.ValueOf(oid))
+		return nil
+	case nullType:
+		if id, err := d.readIdentifier(); err != nil || id != "NULL" {
+			return d.error("expected NULL")
+		}
+		return nil
+	}
+
+	// Generic types:
+	switch value.Kind() {
+	case reflect.Bool:
+		id, err := d.readIdentifier()
+		if err != nil {
+			return err
+		}
+		switch id {
+		case "TRUE":
+			value.SetBool(true)
+		case "FALSE":
+			value.SetBool(false)
+		default:
+			return d.error("invalid BOOLEAN value '%s'", id)
+		}
+		return nil
+
+	case reflect.String:
+		if opts.stringTag == nil {
+			data, err := d.readOctetString()
+			if err != nil {
+				return err
+			}
+			value.SetString(string(data))
+			return nil
+		}
+		s, err := d.readQuoted('"')
+		if err != nil {
+			return err
+		}
+		value.SetString(s)
+		return nil
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if opts.enum != nil {
+			id, err := d.readIdentifier()
+			if err != nil {
+				return err
+			}
+			n, err := d.ctx.getEnumValue(*opts.enum, id)
+			if err != nil {
+				return err
+			}
+			return setBigInt(value, n)
+		}
+		n, err := d.readInteger()
+		if err != nil {
+			return err
+		}
+		return setBigInt(value, n)
+
+	case reflect.Struct:
+		return d.decodeStruct(value, opts)
+
+	case reflect.Array, reflect.Slice:
+		if value.Type().Elem().Kind() == reflect.Uint8 {
+			data, err := d.readOctetString()
+			if err != nil {
+				return err
+			}
+			return d.ctx.decodeOctetString(data, value)
+		}
+		return d.decodeSequenceOf(value, opts)
+	}
+	return syntaxError("invalid Go type: %s", value.Type())
+}
+
+// readOctetString reads an OCTET STRING in hexadecimal form.
+func (d *gserDecoder) readOctetString() ([]byte, error) {
+	s, suffix, err := d.readBinary()
+	if err != nil {
+		return nil, err
+	}
+	if suffix != 'H' {
+		return nil, d.error("expected hexadecimal string")
+	}
+	data, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, d.error("invalid hexadecimal string: %s", err)
+	}
+	return data, nil
+}
+
+func (d *gserDecoder) decodeBitString(value reflect.Value) error {
+	s, suffix, err := d.readBinary()
+	if err != nil {
+		return err
+	}
+	var bs BitString
+	if suffix == 'H' {
+		if len(s)%2 != 0 {
+			s += "0"
+			bs.BitLength = -4
+		}
+		if bs.Bytes, err = hex.DecodeString(s); err != nil {
+			return d.error("invalid hexadecimal string: %s", err)
+		}
+		bs.BitLength += len(bs.Bytes) * 8
+	} else {
+		bs = BitString{Bytes: make([]byte, (len(s)+7)/8), BitLength: len(s)}
+		for i := 0; i < len(s); i++ {
+			switch s[i] {
+			case '1':
+				bs.Bytes[i/8] |= 0x80 >> uint(i%8)
+			case '0':
+			default:
+				return d.error("invalid binary string")
+			}
+		}
+	}
+	value.Set(reflect.ValueOf(bs))
+	return nil
+}
+
+func (d *gserDecoder) decodeStruct(value reflect.Value, opts *fieldOptions) error {
+	fields, err := getStructFields(value.Type())
+	if err != nil {
+		return err
+	}
+	if err = d.expect('{'); err != nil {
+		return err
+	}
+	found := make([]bool, len(fields))
+	for first := true; d.peek() != '}'; first = false {
+		if !first {
+			if err = d.expect(','); err != nil {
+				return err
+			}
+		}
+		id, err := d.readIdentifier()
+		if err != nil {
+			return err
+		}
+		index := -1
+		for i, field := range fields {
+			if !found[i] && getIdentifier(getFieldIdentifier(field)) == id {
+				index = i
+				break
+			}
+		}
+		if index < 0 {
+			if !opts.extensible {
+				return d.error("unexpected component '%s'", id)
+			}
+			if err = d.skipValue(); err != nil {
+				return err
+			}
+		} else {
+			field := fields[index]
+			if err = d.decodeValue(value.Field(field.Index[0]), field.opts); err != nil {
+				return err
+			}
+			found[index] = true
+		}
+	}
+	d.pos++
+	for i, field := range fields {
+		if found[i] {
+			continue
+		}
+		if field.opts.defaultValue != nil {
+			if err := d.ctx.setDefaultValue(value.Field(field.Index[0]), field.opts); err != nil {
+				return err
+			}
+			continue
+		}
+		if !field.opts.optional && !field.opts.addition {
+			return d.error("missing component '%s'", getIdentifier(getFieldIdentifier(field)))
+		}
+	}
+	return nil
+}
+
+func (d *gserDecoder) decodeSequenceOf(value reflect.Value, opts *fieldOptions) error {
+	if err := d.expect('{'); err != nil {
+		return err
+	}
+	elemType := value.Type().Elem()
+	slice := reflect.MakeSlice(reflect.SliceOf(elemType), 0, 0)
+	for d.peek() != '}' {
+		if slice.Len() > 0 {
+			if err := d.expect(','); err != nil {
+				return err
+			}
+		}
+		item := reflect.New(elemType).Elem()
+		if err := d.decodeValue(item, opts.elemOptions()); err != nil {
+			return err
+		}
+		slice = reflect.Append(slice, item)
+	}
+	d.pos++
+	if value.Kind() == reflect.Slice {
+		value.Set(slice)
+		return nil
+	}
+	if slice.Len() != value.Len() {
+		return d.error("expected %d elements but found %d", value.Len(), slice.Len())
+	}
+	reflect.Copy(value, slice)
+	return nil
+}
+
+func (d *gserDecoder) decodeChoice(value reflect.Value, opts *fieldOptions) error {
+	id, err := d.readIdentifier()
+	if err != nil {
+		return err
+	}
+	if err = d.expect(':'); err != nil {
+		return err
+	}
+	entries, err := d.ctx.getChoices(*opts.choice)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if getIdentifier(d.ctx.getChoiceIdentifier(entry)) == id {
+			nestedValue := reflect.New(entry.typ).Elem()
+			if err := d.decodeValue(nestedValue, entry.opts); err != nil {
+				return err
+			}
+			value.Set(nestedValue)
+			return nil
+		}
+	}
+	return d.error("invalid alternative '%s' for choice '%s'", id, *opts.choice)
+}
+
+// decodeOidComponents decodes an OBJECT IDENTIFIER in the value notation, like
+// "{ 1 2 840 }" or "{ iso(1) member-body(2) 840 }".
+func (d *gserDecoder) decodeOidComponents(value reflect.Value) error {
+	if err := d.expect('{'); err != nil {
+		return err
+	}
+	oid := Oid{}
+	for d.peek() != '}' {
+		c := d.peek()
+		if c < '0' || c > '9' {
+			// Name form, which requires the number
+			if _, err := d.readIdentifier(); err != nil {
+				return err
+			}
+			if err := d.expect('('); err != nil {
+				return err
+			}
+		}
+		n, err := d.readInteger()
+		if err != nil || !n.IsUint64() || n.Uint64() > uint64(^uint(0)) {
+			return d.error("invalid OBJECT IDENTIFIER component")
+		}
+		if c < '0' || c > '9' {
+			if err := d.expect(')'); err != nil {
+				return err
+			}
+		}
+		oid = append(oid, uint(n.Uint64()))
+	}
+	d.pos++
+	if len(oid) == 0 {
+		return d.error("expected OBJECT IDENTIFIER")
+	}
+	value.Set(reflect.ValueOf(oid))
+	return nil
+}
+
+// skipValue advances over a value of unknown type, stopping before the ','
+// or '}' that ends it.
+func (d *gserDecoder) skipValue() error {
+	depth := 0
+	for {
+		switch c := d.peek(); c {
+		case 0:
+			return d.error("unexpected end of data")
+		case '"', '\'':
+			if _, err := d.readQuoted(c); err != nil {
+				return err
+			}
+		case '{':
+			depth++
+			d.pos++
+		case '}':
+			if depth == 0 {
+				return nil
+			}
+			depth--
+			d.pos++
+		case ',':
+			if depth == 0 {
+				return nil
+			}
+			d.pos++
+		default:
+			d.pos++
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package asn1
+
+import (
+	"reflect"
+	"testing"
+)
+
+// testGser encodes an object, compares with the expected text and then decodes
+// the result back.
+func testGser(t *testing.T, ctx *Context, options string, value interface{}, expected string) {
+	data, err := ctx.EncodeGser(value, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != expected {
+		t.Fatalf("Failed to encode \"%v\".\n Expected: %s.\n Got:      %s", value, expected, data)
+	}
+	decoded := reflect.New(reflect.TypeOf(value))
+	rest, err := ctx.DecodeGser(data, decoded.Interface(), options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rest) > 0 {
+		t.Fatalf("Unexpected remaining bytes when decoding \"%v\": %q\n", value, rest)
+	}
+	checkEqual(t, decoded.Elem().Interface(), value)
+}
+
+func TestGserSimpleTypes(t *testing.T) {
+	ctx := NewContext()
+	testGser(t, ctx, "", 10, `10`)
+	testGser(t, ctx, "", -3, `-3`)
+	testGser(t, ctx, "", true, `TRUE`)
+	testGser(t, ctx, "", Null{}, `NULL`)
+	testGser(t, ctx, "", []byte{0x0a, 0xff}, `'0AFF'H`)
+	testGser(t, ctx, "utf8", `say "hi"`, `"say ""hi"""`)
+	testGser(t, ctx, "", Oid{2, 5, 4, 3}, `2.5.4.3`)
+	testGser(t, ctx, "", BitString{[]byte{0xa0}, 3}, `'101'B`)
+	testGser(t, ctx, "", []int{1, 2, 3}, `{ 1, 2, 3 }`)
+	testGser(t, ctx, "", []int{}, `{ }`)
+
+	// Hexadecimal BIT STRING
+	bs := BitString{}
+	if _, err := ctx.DecodeGser([]byte(`'A'H`), &bs, ""); err != nil {
+		t.Fatal(err)
+	}
+	checkEqual(t, bs, BitString{[]byte{0xa0}, 4})
+}
+
+func TestGserSequence(t *testing.T) {
+	type Name struct {
+		Type  Oid    `asn1:"name:type"`
+		Value string `asn1:"printable,name:value"`
+	}
+	type Entry struct {
+		Names   []Name `asn1:"name:names"`
+		Version int    `asn1:"name:version,default:1"`
+		Flag    bool   `asn1:"name:flag,optional"`
+	}
+	ctx := NewContext()
+	testGser(t, ctx, "", Entry{[]Name{{Oid{2, 5, 4, 3}, "Bob"}}, 2, true},
+		`{ names { { type 2.5.4.3, value "Bob" } }, version 2, flag TRUE }`)
+	testGser(t, ctx, "", Entry{[]Name{}, 1, false}, `{ names { } }`)
+
+	// Extra white space and unknown components
+	entry := Entry{}
+	data := []byte("{names{},\n  other { x 1, y \"}\" } ,flag   FALSE}")
+	if _, err := ctx.DecodeGser(data, &entry, ""); err == nil {
+		t.Fatal("Decoding an unknown component should have failed.")
+	}
+	if _, err := ctx.DecodeGser(data, &entry, "extensible"); err != nil {
+		t.Fatal(err)
+	}
+	checkEqual(t, entry, Entry{[]Name{}, 1, false})
+
+	// Missing mandatory component
+	if _, err := ctx.DecodeGser([]byte(`{ version 2 }`), &entry, ""); err == nil {
+		t.Fatal("Decoding a missing component should have failed.")
+	}
+}
+
+func TestGserChoiceEnum(t *testing.T) {
+	type Color int
+	type Msg struct {
+		Color Color       `asn1:"enum:color"`
+		Value interface{} `asn1:"choice:value"`
+	}
+	ctx := NewContext()
+	ctx.AddEnum("color", []Enum{
+		{Name: "red", Value: 0},
+		{Name: "green", Value: 1},
+	})
+	ctx.AddChoice("value", []Choice{
+		{reflect.TypeOf(""), "tag:1,utf8,name:text"},
+		{reflect.TypeOf(int(0)), "tag:0,name:number"},
+		{reflect.TypeOf([]byte{}), "tag:2"},
+	})
+	testGser(t, ctx, "", Msg{1, "hi"}, `{ color green, value text:"hi" }`)
+	testGser(t, ctx, "", Msg{0, 5}, `{ color red, value number:5 }`)
+	testGser(t, ctx, "", Msg{0, []byte{1}}, `{ color red, value octet-string:'01'H }`)
+}
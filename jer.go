@@ -0,0 +1,519 @@
+package asn1
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// EncodeJer returns the JSON Encoding Rules (X.697) encoding of obj using
+// additional options. Values are represented as follows:
+//
+//	ASN.1 type             | JER representation
+//	-----------------------|-------------------------------------------
+//	BOOLEAN                | true or false
+//	INTEGER                | number
+//	ENUMERATED             | string with the name of the value
+//	OCTET STRING           | string with hexadecimal digits
+//	BIT STRING             | {"value": "A0", "length": 3}
+//	BIT STRING (SIZE(n))   | string with hexadecimal digits
+//	OBJECT IDENTIFIER      | string with dotted numbers ("1.2.840")
+//	NULL                   | null
+//	Character strings      | string
+//	SEQUENCE and SET       | object with one member per component
+//	SEQUENCE OF and SET OF | array
+//	CHOICE                 | object with the chosen alternative as member
+//
+// Members are named after the Go struct fields, or after the option "name"
+// when it's given. CHOICE alternatives are named after the option "name" given
+// in (*Context).AddChoice() or after their Go type. Components that are
+// omitted in BER, like empty optional values and values equal to their DEFAULT,
+// are also omitted in JER.
+func (ctx *Context) EncodeJer(obj interface{}, options string) ([]byte, error) {
+	opts, err := parseOptions(options)
+	if err != nil {
+		return nil, err
+	}
+	// Return nil if the ignore tag is given
+	if opts == nil {
+		return nil, nil
+	}
+	e := &jerEncoder{ctx: ctx}
+	if err = e.encodeValue(reflect.ValueOf(obj), opts); err != nil {
+		return nil, err
+	}
+	return e.buf.Bytes(), nil
+}
+
+// DecodeJer parses the given JER data into obj using additional options. The
+// argument obj should be a reference to the value that will hold the parsed
+// data.
+//
+// See (*Context).EncodeJer() for further details.
+func (ctx *Context) DecodeJer(data []byte, obj interface{}, options string) (rest []byte, err error) {
+	opts, err := parseOptions(options)
+	if err != nil {
+		return nil, err
+	}
+	// Return nil if the ignore tag is given
+	if opts == nil {
+		return
+	}
+
+	value := reflect.ValueOf(obj)
+	switch value.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		value = value.Elem()
+	}
+
+	if !value.CanSet() {
+		return nil, syntaxError("go type '%s' is read-only", value.Type())
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var jsonValue interface{}
+	if err = decoder.Decode(&jsonValue); err != nil {
+		return nil, parseError("invalid JSON: %s", err)
+	}
+	if err = ctx.decodeJer(jsonValue, value, opts); err != nil {
+		return nil, err
+	}
+	return data[decoder.InputOffset():], nil
+}
+
+/*
+ * Encoder
+ */
+
+type jerEncoder struct {
+	buf bytes.Buffer
+	ctx *Context
+}
+
+// writeString writes a quoted JSON string.
+func (e *jerEncoder) writeString(s string) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return syntaxError("invalid string: %s", err)
+	}
+	e.buf.Write(data)
+	return nil
+}
+
+// Main JER encode function
+func (e *jerEncoder) encodeValue(value reflect.Value, opts *fieldOptions) error {
+
+	if opts.choice != nil {
+		return e.encodeChoice(value, opts)
+	}
+
+	// Skip the interface type
+	value = getActualType(value)
+	if !value.IsValid() {
+		return syntaxError("nil value cannot be encoded")
+	}
+
+	// Special types:
+	switch value.Type() {
+	case bigIntType:
+		e.buf.WriteString(getBigInt(value).String())
+		return nil
+	case bitStringType:
+		bs := value.Interface().(BitString)
+		if len(bs.Bytes) < (bs.BitLength+7)/8 {
+			return syntaxError("invalid BIT STRING length: %d", bs.BitLength)
+		}
+		digits := strings.ToUpper(hex.EncodeToString(getBitStringBytes(bs)))
+		if opts.size.fixed() {
+			return e.writeString(digits)
+		}
+		e.buf.WriteString(`{"value":`)
+		e.writeString(digits)
+		e.buf.WriteString(`,"length":`)
+		e.buf.WriteString(strconv.Itoa(bs.BitLength))
+		e.buf.WriteString("}")
+		return nil
+	case oidType:
+		return e.writeString(strings.TrimPrefix(value.Interface().(Oid).String(), "."))
+	case nullType:
+		e.buf.WriteString("null")
+		return nil
+	}
+
+	// Generic types:
+	switch value.Kind() {
+	case reflect.Bool:
+		if value.Bool() {
+			e.buf.WriteString("true")
+		} else {
+			e.buf.WriteString("false")
+		}
+		return nil
+
+	case reflect.String:
+		if opts.stringTag == nil {
+			return e.writeString(strings.ToUpper(hex.EncodeToString([]byte(value.String()))))
+		}
+		return e.writeString(value.String())
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if opts.enum != nil {
+			name, err := e.ctx.getEnumName(*opts.enum, getBigInt(value))
+			if err != nil {
+				return err
+			}
+			return e.writeString(name)
+		}
+		e.buf.WriteString(getBigInt(value).String())
+		return nil
+
+	case reflect.Struct:
+		return e.encodeStruct(value, opts)
+
+	case reflect.Array, reflect.Slice:
+		if value.Type().Elem().Kind() == reflect.Uint8 {
+			data, err := e.ctx.encodeOctetString(value)
+			if err != nil {
+				return err
+			}
+			return e.writeString(strings.ToUpper(hex.EncodeToString(data)))
+		}
+		return e.encodeSequenceOf(value, opts)
+	}
+	return syntaxError("invalid Go type: %s", value.Type())
+}
+
+func (e *jerEncoder) encodeStruct(value reflect.Value, opts *fieldOptions) error {
+	root, additions, err := e.ctx.getComponents(value.Type(), opts.set)
+	if err != nil {
+		return err
+	}
+	e.buf.WriteString("{")
+	first := true
+	for _, field := range append(root, additions...) {
+		fieldValue := value.Field(field.Index[0])
+		if isAbsent(fieldValue, field.opts) {
+			continue
+		}
+		if !first {
+			e.buf.WriteString(",")
+		}
+		first = false
+		if err := e.writeString(getFieldIdentifier(field)); err != nil {
+			return err
+		}
+		e.buf.WriteString(":")
+		if err := e.encodeValue(fieldValue, field.opts); err != nil {
+			return err
+		}
+	}
+	e.buf.WriteString("}")
+	return nil
+}
+
+func (e *jerEncoder) encodeSequenceOf(value reflect.Value, opts *fieldOptions) error {
+	e.buf.WriteString("[")
+	for i := 0; i < value.Len(); i++ {
+		if i > 0 {
+			e.buf.WriteString(",")
+		}
+		if err := e.encodeValue(value.Index(i), opts.elemOptions()); err != nil {
+			return err
+		}
+	}
+	e.buf.WriteString("]")
+	return nil
+}
+
+func (e *jerEncoder) encodeChoice(value reflect.Value, opts *fieldOptions) error {
+	value = getActualType(value)
+	if !value.IsValid() {
+		return syntaxError("nil value for choice '%s'", *opts.choice)
+	}
+	entry, err := e.ctx.getChoiceByType(*opts.choice, value.Type())
+	if err != nil {
+		return err
+	}
+	e.buf.WriteString("{")
+	if err = e.writeString(e.ctx.getChoiceIdentifier(entry)); err != nil {
+		return err
+	}
+	e.buf.WriteString(":")
+	if err = e.encodeValue(value, entry.opts); err != nil {
+		return err
+	}
+	e.buf.WriteString("}")
+	return nil
+}
+
+/*
+ * Decoder
+ */
+
+// Main JER decode function. The given JSON value is the result of
+// encoding/json with numbers decoded as json.Number.
+func (ctx *Context) decodeJer(jsonValue interface{}, value reflect.Value, opts *fieldOptions) error {
+
+	if opts.choice != nil {
+		return ctx.decodeJerChoice(jsonValue, value, opts)
+	}
+
+	// Allocate pointers
+	if value.Kind() == reflect.Ptr && value.Type() != bigIntType {
+		ptr := reflect.New(value.Type().Elem())
+		if err := ctx.decodeJer(jsonValue, ptr.Elem(), opts); err != nil {
+			return err
+		}
+		value.Set(ptr)
+		return nil
+	}
+
+	// Special types:
+	switch value.Type() {
+	case bigIntType:
+		n, err := getJerInteger(jsonValue)
+		if err != nil {
+			return err
+		}
+		value.Set(reflect.ValueOf(n))
+		return nil
+	case bitStringType:
+		return decodeJerBitString(jsonValue, value, opts)
+	case oidType:
+		s, ok := jsonValue.(string)
+		if !ok {
+			return jerTypeError("OBJECT IDENTIFIER", jsonValue)
+		}
+		oid, err := parseOid(s)
+		if err != nil {
+			return err
+		}
+		value.Set(reflect.ValueOf(oid))
+		return nil
+	case nullType:
+		if jsonValue != nil {
+			return jerTypeError("NULL", jsonValue)
+		}
+		return nil
+	}
+
+	// Generic types:
+	switch value.Kind() {
+	case reflect.Bool:
+		b, ok := jsonValue.(bool)
+		if !ok {
+			return jerTypeError("BOOLEAN", jsonValue)
+		}
+		value.SetBool(b)
+		return nil
+
+	case reflect.String:
+		s, ok := jsonValue.(string)
+		if !ok {
+			return jerTypeError("string", jsonValue)
+		}
+		if opts.stringTag == nil {
+			data, err := decodeJerHex(s)
+			if err != nil {
+				return err
+			}
+			s = string(data)
+		}
+		value.SetString(s)
+		return nil
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if opts.enum != nil {
+			name, ok := jsonValue.(string)
+			if !ok {
+				return jerTypeError("ENUMERATED", jsonValue)
+			}
+			n, err := ctx.getEnumValue(*opts.enum, name)
+			if err != nil {
+				return err
+			}
+			return setBigInt(value, n)
+		}
+		n, err := getJerInteger(jsonValue)
+		if err != nil {
+			return err
+		}
+		return setBigInt(value, n)
+
+	case reflect.Struct:
+		return ctx.decodeJerStruct(jsonValue, value, opts)
+
+	case reflect.Array, reflect.Slice:
+		if value.Type().Elem().Kind() == reflect.Uint8 {
+			s, ok := jsonValue.(string)
+			if !ok {
+				return jerTypeError("OCTET STRING", jsonValue)
+			}
+			data, err := decodeJerHex(s)
+			if err != nil {
+				return err
+			}
+			return ctx.decodeOctetString(data, value)
+		}
+		return ctx.decodeJerSequenceOf(jsonValue, value, opts)
+	}
+	return syntaxError("invalid Go type: %s", value.Type())
+}
+
+func (ctx *Context) decodeJerStruct(jsonValue interface{}, value reflect.Value, opts *fieldOptions) error {
+	members, ok := jsonValue.(map[string]interface{})
+	if !ok {
+		return jerTypeError("object", jsonValue)
+	}
+	fields, err := getStructFields(value.Type())
+	if err != nil {
+		return err
+	}
+	known := make(map[string]bool)
+	for _, field := range fields {
+		name := getFieldIdentifier(field)
+		known[name] = true
+		member, ok := members[name]
+		if !ok {
+			if field.opts.defaultValue != nil {
+				if err := ctx.setDefaultValue(value.Field(field.Index[0]), field.opts); err != nil {
+					return err
+				}
+			} else if !field.opts.optional && !field.opts.addition {
+				return parseError("missing member '%s'", name)
+			}
+			continue
+		}
+		if err := ctx.decodeJer(member, value.Field(field.Index[0]), field.opts); err != nil {
+			return err
+		}
+	}
+	if !opts.extensible {
+		for name := range members {
+			if !known[name] {
+				return parseError("unexpected member '%s'", name)
+			}
+		}
+	}
+	return nil
+}
+
+func (ctx *Context) decodeJerSequenceOf(jsonValue interface{}, value reflect.Value, opts *fieldOptions) error {
+	items, ok := jsonValue.([]interface{})
+	if !ok {
+		return jerTypeError("array", jsonValue)
+	}
+	elemType := value.Type().Elem()
+	slice := reflect.MakeSlice(reflect.SliceOf(elemType), len(items), len(items))
+	for i, item := range items {
+		if err := ctx.decodeJer(item, slice.Index(i), opts.elemOptions()); err != nil {
+			return err
+		}
+	}
+	if value.Kind() == reflect.Slice {
+		value.Set(slice)
+		return nil
+	}
+	if slice.Len() != value.Len() {
+		return parseError("expected %d elements but found %d", value.Len(), slice.Len())
+	}
+	reflect.Copy(value, slice)
+	return nil
+}
+
+func (ctx *Context) decodeJerChoice(jsonValue interface{}, value reflect.Value, opts *fieldOptions) error {
+	members, ok := jsonValue.(map[string]interface{})
+	if !ok || len(members) != 1 {
+		return parseError("expected one alternative for choice '%s'", *opts.choice)
+	}
+	entries, err := ctx.getChoices(*opts.choice)
+	if err != nil {
+		return err
+	}
+	for name, member := range members {
+		for _, entry := range entries {
+			if ctx.getChoiceIdentifier(entry) == name {
+				nestedValue := reflect.New(entry.typ).Elem()
+				if err := ctx.decodeJer(member, nestedValue, entry.opts); err != nil {
+					return err
+				}
+				value.Set(nestedValue)
+				return nil
+			}
+		}
+		return parseError("invalid alternative '%s' for choice '%s'", name, *opts.choice)
+	}
+	return nil
+}
+
+// getJerInteger converts a JSON number to an integer.
+func getJerInteger(jsonValue interface{}) (*big.Int, error) {
+	number, ok := jsonValue.(json.Number)
+	if !ok {
+		return nil, jerTypeError("INTEGER", jsonValue)
+	}
+	n, ok := new(big.Int).SetString(number.String(), 10)
+	if !ok {
+		return nil, parseError("invalid integer value: %s", number)
+	}
+	return n, nil
+}
+
+func decodeJerHex(s string) ([]byte, error) {
+	data, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, parseError("invalid hexadecimal value: %s", err)
+	}
+	return data, nil
+}
+
+func decodeJerBitString(jsonValue interface{}, value reflect.Value, opts *fieldOptions) error {
+	var digits string
+	length := -1
+	switch v := jsonValue.(type) {
+	case string:
+		if !opts.size.fixed() {
+			return parseError("BIT STRING without fixed size requires value and length")
+		}
+		digits, length = v, int(*opts.size.lower)
+	case map[string]interface{}:
+		s, ok := v["value"].(string)
+		if !ok {
+			return jerTypeError("BIT STRING value", v["value"])
+		}
+		n, err := getJerInteger(v["length"])
+		if err != nil {
+			return err
+		}
+		if !n.IsInt64() || len(v) != 2 {
+			return parseError("invalid BIT STRING")
+		}
+		digits, length = s, int(n.Int64())
+	default:
+		return jerTypeError("BIT STRING", jsonValue)
+	}
+	data, err := decodeJerHex(digits)
+	if err != nil {
+		return err
+	}
+	if length < 0 || len(data) != (length+7)/8 {
+		return parseError("invalid BIT STRING length: %d", length)
+	}
+	if length%8 != 0 && data[len(data)-1]&(0xff>>uint(length%8)) != 0 {
+		return parseError("invalid padding bits in BIT STRING")
+	}
+	value.Set(reflect.ValueOf(BitString{Bytes: data, BitLength: length}))
+	return nil
+}
+
+// jerTypeError returns an error for a JSON value of an unexpected type.
+func jerTypeError(expected string, jsonValue interface{}) error {
+	return parseError("expected JSON %s but found %T", expected, jsonValue)
+}
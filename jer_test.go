@@ -0,0 +1,150 @@
+package asn1
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+)
+
+// testJer encodes an object, compares with the expected JSON and then decodes
+// the result back.
+func testJer(t *testing.T, ctx *Context, options string, value interface{}, expected string) {
+	data, err := ctx.EncodeJer(value, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != expected {
+		t.Fatalf("Failed to encode \"%v\".\n Expected: %s.\n Got:      %s", value, expected, data)
+	}
+	decoded := reflect.New(reflect.TypeOf(value))
+	rest, err := ctx.DecodeJer(data, decoded.Interface(), options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rest) > 0 {
+		t.Fatalf("Unexpected remaining bytes when decoding \"%v\": %q\n", value, rest)
+	}
+	checkEqual(t, decoded.Elem().Interface(), value)
+}
+
+func TestJerSimpleTypes(t *testing.T) {
+	ctx := NewContext()
+	testJer(t, ctx, "", 10, `10`)
+	testJer(t, ctx, "", -3, `-3`)
+	n, _ := new(big.Int).SetString("123456789012345678901234567890", 10)
+	testJer(t, ctx, "", n, `123456789012345678901234567890`)
+	testJer(t, ctx, "", true, `true`)
+	testJer(t, ctx, "", Null{}, `null`)
+	testJer(t, ctx, "", []byte{0x0a, 0xff}, `"0AFF"`)
+	testJer(t, ctx, "utf8", "ação \"x\"", `"ação \"x\""`)
+	testJer(t, ctx, "", Oid{1, 2, 840, 113549}, `"1.2.840.113549"`)
+	testJer(t, ctx, "", BitString{[]byte{0xa0}, 3}, `{"value":"A0","length":3}`)
+	testJer(t, ctx, "size:8", BitString{[]byte{0x5a}, 8}, `"5A"`)
+	testJer(t, ctx, "", []int{1, 2, 3}, `[1,2,3]`)
+}
+
+func TestJerBitStringPadding(t *testing.T) {
+	ctx := NewContext()
+	data, err := ctx.EncodeJer(BitString{[]byte{0xff}, 3}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"value":"E0","length":3}` {
+		t.Fatalf("Unexpected encoding: %s", data)
+	}
+	decoded := BitString{}
+	if _, err = ctx.DecodeJer(data, &decoded, ""); err != nil {
+		t.Fatal(err)
+	}
+	checkEqual(t, decoded, BitString{[]byte{0xe0}, 3})
+}
+
+func TestJerSequence(t *testing.T) {
+	type Cert struct {
+		Serial  int    `asn1:"name:serialNumber"`
+		Version int    `asn1:"default:1"`
+		Issuer  string `asn1:"printable,optional"`
+		Algo    Oid
+	}
+	ctx := NewContext()
+	testJer(t, ctx, "", Cert{10, 1, "", Oid{1, 2}}, `{"serialNumber":10,"Algo":"1.2"}`)
+	testJer(t, ctx, "", Cert{10, 2, "CA", Oid{1, 2}},
+		`{"serialNumber":10,"Version":2,"Issuer":"CA","Algo":"1.2"}`)
+
+	// Member order is not relevant
+	cert := Cert{}
+	data := []byte(`{"Algo":"1.2.3","serialNumber":5}`)
+	if _, err := ctx.DecodeJer(data, &cert, ""); err != nil {
+		t.Fatal(err)
+	}
+	checkEqual(t, cert, Cert{5, 1, "", Oid{1, 2, 3}})
+
+	// Unknown members are only accepted by extensible types
+	data = []byte(`{"Algo":"1.2.3","serialNumber":5,"extra":null}`)
+	if _, err := ctx.DecodeJer(data, &cert, ""); err == nil {
+		t.Fatal("Decoding an unknown member should have failed.")
+	}
+	if _, err := ctx.DecodeJer(data, &cert, "extensible"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Missing mandatory member
+	if _, err := ctx.DecodeJer([]byte(`{"serialNumber":5}`), &cert, ""); err == nil {
+		t.Fatal("Decoding a missing member should have failed.")
+	}
+}
+
+func TestJerChoiceEnum(t *testing.T) {
+	type Color int
+	type Msg struct {
+		Color Color       `asn1:"enum:color"`
+		Value interface{} `asn1:"choice:value"`
+	}
+	ctx := NewContext()
+	ctx.AddEnum("color", []Enum{
+		{Name: "red", Value: 0},
+		{Name: "green", Value: 1},
+	})
+	ctx.AddChoice("value", []Choice{
+		{reflect.TypeOf(""), "tag:1,utf8,name:text"},
+		{reflect.TypeOf(int(0)), "tag:0,name:number"},
+	})
+	testJer(t, ctx, "", Msg{1, "hi"}, `{"Color":"green","Value":{"text":"hi"}}`)
+	testJer(t, ctx, "", Msg{0, 5}, `{"Color":"red","Value":{"number":5}}`)
+
+	msg := Msg{}
+	data := []byte(`{"Color":"blue","Value":{"number":5}}`)
+	if _, err := ctx.DecodeJer(data, &msg, ""); err == nil {
+		t.Fatal("Decoding an invalid enumerated value should have failed.")
+	}
+}
+
+func TestJerBerRoundTrip(t *testing.T) {
+	type Entry struct {
+		Id    int
+		Name  string `asn1:"ia5"`
+		Flags BitString
+		Data  []byte `asn1:"optional"`
+	}
+	ctx := NewContext()
+	entry := Entry{7, "test", BitString{[]byte{0x80}, 1}, []byte{1, 2}}
+	ber, err := ctx.Encode(entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jer, err := ctx.EncodeJer(entry, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded := Entry{}
+	if _, err = ctx.DecodeJer(jer, &decoded, ""); err != nil {
+		t.Fatal(err)
+	}
+	ber2, err := ctx.Encode(decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !isBytesEqual(ber, ber2) {
+		t.Fatalf("BER differs after JER round trip.\n Expected: %#v.\n Got:      %#v", ber, ber2)
+	}
+}
@@ -0,0 +1,607 @@
+package asn1
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/PromonLogicalis/asn1/syntax"
+)
+
+// moduleStringTypes are the string type options of character strings.
+var moduleStringTypes = map[string]string{
+	"UTF8String":      "utf8",
+	"NumericString":   "numeric",
+	"PrintableString": "printable",
+	"IA5String":       "ia5",
+	"VisibleString":   "visible",
+	"ISO646String":    "visible",
+}
+
+// moduleUniversalTags are the universal tags of the character strings and time
+// types without a string type option.
+var moduleUniversalTags = map[string]int{
+	"ObjectDescriptor": 7,
+	"TeletexString":    tagT61String,
+	"T61String":        tagT61String,
+	"VideotexString":   21,
+	"UTCTime":          tagUtcTime,
+	"GeneralizedTime":  tagGeneralizedTime,
+	"GraphicString":    25,
+	"GeneralString":    27,
+	"UniversalString":  28,
+	"BMPString":        30,
+}
+
+// LoadModule parses the ASN.1 modules of a source file, written in the
+// notation of X.680, and registers a schema for each of their type
+// assignments, named after it. The filename is only used in error messages.
+//
+// Referenced types are looked up among the modules in src and then among the
+// schemas already registered, so the modules imported by a file must be loaded
+// first.
+//
+// Built-in types are mapped to the kinds of the same name. Character strings
+// and time types, like UTCTime, are mapped to StringKind with their string
+// type option or universal tag. Tags, including AUTOMATIC TAGS, size and
+// value constraints, extension markers and additions are converted to options.
+// Just like struct tags, only INTEGER and ENUMERATED components can have a
+// DEFAULT value, so other components with a DEFAULT value are made optional.
+//
+// Types like REAL and information object classes are not supported, nor are
+// PRIVATE tags and explicit tags over other tags of the same type assignment,
+// like "[1] EXPLICIT [2] INTEGER", although components may have an explicit tag
+// over a tagged type.
+//
+// Errors are returned as *syntax.Error, with the position of the invalid
+// construction.
+func (ctx *Context) LoadModule(filename string, src []byte) error {
+	modules, err := syntax.Parse(filename, src)
+	if err != nil {
+		return err
+	}
+	l := &moduleLoader{
+		ctx:      ctx,
+		filename: filename,
+		types:    map[string]typeAssignment{},
+		values:   map[string]valueAssignment{},
+		schemas:  map[string]*Schema{},
+		loading:  map[string]bool{},
+	}
+	order := []string{}
+	for _, m := range modules {
+		for _, a := range m.Assignments {
+			switch a := a.(type) {
+			case *syntax.TypeAssignment:
+				if _, found := l.types[a.Name]; found || ctx.schemas[a.Name] != nil {
+					return l.errorf(a.Pos, "type %s is already defined", a.Name)
+				}
+				l.types[a.Name] = typeAssignment{a, m}
+				order = append(order, a.Name)
+			case *syntax.ValueAssignment:
+				l.values[a.Name] = valueAssignment{a, m}
+			}
+		}
+	}
+	for _, name := range order {
+		def := l.types[name]
+		if _, err := l.getSchema(name, def.assignment.Pos, def.module); err != nil {
+			return err
+		}
+	}
+	// Nothing is registered if any type is invalid
+	for _, name := range order {
+		if err := checkSchema(l.schemas[name], map[*Schema]bool{}); err != nil {
+			return l.errorf(l.types[name].assignment.Pos, "%s", err)
+		}
+	}
+	for _, name := range order {
+		ctx.schemas[name] = l.schemas[name]
+	}
+	return nil
+}
+
+// typeAssignment is a type assignment and its module.
+type typeAssignment struct {
+	assignment *syntax.TypeAssignment
+	module     *syntax.Module
+}
+
+// valueAssignment is a value assignment and its module.
+type valueAssignment struct {
+	assignment *syntax.ValueAssignment
+	module     *syntax.Module
+}
+
+// moduleLoader converts the type assignments of modules to schemas.
+type moduleLoader struct {
+	ctx      *Context
+	filename string
+	types    map[string]typeAssignment
+	values   map[string]valueAssignment
+	// Schemas of the type assignments
+	schemas map[string]*Schema
+	// Type assignments being converted
+	loading map[string]bool
+}
+
+// errorf returns a *syntax.Error at a position.
+func (l *moduleLoader) errorf(pos syntax.Pos, format string, args ...interface{}) error {
+	return &syntax.Error{Filename: l.filename, Pos: pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+// getSchema returns the schema of a type assignment, which is converted the
+// first time it's requested. Schemas registered in the Context are used for
+// types not defined by the loaded modules. The schema of a type being
+// converted is returned before it's complete, which allows recursive types.
+func (l *moduleLoader) getSchema(name string, pos syntax.Pos, m *syntax.Module) (*Schema, error) {
+	if s := l.schemas[name]; s != nil {
+		return s, nil
+	}
+	def, found := l.types[name]
+	if !found {
+		if s := l.ctx.schemas[name]; s != nil {
+			return s, nil
+		}
+		return nil, l.errorf(pos, "undefined type %s", name)
+	}
+	s := &Schema{Name: name}
+	l.schemas[name] = s
+	l.loading[name] = true
+	defer delete(l.loading, name)
+	return s, l.convert(def.assignment.Type, def.module, s)
+}
+
+// convert sets a schema to the description of a type.
+func (l *moduleLoader) convert(typ syntax.Type, m *syntax.Module, s *Schema) error {
+	switch t := typ.(type) {
+	case *syntax.TaggedType:
+		if err := l.convert(t.Type, m, s); err != nil {
+			return err
+		}
+		opts, _ := s.options()
+		choice := (s.Kind == ChoiceKind || s.Kind == AnyKind) && opts.tag == nil
+		tag, err := l.getTag(t, m, choice)
+		if err != nil {
+			return err
+		}
+		if tag.explicit && opts.tag != nil {
+			return l.errorf(t.Pos, "explicit tags over tagged types are not supported")
+		}
+		// Implicit tags replace the existing tag, which remains explicit if
+		// it was explicit
+		opts.tag, opts.universal, opts.application = tag.tag, tag.universal, tag.application
+		opts.explicit = tag.explicit || opts.explicit
+		s.Options = opts.String()
+		return nil
+
+	case *syntax.ConstrainedType:
+		if err := l.convert(t.Type, m, s); err != nil {
+			return err
+		}
+		return l.applyConstraint(s, t.Constraint, m)
+
+	case *syntax.ReferencedType:
+		// The schema of the referenced type is copied, so it must be complete
+		if l.loading[t.Name] {
+			return l.errorf(t.Pos, "type %s cannot be tagged, constrained or renamed inside its own definition", t.Name)
+		}
+		ref, err := l.getSchema(t.Name, t.Pos, m)
+		if err != nil {
+			return err
+		}
+		name := s.Name
+		*s = *ref
+		s.Name = name
+		return nil
+	}
+	return l.convertBuiltin(typ, m, s)
+}
+
+// getTag returns the options of a tag. Tags of untagged CHOICE and ANY types,
+// given by the flag choice, are always explicit.
+func (l *moduleLoader) getTag(t *syntax.TaggedType, m *syntax.Module, choice bool) (*fieldOptions, error) {
+	if t.Class == syntax.Private {
+		return nil, l.errorf(t.Pos, "PRIVATE tags are not supported")
+	}
+	n, err := l.getInt(t.Number, m, 0)
+	if err != nil {
+		return nil, err
+	}
+	tag := int(n.Int64())
+	return &fieldOptions{
+		tag:         &tag,
+		universal:   t.Class == syntax.Universal,
+		application: t.Class == syntax.Application,
+		explicit:    t.Mode == syntax.ExplicitTags || choice,
+	}, nil
+}
+
+// convertBuiltin sets a schema to the description of a built-in type.
+func (l *moduleLoader) convertBuiltin(typ syntax.Type, m *syntax.Module, s *Schema) error {
+	opts := &fieldOptions{}
+	switch t := typ.(type) {
+	case *syntax.BuiltinType:
+		switch {
+		case t.Name == "BOOLEAN":
+			s.Kind = BooleanKind
+		case t.Name == "NULL":
+			s.Kind = NullKind
+		case t.Name == "OCTET STRING":
+			s.Kind = OctetStringKind
+		case t.Name == "OBJECT IDENTIFIER":
+			s.Kind = OidKind
+		case moduleStringTypes[t.Name] != "":
+			s.Kind = StringKind
+			opts.stringTag, _ = parseStringTypeOption([]string{moduleStringTypes[t.Name]})
+		case moduleUniversalTags[t.Name] != 0:
+			s.Kind = StringKind
+			tag := moduleUniversalTags[t.Name]
+			opts.tag, opts.universal = &tag, true
+		default:
+			return l.errorf(t.Pos, "type %s is not supported", t.Name)
+		}
+
+	case *syntax.IntegerType:
+		s.Kind = IntegerKind
+
+	case *syntax.BitStringType:
+		s.Kind = BitStringKind
+
+	case *syntax.EnumeratedType:
+		s.Kind = EnumeratedKind
+		opts.extensible = t.Extensible || m.ExtensibilityImplied
+		var err error
+		if s.Enum, err = l.getEnum(t, m); err != nil {
+			return err
+		}
+
+	case *syntax.SequenceType:
+		s.Kind = SequenceKind
+		if t.Set {
+			s.Kind = SetKind
+		}
+		opts.extensible = t.Extensible || m.ExtensibilityImplied
+		var err error
+		if s.Components, err = l.getComponents(t.Components, t.Additions, m, true); err != nil {
+			return err
+		}
+
+	case *syntax.ChoiceType:
+		s.Kind = ChoiceKind
+		opts.extensible = t.Extensible || m.ExtensibilityImplied
+		var err error
+		if s.Components, err = l.getComponents(t.Alternatives, t.Additions, m, false); err != nil {
+			return err
+		}
+
+	case *syntax.SequenceOfType:
+		s.Kind = SequenceOfKind
+		if t.Set {
+			s.Kind = SetOfKind
+		}
+		var err error
+		if s.Element, err = l.getInnerSchema(t.Element, m); err != nil {
+			return err
+		}
+		if t.Constraint != nil {
+			s.Options = opts.String()
+			return l.applyConstraint(s, t.Constraint, m)
+		}
+
+	case *syntax.AnyType:
+		s.Kind = AnyKind
+	}
+	s.Options = opts.String()
+	return nil
+}
+
+// getInnerSchema returns the schema of a component or element type. Referenced
+// types share the schema of their assignment.
+func (l *moduleLoader) getInnerSchema(typ syntax.Type, m *syntax.Module) (*Schema, error) {
+	if t, ok := typ.(*syntax.ReferencedType); ok {
+		return l.getSchema(t.Name, t.Pos, m)
+	}
+	s := &Schema{}
+	return s, l.convert(typ, m, s)
+}
+
+// getComponents returns the components of a SEQUENCE or SET, or the
+// alternatives of a CHOICE, with "COMPONENTS OF" replaced by the root
+// components of the referenced type.
+func (l *moduleLoader) getComponents(components, additions []*syntax.Component, m *syntax.Module, sequence bool) ([]Component, error) {
+	all := []*syntax.Component{}
+	automatic := m.TagDefault == syntax.AutomaticTags
+	for i, c := range append(append([]*syntax.Component{}, components...), additions...) {
+		if !c.ComponentsOf {
+			if _, tagged := c.Type.(*syntax.TaggedType); tagged {
+				automatic = false
+			}
+			all = append(all, c)
+			continue
+		}
+		base, _, err := l.getBaseType(c.Type, m)
+		if err != nil {
+			return nil, err
+		}
+		seq, ok := base.(*syntax.SequenceType)
+		if !ok || i >= len(components) {
+			return nil, l.errorf(c.Pos, "invalid COMPONENTS OF")
+		}
+		all = append(all, seq.Components...)
+	}
+
+	result := []Component{}
+	root := len(all) - len(additions)
+	for i, c := range all {
+		opts, s, err := l.getComponentType(c, m)
+		if err != nil {
+			return nil, err
+		}
+		if automatic {
+			tag := i
+			opts.tag = &tag
+			opts.explicit, err = l.isChoice(c.Type, m)
+			if err != nil {
+				return nil, err
+			}
+		}
+		switch {
+		case c.Optional:
+			opts.optional = true
+		case c.Default != nil:
+			if n, err := l.getDefault(c.Default, c.Type, m); err == nil {
+				value := int(n.Int64())
+				opts.defaultValue = &value
+			} else {
+				opts.optional = true
+			}
+		}
+		opts.addition = i >= root
+		result = append(result, Component{Name: c.Name, Schema: s, Options: opts.String()})
+	}
+	return result, nil
+}
+
+// getComponentType returns the options and the schema of a component. The
+// outermost tag of a component is kept in its options, so it may be explicit
+// over a tagged type.
+func (l *moduleLoader) getComponentType(c *syntax.Component, m *syntax.Module) (*fieldOptions, *Schema, error) {
+	t, ok := c.Type.(*syntax.TaggedType)
+	if !ok {
+		s, err := l.getInnerSchema(c.Type, m)
+		return &fieldOptions{}, s, err
+	}
+	s, err := l.getInnerSchema(t.Type, m)
+	if err != nil {
+		return nil, nil, err
+	}
+	choice, err := l.isChoice(t.Type, m)
+	if err != nil {
+		return nil, nil, err
+	}
+	opts, err := l.getTag(t, m, choice)
+	return opts, s, err
+}
+
+// isChoice checks if a type is an untagged CHOICE or ANY, which can only have
+// explicit tags. The syntax is used, since the schema of a referenced type may
+// not be complete yet.
+func (l *moduleLoader) isChoice(typ syntax.Type, m *syntax.Module) (bool, error) {
+	for depth := 0; depth < 100; depth++ {
+		switch t := typ.(type) {
+		case *syntax.ChoiceType, *syntax.AnyType:
+			return true, nil
+		case *syntax.ConstrainedType:
+			typ = t.Type
+		case *syntax.ReferencedType:
+			def, found := l.types[t.Name]
+			if !found {
+				s, err := l.getSchema(t.Name, t.Pos, m)
+				if err != nil {
+					return false, err
+				}
+				tags, _, err := getSchemaTags(s, nil)
+				return err == nil && len(tags) == 0, err
+			}
+			typ, m = def.assignment.Type, def.module
+		default:
+			return false, nil
+		}
+	}
+	return false, l.errorf(typ.Position(), "circular type definition")
+}
+
+// getBaseType returns the built-in type of a type, without references, tags
+// and constraints, and the module where it's defined.
+func (l *moduleLoader) getBaseType(typ syntax.Type, m *syntax.Module) (syntax.Type, *syntax.Module, error) {
+	for depth := 0; depth < 100; depth++ {
+		switch t := typ.(type) {
+		case *syntax.TaggedType:
+			typ = t.Type
+		case *syntax.ConstrainedType:
+			typ = t.Type
+		case *syntax.ReferencedType:
+			def, found := l.types[t.Name]
+			if !found {
+				return nil, nil, l.errorf(t.Pos, "undefined type %s", t.Name)
+			}
+			typ, m = def.assignment.Type, def.module
+		default:
+			return typ, m, nil
+		}
+	}
+	return nil, nil, l.errorf(typ.Position(), "circular type definition")
+}
+
+// applyConstraint sets the options "range" or "size" of a schema from a
+// constraint with a single element. Constraints that cannot be represented are
+// ignored.
+func (l *moduleLoader) applyConstraint(s *Schema, c *syntax.Constraint, m *syntax.Module) error {
+	if len(c.Elements) != 1 {
+		return nil
+	}
+	opts, _ := s.options()
+	if size, ok := c.Elements[0].(*syntax.SizeConstraint); ok {
+		if len(size.Constraint.Elements) != 1 {
+			return nil
+		}
+		switch s.Kind {
+		case BitStringKind, OctetStringKind, StringKind, SequenceOfKind, SetOfKind:
+			b, err := l.getBounds(size.Constraint.Elements[0], m)
+			if b == nil || err != nil {
+				return err
+			}
+			opts.size = b
+			opts.extensible = opts.extensible || c.Extensible || size.Constraint.Extensible
+		}
+	} else if s.Kind == IntegerKind {
+		b, err := l.getBounds(c.Elements[0], m)
+		if b == nil || err != nil {
+			return err
+		}
+		opts.valueRange = b
+		opts.extensible = opts.extensible || c.Extensible
+	}
+	s.Options = opts.String()
+	return nil
+}
+
+// getBounds returns the limits of a single value or a value range, or nil if
+// they cannot be represented.
+func (l *moduleLoader) getBounds(e syntax.ConstraintElement, m *syntax.Module) (*bounds, error) {
+	limit := func(v syntax.Value, exclusive bool, delta int64) (*int64, error) {
+		if v == nil {
+			return nil, nil
+		}
+		n, err := l.getInt(v, m, 0)
+		if err != nil || !n.IsInt64() {
+			return nil, err
+		}
+		value := n.Int64()
+		if exclusive {
+			value += delta
+		}
+		return &value, nil
+	}
+	switch e := e.(type) {
+	case *syntax.SingleValue:
+		n, err := limit(e.Value, false, 0)
+		if n == nil || err != nil {
+			return nil, err
+		}
+		return &bounds{n, n}, nil
+	case *syntax.ValueRange:
+		lower, err := limit(e.Lower, e.LowerExclusive, 1)
+		if err != nil {
+			return nil, err
+		}
+		upper, err := limit(e.Upper, e.UpperExclusive, -1)
+		if err != nil {
+			return nil, err
+		}
+		if (lower == nil && e.Lower != nil) || (upper == nil && e.Upper != nil) {
+			return nil, nil
+		}
+		return &bounds{lower, upper}, nil
+	}
+	return nil, nil
+}
+
+// getEnum returns the values of an ENUMERATED type. Root items without a
+// number take the smallest values not used and additions without a number
+// take the value after the largest one.
+func (l *moduleLoader) getEnum(t *syntax.EnumeratedType, m *syntax.Module) ([]Enum, error) {
+	used := map[int64]bool{}
+	for _, item := range t.Items {
+		if item.Value != nil {
+			n, err := l.getInt(item.Value, m, 0)
+			if err != nil {
+				return nil, err
+			}
+			used[n.Int64()] = true
+		}
+	}
+	enum := []Enum{}
+	next := int64(0)
+	last := int64(-1)
+	for i, item := range append(append([]*syntax.NamedNumber{}, t.Items...), t.Additions...) {
+		addition := i >= len(t.Items)
+		var value int64
+		switch {
+		case item.Value != nil:
+			n, err := l.getInt(item.Value, m, 0)
+			if err != nil {
+				return nil, err
+			}
+			value = n.Int64()
+			if addition && value <= last {
+				return nil, l.errorf(item.Pos, "extension addition %s must be greater than %d", item.Name, last)
+			}
+		case addition:
+			value = last + 1
+		default:
+			for used[next] {
+				next++
+			}
+			value = next
+			used[value] = true
+		}
+		if value > last {
+			last = value
+		}
+		enum = append(enum, Enum{Name: item.Name, Value: int(value), Addition: addition})
+	}
+	return enum, nil
+}
+
+// getInt returns the value of an INTEGER value or of a reference to one.
+func (l *moduleLoader) getInt(v syntax.Value, m *syntax.Module, depth int) (*big.Int, error) {
+	switch v := v.(type) {
+	case *syntax.NumberValue:
+		return v.Value, nil
+	case *syntax.ReferenceValue:
+		def, found := l.values[v.Name]
+		if !found {
+			return nil, l.errorf(v.Pos, "undefined value %s", v.Name)
+		}
+		if depth > 100 {
+			return nil, l.errorf(v.Pos, "circular value definition")
+		}
+		return l.getInt(def.assignment.Value, def.module, depth+1)
+	}
+	return nil, l.errorf(v.Position(), "expected an integer value")
+}
+
+// getDefault returns the DEFAULT value of an INTEGER or ENUMERATED component,
+// which may be a named number of its type.
+func (l *moduleLoader) getDefault(v syntax.Value, typ syntax.Type, m *syntax.Module) (*big.Int, error) {
+	base, baseModule, err := l.getBaseType(typ, m)
+	if err != nil {
+		return nil, err
+	}
+	ref, isRef := v.(*syntax.ReferenceValue)
+	switch t := base.(type) {
+	case *syntax.IntegerType:
+		if isRef {
+			for _, number := range t.NamedNumbers {
+				if number.Name == ref.Name {
+					return l.getInt(number.Value, baseModule, 0)
+				}
+			}
+		}
+	case *syntax.EnumeratedType:
+		enum, err := l.getEnum(t, baseModule)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range enum {
+			if isRef && e.Name == ref.Name {
+				return big.NewInt(int64(e.Value)), nil
+			}
+		}
+		return nil, l.errorf(v.Position(), "invalid ENUMERATED value")
+	default:
+		return nil, l.errorf(v.Position(), "DEFAULT values are only supported for integers")
+	}
+	return l.getInt(v, m, 0)
+}
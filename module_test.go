@@ -0,0 +1,130 @@
+package asn1
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/PromonLogicalis/asn1/syntax"
+)
+
+const testSchemaModule = `
+Test DEFINITIONS IMPLICIT TAGS ::= BEGIN
+maxId INTEGER ::= 100
+
+Record ::= [APPLICATION 1] SEQUENCE {
+    id      INTEGER (0..maxId),
+    version [0] INTEGER DEFAULT 1,
+    color   Color DEFAULT green,
+    name    [1] Name OPTIONAL,
+    body    Body,
+    items   SET OF Item,
+    ...
+}
+Name ::= UTF8String (SIZE (1..64))
+Color ::= ENUMERATED { red, green, blue }
+Body ::= CHOICE { text [2] IA5String, data [3] OCTET STRING, oid OBJECT IDENTIFIER }
+Item ::= INTEGER
+END
+
+Auto DEFINITIONS AUTOMATIC TAGS ::= BEGIN
+Pair ::= SET { a INTEGER, b Body, c BMPString OPTIONAL }
+END
+`
+
+// testRecord is the Go type equivalent to the type Record of testSchemaModule.
+type testRecord struct {
+	Id      int         `asn1:"range:0..100"`
+	Version int         `asn1:"tag:0,default:1"`
+	Color   int         `asn1:"enum:Color,default:1"`
+	Name    string      `asn1:"tag:1,optional,utf8"`
+	Body    interface{} `asn1:"choice:Body"`
+	Items   []int       `asn1:"set"`
+}
+
+func TestLoadModule(t *testing.T) {
+	ctx := NewContext()
+	if err := ctx.LoadModule("test.asn", []byte(testSchemaModule)); err != nil {
+		t.Fatal(err)
+	}
+	record := ctx.Schema("Record")
+	if record == nil || record.Kind != SequenceKind || record.Options != "tag:1,application,extensible" ||
+		len(record.Components) != 6 || record.Components[4].Schema != ctx.Schema("Body") {
+		t.Fatalf("Invalid schema: %+v", record)
+	}
+	if record.Components[0].Schema.Options != "range:0..100" {
+		t.Errorf("Invalid options of id: %q", record.Components[0].Schema.Options)
+	}
+	expected := []string{"", "tag:0,default:1", "default:1", "tag:1,optional", "", ""}
+	for i, c := range record.Components {
+		if c.Options != expected[i] {
+			t.Errorf("Component %s: expected options %q, got %q", c.Name, expected[i], c.Options)
+		}
+	}
+	if name := ctx.Schema("Name"); name.Options != "utf8,size:1..64" {
+		t.Errorf("Invalid options of Name: %q", name.Options)
+	}
+	pair := ctx.Schema("Pair")
+	if pair.Kind != SetKind || pair.Components[1].Options != "tag:1,explicit" ||
+		pair.Components[2].Options != "tag:2,optional" ||
+		pair.Components[2].Schema.Options != "tag:30,universal" {
+		t.Errorf("Invalid schema: %+v", pair)
+	}
+
+	// The same encoding is used by the reflection path
+	ctx.AddEnum("Color", []Enum{{Name: "red", Value: 0}, {Name: "green", Value: 1}, {Name: "blue", Value: 2}})
+	ctx.AddChoice("Body", []Choice{
+		{Type: reflect.TypeOf(""), Options: "tag:2,ia5"},
+		{Type: reflect.TypeOf([]byte{}), Options: "tag:3"},
+		{Type: reflect.TypeOf(Oid{}), Options: ""},
+	})
+	obj := testRecord{Id: 7, Version: 1, Color: 2, Name: "x", Body: Oid{1, 2, 3}, Items: []int{3, 1}}
+	data, err := ctx.EncodeWithOptions(obj, "tag:1,application")
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, _, err := ctx.DecodeValue(data, "Record")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := v.Child("body").Children[0]
+	items := v.Child("items").Children
+	if v.Child("id").Data.(*big.Int).Int64() != 7 || v.Child("version").Data.(*big.Int).Int64() != 1 ||
+		v.Child("color").Data != "blue" || v.Child("name").Data != "x" ||
+		body.Name != "oid" || !reflect.DeepEqual(body.Data, Oid{1, 2, 3}) ||
+		len(items) != 2 || items[0].Data.(*big.Int).Int64() != 1 {
+		t.Fatalf("Invalid decoded value: %+v", v)
+	}
+	encoded, err := ctx.EncodeValue(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !isBytesEqual(encoded, data) {
+		t.Fatalf("Failed to encode value.\n Expected: %#v.\n Got:      %#v", data, encoded)
+	}
+}
+
+func TestLoadModuleErrors(t *testing.T) {
+	testCases := []struct {
+		src      string
+		expected string
+	}{
+		{"M DEFINITIONS ::= BEGIN A ::= SEQUENCE { a B } END", "test.asn:1:44: undefined type B"},
+		{"M DEFINITIONS ::= BEGIN A ::= REAL END", "test.asn:1:31: type REAL is not supported"},
+		{"M DEFINITIONS ::= BEGIN A ::= [PRIVATE 1] INTEGER END", "test.asn:1:31: PRIVATE tags are not supported"},
+		{"M DEFINITIONS ::= BEGIN A ::= [1] EXPLICIT [2] INTEGER END", "test.asn:1:31: explicit tags over tagged types are not supported"},
+		{"M DEFINITIONS ::= BEGIN A ::= SEQUENCE OF [0] A END", "test.asn:1:47: type A cannot be tagged, constrained or renamed inside its own definition"},
+		{"M DEFINITIONS ::= BEGIN A ::= CHOICE { a [0] INTEGER, b [0] BOOLEAN } END", "test.asn:1:25: duplicated tag (2,0) in A"},
+		{"M DEFINITIONS ::= BEGIN A ::= INTEGER A ::= BOOLEAN END", "test.asn:1:39: type A is already defined"},
+	}
+	for _, test := range testCases {
+		err := NewContext().LoadModule("test.asn", []byte(test.src))
+		if err == nil {
+			t.Errorf("%q: expected error %q", test.src, test.expected)
+			continue
+		}
+		if _, ok := err.(*syntax.Error); !ok || err.Error() != test.expected {
+			t.Errorf("%q:\nExpected: %s\nGot:      %s", test.src, test.expected, err)
+		}
+	}
+}
@@ -0,0 +1,336 @@
+package asn1
+
+import (
+	"bytes"
+	"math/big"
+	"reflect"
+	"time"
+)
+
+// Node is an ASN.1 element parsed without any knowledge of its type. It's
+// useful to inspect unknown data.
+//
+// Constructed elements have their content parsed into Children. Primitive
+// elements have no children.
+//
+// A tree can be edited in place, by changing the fields of a node or by using
+// the methods Replace, Insert, Delete and SetValue, and then encoded again.
+// Offset and HeaderLen keep referring to the parsed data, and the Content of
+// constructed nodes is ignored during encoding.
+type Node struct {
+	// Class of the tag, like ClassUniversal or ClassContextSpecific
+	Class       uint
+	Tag         uint
+	Constructed bool
+	Indefinite  bool
+	// Offset of the element in the parsed data
+	Offset int
+	// Number of identifier and length octets
+	HeaderLen int
+	// Content octets, excluding the end-of-contents octets
+	Content  []byte
+	Children []*Node
+}
+
+// nodeContext is used to decode the content of nodes, which are parsed in BER.
+var nodeContext = NewContext()
+
+// Parse parses the first element in data into a tree of nodes and returns the
+// remaining bytes.
+func Parse(data []byte) (node *Node, rest []byte, err error) {
+	node, err = parseNode(data, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	return node, data[node.Len():], nil
+}
+
+// parseNode parses the first element in data. The argument offset is the
+// position of data in the original input.
+func parseNode(data []byte, offset int) (*Node, error) {
+	reader := bytes.NewReader(data)
+	raw, err := decodeRawValue(reader)
+	if err != nil {
+		return nil, err
+	}
+	length := len(data) - reader.Len()
+	node := &Node{
+		Class:       raw.Class,
+		Tag:         raw.Tag,
+		Constructed: raw.Constructed,
+		Indefinite:  raw.Indefinite,
+		Offset:      offset,
+		HeaderLen:   length - len(raw.Content),
+		Content:     raw.Content,
+	}
+	if raw.Indefinite {
+		node.HeaderLen -= 2
+	}
+	if !raw.Constructed {
+		return node, nil
+	}
+
+	// Parse children
+	childOffset := offset + node.HeaderLen
+	content := raw.Content
+	for len(content) > 0 {
+		child, err := parseNode(content, childOffset)
+		if err != nil {
+			return nil, err
+		}
+		node.Children = append(node.Children, child)
+		childOffset += child.Len()
+		content = content[child.Len():]
+	}
+	return node, nil
+}
+
+// Len returns the number of octets of the element as it was parsed, including
+// its header and the end-of-contents octets.
+func (n *Node) Len() int {
+	length := n.HeaderLen + len(n.Content)
+	if n.Indefinite {
+		length += 2
+	}
+	return length
+}
+
+// Encode returns the encoding of the node. The content of constructed nodes is
+// rebuilt from their children, so every enclosing length reflects the changes
+// made to the tree. Nodes marked as Indefinite keep the indefinite length
+// form, the other ones are encoded with the minimal definite form.
+func (n *Node) Encode() ([]byte, error) {
+	raw, err := n.raw(true)
+	if err != nil {
+		return nil, err
+	}
+	return raw.encode()
+}
+
+// EncodeDefinite works similarly to Encode, but all nodes are encoded with the
+// definite length form.
+func (n *Node) EncodeDefinite() ([]byte, error) {
+	raw, err := n.raw(false)
+	if err != nil {
+		return nil, err
+	}
+	return raw.encode()
+}
+
+// raw converts the node into a raw value. The indefinite length form is only
+// used if keepIndefinite is true.
+func (n *Node) raw(keepIndefinite bool) (*rawValue, error) {
+	raw := &rawValue{Class: n.Class, Tag: n.Tag, Constructed: n.Constructed,
+		Indefinite: n.Indefinite && keepIndefinite, Content: n.Content}
+	if n.Constructed {
+		raw.Content = []byte{}
+		for _, child := range n.Children {
+			childRaw, err := child.raw(keepIndefinite)
+			if err != nil {
+				return nil, err
+			}
+			data, err := childRaw.encode()
+			if err != nil {
+				return nil, err
+			}
+			raw.Content = append(raw.Content, data...)
+		}
+	}
+	return raw, nil
+}
+
+// NewNode creates a node with the DER encoding of obj using additional options.
+//
+// See (*Context).EncodeWithOptions() for further details.
+func NewNode(obj interface{}, options string) (*Node, error) {
+	data, err := nodeContext.EncodeWithOptions(obj, options)
+	if err != nil {
+		return nil, err
+	}
+	node, _, err := Parse(data)
+	return node, err
+}
+
+// SetValue replaces the content of the node with the DER encoding of obj. The
+// class and tag of the node are kept, which is suitable for implicitly tagged
+// elements. The node becomes constructed if the encoding of obj is constructed.
+func (n *Node) SetValue(obj interface{}) error {
+	node, err := NewNode(obj, "")
+	if err != nil {
+		return err
+	}
+	n.Constructed = node.Constructed
+	n.Indefinite = false
+	n.Content = node.Content
+	n.Children = node.Children
+	return nil
+}
+
+// checkChild checks if the node is constructed and if i is a valid child
+// index. The argument max is the greatest accepted index.
+func (n *Node) checkChild(i, max int) error {
+	if !n.Constructed {
+		return syntaxError("primitive node (%d,%d) has no children", n.Class, n.Tag)
+	}
+	if i < 0 || i > max {
+		return syntaxError("invalid child index %d", i)
+	}
+	return nil
+}
+
+// Replace replaces the child at the index i.
+func (n *Node) Replace(i int, child *Node) error {
+	if err := n.checkChild(i, len(n.Children)-1); err != nil {
+		return err
+	}
+	if child == nil {
+		return syntaxError("nil child")
+	}
+	n.Children[i] = child
+	return nil
+}
+
+// Insert inserts a child at the index i. The child is appended if i is equal
+// to the number of children.
+func (n *Node) Insert(i int, child *Node) error {
+	if err := n.checkChild(i, len(n.Children)); err != nil {
+		return err
+	}
+	if child == nil {
+		return syntaxError("nil child")
+	}
+	n.Children = append(n.Children, nil)
+	copy(n.Children[i+1:], n.Children[i:])
+	n.Children[i] = child
+	return nil
+}
+
+// Delete removes the child at the index i.
+func (n *Node) Delete(i int) error {
+	if err := n.checkChild(i, len(n.Children)-1); err != nil {
+		return err
+	}
+	n.Children = append(n.Children[:i], n.Children[i+1:]...)
+	return nil
+}
+
+// decodeContent decodes the node content into obj, which should be a
+// reference to a value. The tag of the node is not checked, so implicitly
+// tagged values can also be decoded.
+func (n *Node) decodeContent(obj interface{}) error {
+	value := reflect.ValueOf(obj).Elem()
+	elem, err := nodeContext.getUniversalTag(value.Type(), &fieldOptions{})
+	if err != nil {
+		return err
+	}
+	if n.Constructed && elem.stringTag == 0 {
+		return parseError("constructed node (%d,%d) cannot be decoded as %s",
+			n.Class, n.Tag, value.Type())
+	}
+	raw, err := n.raw(true)
+	if err != nil {
+		return err
+	}
+	content, err := nodeContext.getContent(raw, elem)
+	if err != nil {
+		return err
+	}
+	return elem.decoder(content, value)
+}
+
+// AsBool returns the content of the node as a BOOLEAN.
+func (n *Node) AsBool() (b bool, err error) {
+	err = n.decodeContent(&b)
+	return
+}
+
+// AsInt returns the content of the node as an INTEGER or ENUMERATED that fits
+// into an int64.
+func (n *Node) AsInt() (i int64, err error) {
+	err = n.decodeContent(&i)
+	return
+}
+
+// AsBigInt returns the content of the node as an INTEGER or ENUMERATED.
+func (n *Node) AsBigInt() (i *big.Int, err error) {
+	err = n.decodeContent(&i)
+	return
+}
+
+// AsOid returns the content of the node as an OBJECT IDENTIFIER.
+func (n *Node) AsOid() (oid Oid, err error) {
+	err = n.decodeContent(&oid)
+	return
+}
+
+// AsBitString returns the content of the node as a BIT STRING.
+func (n *Node) AsBitString() (bs BitString, err error) {
+	err = n.decodeContent(&bs)
+	return
+}
+
+// AsBytes returns the content of the node as an OCTET STRING. Constructed
+// strings have their segments joined.
+func (n *Node) AsBytes() (data []byte, err error) {
+	err = n.decodeContent(&data)
+	return
+}
+
+// AsString returns the content of the node as a character string. Constructed
+// strings have their segments joined.
+func (n *Node) AsString() (s string, err error) {
+	err = n.decodeContent(&s)
+	return
+}
+
+// AsTime returns the content of the node as an UTCTime or a GeneralizedTime.
+// The universal tag of the node selects the format. Any other tag is parsed
+// as a GeneralizedTime and then as an UTCTime.
+func (n *Node) AsTime() (time.Time, error) {
+	s, err := n.AsString()
+	if err != nil {
+		return time.Time{}, err
+	}
+	if n.Class == ClassUniversal {
+		switch n.Tag {
+		case tagUtcTime:
+			return parseUtcTime(s)
+		case tagGeneralizedTime:
+			return parseGeneralizedTime(s)
+		}
+	}
+	if t, err := parseGeneralizedTime(s); err == nil {
+		return t, nil
+	}
+	return parseUtcTime(s)
+}
+
+// parseUtcTime parses an UTCTime. Years are in the range 1950 to 2049, as
+// defined by RFC 5280.
+func parseUtcTime(s string) (time.Time, error) {
+	for _, layout := range []string{
+		"0601021504Z0700", "060102150405Z0700",
+	} {
+		if t, err := time.Parse(layout, s); err == nil {
+			if t.Year() >= 2050 {
+				t = t.AddDate(-100, 0, 0)
+			}
+			return t, nil
+		}
+	}
+	return time.Time{}, parseError("invalid UTCTime: %s", s)
+}
+
+// parseGeneralizedTime parses a GeneralizedTime. Values without time zone are
+// considered to be in UTC.
+func parseGeneralizedTime(s string) (time.Time, error) {
+	for _, layout := range []string{
+		"20060102150405Z0700", "20060102150405.999999999Z0700",
+		"20060102150405", "20060102150405.999999999",
+	} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, parseError("invalid GeneralizedTime: %s", s)
+}
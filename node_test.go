@@ -0,0 +1,234 @@
+package asn1
+
+import (
+	"io/ioutil"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	data := []byte{
+		// SEQ LEN=15
+		0x30, 0x0f,
+		// INTEGER LEN=2
+		0x02, 0x02, 0x01, 0x00,
+		// [0] IMPLICIT SEQ INDEFINITE
+		0xa0, 0x80,
+		// OID 1.2
+		0x06, 0x01, 0x2a,
+		// EOC
+		0x00, 0x00,
+		// IA5String "ab"
+		0x16, 0x02, 0x61, 0x62,
+		// Extra byte
+		0xff,
+	}
+	node, rest, err := Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkEqual(t, rest, []byte{0xff})
+	checkEqual(t, len(node.Children), 3)
+	checkEqual(t, node.Len(), 17)
+
+	integer := node.Children[0]
+	checkEqual(t, integer.Offset, 2)
+	checkEqual(t, integer.HeaderLen, 2)
+	n, err := integer.AsInt()
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkEqual(t, n, int64(256))
+	bn, err := integer.AsBigInt()
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkEqual(t, bn, big.NewInt(256))
+
+	tagged := node.Children[1]
+	checkEqual(t, tagged.Class, uint(ClassContextSpecific))
+	checkEqual(t, tagged.Indefinite, true)
+	checkEqual(t, tagged.Len(), 7)
+	oid, err := tagged.Children[0].AsOid()
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkEqual(t, oid, Oid{1, 2})
+	checkEqual(t, tagged.Children[0].Offset, 8)
+
+	s, err := node.Children[2].AsString()
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkEqual(t, s, "ab")
+	if _, err = tagged.AsInt(); err == nil {
+		t.Fatal("A constructed node should not be decoded as INTEGER.")
+	}
+
+	// Encode rebuilds the original bytes
+	encoded, err := node.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkEqual(t, encoded, data[:len(data)-1])
+}
+
+func TestParseInvalid(t *testing.T) {
+	// Declared length much larger than the data
+	if _, _, err := Parse([]byte{0x04, 0x84, 0x7f, 0xff, 0xff, 0xff}); err == nil {
+		t.Fatal("Parsing a truncated value should have failed.")
+	}
+
+	// Empty INTEGER and ENUMERATED
+	for _, data := range [][]byte{{0x02, 0x00}, {0x0a, 0x00}, {0x30, 0x02, 0x02, 0x00}} {
+		node, _, err := Parse(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for len(node.Children) > 0 {
+			node = node.Children[0]
+		}
+		if _, err = node.AsBigInt(); err == nil {
+			t.Fatalf("Decoding %#v as INTEGER should have failed.", data)
+		}
+		if _, err = node.AsInt(); err == nil {
+			t.Fatalf("Decoding %#v as INTEGER should have failed.", data)
+		}
+		if err = Dump(ioutil.Discard, data, nil); err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err = BerToJson(data); err != nil {
+			t.Fatal(err)
+		}
+		if _, err = Select(data, "//INTEGER=0"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var b bool
+	if _, err := Decode([]byte{0x01, 0x00}, &b); err == nil {
+		t.Fatal("Decoding an empty BOOLEAN should have failed.")
+	}
+}
+
+func TestNodeConstructedString(t *testing.T) {
+	node, _, err := Parse([]byte{0x24, 0x80, 0x04, 0x01, 0x61, 0x04, 0x01, 0x62, 0x00, 0x00})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := node.AsBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkEqual(t, data, []byte("ab"))
+}
+
+func TestNodeTime(t *testing.T) {
+	testCases := []struct {
+		data     []byte
+		expected time.Time
+	}{
+		{
+			append([]byte{0x17, 0x0d}, "491231235959Z"...),
+			time.Date(2049, 12, 31, 23, 59, 59, 0, time.UTC),
+		},
+		{
+			append([]byte{0x17, 0x0b}, "5001010000Z"...),
+			time.Date(1950, 1, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			append([]byte{0x18, 0x11}, "20180102030405.5Z"...),
+			time.Date(2018, 1, 2, 3, 4, 5, 500000000, time.UTC),
+		},
+		{
+			append([]byte{0x18, 0x13}, "20180102030405+0100"...),
+			time.Date(2018, 1, 2, 2, 4, 5, 0, time.UTC),
+		},
+	}
+	for _, test := range testCases {
+		node, _, err := Parse(test.data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tm, err := node.AsTime()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !tm.Equal(test.expected) {
+			t.Fatalf("Expected %s but got %s", test.expected, tm)
+		}
+	}
+}
+
+func TestNodeEdit(t *testing.T) {
+	data := []byte{
+		// SEQ INDEFINITE
+		0x30, 0x80,
+		// [0] IMPLICIT INTEGER 1
+		0x80, 0x01, 0x01,
+		// SEQ LEN=3
+		0x30, 0x03,
+		// BOOLEAN true
+		0x01, 0x01, 0xff,
+		// EOC
+		0x00, 0x00,
+	}
+	node, _, err := Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Bump the sequence number keeping its tag
+	if err = node.Children[0].SetValue(300); err != nil {
+		t.Fatal(err)
+	}
+	// Add an element to the nested sequence
+	child, err := NewNode("ab", "ia5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = node.Children[1].Insert(1, child); err != nil {
+		t.Fatal(err)
+	}
+	encoded, err := node.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkEqual(t, encoded, []byte{
+		0x30, 0x80,
+		0x80, 0x02, 0x01, 0x2c,
+		0x30, 0x07,
+		0x01, 0x01, 0xff,
+		0x16, 0x02, 0x61, 0x62,
+		0x00, 0x00,
+	})
+
+	// Remove the boolean and use definite lengths
+	if err = node.Children[1].Delete(0); err != nil {
+		t.Fatal(err)
+	}
+	encoded, err = node.EncodeDefinite()
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkEqual(t, encoded, []byte{
+		0x30, 0x0a,
+		0x80, 0x02, 0x01, 0x2c,
+		0x30, 0x04,
+		0x16, 0x02, 0x61, 0x62,
+	})
+
+	// Invalid edits
+	if err = node.Children[0].Delete(0); err == nil {
+		t.Fatal("Deleting a child of a primitive node should have failed.")
+	}
+	if err = node.Replace(2, child); err == nil {
+		t.Fatal("Replacing an invalid index should have failed.")
+	}
+	if err = node.Replace(0, nil); err == nil {
+		t.Fatal("Replacing with a nil child should have failed.")
+	}
+	if err = node.Insert(0, nil); err == nil {
+		t.Fatal("Inserting a nil child should have failed.")
+	}
+}
@@ -0,0 +1,60 @@
+package asn1
+
+import "reflect"
+
+// FormatValueNotation returns obj in the ASN.1 value notation defined by
+// X.680, like:
+//
+//	{ version v2, serial 42, subject rdnSequence : { { type { 2 5 4 3 } } } }
+//
+// The representation follows the one of EncodeGser(), using the same struct
+// tags, registered choices and enums of ctx, except for OBJECT IDENTIFIERs,
+// which are written as a list of numbers, and CHOICE values, which are written
+// as "id : value". Identifiers are converted as in GSER, so the field
+// "SerialNumber" is written as "serialNumber" and the alternative
+// "OCTET_STRING" as "octet-string".
+//
+// A new Context is used if ctx is nil.
+func FormatValueNotation(obj interface{}, ctx *Context) (string, error) {
+	if ctx == nil {
+		ctx = NewContext()
+	}
+	e := &gserEncoder{ctx: ctx, notation: true}
+	if err := e.encodeValue(reflect.ValueOf(obj), &fieldOptions{}); err != nil {
+		return "", err
+	}
+	return e.buf.String(), nil
+}
+
+// ParseValueNotation parses a value in the ASN.1 value notation into obj, which
+// should be a reference to the value that will hold the parsed data. It accepts
+// the representation produced by FormatValueNotation(), and also:
+//
+//   - Comments ("-- text" and "/* text */") and any amount of white space;
+//   - OBJECT IDENTIFIER components in the name form, like "iso(1)";
+//   - BIT STRINGs as hexadecimal strings ('A'H).
+//
+// CHOICE alternatives are found in the choices registered in ctx, or in a new
+// Context if ctx is nil. Errors report the line and the column where they were
+// found.
+func ParseValueNotation(text string, obj interface{}, ctx *Context) error {
+	if ctx == nil {
+		ctx = NewContext()
+	}
+	value := reflect.ValueOf(obj)
+	switch value.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		value = value.Elem()
+	}
+	if !value.CanSet() {
+		return syntaxError("go type '%s' is read-only", value.Type())
+	}
+	d := &gserDecoder{data: []byte(text), ctx: ctx, notation: true}
+	if err := d.decodeValue(value, &fieldOptions{}); err != nil {
+		return err
+	}
+	if d.peek() != 0 {
+		return d.error("unexpected data after the value")
+	}
+	return nil
+}
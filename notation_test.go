@@ -0,0 +1,123 @@
+package asn1
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFormatValueNotation(t *testing.T) {
+	type Version int
+	type Name struct {
+		Type  Oid
+		Value string `asn1:"utf8"`
+	}
+	type Cert struct {
+		Version      Version     `asn1:"tag:0,explicit,enum:version,default:0"`
+		SerialNumber int         `asn1:"name:serial"`
+		Subject      interface{} `asn1:"choice:name"`
+		Flags        BitString   `asn1:"optional"`
+		Extra_Data   []byte
+	}
+	ctx := NewContext()
+	ctx.AddEnum("version", []Enum{{Name: "v1", Value: 0}, {Name: "v2", Value: 1}})
+	ctx.AddChoice("name", []Choice{
+		{reflect.TypeOf([]Name{}), "name:rdnSequence"},
+		{reflect.TypeOf(""), "tag:0,utf8"},
+	})
+	testCases := []struct {
+		obj      interface{}
+		expected string
+	}{
+		{
+			Cert{1, 42, []Name{{Oid{2, 5, 4, 3}, "Bob"}}, BitString{}, []byte{0xab}},
+			`{ version v2, serial 42, subject rdnSequence : { { type { 2 5 4 3 }, value "Bob" } }, extra-Data 'AB'H }`,
+		},
+		{
+			Cert{0, 1, "x", BitString{[]byte{0x80}, 2}, nil},
+			`{ serial 1, subject utf8String : "x", flags '10'B, extra-Data ''H }`,
+		},
+		{true, "TRUE"},
+		{[]Oid{{1, 2}}, "{ { 1 2 } }"},
+		{Null{}, "NULL"},
+	}
+	for _, test := range testCases {
+		s, err := FormatValueNotation(test.obj, ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkEqual(t, s, test.expected)
+	}
+
+	for name, expected := range map[string]string{
+		"SerialNumber": "serialNumber",
+		"UTF8String":   "utf8String",
+		"OCTET_STRING": "octet-string",
+		"ID":           "id",
+		"value":        "value",
+	} {
+		checkEqual(t, getIdentifier(name), expected)
+	}
+}
+
+func TestParseValueNotation(t *testing.T) {
+	type Name struct {
+		Type  Oid
+		Value string `asn1:"utf8"`
+	}
+	type Record struct {
+		Id      int
+		Version int         `asn1:"default:1"`
+		Subject interface{} `asn1:"choice:name"`
+		Names   []Name
+	}
+	ctx := NewContext()
+	ctx.AddChoice("name", []Choice{
+		{reflect.TypeOf([]Name{}), "name:rdnSequence"},
+		{reflect.TypeOf(""), "tag:0,utf8"},
+	})
+	text := `
+		-- A record
+		{
+			id 7,
+			subject rdnSequence : {
+				{ type { joint-iso-itu-t(2) ds(5) 4 3 }, value "Bob" } -- the CN --
+			},
+			/* no names */
+			names { }
+		}
+	`
+	var record Record
+	if err := ParseValueNotation(text, &record, ctx); err != nil {
+		t.Fatal(err)
+	}
+	checkEqual(t, record, Record{
+		Id:      7,
+		Version: 1,
+		Subject: []Name{{Oid{2, 5, 4, 3}, "Bob"}},
+		Names:   []Name{},
+	})
+
+	// The printed values are parsed back
+	expected := Record{Id: 1, Version: 2, Subject: "x", Names: []Name{{Oid{1, 2}, "a"}}}
+	s, err := FormatValueNotation(expected, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	record = Record{}
+	if err = ParseValueNotation(s, &record, ctx); err != nil {
+		t.Fatal(err)
+	}
+	checkEqual(t, record, expected)
+
+	// Errors have the line and column
+	err = ParseValueNotation("{\n  id 7,\n  subject other : 1\n}", &record, ctx)
+	if err == nil {
+		t.Fatal("An invalid alternative should have failed.")
+	}
+	checkEqual(t, err.Error(), "line 3, column 18: invalid alternative 'other' for choice 'name'")
+	for _, text := range []string{"{ id 1, subject utf8String : \"a\", names { } } x", "{ id 1, names { { type { iso 1 }, value \"\" } } }", "{ id 1"} {
+		if err = ParseValueNotation(text, &record, ctx); err == nil {
+			t.Fatalf("Parsing %q should have failed.", text)
+		}
+	}
+}
@@ -0,0 +1,935 @@
+package asn1
+
+import (
+	"bytes"
+	"math/big"
+	"reflect"
+)
+
+// EncodeOer returns the Octet Encoding Rules (X.696) encoding of obj using
+// additional options.
+//
+// OER uses the same Go types and options as PER (see (*Context).EncodeAper()).
+// Integers with a non extensible "range" are encoded using fixed size fields of
+// 1, 2, 4 or 8 octets and non extensible fixed "size" constraints suppress the
+// length determinant of strings. Tags are only encoded to identify the
+// alternative of a CHOICE.
+//
+// The encoding produced is always canonical, as DEFAULT values are omitted and
+// lengths use their minimum form, so EncodeOer and EncodeCoer are equivalent.
+func (ctx *Context) EncodeOer(obj interface{}, options string) ([]byte, error) {
+	return ctx.encodeOer(obj, options)
+}
+
+// EncodeCoer returns the Canonical Octet Encoding Rules (X.696) encoding of
+// obj using additional options.
+//
+// See (*Context).EncodeOer() for further details.
+func (ctx *Context) EncodeCoer(obj interface{}, options string) ([]byte, error) {
+	return ctx.encodeOer(obj, options)
+}
+
+// DecodeOer parses the given OER data into obj using additional options. The
+// argument obj should be a reference to the value that will hold the parsed
+// data.
+//
+// See (*Context).EncodeOer() for further details.
+func (ctx *Context) DecodeOer(data []byte, obj interface{}, options string) (rest []byte, err error) {
+	return ctx.decodeOer(data, obj, options, false)
+}
+
+// DecodeCoer parses the given canonical OER data into obj using additional
+// options. Unlike DecodeOer, a ParseError is returned for any encoding that is
+// not in its canonical form, such as non minimal lengths and integers, or
+// booleans other than 0x00 and 0xff.
+//
+// See (*Context).EncodeOer() for further details.
+func (ctx *Context) DecodeCoer(data []byte, obj interface{}, options string) (rest []byte, err error) {
+	return ctx.decodeOer(data, obj, options, true)
+}
+
+func (ctx *Context) encodeOer(obj interface{}, options string) ([]byte, error) {
+	opts, err := parseOptions(options)
+	if err != nil {
+		return nil, err
+	}
+	// Return nil if the ignore tag is given
+	if opts == nil {
+		return nil, nil
+	}
+
+	e := &oerEncoder{ctx: ctx}
+	err = e.encode(reflect.ValueOf(obj), opts)
+	if err != nil {
+		return nil, err
+	}
+	return e.buf.Bytes(), nil
+}
+
+func (ctx *Context) decodeOer(data []byte, obj interface{}, options string, canonical bool) (rest []byte, err error) {
+	opts, err := parseOptions(options)
+	if err != nil {
+		return nil, err
+	}
+	// Return nil if the ignore tag is given
+	if opts == nil {
+		return
+	}
+
+	value := reflect.ValueOf(obj)
+	switch value.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		value = value.Elem()
+	}
+
+	if !value.CanSet() {
+		return nil, syntaxError("go type '%s' is read-only", value.Type())
+	}
+
+	d := &oerDecoder{ctx: ctx, canonical: canonical}
+	d.reader = bytes.NewBuffer(data)
+	err = d.decode(value, opts)
+	if err != nil {
+		return nil, err
+	}
+	return d.reader.Bytes(), nil
+}
+
+// oerIntegerWidth returns the size in octets of the fixed size field used to
+// encode an integer with the given constraint, or zero when the value is
+// encoded with a length determinant. The flag signed indicates the use of
+// two's complement.
+func oerIntegerWidth(r *bounds, extensible bool) (width int, signed bool) {
+	// Extensible constraints are not visible to OER
+	if r == nil || extensible {
+		return 0, true
+	}
+	if r.lower != nil && *r.lower >= 0 {
+		if r.upper == nil {
+			return 0, false
+		}
+		switch {
+		case *r.upper <= 0xff:
+			return 1, false
+		case *r.upper <= 0xffff:
+			return 2, false
+		case *r.upper <= 0xffffffff:
+			return 4, false
+		}
+		return 8, false
+	}
+	if !r.constrained() {
+		return 0, true
+	}
+	for _, width := range []uint{1, 2, 4} {
+		min := -int64(1) << (width*8 - 1)
+		if *r.lower >= min && *r.upper <= -min-1 {
+			return int(width), true
+		}
+	}
+	return 8, true
+}
+
+// oerFixedSize returns the fixed size of a non extensible size constraint.
+func oerFixedSize(opts *fieldOptions) (int, bool) {
+	if opts.size.fixed() && !opts.extensible {
+		return int(*opts.size.lower), true
+	}
+	return 0, false
+}
+
+/*
+ * Encoder
+ */
+
+type oerEncoder struct {
+	buf bytes.Buffer
+	ctx *Context
+}
+
+// Main OER encode function
+func (e *oerEncoder) encode(value reflect.Value, opts *fieldOptions) error {
+
+	if opts.choice != nil {
+		return e.encodeChoice(value, opts)
+	}
+
+	// Skip the interface type
+	value = getActualType(value)
+	if !value.IsValid() {
+		return syntaxError("nil value cannot be encoded")
+	}
+
+	// Special types:
+	switch value.Type() {
+	case bigIntType:
+		return e.encodeInteger(getBigInt(value), opts)
+	case bitStringType:
+		return e.encodeBitString(value, opts)
+	case oidType:
+		data, err := e.ctx.encodeOid(value)
+		if err != nil {
+			return err
+		}
+		e.writeOpenBytes(data)
+		return nil
+	case nullType:
+		return nil
+	}
+
+	// Generic types:
+	switch value.Kind() {
+	case reflect.Bool:
+		data, err := e.ctx.encodeBool(value)
+		if err != nil {
+			return err
+		}
+		e.buf.Write(data)
+		return nil
+
+	case reflect.String:
+		return e.encodeString(value, opts)
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if opts.enum != nil {
+			return e.encodeEnumerated(value, opts)
+		}
+		return e.encodeInteger(getBigInt(value), opts)
+
+	case reflect.Struct:
+		return e.encodeStruct(value, opts)
+
+	case reflect.Array, reflect.Slice:
+		if value.Type().Elem().Kind() == reflect.Uint8 {
+			data, err := e.ctx.encodeOctetString(value)
+			if err != nil {
+				return err
+			}
+			return e.encodeOctetString(data, opts)
+		}
+		return e.encodeSequenceOf(value, opts)
+	}
+	return syntaxError("invalid Go type: %s", value.Type())
+}
+
+// writeLength encodes a length determinant.
+func (e *oerEncoder) writeLength(n int) {
+	if n < 0x80 {
+		e.buf.WriteByte(byte(n))
+		return
+	}
+	buf := new(big.Int).SetUint64(uint64(n)).Bytes()
+	e.buf.WriteByte(0x80 | byte(len(buf)))
+	e.buf.Write(buf)
+}
+
+// writeOpenBytes encodes data preceded by its length.
+func (e *oerEncoder) writeOpenBytes(data []byte) {
+	e.writeLength(len(data))
+	e.buf.Write(data)
+}
+
+// writeFixedInt encodes n in a field of the given number of octets.
+func (e *oerEncoder) writeFixedInt(n *big.Int, width int) {
+	buf := make([]byte, width)
+	if n.Sign() >= 0 {
+		n.FillBytes(buf)
+	} else {
+		// Two's complement
+		m := new(big.Int).Lsh(big.NewInt(1), uint(width*8))
+		m.Add(m, n).FillBytes(buf)
+	}
+	e.buf.Write(buf)
+}
+
+func (e *oerEncoder) encodeInteger(n *big.Int, opts *fieldOptions) error {
+	r := opts.valueRange
+	if r != nil && !opts.extensible && !(n.IsInt64() && r.contains(n.Int64())) {
+		return syntaxError("integer %s does not satisfy the value constraint", n)
+	}
+	width, signed := oerIntegerWidth(r, opts.extensible)
+	if width > 0 {
+		e.writeFixedInt(n, width)
+		return nil
+	}
+	if !signed {
+		buf := n.Bytes()
+		if len(buf) == 0 {
+			buf = []byte{0x00}
+		}
+		e.writeOpenBytes(buf)
+		return nil
+	}
+	buf, err := e.ctx.encodeBigInt(reflect.ValueOf(n))
+	if err != nil {
+		return err
+	}
+	e.writeOpenBytes(buf)
+	return nil
+}
+
+func (e *oerEncoder) encodeEnumerated(value reflect.Value, opts *fieldOptions) error {
+	root, additions, err := e.ctx.getEnumValues(*opts.enum)
+	if err != nil {
+		return err
+	}
+	n := getBigInt(value)
+	valid := false
+	for _, entry := range root {
+		valid = valid || n.Cmp(big.NewInt(int64(entry.Value))) == 0
+	}
+	for _, entry := range additions {
+		valid = valid || opts.extensible && n.Cmp(big.NewInt(int64(entry.Value))) == 0
+	}
+	if !valid {
+		return syntaxError("invalid value %s for enum '%s'", n, *opts.enum)
+	}
+	// Short form for values between 0 and 127
+	if n.Sign() >= 0 && n.Cmp(big.NewInt(0x7f)) <= 0 {
+		e.buf.WriteByte(byte(n.Int64()))
+		return nil
+	}
+	buf, err := e.ctx.encodeBigInt(reflect.ValueOf(n))
+	if err != nil {
+		return err
+	}
+	e.buf.WriteByte(0x80 | byte(len(buf)))
+	e.buf.Write(buf)
+	return nil
+}
+
+func (e *oerEncoder) encodeBitString(value reflect.Value, opts *fieldOptions) error {
+	bs, ok := value.Interface().(BitString)
+	if !ok {
+		return wrongType(bitStringType.String(), value)
+	}
+	if bs.BitLength > len(bs.Bytes)*8 {
+		return syntaxError("invalid BIT STRING length: %d", bs.BitLength)
+	}
+	if !opts.extensible && !opts.size.contains(int64(bs.BitLength)) {
+		return syntaxError("size %d does not satisfy the size constraint", bs.BitLength)
+	}
+	data := getBitStringBytes(bs)
+	if _, ok := oerFixedSize(opts); ok {
+		e.buf.Write(data)
+		return nil
+	}
+	e.writeLength(len(data) + 1)
+	e.buf.WriteByte(byte(len(data)*8 - bs.BitLength))
+	e.buf.Write(data)
+	return nil
+}
+
+// getBitStringBytes returns a copy of the octets that hold the bits of bs, with
+// the unused bits of the last octet cleared as canonical encodings require.
+func getBitStringBytes(bs BitString) []byte {
+	data := append([]byte{}, bs.Bytes[:(bs.BitLength+7)/8]...)
+	if unused := uint(len(data)*8 - bs.BitLength); unused > 0 {
+		data[len(data)-1] &= 0xff << unused
+	}
+	return data
+}
+
+func (e *oerEncoder) encodeOctetString(data []byte, opts *fieldOptions) error {
+	if !opts.extensible && !opts.size.contains(int64(len(data))) {
+		return syntaxError("size %d does not satisfy the size constraint", len(data))
+	}
+	if _, ok := oerFixedSize(opts); ok {
+		e.buf.Write(data)
+		return nil
+	}
+	e.writeOpenBytes(data)
+	return nil
+}
+
+func (e *oerEncoder) encodeString(value reflect.Value, opts *fieldOptions) error {
+	s := value.String()
+	if opts.stringTag == nil {
+		return e.encodeOctetString([]byte(s), opts)
+	}
+	alphabet := getPerAlphabet(*opts.stringTag)
+	if alphabet == nil {
+		// Size constraints of other string types are not visible to OER
+		e.writeOpenBytes([]byte(s))
+		return nil
+	}
+	for _, c := range s {
+		if _, ok := alphabet.code(c); !ok {
+			return syntaxError("invalid character %q for string type", c)
+		}
+	}
+	return e.encodeOctetString([]byte(s), opts)
+}
+
+func (e *oerEncoder) encodeSequenceOf(value reflect.Value, opts *fieldOptions) error {
+	// Quantity field
+	quantity := new(big.Int).SetUint64(uint64(value.Len())).Bytes()
+	if len(quantity) == 0 {
+		quantity = []byte{0x00}
+	}
+	e.writeOpenBytes(quantity)
+	for i := 0; i < value.Len(); i++ {
+		err := e.encode(value.Index(i), opts.elemOptions())
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *oerEncoder) encodeStruct(value reflect.Value, opts *fieldOptions) error {
+	root, additions, err := e.ctx.getComponents(value.Type(), opts.set)
+	if err != nil {
+		return err
+	}
+	if len(additions) > 0 && !opts.extensible {
+		return syntaxError("extension additions require the flag 'extensible' on Go type '%s'", value.Type())
+	}
+
+	// Preamble with the extension bit and the bitmap of optional components
+	extended := false
+	preamble := bitWriter{}
+	if opts.extensible {
+		for _, field := range additions {
+			extended = extended || !isAbsent(value.Field(field.Index[0]), field.opts)
+		}
+		preamble.writeBit(extended)
+	}
+	for _, field := range root {
+		if isOptional(field.opts) {
+			preamble.writeBit(!isAbsent(value.Field(field.Index[0]), field.opts))
+		}
+	}
+	e.buf.Write(preamble.buf)
+
+	// Root components
+	for _, field := range root {
+		fieldValue := value.Field(field.Index[0])
+		if isAbsent(fieldValue, field.opts) {
+			continue
+		}
+		if err := e.encode(fieldValue, field.opts); err != nil {
+			return err
+		}
+	}
+
+	if !extended {
+		return nil
+	}
+
+	// Extension additions presence bitmap, encoded as a BIT STRING
+	bitmap := bitWriter{}
+	for _, field := range additions {
+		bitmap.writeBit(!isAbsent(value.Field(field.Index[0]), field.opts))
+	}
+	e.writeLength(len(bitmap.buf) + 1)
+	e.buf.WriteByte(byte(len(bitmap.buf)*8 - bitmap.bits))
+	e.buf.Write(bitmap.buf)
+
+	// Extension additions as open types
+	for _, field := range additions {
+		fieldValue := value.Field(field.Index[0])
+		if isAbsent(fieldValue, field.opts) {
+			continue
+		}
+		if err := e.encodeOpenType(fieldValue, field.opts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *oerEncoder) encodeChoice(value reflect.Value, opts *fieldOptions) error {
+	value = getActualType(value)
+	if !value.IsValid() {
+		return syntaxError("nil value for choice '%s'", *opts.choice)
+	}
+	entry, err := e.ctx.getChoiceByType(*opts.choice, value.Type())
+	if err != nil {
+		return err
+	}
+	if entry.opts.addition && !opts.extensible {
+		return syntaxError("extension additions require the flag 'extensible' on choice '%s'", *opts.choice)
+	}
+
+	// Tag of the alternative
+	if entry.tag < 0x3f {
+		e.buf.WriteByte(byte(entry.class<<6 | entry.tag))
+	} else {
+		e.buf.WriteByte(byte(entry.class<<6 | 0x3f))
+		e.buf.Write(encodeMultiByteTag(entry.tag))
+	}
+
+	if entry.opts.addition {
+		return e.encodeOpenType(value, entry.opts)
+	}
+	return e.encode(value, entry.opts)
+}
+
+// encodeOpenType encodes a value preceded by the length of its encoding.
+func (e *oerEncoder) encodeOpenType(value reflect.Value, opts *fieldOptions) error {
+	inner := &oerEncoder{ctx: e.ctx}
+	if err := inner.encode(value, opts); err != nil {
+		return err
+	}
+	e.writeOpenBytes(inner.buf.Bytes())
+	return nil
+}
+
+/*
+ * Decoder
+ */
+
+type oerDecoder struct {
+	reader    *bytes.Buffer
+	ctx       *Context
+	canonical bool
+}
+
+// Main OER decode function
+func (d *oerDecoder) decode(value reflect.Value, opts *fieldOptions) error {
+
+	if opts.choice != nil {
+		return d.decodeChoice(value, opts)
+	}
+
+	// Allocate pointers
+	if value.Kind() == reflect.Ptr && value.Type() != bigIntType {
+		elem := reflect.New(value.Type().Elem())
+		if err := d.decode(elem.Elem(), opts); err != nil {
+			return err
+		}
+		value.Set(elem)
+		return nil
+	}
+
+	// Special types:
+	switch value.Type() {
+	case bigIntType:
+		n, err := d.decodeInteger(opts)
+		if err != nil {
+			return err
+		}
+		value.Set(reflect.ValueOf(n))
+		return nil
+	case bitStringType:
+		return d.decodeBitString(value, opts)
+	case oidType:
+		data, err := d.readOpenBytes()
+		if err != nil {
+			return err
+		}
+		return d.ctx.decodeOid(data, value)
+	case nullType:
+		return nil
+	}
+
+	// Generic types:
+	switch value.Kind() {
+	case reflect.Bool:
+		b, err := d.readBytes(1)
+		if err != nil {
+			return err
+		}
+		if d.canonical && b[0] != 0x00 && b[0] != 0xff {
+			return parseError("invalid bool value")
+		}
+		value.SetBool(b[0] != 0x00)
+		return nil
+
+	case reflect.String:
+		return d.decodeString(value, opts)
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if opts.enum != nil {
+			return d.decodeEnumerated(value, opts)
+		}
+		n, err := d.decodeInteger(opts)
+		if err != nil {
+			return err
+		}
+		return setBigInt(value, n)
+
+	case reflect.Struct:
+		return d.decodeStruct(value, opts)
+
+	case reflect.Array, reflect.Slice:
+		if value.Type().Elem().Kind() == reflect.Uint8 {
+			data, err := d.decodeOctetString(opts)
+			if err != nil {
+				return err
+			}
+			return d.ctx.decodeOctetString(data, value)
+		}
+		return d.decodeSequenceOf(value, opts)
+	}
+	return syntaxError("invalid Go type: %s", value.Type())
+}
+
+func (d *oerDecoder) readBytes(n int) ([]byte, error) {
+	if n > d.reader.Len() {
+		return nil, parseError("unexpected end of OER data")
+	}
+	return append([]byte{}, d.reader.Next(n)...), nil
+}
+
+// readLength decodes a length determinant.
+func (d *oerDecoder) readLength() (int, error) {
+	b, err := d.readBytes(1)
+	if err != nil {
+		return 0, err
+	}
+	if b[0] < 0x80 {
+		return int(b[0]), nil
+	}
+	buf, err := d.readBytes(int(b[0] & 0x7f))
+	if err != nil {
+		return 0, err
+	}
+	n := new(big.Int).SetBytes(buf)
+	if !n.IsInt64() || n.Int64() > int64(d.reader.Len()) {
+		return 0, parseError("invalid OER length")
+	}
+	if d.canonical && (n.Int64() < 0x80 || len(buf) == 0 || buf[0] == 0x00) {
+		return 0, parseError("length not encoded in the canonical form")
+	}
+	return int(n.Int64()), nil
+}
+
+// readOpenBytes decodes data preceded by its length.
+func (d *oerDecoder) readOpenBytes() ([]byte, error) {
+	n, err := d.readLength()
+	if err != nil {
+		return nil, err
+	}
+	return d.readBytes(n)
+}
+
+func (d *oerDecoder) decodeInteger(opts *fieldOptions) (*big.Int, error) {
+	var n *big.Int
+	width, signed := oerIntegerWidth(opts.valueRange, opts.extensible)
+	if width > 0 {
+		buf, err := d.readBytes(width)
+		if err != nil {
+			return nil, err
+		}
+		if signed {
+			n = parseBigInt(buf)
+		} else {
+			n = new(big.Int).SetBytes(buf)
+		}
+	} else {
+		buf, err := d.readOpenBytes()
+		if err != nil {
+			return nil, err
+		}
+		if len(buf) == 0 {
+			return nil, parseError("empty OER integer")
+		}
+		if signed {
+			if d.canonical {
+				if err := checkMinimalInt(buf); err != nil {
+					return nil, err
+				}
+			}
+			n = parseBigInt(buf)
+		} else {
+			if d.canonical && len(buf) > 1 && buf[0] == 0x00 {
+				return nil, parseError("integer not encoded in the short form")
+			}
+			n = new(big.Int).SetBytes(buf)
+		}
+	}
+	r := opts.valueRange
+	if r != nil && !opts.extensible && !(n.IsInt64() && r.contains(n.Int64())) {
+		return nil, parseError("integer %s does not satisfy the value constraint", n)
+	}
+	return n, nil
+}
+
+func (d *oerDecoder) decodeEnumerated(value reflect.Value, opts *fieldOptions) error {
+	b, err := d.readBytes(1)
+	if err != nil {
+		return err
+	}
+	n := big.NewInt(int64(b[0]))
+	if b[0] >= 0x80 {
+		buf, err := d.readBytes(int(b[0] & 0x7f))
+		if err != nil {
+			return err
+		}
+		if len(buf) == 0 {
+			return parseError("empty OER enumerated value")
+		}
+		n = parseBigInt(buf)
+		if d.canonical {
+			if err := checkMinimalInt(buf); err != nil {
+				return err
+			}
+			if n.Sign() >= 0 && n.Cmp(big.NewInt(0x7f)) <= 0 {
+				return parseError("enumerated value not encoded in the short form")
+			}
+		}
+	}
+	root, additions, err := d.ctx.getEnumValues(*opts.enum)
+	if err != nil {
+		return err
+	}
+	valid := false
+	for _, entry := range root {
+		valid = valid || n.Cmp(big.NewInt(int64(entry.Value))) == 0
+	}
+	for _, entry := range additions {
+		valid = valid || opts.extensible && n.Cmp(big.NewInt(int64(entry.Value))) == 0
+	}
+	if !valid {
+		return parseError("invalid value %s for enum '%s'", n, *opts.enum)
+	}
+	return setBigInt(value, n)
+}
+
+func (d *oerDecoder) decodeBitString(value reflect.Value, opts *fieldOptions) error {
+	var bs BitString
+	if size, ok := oerFixedSize(opts); ok {
+		data, err := d.readBytes((size + 7) / 8)
+		if err != nil {
+			return err
+		}
+		bs = BitString{data, size}
+	} else {
+		data, err := d.readOpenBytes()
+		if err != nil {
+			return err
+		}
+		if len(data) == 0 || data[0] > 7 || len(data) == 1 && data[0] != 0 {
+			return parseError("invalid padding bits in BIT STRING")
+		}
+		bs = BitString{data[1:], (len(data)-1)*8 - int(data[0])}
+	}
+	if d.canonical && len(bs.Bytes) > 0 {
+		unused := uint(len(bs.Bytes)*8 - bs.BitLength)
+		if bs.Bytes[len(bs.Bytes)-1]&(1<<unused-1) != 0 {
+			return parseError("invalid padding bits in BIT STRING")
+		}
+	}
+	if !opts.extensible && !opts.size.contains(int64(bs.BitLength)) {
+		return parseError("size %d does not satisfy the size constraint", bs.BitLength)
+	}
+	value.Set(reflect.ValueOf(bs))
+	return nil
+}
+
+func (d *oerDecoder) decodeOctetString(opts *fieldOptions) ([]byte, error) {
+	var data []byte
+	var err error
+	if size, ok := oerFixedSize(opts); ok {
+		data, err = d.readBytes(size)
+	} else {
+		data, err = d.readOpenBytes()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !opts.extensible && !opts.size.contains(int64(len(data))) {
+		return nil, parseError("size %d does not satisfy the size constraint", len(data))
+	}
+	return data, nil
+}
+
+func (d *oerDecoder) decodeString(value reflect.Value, opts *fieldOptions) error {
+	var data []byte
+	var err error
+	if opts.stringTag != nil && getPerAlphabet(*opts.stringTag) == nil {
+		data, err = d.readOpenBytes()
+	} else {
+		data, err = d.decodeOctetString(opts)
+	}
+	if err != nil {
+		return err
+	}
+	if opts.stringTag != nil {
+		if alphabet := getPerAlphabet(*opts.stringTag); alphabet != nil {
+			for _, c := range string(data) {
+				if _, ok := alphabet.code(c); !ok {
+					return parseError("invalid character %q for string type", c)
+				}
+			}
+		}
+	}
+	value.SetString(string(data))
+	return nil
+}
+
+func (d *oerDecoder) decodeSequenceOf(value reflect.Value, opts *fieldOptions) error {
+	buf, err := d.readOpenBytes()
+	if err != nil {
+		return err
+	}
+	if d.canonical && (len(buf) == 0 || len(buf) > 1 && buf[0] == 0x00) {
+		return parseError("quantity not encoded in the canonical form")
+	}
+	quantity := new(big.Int).SetBytes(buf)
+	if !quantity.IsInt64() || quantity.Int64() > int64(d.reader.Len()) {
+		return parseError("invalid OER quantity")
+	}
+
+	slice := reflect.MakeSlice(reflect.SliceOf(value.Type().Elem()), 0, 0)
+	for i := int64(0); i < quantity.Int64(); i++ {
+		elem := reflect.New(value.Type().Elem()).Elem()
+		if err := d.decode(elem, opts.elemOptions()); err != nil {
+			return err
+		}
+		slice = reflect.Append(slice, elem)
+	}
+	if value.Kind() == reflect.Slice {
+		value.Set(slice)
+		return nil
+	}
+	if slice.Len() != value.Len() {
+		return parseError("expected %d elements but found %d", value.Len(), slice.Len())
+	}
+	reflect.Copy(value, slice)
+	return nil
+}
+
+func (d *oerDecoder) decodeStruct(value reflect.Value, opts *fieldOptions) error {
+	root, additions, err := d.ctx.getComponents(value.Type(), opts.set)
+	if err != nil {
+		return err
+	}
+
+	// Preamble
+	count := 0
+	if opts.extensible {
+		count++
+	}
+	for _, field := range root {
+		if isOptional(field.opts) {
+			count++
+		}
+	}
+	data, err := d.readBytes((count + 7) / 8)
+	if err != nil {
+		return err
+	}
+	preamble := bitReader{data: data}
+	extended := false
+	if opts.extensible {
+		extended, _ = preamble.readBit()
+	}
+	present := make([]bool, len(root))
+	for i, field := range root {
+		present[i] = true
+		if isOptional(field.opts) {
+			present[i], _ = preamble.readBit()
+		}
+	}
+	if d.canonical && count%8 != 0 && data[len(data)-1]&(1<<uint(8-count%8)-1) != 0 {
+		return parseError("invalid padding bits in OER preamble")
+	}
+
+	// Root components
+	for i, field := range root {
+		fieldValue := value.Field(field.Index[0])
+		if !present[i] {
+			if field.opts.defaultValue != nil {
+				if err := d.ctx.setDefaultValue(fieldValue, field.opts); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if err := d.decode(fieldValue, field.opts); err != nil {
+			return err
+		}
+	}
+
+	// Extension additions
+	var bitmap []bool
+	if extended {
+		data, err := d.readOpenBytes()
+		if err != nil {
+			return err
+		}
+		if len(data) < 2 || data[0] > 7 {
+			return parseError("invalid OER extension bitmap")
+		}
+		reader := bitReader{data: data[1:]}
+		bitmap = make([]bool, (len(data)-1)*8-int(data[0]))
+		for i := range bitmap {
+			bitmap[i], _ = reader.readBit()
+		}
+	}
+	for i, field := range additions {
+		fieldValue := value.Field(field.Index[0])
+		if i >= len(bitmap) || !bitmap[i] {
+			if field.opts.defaultValue != nil {
+				if err := d.ctx.setDefaultValue(fieldValue, field.opts); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if err := d.decodeOpenType(fieldValue, field.opts); err != nil {
+			return err
+		}
+	}
+	// Skip unknown extension additions
+	for i := len(additions); i < len(bitmap); i++ {
+		if bitmap[i] {
+			if _, err := d.readOpenBytes(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (d *oerDecoder) decodeChoice(value reflect.Value, opts *fieldOptions) error {
+	b, err := d.readBytes(1)
+	if err != nil {
+		return err
+	}
+	class := uint(b[0] >> 6)
+	tag := uint(b[0] & 0x3f)
+	if tag == 0x3f {
+		tag, err = decodeMultiByteTag(d.reader)
+		if err != nil {
+			return err
+		}
+	}
+	entry, err := d.ctx.getChoiceByTag(*opts.choice, class, tag)
+	if err != nil {
+		return err
+	}
+	nestedValue := reflect.New(entry.typ).Elem()
+	if entry.opts.addition {
+		err = d.decodeOpenType(nestedValue, entry.opts)
+	} else {
+		err = d.decode(nestedValue, entry.opts)
+	}
+	if err != nil {
+		return err
+	}
+	value.Set(nestedValue)
+	return nil
+}
+
+// decodeOpenType decodes a value preceded by the length of its encoding.
+func (d *oerDecoder) decodeOpenType(value reflect.Value, opts *fieldOptions) error {
+	data, err := d.readOpenBytes()
+	if err != nil {
+		return err
+	}
+	inner := &oerDecoder{ctx: d.ctx, canonical: d.canonical}
+	inner.reader = bytes.NewBuffer(data)
+	if err := inner.decode(value, opts); err != nil {
+		return err
+	}
+	if d.canonical && inner.reader.Len() > 0 {
+		return parseError("unexpected data in OER open type")
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+package asn1
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+)
+
+// testOer encodes an object using OER, compares with the expected bytes and
+// then decodes the result back using both OER and COER.
+func testOer(t *testing.T, ctx *Context, options string, value interface{}, expected []byte) {
+	data, err := ctx.EncodeOer(value, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !isBytesEqual(data, expected) {
+		t.Fatalf("Failed to encode \"%v\".\n Expected: %#v.\n Got:      %#v",
+			value, expected, data)
+	}
+	for _, decode := range []func([]byte, interface{}, string) ([]byte, error){
+		ctx.DecodeOer, ctx.DecodeCoer,
+	} {
+		decoded := reflect.New(reflect.TypeOf(value))
+		rest, err := decode(data, decoded.Interface(), options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(rest) > 0 {
+			t.Fatalf("Unexpected remaining bytes when decoding \"%v\": %#v\n",
+				value, rest)
+		}
+		checkEqual(t, decoded.Elem().Interface(), value)
+	}
+}
+
+func TestOerInteger(t *testing.T) {
+	ctx := NewContext()
+	testOer(t, ctx, "range:0..255", 5, []byte{0x05})
+	testOer(t, ctx, "range:0..65535", 258, []byte{0x01, 0x02})
+	testOer(t, ctx, "range:0..4294967295", uint32(1), []byte{0x00, 0x00, 0x00, 0x01})
+	testOer(t, ctx, "range:-128..127", -1, []byte{0xff})
+	testOer(t, ctx, "range:-129..127", -1, []byte{0xff, 0xff})
+	testOer(t, ctx, "range:0..MAX", 256, []byte{0x02, 0x01, 0x00})
+	testOer(t, ctx, "", 1000, []byte{0x02, 0x03, 0xe8})
+	testOer(t, ctx, "range:0..7,extensible", 10, []byte{0x01, 0x0a})
+	testOer(t, ctx, "", new(big.Int).Lsh(big.NewInt(1), 64),
+		[]byte{0x09, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00})
+	testOer(t, ctx, "", true, []byte{0xff})
+	testOer(t, ctx, "", Null{}, []byte{})
+}
+
+func TestOerStrings(t *testing.T) {
+	ctx := NewContext()
+	testOer(t, ctx, "ia5,size:3", "abc", []byte{0x61, 0x62, 0x63})
+	testOer(t, ctx, "ia5,size:1..3", "abc", []byte{0x03, 0x61, 0x62, 0x63})
+	testOer(t, ctx, "utf8,size:1", "ç", []byte{0x02, 0xc3, 0xa7})
+	testOer(t, ctx, "", BitString{[]byte{0xa0}, 3}, []byte{0x02, 0x05, 0xa0})
+	testOer(t, ctx, "size:3", BitString{[]byte{0xa0}, 3}, []byte{0xa0})
+	testOer(t, ctx, "", Oid{1, 2, 840}, []byte{0x03, 0x2a, 0x86, 0x48})
+
+	long := make([]byte, 200)
+	testOer(t, ctx, "", long, append([]byte{0x81, 0xc8}, long...))
+}
+
+func TestOerSequence(t *testing.T) {
+	type Type struct {
+		A bool
+		B int `asn1:"range:0..7,optional"`
+		C int `asn1:"range:0..7,default:1"`
+	}
+	ctx := NewContext()
+	testOer(t, ctx, "", Type{true, 3, 1}, []byte{0x80, 0xff, 0x03})
+	testOer(t, ctx, "", Type{false, 0, 2}, []byte{0x40, 0x00, 0x02})
+	testOer(t, ctx, "", []int{1, 2}, []byte{0x01, 0x02, 0x01, 0x01, 0x01, 0x02})
+
+	type V1 struct {
+		A int `asn1:"range:0..7"`
+	}
+	type V2 struct {
+		A int  `asn1:"range:0..7"`
+		B bool `asn1:"addition"`
+	}
+	testOer(t, ctx, "extensible", V2{5, true},
+		[]byte{0x80, 0x05, 0x02, 0x07, 0x80, 0x01, 0xff})
+	v1 := V1{}
+	_, err := ctx.DecodeOer([]byte{0x80, 0x05, 0x02, 0x07, 0x80, 0x01, 0xff}, &v1, "extensible")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkEqual(t, v1, V1{5})
+}
+
+func TestOerChoiceEnum(t *testing.T) {
+	type Msg struct {
+		Color int         `asn1:"enum:color"`
+		Value interface{} `asn1:"choice:value,extensible"`
+	}
+	ctx := NewContext()
+	ctx.AddEnum("color", []Enum{{Name: "red", Value: 0}, {Name: "blue", Value: 128}})
+	ctx.AddChoice("value", []Choice{
+		{reflect.TypeOf(""), "tag:1"},
+		{reflect.TypeOf(false), "tag:2,addition"},
+	})
+	testOer(t, ctx, "", Msg{0, "a"}, []byte{0x00, 0x81, 0x01, 0x61})
+	testOer(t, ctx, "", Msg{128, true}, []byte{0x82, 0x00, 0x80, 0x82, 0x01, 0xff})
+}
+
+func TestOerBitStringPadding(t *testing.T) {
+	ctx := NewContext()
+	bs := BitString{[]byte{0xff}, 3}
+	for options, expected := range map[string][]byte{
+		"":       {0x02, 0x05, 0xe0},
+		"size:3": {0xe0},
+	} {
+		data, err := ctx.EncodeCoer(bs, options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !isBytesEqual(data, expected) {
+			t.Fatalf("Unexpected encoding: %#v", data)
+		}
+		decoded := BitString{}
+		if _, err = ctx.DecodeCoer(data, &decoded, options); err != nil {
+			t.Fatal(err)
+		}
+		checkEqual(t, decoded, BitString{[]byte{0xe0}, 3})
+	}
+	checkEqual(t, bs.Bytes, []byte{0xff})
+}
+
+func TestCoer(t *testing.T) {
+	ctx := NewContext()
+	tests := []struct {
+		options string
+		data    []byte
+	}{
+		{"", []byte{0x01}},
+		{"ia5", []byte{0x81, 0x01, 0x61}},
+		{"", []byte{0x02, 0x00, 0x01}},
+	}
+	for _, test := range tests {
+		var value interface{} = false
+		if test.options != "" {
+			value = ""
+		} else if len(test.data) > 1 {
+			value = 0
+		}
+		ptr := reflect.New(reflect.TypeOf(value)).Interface()
+		if _, err := ctx.DecodeOer(test.data, ptr, test.options); err != nil {
+			t.Fatal(err)
+		}
+		_, err := ctx.DecodeCoer(test.data, ptr, test.options)
+		if _, ok := err.(*ParseError); !ok {
+			t.Fatalf("COER should not accept %#v: %v", test.data, err)
+		}
+	}
+}
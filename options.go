@@ -1,6 +1,8 @@
 package asn1
 
 import (
+	"reflect"
+	"sort"
 	"strconv"
 	"strings"
 )
@@ -12,9 +14,49 @@ type fieldOptions struct {
 	indefinite   bool
 	optional     bool
 	set          bool
+	extensible   bool
+	addition     bool
 	tag          *int
 	defaultValue *int
 	choice       *string
+	enum         *string
+	name         *string
+	stringTag    *int
+	valueRange   *bounds
+	size         *bounds
+	// Options of the elements of arrays and slices
+	elem *fieldOptions
+}
+
+// bounds holds the limits of a range or size constraint. A nil limit stands
+// for MIN or MAX.
+type bounds struct {
+	lower *int64
+	upper *int64
+}
+
+// constrained returns true if both limits are defined.
+func (b *bounds) constrained() bool {
+	return b != nil && b.lower != nil && b.upper != nil
+}
+
+// fixed returns true if both limits are defined and equal.
+func (b *bounds) fixed() bool {
+	return b.constrained() && *b.lower == *b.upper
+}
+
+// contains checks if n is within the limits.
+func (b *bounds) contains(n int64) bool {
+	if b == nil {
+		return true
+	}
+	if b.lower != nil && n < *b.lower {
+		return false
+	}
+	if b.upper != nil && n > *b.upper {
+		return false
+	}
+	return true
 }
 
 // validate returns an error if any option is invalid.
@@ -35,9 +77,43 @@ func (opts *fieldOptions) validate() error {
 	if opts.choice != nil && *opts.choice == "" {
 		return syntaxError("'choice' cannot be empty")
 	}
+	if opts.enum != nil && *opts.enum == "" {
+		return syntaxError("'enum' cannot be empty")
+	}
+	if opts.name != nil && *opts.name == "" {
+		return syntaxError("'name' cannot be empty")
+	}
+	if opts.valueRange.constrained() && *opts.valueRange.lower > *opts.valueRange.upper {
+		return syntaxError("invalid 'range': lower bound greater than upper bound")
+	}
+	if opts.size != nil {
+		if opts.size.lower != nil && *opts.size.lower < 0 {
+			return syntaxError("'size' cannot be negative: %d", *opts.size.lower)
+		}
+		if opts.size.constrained() && *opts.size.lower > *opts.size.upper {
+			return syntaxError("invalid 'size': lower bound greater than upper bound")
+		}
+	}
+	if opts.elem != nil {
+		if opts.elem.optional || opts.elem.defaultValue != nil || opts.elem.addition || opts.elem.name != nil {
+			return syntaxError("'elem' does not accept 'optional', 'default', 'addition' or 'name'")
+		}
+		return opts.elem.validate()
+	}
 	return nil
 }
 
+// elemOptions returns a copy of the options of the elements of an array or
+// slice. A copy is returned since decoders may change the options of explicit
+// tags.
+func (opts *fieldOptions) elemOptions() *fieldOptions {
+	if opts.elem == nil {
+		return &fieldOptions{}
+	}
+	elem := *opts.elem
+	return &elem
+}
+
 // parseOption returns a parsed fieldOptions or an error. Returns nil for the ignore tag "-".
 func parseOptions(s string) (*fieldOptions, error) {
 	if s == "-" {
@@ -57,6 +133,166 @@ func parseOptions(s string) (*fieldOptions, error) {
 	return &opts, nil
 }
 
+// String returns the options in the format of struct tags.
+func (opts *fieldOptions) String() string {
+	s := []string{}
+	if opts.tag != nil {
+		s = append(s, "tag:"+strconv.Itoa(*opts.tag))
+	}
+	flags := []struct {
+		set  bool
+		name string
+	}{
+		{opts.universal, "universal"},
+		{opts.application, "application"},
+		{opts.explicit, "explicit"},
+		{opts.indefinite, "indefinite"},
+		{opts.optional, "optional"},
+		{opts.set, "set"},
+	}
+	for _, flag := range flags {
+		if flag.set {
+			s = append(s, flag.name)
+		}
+	}
+	if opts.defaultValue != nil {
+		s = append(s, "default:"+strconv.Itoa(*opts.defaultValue))
+	}
+	for _, arg := range []struct {
+		value *string
+		name  string
+	}{{opts.choice, "choice"}, {opts.enum, "enum"}, {opts.name, "name"}} {
+		if arg.value != nil {
+			s = append(s, arg.name+":"+*arg.value)
+		}
+	}
+	if opts.stringTag != nil {
+		for name, tag := range stringTags {
+			if tag == *opts.stringTag {
+				s = append(s, name)
+			}
+		}
+	}
+	if opts.valueRange != nil {
+		s = append(s, "range:"+opts.valueRange.String())
+	}
+	if opts.size != nil {
+		s = append(s, "size:"+opts.size.String())
+	}
+	if opts.extensible {
+		s = append(s, "extensible")
+	}
+	if opts.addition {
+		s = append(s, "addition")
+	}
+	if opts.elem != nil {
+		for _, elem := range strings.Split(opts.elem.String(), ",") {
+			if elem != "" {
+				s = append(s, "elem:"+elem)
+			}
+		}
+	}
+	return strings.Join(s, ",")
+}
+
+// String returns the limits in the format of the options "range" and "size".
+func (b *bounds) String() string {
+	if b.fixed() {
+		return strconv.FormatInt(*b.lower, 10)
+	}
+	lower, upper := "MIN", "MAX"
+	if b.lower != nil {
+		lower = strconv.FormatInt(*b.lower, 10)
+	}
+	if b.upper != nil {
+		upper = strconv.FormatInt(*b.upper, 10)
+	}
+	return lower + ".." + upper
+}
+
+// structField is an exported struct field along with its parsed options.
+type structField struct {
+	reflect.StructField
+	opts *fieldOptions
+}
+
+// getStructFields returns the exported fields of a struct type and their
+// options. Fields marked with the ignore tag "-" are not included.
+func getStructFields(objType reflect.Type) ([]structField, error) {
+	fields := []structField{}
+	for i := 0; i < objType.NumField(); i++ {
+		field := objType.Field(i)
+		// Ignore field that are not exported (that starts with lowercase)
+		if !isFieldExported(field) {
+			continue
+		}
+		opts, err := parseOptions(field.Tag.Get(tagKey))
+		if err != nil {
+			return nil, err
+		}
+		// Skip if the ignore tag is given
+		if opts == nil {
+			continue
+		}
+		fields = append(fields, structField{field, opts})
+	}
+	return fields, nil
+}
+
+// getComponents splits the fields of a struct type in root components and
+// extension additions. The root components of a SET are sorted in the
+// canonical order of their tags.
+func (ctx *Context) getComponents(objType reflect.Type, set bool) (root, additions []structField, err error) {
+	fields, err := getStructFields(objType)
+	if err != nil {
+		return
+	}
+	for _, field := range fields {
+		if field.opts.addition {
+			additions = append(additions, field)
+		} else {
+			root = append(root, field)
+		}
+	}
+	if !set {
+		return
+	}
+	type tag struct{ class, number uint }
+	tags := make(map[int]tag)
+	for _, field := range root {
+		var t tag
+		t.class, t.number, err = ctx.getComponentTag(field)
+		if err != nil {
+			return
+		}
+		tags[field.Index[0]] = t
+	}
+	sort.SliceStable(root, func(i, j int) bool {
+		a, b := tags[root[i].Index[0]], tags[root[j].Index[0]]
+		return isTagLessThan(a.class, a.number, b.class, b.number)
+	})
+	return
+}
+
+// getComponentTag returns the tag used to sort a component in the canonical
+// order. The smallest tag among the alternatives is used for choices.
+func (ctx *Context) getComponentTag(field structField) (class, tag uint, err error) {
+	if field.opts.choice == nil {
+		var elem expectedElement
+		elem, err = ctx.getExpectedElement(&rawValue{}, field.Type, field.opts)
+		return elem.class, elem.tag, err
+	}
+	root, _, err := ctx.getChoiceAlternatives(*field.opts.choice)
+	if err != nil {
+		return
+	}
+	if len(root) == 0 {
+		err = syntaxError("choice '%s' has no root alternatives", *field.opts.choice)
+		return
+	}
+	return root[0].class, root[0].tag, nil
+}
+
 // parseOption parse a single option.
 func parseOption(opts *fieldOptions, args []string) error {
 	var err error
@@ -82,6 +318,12 @@ func parseOption(opts *fieldOptions, args []string) error {
 	case "set":
 		opts.set, err = parseBoolOption(args)
 
+	case "extensible":
+		opts.extensible, err = parseBoolOption(args)
+
+	case "addition":
+		opts.addition, err = parseBoolOption(args)
+
 	case "tag":
 		opts.tag, err = parseIntOption(args)
 
@@ -91,6 +333,33 @@ func parseOption(opts *fieldOptions, args []string) error {
 	case "choice":
 		opts.choice, err = parseStringOption(args)
 
+	case "enum":
+		opts.enum, err = parseStringOption(args)
+
+	case "name":
+		opts.name, err = parseStringOption(args)
+
+	case "utf8", "numeric", "printable", "ia5", "visible":
+		if opts.stringTag != nil {
+			return syntaxError("only one string type can be used: '%s'", args[0])
+		}
+		opts.stringTag, err = parseStringTypeOption(args)
+
+	case "range":
+		opts.valueRange, err = parseBoundsOption(args)
+
+	case "size":
+		opts.size, err = parseBoundsOption(args)
+
+	case "elem":
+		if len(args) < 2 {
+			return syntaxError("option '%s' requires an argument.", args[0])
+		}
+		if opts.elem == nil {
+			opts.elem = &fieldOptions{}
+		}
+		err = parseOption(opts.elem, args[1:])
+
 	default:
 		err = syntaxError("Invalid option: %s", args[0])
 	}
@@ -109,7 +378,7 @@ func parseBoolOption(args []string) (bool, error) {
 // parseIntOption parses an integer argument.
 func parseIntOption(args []string) (*int, error) {
 	if len(args) != 2 {
-		return nil, syntaxError("option '%s' does not have arguments.")
+		return nil, syntaxError("option '%s' requires an argument.", args[0])
 	}
 	num, err := strconv.Atoi(args[1])
 	if err != nil {
@@ -122,7 +391,67 @@ func parseIntOption(args []string) (*int, error) {
 // parseStringOption parses a string argument.
 func parseStringOption(args []string) (*string, error) {
 	if len(args) != 2 {
-		return nil, syntaxError("option '%s' does not have arguments.")
+		return nil, syntaxError("option '%s' requires an argument.", args[0])
 	}
 	return &args[1], nil
 }
+
+// stringTags maps the string type options to their universal tags.
+var stringTags = map[string]int{
+	"utf8":      tagUtf8String,
+	"numeric":   tagNumericString,
+	"printable": tagPrintableString,
+	"ia5":       tagIA5String,
+	"visible":   tagVisibleString,
+}
+
+// parseStringTypeOption returns the universal tag of a string type option.
+func parseStringTypeOption(args []string) (*int, error) {
+	if _, err := parseBoolOption(args); err != nil {
+		return nil, err
+	}
+	tag := stringTags[args[0]]
+	return &tag, nil
+}
+
+// parseBoundsOption parses a constraint in the form "lower..upper" or a single
+// value for fixed constraints. The words MIN and MAX can be used for
+// unbounded limits.
+func parseBoundsOption(args []string) (*bounds, error) {
+	if len(args) != 2 {
+		return nil, syntaxError("option '%s' requires an argument.", args[0])
+	}
+	invalid := syntaxError("invalid value '%s' for option '%s'.", args[1], args[0])
+	parseLimit := func(s string, unbounded string) (*int64, error) {
+		if s == "" {
+			return nil, invalid
+		}
+		if s == unbounded {
+			return nil, nil
+		}
+		num, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return nil, invalid
+		}
+		return &num, nil
+	}
+	var err error
+	var b bounds
+	limits := strings.Split(args[1], "..")
+	switch len(limits) {
+	case 1:
+		b.lower, err = parseLimit(limits[0], "")
+		b.upper = b.lower
+	case 2:
+		b.lower, err = parseLimit(limits[0], "MIN")
+		if err == nil {
+			b.upper, err = parseLimit(limits[1], "MAX")
+		}
+	default:
+		err = invalid
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
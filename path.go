@@ -0,0 +1,296 @@
+package asn1
+
+import (
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Match is an element found by Select.
+type Match struct {
+	// Offset of the element in the data
+	Offset int
+	// Raw encoding of the element, including its header
+	Raw  []byte
+	Node *Node
+}
+
+// Select finds the elements in data that match a path expression, without any
+// knowledge of their types. The data may contain several consecutive elements,
+// like a file of records.
+//
+// An expression is a sequence of steps separated by "/". Each step selects
+// elements among the children of the elements selected by the previous step,
+// starting with the elements in data. The following steps are accepted:
+//
+//	N            the child at index N, starting at 0
+//	*            all children
+//	[N]          children with the context-specific tag N
+//	[CLASS N]    children with the given class (UNIVERSAL, APPLICATION,
+//	             CONTEXT or PRIVATE) and tag N
+//	OID=1.2.3    children that are OBJECT IDENTIFIERs with the given value
+//	INTEGER=N    children that are INTEGERs with the given value
+//	STRING=text  children that are strings with the given text
+//	.            the element itself
+//	..           the parent element
+//
+// A step preceded by "//" instead of "/" selects among all descendants. For
+// example, "0/2/[APPLICATION 1]/1" selects the second child of the elements
+// tagged as [APPLICATION 1] in the third child of the first element, and
+// "//OID=2.5.4.3/../1" selects the values that follow the OID 2.5.4.3 in the
+// same SEQUENCE.
+//
+// The matches are returned in the order they appear in data.
+func Select(data []byte, expr string) ([]Match, error) {
+	root := &Node{Constructed: true}
+	for offset := 0; offset < len(data); {
+		node, err := parseNode(data[offset:], offset)
+		if err != nil {
+			return nil, err
+		}
+		root.Children = append(root.Children, node)
+		offset += node.Len()
+	}
+	nodes, err := root.Select(expr)
+	if err != nil {
+		return nil, err
+	}
+	matches := make([]Match, len(nodes))
+	for i, node := range nodes {
+		matches[i] = Match{node.Offset, data[node.Offset : node.Offset+node.Len()], node}
+	}
+	return matches, nil
+}
+
+// Select finds the descendants of the node that match a path expression. The
+// first step of the expression selects among the children of the node.
+//
+// See Select() for further details.
+func (n *Node) Select(expr string) ([]*Node, error) {
+	steps, err := parsePath(expr)
+	if err != nil {
+		return nil, err
+	}
+	parents := map[*Node]*Node{}
+	setParents(n, parents)
+
+	current := []*Node{n}
+	for _, step := range steps {
+		selected := []*Node{}
+		for _, node := range current {
+			candidates := []*Node{node}
+			if step.descendants {
+				candidates = getDescendants(node, candidates)
+			}
+			for _, candidate := range candidates {
+				selected = append(selected, step.apply(candidate, parents)...)
+			}
+		}
+		current = uniqueNodes(selected)
+	}
+
+	// The starting node is not a result
+	result := []*Node{}
+	for _, node := range current {
+		if node != n {
+			result = append(result, node)
+		}
+	}
+	return result, nil
+}
+
+// setParents maps the children of a node to their parent.
+func setParents(node *Node, parents map[*Node]*Node) {
+	for _, child := range node.Children {
+		parents[child] = node
+		setParents(child, parents)
+	}
+}
+
+// getDescendants appends all descendants of a node to list.
+func getDescendants(node *Node, list []*Node) []*Node {
+	for _, child := range node.Children {
+		list = append(list, child)
+		list = getDescendants(child, list)
+	}
+	return list
+}
+
+// uniqueNodes removes duplicated nodes and sorts them by offset.
+func uniqueNodes(nodes []*Node) []*Node {
+	seen := map[*Node]bool{}
+	unique := []*Node{}
+	for _, node := range nodes {
+		if !seen[node] {
+			seen[node] = true
+			unique = append(unique, node)
+		}
+	}
+	sort.SliceStable(unique, func(i, j int) bool {
+		return unique[i].Offset < unique[j].Offset
+	})
+	return unique
+}
+
+// pathStep is a parsed step of a path expression.
+type pathStep struct {
+	descendants bool
+	// Only one of the following is used
+	self, parent bool
+	index        int
+	match        func(*Node) bool
+}
+
+// apply returns the nodes selected by the step for the given node.
+func (s pathStep) apply(node *Node, parents map[*Node]*Node) []*Node {
+	switch {
+	case s.self:
+		return []*Node{node}
+	case s.parent:
+		if parent := parents[node]; parent != nil {
+			return []*Node{parent}
+		}
+		return nil
+	case s.match != nil:
+		selected := []*Node{}
+		for _, child := range node.Children {
+			if s.match(child) {
+				selected = append(selected, child)
+			}
+		}
+		return selected
+	}
+	if s.index < len(node.Children) {
+		return []*Node{node.Children[s.index]}
+	}
+	return nil
+}
+
+var pathClasses = map[string]uint{
+	"UNIVERSAL":   ClassUniversal,
+	"APPLICATION": ClassApplication,
+	"CONTEXT":     ClassContextSpecific,
+	"PRIVATE":     ClassPrivate,
+}
+
+// parsePath parses a path expression.
+func parsePath(expr string) ([]pathStep, error) {
+	if expr == "" {
+		return nil, syntaxError("empty path expression")
+	}
+	parts := strings.Split(expr, "/")
+	steps := []pathStep{}
+	descendants := false
+	for i, text := range parts {
+		if text == "" {
+			// An absolute path is the same as a relative one
+			if i == 0 {
+				continue
+			}
+			// The second slash of "//"
+			if descendants || i == len(parts)-1 {
+				return nil, syntaxError("invalid path expression '%s'", expr)
+			}
+			descendants = true
+			continue
+		}
+		step, err := parsePathStep(strings.TrimSpace(text))
+		if err != nil {
+			return nil, err
+		}
+		step.descendants = descendants
+		descendants = false
+		steps = append(steps, step)
+	}
+	return steps, nil
+}
+
+// parsePathStep parses a single step of a path expression.
+func parsePathStep(text string) (step pathStep, err error) {
+	switch {
+	case text == ".":
+		step.self = true
+	case text == "..":
+		step.parent = true
+	case text == "*":
+		step.match = func(*Node) bool { return true }
+	case strings.HasPrefix(text, "[") && strings.HasSuffix(text, "]"):
+		class, tag, err := parsePathTag(text[1 : len(text)-1])
+		if err != nil {
+			return step, err
+		}
+		step.match = func(n *Node) bool { return n.Class == class && n.Tag == tag }
+	case strings.Contains(text, "="):
+		parts := strings.SplitN(text, "=", 2)
+		step.match, err = parsePathValue(parts[0], parts[1])
+	default:
+		step.index, err = strconv.Atoi(text)
+		if err != nil || step.index < 0 {
+			err = syntaxError("invalid path step '%s'", text)
+		}
+	}
+	return
+}
+
+// parsePathTag parses the content of a tag step, like "APPLICATION 1".
+func parsePathTag(text string) (class, tag uint, err error) {
+	fields := strings.Fields(text)
+	class = ClassContextSpecific
+	if len(fields) == 2 {
+		var ok bool
+		class, ok = pathClasses[fields[0]]
+		if !ok {
+			return 0, 0, syntaxError("invalid class '%s' in path", fields[0])
+		}
+		fields = fields[1:]
+	}
+	if len(fields) != 1 {
+		return 0, 0, syntaxError("invalid tag '[%s]' in path", text)
+	}
+	n, err := strconv.ParseUint(fields[0], 10, 0)
+	if err != nil {
+		return 0, 0, syntaxError("invalid tag '[%s]' in path", text)
+	}
+	return class, uint(n), nil
+}
+
+// parsePathValue returns a function that checks if a node is an universal
+// value of the given type and value.
+func parsePathValue(typeName, text string) (func(*Node) bool, error) {
+	switch typeName {
+	case "OID":
+		expected, err := parseOid(text)
+		if err != nil {
+			return nil, err
+		}
+		return func(n *Node) bool {
+			if n.Class != ClassUniversal || n.Tag != tagOid {
+				return false
+			}
+			oid, err := n.AsOid()
+			return err == nil && oid.Cmp(expected) == 0
+		}, nil
+	case "INTEGER":
+		expected, ok := new(big.Int).SetString(text, 10)
+		if !ok {
+			return nil, syntaxError("invalid integer '%s' in path", text)
+		}
+		return func(n *Node) bool {
+			if n.Class != ClassUniversal || n.Tag != tagInteger {
+				return false
+			}
+			value, err := n.AsBigInt()
+			return err == nil && value.Cmp(expected) == 0
+		}, nil
+	case "STRING":
+		return func(n *Node) bool {
+			if n.Class != ClassUniversal || !isStringTag(n.Tag) || n.Tag == tagBitString {
+				return false
+			}
+			value, err := n.AsString()
+			return err == nil && value == text
+		}, nil
+	}
+	return nil, syntaxError("invalid value type '%s' in path", typeName)
+}
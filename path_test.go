@@ -0,0 +1,73 @@
+package asn1
+
+import (
+	"testing"
+)
+
+func TestSelect(t *testing.T) {
+	data := []byte{
+		// SEQ LEN=32
+		0x30, 0x20,
+		// SET LEN=12 { SEQ LEN=10 { OID 2.5.4.3, PrintableString "Bob" } }
+		0x31, 0x0c, 0x30, 0x0a,
+		0x06, 0x03, 0x55, 0x04, 0x03,
+		0x13, 0x03, 0x42, 0x6f, 0x62,
+		// SET LEN=11 { SEQ LEN=9 { OID 2.5.4.6, PrintableString "BR" } }
+		0x31, 0x0b, 0x30, 0x09,
+		0x06, 0x03, 0x55, 0x04, 0x06,
+		0x13, 0x02, 0x42, 0x52,
+		// [APPLICATION 1] { INTEGER 7 }
+		0x61, 0x03, 0x02, 0x01, 0x07,
+		// Second element: INTEGER 5
+		0x02, 0x01, 0x05,
+	}
+	testCases := []struct {
+		expr    string
+		offsets []int
+	}{
+		{"0", []int{0}},
+		{"/1", []int{34}},
+		{"0/1/0/1", []int{25}},
+		{"0/*/0/0", []int{6, 20}},
+		{"0/[APPLICATION 1]/0", []int{31}},
+		{"0/[UNIVERSAL 17]", []int{2, 16}},
+		{"//OID=2.5.4.3/../1", []int{11}},
+		{"//STRING=BR", []int{25}},
+		{"//INTEGER=5", []int{34}},
+		{"0/0/./0/..", []int{2}},
+		{"0/5", []int{}},
+		{"//[3]", []int{}},
+	}
+	for _, test := range testCases {
+		matches, err := Select(data, test.expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		offsets := []int{}
+		for _, m := range matches {
+			offsets = append(offsets, m.Offset)
+			checkEqual(t, m.Raw, data[m.Offset:m.Offset+m.Node.Len()])
+		}
+		if len(offsets) != len(test.offsets) {
+			t.Fatalf("Expression %q returned offsets %v, expected %v", test.expr, offsets, test.offsets)
+		}
+		for i := range offsets {
+			if offsets[i] != test.offsets[i] {
+				t.Fatalf("Expression %q returned offsets %v, expected %v", test.expr, offsets, test.offsets)
+			}
+		}
+	}
+
+	// The raw value of the selected element
+	matches, err := Select(data, "//OID=2.5.4.3/../1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkEqual(t, matches[0].Raw, []byte{0x13, 0x03, 0x42, 0x6f, 0x62})
+
+	for _, expr := range []string{"", "0/", "0///1", "x", "-1", "[FOO 1]", "[1 2 3]", "OID=x", "FOO=1"} {
+		if _, err := Select(data, expr); err == nil {
+			t.Fatalf("Expression %q should have failed.", expr)
+		}
+	}
+}
@@ -0,0 +1,1413 @@
+package asn1
+
+import (
+	"math/big"
+	"math/bits"
+	"reflect"
+)
+
+// PER length determinant limits.
+const (
+	perFragmentSize = 16384
+	perMaxFragments = 4
+	perSmallLength  = 64
+	perLargeRange   = 65536
+)
+
+// EncodeAper returns the aligned Packed Encoding Rules (X.691) encoding of obj
+// using additional options.
+//
+// PER uses the same Go types and options as BER, but tags are not encoded.
+// Instead, PER relies on the following options to produce compact encodings:
+//
+//	range
+//
+// Defines the value constraint of an INTEGER (ie: "range:0..255" or
+// "range:1..MAX").
+//
+//	size
+//
+// Defines the size constraint of a string, BIT STRING, OCTET STRING or SEQUENCE
+// OF (ie: "size:8" or "size:1..32").
+//
+//	extensible
+//
+// Indicates that the type of an element has an extension marker, either in
+// its constraint (INTEGER and sized types), or in its list of components,
+// alternatives or values (SEQUENCE, SET, CHOICE and ENUMERATED).
+//
+//	addition
+//
+// Indicates that a struct field or a CHOICE alternative is an extension
+// addition, that is, it comes after the extension marker.
+//
+//	enum
+//
+// Indicates that an integer element is an ENUMERATED type whose values were
+// registered with (*Context).AddEnum().
+//
+//	utf8, numeric, printable, ia5 and visible
+//
+// Indicates that a string element is encoded as a UTF8String, NumericString,
+// PrintableString, IA5String or VisibleString instead of an OCTET STRING.
+//
+func (ctx *Context) EncodeAper(obj interface{}, options string) ([]byte, error) {
+	return ctx.encodePer(obj, options, true)
+}
+
+// EncodeUper returns the unaligned Packed Encoding Rules (X.691) encoding of
+// obj using additional options.
+//
+// See (*Context).EncodeAper() for further details.
+func (ctx *Context) EncodeUper(obj interface{}, options string) ([]byte, error) {
+	return ctx.encodePer(obj, options, false)
+}
+
+// DecodeAper parses the given aligned PER data into obj using additional
+// options. The argument obj should be a reference to the value that will hold
+// the parsed data.
+//
+// See (*Context).EncodeAper() for further details.
+func (ctx *Context) DecodeAper(data []byte, obj interface{}, options string) (rest []byte, err error) {
+	return ctx.decodePer(data, obj, options, true)
+}
+
+// DecodeUper parses the given unaligned PER data into obj using additional
+// options. The argument obj should be a reference to the value that will hold
+// the parsed data.
+//
+// See (*Context).EncodeAper() for further details.
+func (ctx *Context) DecodeUper(data []byte, obj interface{}, options string) (rest []byte, err error) {
+	return ctx.decodePer(data, obj, options, false)
+}
+
+func (ctx *Context) encodePer(obj interface{}, options string, aligned bool) ([]byte, error) {
+	opts, err := parseOptions(options)
+	if err != nil {
+		return nil, err
+	}
+	// Return nil if the ignore tag is given
+	if opts == nil {
+		return nil, nil
+	}
+
+	e := &perEncoder{ctx: ctx, aligned: aligned}
+	err = e.encode(reflect.ValueOf(obj), opts)
+	if err != nil {
+		return nil, err
+	}
+	return e.complete(), nil
+}
+
+func (ctx *Context) decodePer(data []byte, obj interface{}, options string, aligned bool) (rest []byte, err error) {
+	opts, err := parseOptions(options)
+	if err != nil {
+		return nil, err
+	}
+	// Return nil if the ignore tag is given
+	if opts == nil {
+		return
+	}
+
+	value := reflect.ValueOf(obj)
+	switch value.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		value = value.Elem()
+	}
+
+	if !value.CanSet() {
+		return nil, syntaxError("go type '%s' is read-only", value.Type())
+	}
+
+	d := &perDecoder{ctx: ctx, aligned: aligned}
+	d.data = data
+	err = d.decode(value, opts)
+	if err != nil {
+		return nil, err
+	}
+	// A complete encoding has at least one octet
+	consumed := (d.pos + 7) / 8
+	if consumed == 0 {
+		consumed = 1
+	}
+	if consumed > len(data) {
+		return nil, parseError("missing PER data")
+	}
+	return data[consumed:], nil
+}
+
+/*
+ * Bit buffers
+ */
+
+// bitWriter packs bits into a byte slice, most significant bit first.
+type bitWriter struct {
+	buf  []byte
+	bits int
+}
+
+func (w *bitWriter) writeBit(bit bool) {
+	if w.bits%8 == 0 {
+		w.buf = append(w.buf, 0x00)
+	}
+	if bit {
+		w.buf[w.bits/8] |= 0x80 >> uint(w.bits%8)
+	}
+	w.bits++
+}
+
+// writeBits writes the n least significant bits of v.
+func (w *bitWriter) writeBits(v uint64, n int) {
+	for i := n - 1; i >= 0; i-- {
+		w.writeBit(v&(1<<uint(i)) != 0)
+	}
+}
+
+// writeBitString writes n bits of data starting at the bit offset start.
+func (w *bitWriter) writeBitString(data []byte, start, n int) {
+	if w.bits%8 == 0 && start%8 == 0 && n%8 == 0 {
+		w.buf = append(w.buf, data[start/8:(start+n)/8]...)
+		w.bits += n
+		return
+	}
+	for i := start; i < start+n; i++ {
+		w.writeBit(data[i/8]&(0x80>>uint(i%8)) != 0)
+	}
+}
+
+// padding completes the current octet with zero bits.
+func (w *bitWriter) padding() {
+	if w.bits%8 != 0 {
+		w.bits += 8 - w.bits%8
+	}
+}
+
+// bitReader reads bits from a byte slice, most significant bit first.
+type bitReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *bitReader) readBit() (bool, error) {
+	if r.pos >= len(r.data)*8 {
+		return false, parseError("unexpected end of PER data")
+	}
+	bit := r.data[r.pos/8]&(0x80>>uint(r.pos%8)) != 0
+	r.pos++
+	return bit, nil
+}
+
+// readBits reads n bits as an unsigned number.
+func (r *bitReader) readBits(n int) (uint64, error) {
+	v := uint64(0)
+	for i := 0; i < n; i++ {
+		bit, err := r.readBit()
+		if err != nil {
+			return 0, err
+		}
+		v <<= 1
+		if bit {
+			v |= 1
+		}
+	}
+	return v, nil
+}
+
+// readBitString reads n bits into a byte slice padded with zero bits.
+func (r *bitReader) readBitString(n int) ([]byte, error) {
+	if r.pos+n > len(r.data)*8 {
+		return nil, parseError("unexpected end of PER data")
+	}
+	if r.pos%8 == 0 && n%8 == 0 {
+		data := append([]byte{}, r.data[r.pos/8:(r.pos+n)/8]...)
+		r.pos += n
+		return data, nil
+	}
+	data := make([]byte, (n+7)/8)
+	for i := 0; i < n; i++ {
+		bit, err := r.readBit()
+		if err != nil {
+			return nil, err
+		}
+		if bit {
+			data[i/8] |= 0x80 >> uint(i%8)
+		}
+	}
+	return data, nil
+}
+
+// padding skips the remaining bits of the current octet.
+func (r *bitReader) padding() {
+	if r.pos%8 != 0 {
+		r.pos += 8 - r.pos%8
+	}
+}
+
+/*
+ * Encoder
+ */
+
+type perEncoder struct {
+	bitWriter
+	ctx     *Context
+	aligned bool
+}
+
+// align adds padding bits in the ALIGNED variant.
+func (e *perEncoder) align() {
+	if e.aligned {
+		e.padding()
+	}
+}
+
+// complete returns the complete encoding, which has at least one octet.
+func (e *perEncoder) complete() []byte {
+	e.padding()
+	if len(e.buf) == 0 {
+		return []byte{0x00}
+	}
+	return e.buf
+}
+
+// Main PER encode function
+func (e *perEncoder) encode(value reflect.Value, opts *fieldOptions) error {
+
+	if opts.choice != nil {
+		return e.encodeChoice(value, opts)
+	}
+
+	// Skip the interface type
+	value = getActualType(value)
+	if !value.IsValid() {
+		return syntaxError("nil value cannot be encoded")
+	}
+
+	// Special types:
+	switch value.Type() {
+	case bigIntType:
+		return e.encodeInteger(getBigInt(value), opts)
+	case bitStringType:
+		return e.encodeBitString(value, opts)
+	case oidType:
+		return e.encodeOid(value)
+	case nullType:
+		return nil
+	}
+
+	// Generic types:
+	switch value.Kind() {
+	case reflect.Bool:
+		e.writeBit(value.Bool())
+		return nil
+
+	case reflect.String:
+		return e.encodeString(value, opts)
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if opts.enum != nil {
+			return e.encodeEnumerated(value, opts)
+		}
+		return e.encodeInteger(getBigInt(value), opts)
+
+	case reflect.Struct:
+		return e.encodeStruct(value, opts)
+
+	case reflect.Array, reflect.Slice:
+		if value.Type().Elem().Kind() == reflect.Uint8 {
+			data, err := e.ctx.encodeOctetString(value)
+			if err != nil {
+				return err
+			}
+			return e.encodeOctetString(data, opts)
+		}
+		return e.encodeSequenceOf(value, opts)
+	}
+	return syntaxError("invalid Go type: %s", value.Type())
+}
+
+// writeConstrainedNumber encodes a constrained whole number n, already offset
+// by the lower bound, where rng is the difference between the bounds.
+func (e *perEncoder) writeConstrainedNumber(n, rng uint64) {
+	if !e.aligned {
+		e.writeBits(n, bits.Len64(rng))
+		return
+	}
+	switch {
+	case rng < 255:
+		// Bit-field case
+		e.writeBits(n, bits.Len64(rng))
+	case rng == 255:
+		// One-octet case
+		e.align()
+		e.writeBits(n, 8)
+	case rng < perLargeRange:
+		// Two-octet case
+		e.align()
+		e.writeBits(n, 16)
+	default:
+		// Indefinite length case: the number of octets precedes the value
+		size := octetLen(n)
+		e.writeConstrainedNumber(uint64(size-1), uint64(octetLen(rng)-1))
+		e.align()
+		e.writeBits(n, size*8)
+	}
+}
+
+// writeLength encodes an unconstrained length determinant lower than 16K.
+func (e *perEncoder) writeLength(n int) {
+	e.align()
+	if n < 128 {
+		e.writeBits(uint64(n), 8)
+	} else {
+		e.writeBits(uint64(n)|0x8000, 16)
+	}
+}
+
+// writeNormallySmallNumber encodes a normally small non-negative whole number.
+func (e *perEncoder) writeNormallySmallNumber(n uint64) {
+	if n < perSmallLength {
+		e.writeBit(false)
+		e.writeBits(n, 6)
+		return
+	}
+	e.writeBit(true)
+	buf := new(big.Int).SetUint64(n).Bytes()
+	e.writeLength(len(buf))
+	e.writeBitString(buf, 0, len(buf)*8)
+}
+
+// writeNormallySmallLength encodes a normally small length, which must be
+// greater than zero.
+func (e *perEncoder) writeNormallySmallLength(n int) {
+	if n <= perSmallLength {
+		e.writeBit(false)
+		e.writeBits(uint64(n-1), 6)
+		return
+	}
+	e.writeBit(true)
+	e.writeLength(n)
+}
+
+// encodeSized encodes the number of items according to the size constraint,
+// followed by the items themselves. If align is set, the items are octet
+// aligned when the length is constrained.
+func (e *perEncoder) encodeSized(count int, size *bounds, extensible, align bool, write func(start, n int) error) error {
+	if size != nil {
+		inRoot := size.contains(int64(count))
+		if extensible {
+			e.writeBit(!inRoot)
+			if !inRoot {
+				size = nil
+			}
+		} else if !inRoot {
+			return syntaxError("size %d does not satisfy the size constraint", count)
+		}
+	}
+
+	if size.constrained() && *size.upper < perLargeRange {
+		if !size.fixed() {
+			e.writeConstrainedNumber(uint64(count-int(*size.lower)), uint64(*size.upper-*size.lower))
+		}
+		if align && count > 0 {
+			e.align()
+		}
+		return write(0, count)
+	}
+
+	// Unconstrained length, split in fragments if necessary
+	start := 0
+	for {
+		n := count - start
+		if n < perFragmentSize {
+			e.writeLength(n)
+			return write(start, n)
+		}
+		m := n / perFragmentSize
+		if m > perMaxFragments {
+			m = perMaxFragments
+		}
+		e.align()
+		e.writeBits(uint64(0xc0|m), 8)
+		if err := write(start, m*perFragmentSize); err != nil {
+			return err
+		}
+		start += m * perFragmentSize
+	}
+}
+
+func (e *perEncoder) encodeInteger(n *big.Int, opts *fieldOptions) error {
+	r := opts.valueRange
+	if r != nil {
+		inRoot := n.IsInt64() && r.contains(n.Int64())
+		if opts.extensible {
+			e.writeBit(!inRoot)
+			if !inRoot {
+				r = nil
+			}
+		} else if !inRoot {
+			return syntaxError("integer %s does not satisfy the value constraint", n)
+		}
+	}
+
+	switch {
+	case r.constrained():
+		e.writeConstrainedNumber(uint64(n.Int64()-*r.lower), uint64(*r.upper-*r.lower))
+		return nil
+
+	case r != nil && r.lower != nil:
+		// Semi-constrained whole number
+		buf := new(big.Int).Sub(n, big.NewInt(*r.lower)).Bytes()
+		if len(buf) == 0 {
+			buf = []byte{0x00}
+		}
+		return e.encodeOpenBytes(buf)
+	}
+
+	// Unconstrained whole number
+	buf, err := e.ctx.encodeBigInt(reflect.ValueOf(n))
+	if err != nil {
+		return err
+	}
+	return e.encodeOpenBytes(buf)
+}
+
+func (e *perEncoder) encodeEnumerated(value reflect.Value, opts *fieldOptions) error {
+	root, additions, err := e.ctx.getEnumValues(*opts.enum)
+	if err != nil {
+		return err
+	}
+	n := getBigInt(value)
+	for i, entry := range root {
+		if n.Cmp(big.NewInt(int64(entry.Value))) == 0 {
+			if opts.extensible {
+				e.writeBit(false)
+			}
+			e.writeConstrainedNumber(uint64(i), uint64(len(root)-1))
+			return nil
+		}
+	}
+	if opts.extensible {
+		for i, entry := range additions {
+			if n.Cmp(big.NewInt(int64(entry.Value))) == 0 {
+				e.writeBit(true)
+				e.writeNormallySmallNumber(uint64(i))
+				return nil
+			}
+		}
+	}
+	return syntaxError("invalid value %s for enum '%s'", n, *opts.enum)
+}
+
+func (e *perEncoder) encodeBitString(value reflect.Value, opts *fieldOptions) error {
+	bs, ok := value.Interface().(BitString)
+	if !ok {
+		return wrongType(bitStringType.String(), value)
+	}
+	if bs.BitLength > len(bs.Bytes)*8 {
+		return syntaxError("invalid BIT STRING length: %d", bs.BitLength)
+	}
+	align := !(opts.size.fixed() && *opts.size.upper <= 16)
+	return e.encodeSized(bs.BitLength, opts.size, opts.extensible, align,
+		func(start, n int) error {
+			e.writeBitString(bs.Bytes, start, n)
+			return nil
+		})
+}
+
+func (e *perEncoder) encodeOctetString(data []byte, opts *fieldOptions) error {
+	align := !(opts.size.fixed() && *opts.size.upper <= 2)
+	return e.encodeSized(len(data), opts.size, opts.extensible, align,
+		func(start, n int) error {
+			e.writeBitString(data, start*8, n*8)
+			return nil
+		})
+}
+
+// encodeOpenBytes encodes data preceded by an unconstrained length.
+func (e *perEncoder) encodeOpenBytes(data []byte) error {
+	return e.encodeOctetString(data, &fieldOptions{})
+}
+
+func (e *perEncoder) encodeOid(value reflect.Value) error {
+	data, err := e.ctx.encodeOid(value)
+	if err != nil {
+		return err
+	}
+	return e.encodeOpenBytes(data)
+}
+
+func (e *perEncoder) encodeString(value reflect.Value, opts *fieldOptions) error {
+	s := value.String()
+	if opts.stringTag == nil {
+		return e.encodeOctetString([]byte(s), opts)
+	}
+	alphabet := getPerAlphabet(*opts.stringTag)
+	if alphabet == nil {
+		// Not a known-multiplier character string
+		return e.encodeOpenBytes([]byte(s))
+	}
+
+	// Convert characters to their PER values
+	chars := []rune(s)
+	codes := make([]uint64, len(chars))
+	for i, c := range chars {
+		code, ok := alphabet.code(c)
+		if !ok {
+			return syntaxError("invalid character %q for string type", c)
+		}
+		codes[i] = code
+	}
+
+	b := alphabet.bits(e.aligned)
+	align := !(opts.size.constrained() && *opts.size.upper*int64(b) <= 16)
+	return e.encodeSized(len(codes), opts.size, opts.extensible, align,
+		func(start, n int) error {
+			for _, code := range codes[start : start+n] {
+				e.writeBits(code, b)
+			}
+			return nil
+		})
+}
+
+func (e *perEncoder) encodeSequenceOf(value reflect.Value, opts *fieldOptions) error {
+	return e.encodeSized(value.Len(), opts.size, opts.extensible, false,
+		func(start, n int) error {
+			for i := start; i < start+n; i++ {
+				err := e.encode(value.Index(i), opts.elemOptions())
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+}
+
+func (e *perEncoder) encodeStruct(value reflect.Value, opts *fieldOptions) error {
+	root, additions, err := e.ctx.getComponents(value.Type(), opts.set)
+	if err != nil {
+		return err
+	}
+	if len(additions) > 0 && !opts.extensible {
+		return syntaxError("extension additions require the flag 'extensible' on Go type '%s'", value.Type())
+	}
+
+	// Extension bit
+	extended := false
+	if opts.extensible {
+		for _, field := range additions {
+			extended = extended || !isAbsent(value.Field(field.Index[0]), field.opts)
+		}
+		e.writeBit(extended)
+	}
+
+	// Bitmap of optional components
+	for _, field := range root {
+		if isOptional(field.opts) {
+			e.writeBit(!isAbsent(value.Field(field.Index[0]), field.opts))
+		}
+	}
+
+	// Root components
+	for _, field := range root {
+		fieldValue := value.Field(field.Index[0])
+		if isAbsent(fieldValue, field.opts) {
+			continue
+		}
+		if err := e.encode(fieldValue, field.opts); err != nil {
+			return err
+		}
+	}
+
+	if !extended {
+		return nil
+	}
+
+	// Extension additions are encoded as open types after their bitmap
+	e.writeNormallySmallLength(len(additions))
+	for _, field := range additions {
+		e.writeBit(!isAbsent(value.Field(field.Index[0]), field.opts))
+	}
+	for _, field := range additions {
+		fieldValue := value.Field(field.Index[0])
+		if isAbsent(fieldValue, field.opts) {
+			continue
+		}
+		if err := e.encodeOpenType(fieldValue, field.opts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *perEncoder) encodeChoice(value reflect.Value, opts *fieldOptions) error {
+	value = getActualType(value)
+	if !value.IsValid() {
+		return syntaxError("nil value for choice '%s'", *opts.choice)
+	}
+	root, additions, err := e.ctx.getChoiceAlternatives(*opts.choice)
+	if err != nil {
+		return err
+	}
+	for i, entry := range root {
+		if entry.typ == value.Type() {
+			if opts.extensible {
+				e.writeBit(false)
+			}
+			e.writeConstrainedNumber(uint64(i), uint64(len(root)-1))
+			return e.encode(value, entry.opts)
+		}
+	}
+	if opts.extensible {
+		for i, entry := range additions {
+			if entry.typ == value.Type() {
+				e.writeBit(true)
+				e.writeNormallySmallNumber(uint64(i))
+				return e.encodeOpenType(value, entry.opts)
+			}
+		}
+	}
+	return syntaxError("invalid Go type '%s' for choice '%s'", value.Type(), *opts.choice)
+}
+
+// encodeOpenType encodes a value as a complete encoding preceded by its
+// length.
+func (e *perEncoder) encodeOpenType(value reflect.Value, opts *fieldOptions) error {
+	inner := &perEncoder{ctx: e.ctx, aligned: e.aligned}
+	if err := inner.encode(value, opts); err != nil {
+		return err
+	}
+	return e.encodeOpenBytes(inner.complete())
+}
+
+/*
+ * Decoder
+ */
+
+type perDecoder struct {
+	bitReader
+	ctx     *Context
+	aligned bool
+}
+
+// align skips padding bits in the ALIGNED variant.
+func (d *perDecoder) align() {
+	if d.aligned {
+		d.padding()
+	}
+}
+
+// Main PER decode function
+func (d *perDecoder) decode(value reflect.Value, opts *fieldOptions) error {
+
+	if opts.choice != nil {
+		return d.decodeChoice(value, opts)
+	}
+
+	// Allocate pointers
+	if value.Kind() == reflect.Ptr && value.Type() != bigIntType {
+		elem := reflect.New(value.Type().Elem())
+		if err := d.decode(elem.Elem(), opts); err != nil {
+			return err
+		}
+		value.Set(elem)
+		return nil
+	}
+
+	// Special types:
+	switch value.Type() {
+	case bigIntType:
+		n, err := d.decodeInteger(opts)
+		if err != nil {
+			return err
+		}
+		value.Set(reflect.ValueOf(n))
+		return nil
+	case bitStringType:
+		return d.decodeBitString(value, opts)
+	case oidType:
+		data, err := d.decodeOpenBytes()
+		if err != nil {
+			return err
+		}
+		return d.ctx.decodeOid(data, value)
+	case nullType:
+		return nil
+	}
+
+	// Generic types:
+	switch value.Kind() {
+	case reflect.Bool:
+		bit, err := d.readBit()
+		if err != nil {
+			return err
+		}
+		value.SetBool(bit)
+		return nil
+
+	case reflect.String:
+		return d.decodeString(value, opts)
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if opts.enum != nil {
+			return d.decodeEnumerated(value, opts)
+		}
+		n, err := d.decodeInteger(opts)
+		if err != nil {
+			return err
+		}
+		return setBigInt(value, n)
+
+	case reflect.Struct:
+		return d.decodeStruct(value, opts)
+
+	case reflect.Array, reflect.Slice:
+		if value.Type().Elem().Kind() == reflect.Uint8 {
+			data, err := d.decodeOctetString(opts)
+			if err != nil {
+				return err
+			}
+			return d.ctx.decodeOctetString(data, value)
+		}
+		return d.decodeSequenceOf(value, opts)
+	}
+	return syntaxError("invalid Go type: %s", value.Type())
+}
+
+// readConstrainedNumber decodes a constrained whole number, offset by the lower
+// bound, where rng is the difference between the bounds.
+func (d *perDecoder) readConstrainedNumber(rng uint64) (n uint64, err error) {
+	if !d.aligned {
+		n, err = d.readBits(bits.Len64(rng))
+	} else {
+		switch {
+		case rng < 255:
+			n, err = d.readBits(bits.Len64(rng))
+		case rng == 255:
+			d.align()
+			n, err = d.readBits(8)
+		case rng < perLargeRange:
+			d.align()
+			n, err = d.readBits(16)
+		default:
+			var size uint64
+			size, err = d.readConstrainedNumber(uint64(octetLen(rng) - 1))
+			if err != nil {
+				return
+			}
+			d.align()
+			n, err = d.readBits(int(size+1) * 8)
+		}
+	}
+	if err == nil && n > rng {
+		err = parseError("constrained number out of range")
+	}
+	return
+}
+
+// readLength decodes an unconstrained length determinant. The flag more
+// indicates that the length refers to a fragment and that more fragments
+// follow.
+func (d *perDecoder) readLength() (n int, more bool, err error) {
+	d.align()
+	b, err := d.readBits(8)
+	if err != nil {
+		return
+	}
+	switch {
+	case b&0x80 == 0:
+		n = int(b)
+	case b&0x40 == 0:
+		var low uint64
+		low, err = d.readBits(8)
+		n = int(b&0x3f)<<8 | int(low)
+	default:
+		m := int(b & 0x3f)
+		if m < 1 || m > perMaxFragments {
+			err = parseError("invalid number of PER fragments: %d", m)
+		}
+		n, more = m*perFragmentSize, true
+	}
+	return
+}
+
+func (d *perDecoder) readNormallySmallNumber() (uint64, error) {
+	large, err := d.readBit()
+	if err != nil {
+		return 0, err
+	}
+	if !large {
+		return d.readBits(6)
+	}
+	data, err := d.decodeOpenBytes()
+	if err != nil {
+		return 0, err
+	}
+	n := new(big.Int).SetBytes(data)
+	if !n.IsUint64() {
+		return 0, parseError("normally small number too large")
+	}
+	return n.Uint64(), nil
+}
+
+func (d *perDecoder) readNormallySmallLength() (int, error) {
+	large, err := d.readBit()
+	if err != nil {
+		return 0, err
+	}
+	if !large {
+		n, err := d.readBits(6)
+		return int(n) + 1, err
+	}
+	n, more, err := d.readLength()
+	if err == nil && more {
+		err = parseError("fragmented normally small length")
+	}
+	return n, err
+}
+
+// decodeSized decodes the number of items according to the size constraint
+// and calls read for each sequence of items. If align is set, the items are
+// octet aligned when the length is constrained.
+func (d *perDecoder) decodeSized(size *bounds, extensible, align bool, read func(n int) error) error {
+	if size != nil && extensible {
+		outside, err := d.readBit()
+		if err != nil {
+			return err
+		}
+		if outside {
+			size = nil
+		}
+	}
+
+	if size.constrained() && *size.upper < perLargeRange {
+		count := int(*size.lower)
+		if !size.fixed() {
+			n, err := d.readConstrainedNumber(uint64(*size.upper - *size.lower))
+			if err != nil {
+				return err
+			}
+			count += int(n)
+		}
+		if align && count > 0 {
+			d.align()
+		}
+		return read(count)
+	}
+
+	total := 0
+	for {
+		n, more, err := d.readLength()
+		if err != nil {
+			return err
+		}
+		if err := read(n); err != nil {
+			return err
+		}
+		total += n
+		if !more {
+			break
+		}
+	}
+	if !extensible && !size.contains(int64(total)) {
+		return parseError("size %d does not satisfy the size constraint", total)
+	}
+	return nil
+}
+
+func (d *perDecoder) decodeInteger(opts *fieldOptions) (*big.Int, error) {
+	r := opts.valueRange
+	if r != nil && opts.extensible {
+		outside, err := d.readBit()
+		if err != nil {
+			return nil, err
+		}
+		if outside {
+			r = nil
+		}
+	}
+
+	switch {
+	case r.constrained():
+		n, err := d.readConstrainedNumber(uint64(*r.upper - *r.lower))
+		if err != nil {
+			return nil, err
+		}
+		return big.NewInt(int64(uint64(*r.lower) + n)), nil
+
+	case r != nil && r.lower != nil:
+		data, err := d.decodeOpenBytes()
+		if err != nil {
+			return nil, err
+		}
+		n := new(big.Int).SetBytes(data)
+		return n.Add(n, big.NewInt(*r.lower)), nil
+	}
+
+	data, err := d.decodeOpenBytes()
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, parseError("empty PER integer")
+	}
+	return parseBigInt(data), nil
+}
+
+func (d *perDecoder) decodeEnumerated(value reflect.Value, opts *fieldOptions) error {
+	root, additions, err := d.ctx.getEnumValues(*opts.enum)
+	if err != nil {
+		return err
+	}
+	extended := false
+	if opts.extensible {
+		extended, err = d.readBit()
+		if err != nil {
+			return err
+		}
+	}
+	var entry Enum
+	if !extended {
+		i, err := d.readConstrainedNumber(uint64(len(root) - 1))
+		if err != nil {
+			return err
+		}
+		entry = root[i]
+	} else {
+		i, err := d.readNormallySmallNumber()
+		if err != nil {
+			return err
+		}
+		if i >= uint64(len(additions)) {
+			return parseError("unknown extension value for enum '%s'", *opts.enum)
+		}
+		entry = additions[i]
+	}
+	return setBigInt(value, big.NewInt(int64(entry.Value)))
+}
+
+func (d *perDecoder) decodeBitString(value reflect.Value, opts *fieldOptions) error {
+	var bs BitString
+	align := !(opts.size.fixed() && *opts.size.upper <= 16)
+	err := d.decodeSized(opts.size, opts.extensible, align, func(n int) error {
+		data, err := d.readBitString(n)
+		if err != nil {
+			return err
+		}
+		if bs.BitLength%8 == 0 {
+			bs.Bytes = append(bs.Bytes, data...)
+			bs.BitLength += n
+			return nil
+		}
+		// Unaligned concatenation
+		w := bitWriter{bs.Bytes, bs.BitLength}
+		w.writeBitString(data, 0, n)
+		bs.Bytes, bs.BitLength = w.buf, w.bits
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if bs.Bytes == nil {
+		bs.Bytes = []byte{}
+	}
+	value.Set(reflect.ValueOf(bs))
+	return nil
+}
+
+func (d *perDecoder) decodeOctetString(opts *fieldOptions) ([]byte, error) {
+	data := []byte{}
+	align := !(opts.size.fixed() && *opts.size.upper <= 2)
+	err := d.decodeSized(opts.size, opts.extensible, align, func(n int) error {
+		buf, err := d.readBitString(n * 8)
+		data = append(data, buf...)
+		return err
+	})
+	return data, err
+}
+
+// decodeOpenBytes decodes data preceded by an unconstrained length.
+func (d *perDecoder) decodeOpenBytes() ([]byte, error) {
+	return d.decodeOctetString(&fieldOptions{})
+}
+
+func (d *perDecoder) decodeString(value reflect.Value, opts *fieldOptions) error {
+	if opts.stringTag == nil {
+		data, err := d.decodeOctetString(opts)
+		if err != nil {
+			return err
+		}
+		value.SetString(string(data))
+		return nil
+	}
+	alphabet := getPerAlphabet(*opts.stringTag)
+	if alphabet == nil {
+		data, err := d.decodeOpenBytes()
+		if err != nil {
+			return err
+		}
+		value.SetString(string(data))
+		return nil
+	}
+
+	chars := []rune{}
+	b := alphabet.bits(d.aligned)
+	align := !(opts.size.constrained() && *opts.size.upper*int64(b) <= 16)
+	err := d.decodeSized(opts.size, opts.extensible, align, func(n int) error {
+		for i := 0; i < n; i++ {
+			code, err := d.readBits(b)
+			if err != nil {
+				return err
+			}
+			c, ok := alphabet.char(code)
+			if !ok {
+				return parseError("invalid character code %d for string type", code)
+			}
+			chars = append(chars, c)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	value.SetString(string(chars))
+	return nil
+}
+
+func (d *perDecoder) decodeSequenceOf(value reflect.Value, opts *fieldOptions) error {
+	slice := reflect.MakeSlice(reflect.SliceOf(value.Type().Elem()), 0, 0)
+	err := d.decodeSized(opts.size, opts.extensible, false, func(n int) error {
+		for i := 0; i < n; i++ {
+			elem := reflect.New(value.Type().Elem()).Elem()
+			if err := d.decode(elem, opts.elemOptions()); err != nil {
+				return err
+			}
+			slice = reflect.Append(slice, elem)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if value.Kind() == reflect.Slice {
+		value.Set(slice)
+		return nil
+	}
+	if slice.Len() != value.Len() {
+		return parseError("expected %d elements but found %d", value.Len(), slice.Len())
+	}
+	reflect.Copy(value, slice)
+	return nil
+}
+
+func (d *perDecoder) decodeStruct(value reflect.Value, opts *fieldOptions) error {
+	root, additions, err := d.ctx.getComponents(value.Type(), opts.set)
+	if err != nil {
+		return err
+	}
+
+	extended := false
+	if opts.extensible {
+		extended, err = d.readBit()
+		if err != nil {
+			return err
+		}
+	}
+
+	// Bitmap of optional components
+	present := make([]bool, len(root))
+	for i, field := range root {
+		present[i] = true
+		if isOptional(field.opts) {
+			present[i], err = d.readBit()
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	// Root components
+	for i, field := range root {
+		fieldValue := value.Field(field.Index[0])
+		if !present[i] {
+			if err := d.setMissingValue(fieldValue, field.opts); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := d.decode(fieldValue, field.opts); err != nil {
+			return err
+		}
+	}
+
+	// Extension additions
+	var bitmap []bool
+	if extended {
+		n, err := d.readNormallySmallLength()
+		if err != nil {
+			return err
+		}
+		bitmap = make([]bool, n)
+		for i := range bitmap {
+			bitmap[i], err = d.readBit()
+			if err != nil {
+				return err
+			}
+		}
+	}
+	for i, field := range additions {
+		fieldValue := value.Field(field.Index[0])
+		if i >= len(bitmap) || !bitmap[i] {
+			if err := d.setMissingValue(fieldValue, field.opts); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := d.decodeOpenType(fieldValue, field.opts); err != nil {
+			return err
+		}
+	}
+	// Skip unknown extension additions
+	for i := len(additions); i < len(bitmap); i++ {
+		if bitmap[i] {
+			if _, err := d.decodeOpenBytes(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// setMissingValue sets the default value of a missing component.
+func (d *perDecoder) setMissingValue(value reflect.Value, opts *fieldOptions) error {
+	if opts.defaultValue != nil {
+		return d.ctx.setDefaultValue(value, opts)
+	}
+	return nil
+}
+
+func (d *perDecoder) decodeChoice(value reflect.Value, opts *fieldOptions) error {
+	root, additions, err := d.ctx.getChoiceAlternatives(*opts.choice)
+	if err != nil {
+		return err
+	}
+	extended := false
+	if opts.extensible {
+		extended, err = d.readBit()
+		if err != nil {
+			return err
+		}
+	}
+
+	if !extended {
+		if len(root) == 0 {
+			return syntaxError("choice '%s' has no root alternatives", *opts.choice)
+		}
+		i, err := d.readConstrainedNumber(uint64(len(root) - 1))
+		if err != nil {
+			return err
+		}
+		entry := root[i]
+		nestedValue := reflect.New(entry.typ).Elem()
+		if err := d.decode(nestedValue, entry.opts); err != nil {
+			return err
+		}
+		value.Set(nestedValue)
+		return nil
+	}
+
+	i, err := d.readNormallySmallNumber()
+	if err != nil {
+		return err
+	}
+	if i >= uint64(len(additions)) {
+		return parseError("unknown extension alternative for choice '%s'", *opts.choice)
+	}
+	entry := additions[i]
+	nestedValue := reflect.New(entry.typ).Elem()
+	if err := d.decodeOpenType(nestedValue, entry.opts); err != nil {
+		return err
+	}
+	value.Set(nestedValue)
+	return nil
+}
+
+// decodeOpenType decodes a value from a complete encoding preceded by its
+// length.
+func (d *perDecoder) decodeOpenType(value reflect.Value, opts *fieldOptions) error {
+	data, err := d.decodeOpenBytes()
+	if err != nil {
+		return err
+	}
+	inner := &perDecoder{ctx: d.ctx, aligned: d.aligned}
+	inner.data = data
+	return inner.decode(value, opts)
+}
+
+/*
+ * Known-multiplier character strings
+ */
+
+// perAlphabet describes the characters of a known-multiplier character string
+// type. A nil set means that the character codes are used as they are.
+type perAlphabet struct {
+	size int
+	set  []rune
+}
+
+var (
+	numericAlphabet = &perAlphabet{set: []rune(" 0123456789")}
+	ia5Alphabet     = &perAlphabet{size: 128}
+	visibleAlphabet = &perAlphabet{size: 95}
+	printableChars  = "" +
+		" '()+,-./0123456789:=?" +
+		"ABCDEFGHIJKLMNOPQRSTUVWXYZ" +
+		"abcdefghijklmnopqrstuvwxyz"
+	printableAlphabet = &perAlphabet{size: len(printableChars)}
+)
+
+// getPerAlphabet returns the alphabet of a string type, or nil if the type is
+// not a known-multiplier character string.
+func getPerAlphabet(stringTag int) *perAlphabet {
+	switch stringTag {
+	case tagNumericString:
+		return numericAlphabet
+	case tagPrintableString:
+		return printableAlphabet
+	case tagIA5String:
+		return ia5Alphabet
+	case tagVisibleString:
+		return visibleAlphabet
+	}
+	return nil
+}
+
+// bits returns the number of bits used by each character.
+func (a *perAlphabet) bits(aligned bool) int {
+	n := a.size
+	if a.set != nil {
+		n = len(a.set)
+	}
+	b := bits.Len(uint(n - 1))
+	if aligned {
+		// Rounded up to a power of two
+		b = 1 << uint(bits.Len(uint(b-1)))
+	}
+	return b
+}
+
+// code returns the encoded value of a character.
+func (a *perAlphabet) code(c rune) (uint64, bool) {
+	switch a {
+	case numericAlphabet:
+		for i, r := range a.set {
+			if r == c {
+				return uint64(i), true
+			}
+		}
+		return 0, false
+	case printableAlphabet:
+		for _, r := range printableChars {
+			if r == c {
+				return uint64(c), true
+			}
+		}
+		return 0, false
+	case visibleAlphabet:
+		return uint64(c), c >= 0x20 && c < 0x7f
+	}
+	return uint64(c), c >= 0 && c < 0x80
+}
+
+// char returns the character of an encoded value.
+func (a *perAlphabet) char(code uint64) (rune, bool) {
+	if a.set != nil {
+		if code >= uint64(len(a.set)) {
+			return 0, false
+		}
+		return a.set[code], true
+	}
+	c := rune(code)
+	_, ok := a.code(c)
+	return c, ok
+}
+
+/*
+ * Helper functions
+ */
+
+// octetLen returns the minimum number of octets needed to hold n.
+func octetLen(n uint64) int {
+	size := (bits.Len64(n) + 7) / 8
+	if size == 0 {
+		size = 1
+	}
+	return size
+}
+
+// getBigInt returns the value of an integer type as a big.Int.
+func getBigInt(value reflect.Value) *big.Int {
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return big.NewInt(value.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return new(big.Int).SetUint64(value.Uint())
+	}
+	if n, ok := value.Interface().(*big.Int); ok && n != nil {
+		return n
+	}
+	return big.NewInt(0)
+}
+
+// setBigInt sets an integer type with the value of a big.Int.
+func setBigInt(value reflect.Value, n *big.Int) error {
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if !n.IsInt64() || value.OverflowInt(n.Int64()) {
+			return parseError("integer too large for Go type '%s'", value.Type())
+		}
+		value.SetInt(n.Int64())
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if n.Sign() < 0 {
+			return parseError("negative integer can't be assigned to Go type '%s'", value.Type())
+		}
+		if !n.IsUint64() || value.OverflowUint(n.Uint64()) {
+			return parseError("integer too large for Go type '%s'", value.Type())
+		}
+		value.SetUint(n.Uint64())
+		return nil
+	}
+	if value.Type() == bigIntType {
+		value.Set(reflect.ValueOf(n))
+		return nil
+	}
+	return wrongType("integer", value)
+}
+
+// isOptional checks if a component can be absent.
+func isOptional(opts *fieldOptions) bool {
+	return opts.optional || opts.defaultValue != nil
+}
+
+// isAbsent checks if an optional component is omitted. Components are omitted
+// when they are empty or when they are equal to their default value.
+func isAbsent(value reflect.Value, opts *fieldOptions) bool {
+	if !isOptional(opts) {
+		return false
+	}
+	value = getActualType(value)
+	if !value.IsValid() || isEmpty(value) {
+		return true
+	}
+	if opts.defaultValue != nil {
+		switch value.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return value.Int() == int64(*opts.defaultValue)
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return *opts.defaultValue >= 0 && value.Uint() == uint64(*opts.defaultValue)
+		}
+	}
+	return false
+}
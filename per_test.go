@@ -0,0 +1,188 @@
+package asn1
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+)
+
+// testPer encodes an object using both PER variants, compares with the
+// expected bytes and then decodes the result back.
+func testPer(t *testing.T, ctx *Context, options string, value interface{}, aper, uper []byte) {
+	variants := []struct {
+		name     string
+		expected []byte
+		encode   func(interface{}, string) ([]byte, error)
+		decode   func([]byte, interface{}, string) ([]byte, error)
+	}{
+		{"APER", aper, ctx.EncodeAper, ctx.DecodeAper},
+		{"UPER", uper, ctx.EncodeUper, ctx.DecodeUper},
+	}
+	for _, v := range variants {
+		data, err := v.encode(value, options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v.expected != nil && !isBytesEqual(data, v.expected) {
+			t.Fatalf("Failed to encode %s \"%v\".\n Expected: %#v.\n Got:      %#v",
+				v.name, value, v.expected, data)
+		}
+		decoded := reflect.New(reflect.TypeOf(value))
+		rest, err := v.decode(data, decoded.Interface(), options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(rest) > 0 {
+			t.Fatalf("Unexpected remaining bytes when decoding %s \"%v\": %#v\n",
+				v.name, value, rest)
+		}
+		checkEqual(t, decoded.Elem().Interface(), value)
+	}
+}
+
+func TestPerInteger(t *testing.T) {
+	ctx := NewContext()
+	testPer(t, ctx, "range:0..7", 3, []byte{0x60}, []byte{0x60})
+	testPer(t, ctx, "range:0..255", 5, []byte{0x05}, []byte{0x05})
+	testPer(t, ctx, "range:1000..1255", uint(1001), []byte{0x01}, []byte{0x01})
+	testPer(t, ctx, "range:0..65535", 258, []byte{0x01, 0x02}, []byte{0x01, 0x02})
+	testPer(t, ctx, "range:0..100000", 256, []byte{0x40, 0x01, 0x00}, []byte{0x00, 0x80, 0x00})
+	testPer(t, ctx, "range:-5..MAX", 10, []byte{0x01, 0x0f}, []byte{0x01, 0x0f})
+	testPer(t, ctx, "", 1000, []byte{0x02, 0x03, 0xe8}, []byte{0x02, 0x03, 0xe8})
+	testPer(t, ctx, "", -1, []byte{0x01, 0xff}, []byte{0x01, 0xff})
+	testPer(t, ctx, "", big.NewInt(128), []byte{0x02, 0x00, 0x80}, []byte{0x02, 0x00, 0x80})
+	// Extensible constraint
+	testPer(t, ctx, "range:0..7,extensible", 3, []byte{0x30}, []byte{0x30})
+	testPer(t, ctx, "range:0..7,extensible", 10,
+		[]byte{0x80, 0x01, 0x0a}, []byte{0x80, 0x85, 0x00})
+
+	// Out of range values
+	if _, err := ctx.EncodeUper(8, "range:0..7"); err == nil {
+		t.Fatal("Encoding a value out of the constraint should have failed.")
+	}
+}
+
+func TestPerBoolNull(t *testing.T) {
+	ctx := NewContext()
+	testPer(t, ctx, "", true, []byte{0x80}, []byte{0x80})
+	testPer(t, ctx, "", false, []byte{0x00}, []byte{0x00})
+	testPer(t, ctx, "", Null{}, []byte{0x00}, []byte{0x00})
+}
+
+func TestPerStrings(t *testing.T) {
+	ctx := NewContext()
+	testPer(t, ctx, "", "abc",
+		[]byte{0x03, 0x61, 0x62, 0x63}, []byte{0x03, 0x61, 0x62, 0x63})
+	testPer(t, ctx, "size:3", []byte{0x01, 0x02, 0x03},
+		[]byte{0x01, 0x02, 0x03}, []byte{0x01, 0x02, 0x03})
+	testPer(t, ctx, "ia5,size:1..4", "ab",
+		[]byte{0x40, 0x61, 0x62}, []byte{0x70, 0xe2})
+	testPer(t, ctx, "numeric,size:4", "2018",
+		[]byte{0x31, 0x29}, []byte{0x31, 0x29})
+	testPer(t, ctx, "utf8", "ação",
+		[]byte{0x06, 0x61, 0xc3, 0xa7, 0xc3, 0xa3, 0x6f},
+		[]byte{0x06, 0x61, 0xc3, 0xa7, 0xc3, 0xa3, 0x6f})
+	testPer(t, ctx, "size:3", BitString{[]byte{0xa0}, 3}, []byte{0xa0}, []byte{0xa0})
+	testPer(t, ctx, "", BitString{[]byte{0xa0}, 3}, []byte{0x03, 0xa0}, []byte{0x03, 0xa0})
+	testPer(t, ctx, "", Oid{1, 2, 840, 113549},
+		[]byte{0x06, 0x2a, 0x86, 0x48, 0x86, 0xf7, 0x0d},
+		[]byte{0x06, 0x2a, 0x86, 0x48, 0x86, 0xf7, 0x0d})
+
+	if _, err := ctx.EncodeUper("a_b", "printable"); err == nil {
+		t.Fatal("Encoding an invalid PrintableString should have failed.")
+	}
+}
+
+func TestPerFragments(t *testing.T) {
+	ctx := NewContext()
+	for _, length := range []int{16383, 16384, 70000} {
+		data := make([]byte, length)
+		for i := range data {
+			data[i] = byte(i)
+		}
+		testPer(t, ctx, "", data, nil, nil)
+	}
+}
+
+func TestPerSequence(t *testing.T) {
+	// Question ::= SEQUENCE { id INTEGER, question IA5String }
+	type Question struct {
+		Id       int
+		Question string `asn1:"ia5"`
+	}
+	ctx := NewContext()
+	testPer(t, ctx, "", Question{1, "Is 1+1=3?"}, nil,
+		[]byte{0x01, 0x01, 0x09, 0x93, 0xcd, 0x03, 0x15, 0x6c, 0x5e, 0xb3, 0x7e})
+
+	type Type struct {
+		A bool
+		B int `asn1:"range:0..7,optional"`
+		C int `asn1:"range:0..7,default:1"`
+	}
+	testPer(t, ctx, "", Type{true, 3, 1}, []byte{0xac}, []byte{0xac})
+	testPer(t, ctx, "", Type{true, 0, 1}, []byte{0x20}, []byte{0x20})
+
+	type SeqOf struct {
+		Items []int `asn1:"size:0..3"`
+	}
+	testPer(t, ctx, "", SeqOf{[]int{1, 2}},
+		[]byte{0x80, 0x01, 0x01, 0x01, 0x02}, nil)
+
+	// Constraints of the elements
+	testPer(t, ctx, "size:0..3,elem:range:0..7", []int{1, 2}, []byte{0x8a}, []byte{0x8a})
+	testPer(t, ctx, "elem:size:0..3,elem:elem:range:0..7", [][]int{{1, 2}, {7}},
+		nil, []byte{0x02, 0x8a, 0x78})
+}
+
+func TestPerExtensions(t *testing.T) {
+	type V1 struct {
+		A int `asn1:"range:0..7"`
+	}
+	type V2 struct {
+		A int  `asn1:"range:0..7"`
+		B bool `asn1:"addition"`
+	}
+	ctx := NewContext()
+	testPer(t, ctx, "extensible", V1{5}, []byte{0x50}, []byte{0x50})
+	testPer(t, ctx, "extensible", V2{5, true},
+		[]byte{0xd0, 0x10, 0x01, 0x80}, []byte{0xd0, 0x10, 0x18, 0x00})
+
+	// A decoder unaware of the addition skips it
+	data, err := ctx.EncodeUper(V2{5, true}, "extensible")
+	if err != nil {
+		t.Fatal(err)
+	}
+	v1 := V1{}
+	if _, err = ctx.DecodeUper(data, &v1, "extensible"); err != nil {
+		t.Fatal(err)
+	}
+	checkEqual(t, v1, V1{5})
+}
+
+func TestPerChoiceEnum(t *testing.T) {
+	type Color int
+	type Msg struct {
+		Color Color       `asn1:"enum:color,extensible"`
+		Value interface{} `asn1:"choice:value,extensible"`
+	}
+	ctx := NewContext()
+	ctx.AddEnum("color", []Enum{
+		{Name: "green", Value: 1},
+		{Name: "red", Value: 0},
+		{Name: "blue", Value: 5, Addition: true},
+	})
+	ctx.AddChoice("value", []Choice{
+		{reflect.TypeOf(""), "tag:1"},
+		{reflect.TypeOf(int(0)), "tag:0,range:0..255"},
+		{reflect.TypeOf(false), "tag:2,addition"},
+	})
+	// green (index 1), int (index 0), 7
+	testPer(t, ctx, "", Msg{1, 7}, []byte{0x40, 0x07}, []byte{0x40, 0x70})
+	// blue (extension 0), string (index 1), "a"
+	testPer(t, ctx, "", Msg{5, "a"}, []byte{0x80, 0x40, 0x01, 0x61}, []byte{0x80, 0x40, 0x58, 0x40})
+	// red (index 0), bool (extension 0), true
+	testPer(t, ctx, "", Msg{0, true}, nil, nil)
+
+	// BER uses the ENUMERATED tag
+	testEncodeDecode(t, ctx, "enum:color", testCase{Color(1), []byte{0x0a, 0x01, 0x01}})
+}
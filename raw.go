@@ -7,12 +7,12 @@ import (
 	"strconv"
 )
 
-// ASN.1 class tags.
+// ASN.1 tag classes, as found in the field Class of Node and Tag.
 const (
-	classUniversal       = 0x00
-	classApplication     = 0x01
-	classContextSpecific = 0x02
-	classPrivate         = 0x03
+	ClassUniversal       = 0x00
+	ClassApplication     = 0x01
+	ClassContextSpecific = 0x02
+	ClassPrivate         = 0x03
 )
 
 // ASN.1 universal tag numbers.
@@ -24,14 +24,30 @@ const (
 	tagOctetString     = 0x04
 	tagNull            = 0x05
 	tagOid             = 0x06
+	tagEnumerated      = 0x0a
+	tagUtf8String      = 0x0c
 	tagSequence        = 0x10
 	tagSet             = 0x11
+	tagNumericString   = 0x12
 	tagPrintableString = 0x13
 	tagT61String       = 0x14
 	tagIA5String       = 0x16
 	tagUtcTime         = 0x17
+	tagGeneralizedTime = 0x18
+	tagVisibleString   = 0x1a
 )
 
+// isStringTag checks if a universal tag is a string type, which can be encoded
+// in constructed form.
+func isStringTag(tag uint) bool {
+	switch tag {
+	case tagBitString, tagOctetString, tagUtf8String, tagNumericString,
+		tagPrintableString, tagT61String, tagIA5String, tagVisibleString:
+		return true
+	}
+	return false
+}
+
 // Internal consts
 const (
 	intBits  = strconv.IntSize
@@ -44,6 +60,8 @@ type rawValue struct {
 	Constructed bool
 	Indefinite  bool
 	Content     []byte
+	// Set only while encoding with a Trace
+	trace *rawTrace
 }
 
 func (raw *rawValue) encode() ([]byte, error) {
@@ -177,6 +195,10 @@ func decodeRawValue(reader io.Reader) (*rawValue, error) {
 	// Indefinite form
 	var content []byte
 	if !indefinite {
+		// Avoid allocating more than the available data
+		if buf, ok := reader.(interface{ Len() int }); ok && uint64(length) > uint64(buf.Len()) {
+			return nil, parseError("length %d exceeds the available data", length)
+		}
 		content = make([]byte, length)
 		_, err = io.ReadFull(reader, content)
 		if err != nil {
@@ -194,7 +216,7 @@ func decodeRawValue(reader io.Reader) (*rawValue, error) {
 		content = content[:len(content)-2]
 	}
 
-	raw := rawValue{class, tag, constructed, indefinite, content}
+	raw := rawValue{Class: class, Tag: tag, Constructed: constructed, Indefinite: indefinite, Content: content}
 	return &raw, nil
 }
 
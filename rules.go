@@ -0,0 +1,137 @@
+package asn1
+
+// EncodingRules defines the variations among the encoding rules based on the
+// BER format (X.690). A Context delegates to its EncodingRules every decision
+// that differs between BER, DER and CER.
+//
+// The package provides the implementations BER, DER and CER. New variants can
+// be created by embedding one of them and overriding some of its methods. For
+// example, BER that always uses the definite length form:
+//
+//	type DefiniteBer struct {
+//		asn1.BasicEncodingRules
+//	}
+//
+//	func (DefiniteBer) IndefiniteLength(requested bool) bool {
+//		return false
+//	}
+//
+//	ctx.SetRules(DefiniteBer{}, asn1.BER)
+//
+type EncodingRules interface {
+	// IndefiniteLength reports if a constructed value is encoded using the
+	// indefinite length form. The argument requested is true if the option
+	// "indefinite" was given.
+	IndefiniteLength(requested bool) bool
+
+	// SortSet reports if the components of a SET and the elements of a SET OF
+	// are sorted during encoding. SET components are sorted by tag and SET OF
+	// elements by their encodings.
+	SortSet() bool
+
+	// OmitDefault reports if components that are empty or equal to their
+	// DEFAULT value are omitted during encoding. Otherwise empty components
+	// are encoded with their DEFAULT value.
+	OmitDefault() bool
+
+	// SegmentSize returns the maximum number of content octets of a primitive
+	// string. Longer strings are encoded in constructed form, with segments of
+	// this size. Zero disables segmentation. When decoding with strict rules,
+	// strings in constructed form are only accepted if SegmentSize is not zero.
+	SegmentSize() int
+
+	// CheckLength validates the length form of a decoded element.
+	CheckLength(constructed, indefinite bool) error
+
+	// Strict reports if decoding requires the unique encodings of DER and CER,
+	// like minimal integers, booleans as 0x00 or 0xff and SET components in
+	// ascending order of their tags.
+	Strict() bool
+}
+
+// Default implementations of EncodingRules.
+var (
+	BER EncodingRules = BasicEncodingRules{}
+	DER EncodingRules = DistinguishedEncodingRules{}
+	CER EncodingRules = CanonicalEncodingRules{}
+)
+
+// BasicEncodingRules implements the Basic Encoding Rules. During encoding
+// it honors the option "indefinite" and keeps the order of SET components.
+// During decoding all valid BER encodings are accepted.
+type BasicEncodingRules struct{}
+
+// IndefiniteLength implements EncodingRules.
+func (BasicEncodingRules) IndefiniteLength(requested bool) bool { return requested }
+
+// SortSet implements EncodingRules.
+func (BasicEncodingRules) SortSet() bool { return false }
+
+// OmitDefault implements EncodingRules.
+func (BasicEncodingRules) OmitDefault() bool { return false }
+
+// SegmentSize implements EncodingRules.
+func (BasicEncodingRules) SegmentSize() int { return 0 }
+
+// CheckLength implements EncodingRules.
+func (BasicEncodingRules) CheckLength(constructed, indefinite bool) error { return nil }
+
+// Strict implements EncodingRules.
+func (BasicEncodingRules) Strict() bool { return false }
+
+// DistinguishedEncodingRules implements the Distinguished Encoding Rules.
+//
+// For compatibility with previous versions, the option "indefinite" is still
+// honored during encoding, but indefinite lengths are rejected during decoding.
+type DistinguishedEncodingRules struct{}
+
+// IndefiniteLength implements EncodingRules.
+func (DistinguishedEncodingRules) IndefiniteLength(requested bool) bool { return requested }
+
+// SortSet implements EncodingRules.
+func (DistinguishedEncodingRules) SortSet() bool { return true }
+
+// OmitDefault implements EncodingRules.
+func (DistinguishedEncodingRules) OmitDefault() bool { return true }
+
+// SegmentSize implements EncodingRules.
+func (DistinguishedEncodingRules) SegmentSize() int { return 0 }
+
+// CheckLength implements EncodingRules.
+func (DistinguishedEncodingRules) CheckLength(constructed, indefinite bool) error {
+	if indefinite {
+		return parseError("indefinite length form is not supported by DER mode")
+	}
+	return nil
+}
+
+// Strict implements EncodingRules.
+func (DistinguishedEncodingRules) Strict() bool { return true }
+
+// CanonicalEncodingRules implements the Canonical Encoding Rules. Constructed
+// values always use the indefinite length form and strings longer than 1000
+// octets are segmented.
+type CanonicalEncodingRules struct{}
+
+// IndefiniteLength implements EncodingRules.
+func (CanonicalEncodingRules) IndefiniteLength(requested bool) bool { return true }
+
+// SortSet implements EncodingRules.
+func (CanonicalEncodingRules) SortSet() bool { return true }
+
+// OmitDefault implements EncodingRules.
+func (CanonicalEncodingRules) OmitDefault() bool { return true }
+
+// SegmentSize implements EncodingRules.
+func (CanonicalEncodingRules) SegmentSize() int { return 1000 }
+
+// CheckLength implements EncodingRules.
+func (CanonicalEncodingRules) CheckLength(constructed, indefinite bool) error {
+	if constructed && !indefinite {
+		return parseError("definite length form is not supported by CER mode for constructed values")
+	}
+	return nil
+}
+
+// Strict implements EncodingRules.
+func (CanonicalEncodingRules) Strict() bool { return true }
@@ -0,0 +1,106 @@
+package asn1
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestCer(t *testing.T) {
+	type Type struct {
+		Flag bool
+		Num  int `asn1:"default:1"`
+	}
+	ctx := NewContext()
+	ctx.SetRules(CER, CER)
+	testEncodeDecode(t, ctx, "", testCase{
+		Type{true, 5},
+		[]byte{
+			0x30, 0x80,
+			0x01, 0x01, 0xff,
+			0x02, 0x01, 0x05,
+			0x00, 0x00,
+		},
+	})
+	// Values equal to the DEFAULT are omitted
+	testEncode(t, ctx, "", testCase{
+		Type{true, 1},
+		[]byte{0x30, 0x80, 0x01, 0x01, 0xff, 0x00, 0x00},
+	})
+
+	// Definite lengths are rejected for constructed values
+	obj := Type{}
+	_, err := ctx.Decode([]byte{0x30, 0x03, 0x01, 0x01, 0xff}, &obj)
+	if err == nil {
+		t.Fatal("Decoding a definite length in CER should have failed.")
+	}
+}
+
+func TestCerSegments(t *testing.T) {
+	ctx := NewContext()
+	ctx.SetRules(CER, CER)
+
+	data := bytes.Repeat([]byte{0x61}, 1001)
+	expected := []byte{0x24, 0x80, 0x04, 0x82, 0x03, 0xe8}
+	expected = append(expected, data[:1000]...)
+	expected = append(expected, 0x04, 0x01, 0x61, 0x00, 0x00)
+	testEncodeDecode(t, ctx, "", testCase{data, expected})
+	testEncodeDecode(t, ctx, "", testCase{data[:1000], append([]byte{0x04, 0x82, 0x03, 0xe8}, data[:1000]...)})
+
+	// Character strings use OCTET STRING segments
+	expected[0] = 0x36
+	testEncodeDecode(t, ctx, "ia5", testCase{string(data), expected})
+
+	// BIT STRING segments have their own unused bits octet
+	bs := BitString{Bytes: bytes.Repeat([]byte{0xf0}, 1000), BitLength: 7996}
+	expected = []byte{0x23, 0x80, 0x03, 0x82, 0x03, 0xe8, 0x00}
+	expected = append(expected, bs.Bytes[:999]...)
+	expected = append(expected, 0x03, 0x02, 0x04, 0xf0, 0x00, 0x00)
+	testEncodeDecode(t, ctx, "", testCase{bs, expected})
+
+	// DER does not accept constructed strings
+	ctx.SetRules(DER, DER)
+	var decoded []byte
+	if _, err := ctx.Decode(expected, &decoded); err == nil {
+		t.Fatal("Decoding a constructed string in DER should have failed.")
+	}
+	// But BER does
+	ctx.SetRules(DER, BER)
+	if _, err := ctx.Decode([]byte{0x24, 0x06, 0x04, 0x01, 0x61, 0x04, 0x01, 0x62}, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	checkEqual(t, decoded, []byte("ab"))
+}
+
+func TestDerSetOf(t *testing.T) {
+	ctx := NewContext()
+	testEncode(t, ctx, "set", testCase{
+		[]int{3, 1, 2},
+		[]byte{0x31, 0x09, 0x02, 0x01, 0x01, 0x02, 0x01, 0x02, 0x02, 0x01, 0x03},
+	})
+	ctx.SetRules(BER, BER)
+	testEncode(t, ctx, "set", testCase{
+		[]int{3, 1, 2},
+		[]byte{0x31, 0x09, 0x02, 0x01, 0x03, 0x02, 0x01, 0x01, 0x02, 0x01, 0x02},
+	})
+}
+
+// definiteBer is a BER variant that never uses the indefinite length form.
+type definiteBer struct {
+	BasicEncodingRules
+}
+
+func (definiteBer) IndefiniteLength(requested bool) bool {
+	return false
+}
+
+func TestCustomRules(t *testing.T) {
+	type Type struct {
+		Flag bool
+	}
+	ctx := NewContext()
+	ctx.SetRules(definiteBer{}, nil)
+	testEncode(t, ctx, "indefinite", testCase{
+		Type{true},
+		[]byte{0x30, 0x03, 0x01, 0x01, 0xff},
+	})
+}
@@ -0,0 +1,858 @@
+package asn1
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"reflect"
+	"sort"
+)
+
+// Kind is the kind of an ASN.1 type described by a Schema.
+type Kind int
+
+// Kinds of types
+const (
+	BooleanKind Kind = iota
+	IntegerKind
+	EnumeratedKind
+	BitStringKind
+	OctetStringKind
+	NullKind
+	OidKind
+	// Character strings, whose type is given by the options "utf8",
+	// "printable", etc. Like Go strings, they are OCTET STRINGs otherwise.
+	StringKind
+	SequenceKind
+	SetKind
+	SequenceOfKind
+	SetOfKind
+	ChoiceKind
+	// Open type, like ANY, which accepts an element with any tag
+	AnyKind
+)
+
+// kindNames has the ASN.1 names of the kinds.
+var kindNames = map[Kind]string{
+	BooleanKind:     "BOOLEAN",
+	IntegerKind:     "INTEGER",
+	EnumeratedKind:  "ENUMERATED",
+	BitStringKind:   "BIT STRING",
+	OctetStringKind: "OCTET STRING",
+	NullKind:        "NULL",
+	OidKind:         "OBJECT IDENTIFIER",
+	StringKind:      "OCTET STRING",
+	SequenceKind:    "SEQUENCE",
+	SetKind:         "SET",
+	SequenceOfKind:  "SEQUENCE OF",
+	SetOfKind:       "SET OF",
+	ChoiceKind:      "CHOICE",
+	AnyKind:         "ANY",
+}
+
+// String returns the ASN.1 name of the kind.
+func (k Kind) String() string {
+	if name, ok := kindNames[k]; ok {
+		return name
+	}
+	return fmt.Sprintf("Kind(%d)", int(k))
+}
+
+// schemaUniversalTags are the universal tags of the kinds. CHOICE and ANY have
+// no tag of their own.
+var schemaUniversalTags = map[Kind]uint{
+	BooleanKind:     tagBoolean,
+	IntegerKind:     tagInteger,
+	EnumeratedKind:  tagEnumerated,
+	BitStringKind:   tagBitString,
+	OctetStringKind: tagOctetString,
+	NullKind:        tagNull,
+	OidKind:         tagOid,
+	StringKind:      tagOctetString,
+	SequenceKind:    tagSequence,
+	SetKind:         tagSet,
+	SequenceOfKind:  tagSequence,
+	SetOfKind:       tagSet,
+}
+
+// schemaGoTypes are the Go types of the values of simple kinds.
+var schemaGoTypes = map[Kind]reflect.Type{
+	BooleanKind:     reflect.TypeOf(false),
+	IntegerKind:     bigIntType,
+	EnumeratedKind:  bigIntType,
+	BitStringKind:   bitStringType,
+	OctetStringKind: reflect.TypeOf([]byte(nil)),
+	NullKind:        nullType,
+	OidKind:         oidType,
+	StringKind:      reflect.TypeOf(""),
+}
+
+// Schema describes an ASN.1 type at runtime. It takes the place of a Go type
+// to encode and decode values whose types are only known at runtime, such as
+// the types of a module loaded by (*Context).LoadModule.
+//
+// Schemas may refer to each other, so recursive types are allowed:
+//
+//	tree := &asn1.Schema{Name: "Tree", Kind: asn1.SequenceKind}
+//	tree.Components = []asn1.Component{
+//		{Name: "label", Schema: &asn1.Schema{Kind: asn1.StringKind, Options: "utf8"}},
+//		{Name: "children", Schema: &asn1.Schema{Kind: asn1.SequenceOfKind, Element: tree}, Options: "optional"},
+//	}
+//	err := ctx.AddSchema(tree)
+//
+type Schema struct {
+	// Name of the type, empty for types defined inline
+	Name string
+	Kind Kind
+	// Options of the type in the format of struct tags, like
+	// "tag:1,application", "ia5" or "range:0..10". The options "optional",
+	// "default", "addition" and "name" belong to components, while "set",
+	// "choice" and "enum" are replaced by the kinds SET, SET OF, CHOICE and
+	// ENUMERATED.
+	Options string
+	// Components of SEQUENCE and SET types and alternatives of CHOICE types,
+	// with the extension additions last
+	Components []Component
+	// Element type of SEQUENCE OF and SET OF types
+	Element *Schema
+	// Named values of ENUMERATED types
+	Enum []Enum
+}
+
+// Component is a component of a SEQUENCE or SET, or an alternative of a CHOICE.
+type Component struct {
+	Name   string
+	Schema *Schema
+	// Options of the component, like "tag:0,explicit", "optional", "default:1"
+	// or "addition". Tags are applied over the ones given by the options of
+	// the schema.
+	Options string
+}
+
+// Value is a value of a Schema, decoded by (*Context).DecodeValue or to be
+// encoded by (*Context).EncodeValue.
+//
+// The Go types of simple values are bool for BOOLEAN, *big.Int for INTEGER,
+// the name of the value for ENUMERATED, BitString, []byte for OCTET STRING,
+// Null, Oid, string for character strings and *Node for ANY.
+type Value struct {
+	// Name of the component or alternative, empty for the root value and for
+	// the elements of SEQUENCE OF and SET OF
+	Name   string
+	Schema *Schema
+	// Value of simple types
+	Data interface{}
+	// Components of SEQUENCE and SET values, elements of SEQUENCE OF and SET
+	// OF values and the chosen alternative of CHOICE values
+	Children []*Value
+}
+
+// schemaTag is the class and number of a tag.
+type schemaTag struct {
+	class, tag uint
+}
+
+// TypeName returns the name of the schema, or the ASN.1 name of its kind for
+// types defined inline, like "UTF8String" or "SEQUENCE OF INTEGER".
+func (s *Schema) TypeName() string {
+	if s.Name != "" {
+		return s.Name
+	}
+	switch s.Kind {
+	case StringKind:
+		if opts, err := s.options(); err == nil && opts.stringTag != nil {
+			return universalNames[uint(*opts.stringTag)]
+		}
+	case SequenceOfKind, SetOfKind:
+		if s.Element != nil {
+			return s.Kind.String() + " " + s.Element.TypeName()
+		}
+	}
+	return s.Kind.String()
+}
+
+// options returns the parsed options of the schema.
+func (s *Schema) options() (*fieldOptions, error) {
+	opts, err := parseOptions(s.Options)
+	if err == nil && opts == nil {
+		err = syntaxError("invalid options '-' for %s", s.TypeName())
+	}
+	return opts, err
+}
+
+// options returns the parsed options of the component.
+func (c Component) options() (*fieldOptions, error) {
+	opts, err := parseOptions(c.Options)
+	if err == nil && opts == nil {
+		err = syntaxError("invalid options '-' for component '%s'", c.Name)
+	}
+	return opts, err
+}
+
+// component returns the index of a component, or -1 if there's none with the
+// given name.
+func (s *Schema) component(name string) int {
+	for i, c := range s.Components {
+		if c.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// getEnumName returns the name of an ENUMERATED value.
+func (s *Schema) getEnumName(n int64) (string, bool) {
+	for _, e := range s.Enum {
+		if int64(e.Value) == n {
+			return e.Name, true
+		}
+	}
+	return "", false
+}
+
+// getDefault returns the DEFAULT value of a component.
+func (s *Schema) getDefault(opts *fieldOptions) (interface{}, error) {
+	switch s.Kind {
+	case IntegerKind:
+		return big.NewInt(int64(*opts.defaultValue)), nil
+	case EnumeratedKind:
+		if name, ok := s.getEnumName(int64(*opts.defaultValue)); ok {
+			return name, nil
+		}
+		return nil, syntaxError("invalid default value %d for %s", *opts.defaultValue, s.TypeName())
+	}
+	return nil, syntaxError("default value is only allowed to integers")
+}
+
+// isDefault checks if a value is equal to the DEFAULT value of a component.
+func (s *Schema) isDefault(v *Value, opts *fieldOptions) bool {
+	def, err := s.getDefault(opts)
+	if err != nil {
+		return false
+	}
+	if n, ok := v.Data.(*big.Int); ok && n != nil {
+		return n.Cmp(def.(*big.Int)) == 0
+	}
+	return v.Data == def
+}
+
+// Child returns the component or alternative with the given name, or nil if
+// the value has no such child.
+func (v *Value) Child(name string) *Value {
+	for _, child := range v.Children {
+		if child.Name == name {
+			return child
+		}
+	}
+	return nil
+}
+
+// AddSchema registers a named schema, which can be used by DecodeValue.
+// Schemas referenced by the registered one don't need to be registered.
+func (ctx *Context) AddSchema(s *Schema) error {
+	if s.Name == "" {
+		return syntaxError("schema must have a name")
+	}
+	if ctx.schemas[s.Name] != nil {
+		return fmt.Errorf("schema already registered: %s", s.Name)
+	}
+	if err := checkSchema(s, map[*Schema]bool{}); err != nil {
+		return err
+	}
+	ctx.schemas[s.Name] = s
+	return nil
+}
+
+// Schema returns the registered schema with the given name, or nil if there is
+// none.
+func (ctx *Context) Schema(name string) *Schema {
+	return ctx.schemas[name]
+}
+
+// checkSchema returns an error if a schema or any schema referenced by it is
+// invalid. Visited schemas are not checked again, so recursive types are
+// accepted.
+func checkSchema(s *Schema, visited map[*Schema]bool) error {
+	if visited[s] {
+		return nil
+	}
+	visited[s] = true
+	if _, ok := kindNames[s.Kind]; !ok {
+		return syntaxError("invalid kind %s", s.Kind)
+	}
+	opts, err := s.options()
+	if err != nil {
+		return err
+	}
+	if opts.optional || opts.defaultValue != nil || opts.addition || opts.name != nil ||
+		opts.set || opts.choice != nil || opts.enum != nil {
+		return syntaxError("invalid options '%s' for %s", s.Options, s.TypeName())
+	}
+	if opts.stringTag != nil && s.Kind != StringKind {
+		return syntaxError("string types cannot be used with %s", s.TypeName())
+	}
+	if _, _, err = getSchemaTags(s, nil); err != nil {
+		return err
+	}
+
+	switch s.Kind {
+	case SequenceKind, SetKind, ChoiceKind:
+		names := map[string]bool{}
+		for _, c := range s.Components {
+			if c.Name == "" || names[c.Name] {
+				return syntaxError("invalid component name '%s' in %s", c.Name, s.TypeName())
+			}
+			names[c.Name] = true
+			if c.Schema == nil {
+				return syntaxError("component '%s' of %s has no schema", c.Name, s.TypeName())
+			}
+			if err := checkComponent(s, c); err != nil {
+				return err
+			}
+			if err := checkSchema(c.Schema, visited); err != nil {
+				return err
+			}
+		}
+		if s.Kind != SequenceKind {
+			return checkDistinctTags(s)
+		}
+
+	case SequenceOfKind, SetOfKind:
+		if s.Element == nil {
+			return syntaxError("%s has no element schema", s.TypeName())
+		}
+		return checkSchema(s.Element, visited)
+	}
+	return nil
+}
+
+// checkComponent returns an error if the options of a component are invalid.
+func checkComponent(s *Schema, c Component) error {
+	opts, err := c.options()
+	if err != nil {
+		return err
+	}
+	if opts.set || opts.choice != nil || opts.enum != nil || opts.name != nil ||
+		opts.stringTag != nil || opts.valueRange != nil || opts.size != nil || opts.extensible ||
+		(s.Kind == ChoiceKind && (opts.optional || opts.defaultValue != nil)) {
+		return syntaxError("invalid options '%s' for component '%s' of %s",
+			c.Options, c.Name, s.TypeName())
+	}
+	if opts.defaultValue != nil {
+		if _, err = c.Schema.getDefault(opts); err != nil {
+			return err
+		}
+	}
+	_, _, err = getSchemaTags(c.Schema, opts)
+	return err
+}
+
+// checkDistinctTags returns an error if the components of a SET or the
+// alternatives of a CHOICE have the same tag.
+func checkDistinctTags(s *Schema) error {
+	used := map[schemaTag]bool{}
+	for _, c := range s.Components {
+		opts, _ := parseOptions(c.Options)
+		tags, _, err := getFirstTags(c.Schema, opts, 0)
+		if err != nil {
+			return err
+		}
+		for _, t := range tags {
+			if used[t] {
+				return syntaxError("duplicated tag (%d,%d) in %s", t.class, t.tag, s.TypeName())
+			}
+			used[t] = true
+		}
+	}
+	return nil
+}
+
+// getSchemaTags returns the tags of the encoding of a schema, from the
+// outermost to the innermost, and its universal tag. The options of a
+// component, if given, are applied over the options of the schema.
+//
+// All tags but the innermost one are explicit. CHOICE and ANY types, whose
+// universal tag is zero, only have their explicit tags, since the innermost tag
+// depends on the value.
+func getSchemaTags(s *Schema, opts *fieldOptions) (tags []schemaTag, universal uint, err error) {
+	schemaOpts, err := s.options()
+	if err != nil {
+		return
+	}
+	universal = schemaUniversalTags[s.Kind]
+	if schemaOpts.stringTag != nil {
+		universal = uint(*schemaOpts.stringTag)
+	}
+	if universal != 0 {
+		tags = append(tags, schemaTag{ClassUniversal, universal})
+	}
+	for _, o := range []*fieldOptions{schemaOpts, opts} {
+		if o == nil {
+			continue
+		}
+		if o.tag == nil {
+			if o.explicit {
+				err = syntaxError("invalid flag 'explicit' without tag on %s", s.TypeName())
+				return
+			}
+			continue
+		}
+		t := schemaTag{ClassContextSpecific, uint(*o.tag)}
+		if o.universal {
+			t.class = ClassUniversal
+		}
+		if o.application {
+			t.class = ClassApplication
+		}
+		switch {
+		case o.explicit:
+			tags = append([]schemaTag{t}, tags...)
+		case len(tags) == 0:
+			err = syntaxError("%s cannot have an implicit tag", s.TypeName())
+			return
+		default:
+			tags[0] = t
+		}
+	}
+	return
+}
+
+// getFirstTags returns the tags that the outermost element of a schema may
+// have. The flag any is set if any tag is accepted, which happens for untagged
+// ANY types.
+func getFirstTags(s *Schema, opts *fieldOptions, depth int) (tags []schemaTag, any bool, err error) {
+	if depth > 100 {
+		return nil, false, syntaxError("circular CHOICE %s", s.TypeName())
+	}
+	schemaTags, _, err := getSchemaTags(s, opts)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(schemaTags) > 0 {
+		return schemaTags[:1], false, nil
+	}
+	if s.Kind == AnyKind {
+		return nil, true, nil
+	}
+	for _, c := range s.Components {
+		altOpts, err := parseOptions(c.Options)
+		if err != nil {
+			return nil, false, err
+		}
+		altTags, altAny, err := getFirstTags(c.Schema, altOpts, depth+1)
+		if err != nil {
+			return nil, false, err
+		}
+		tags = append(tags, altTags...)
+		any = any || altAny
+	}
+	return
+}
+
+// matchesSchema checks if a node may be a value of a schema.
+func matchesSchema(node *Node, s *Schema, opts *fieldOptions) bool {
+	tags, any, err := getFirstTags(s, opts, 0)
+	if err != nil {
+		return false
+	}
+	for _, t := range tags {
+		if node.Class == t.class && node.Tag == t.tag {
+			return true
+		}
+	}
+	return any
+}
+
+/*
+ * Encoding
+ */
+
+// EncodeValue returns the encoding of a value of a Schema.
+//
+// The schemas of the descendants of the value are given by the components and
+// elements of its schema, so their field Schema is not used. Components that
+// are not found are handled as empty Go values: they're omitted if marked as
+// "optional" and replaced by their DEFAULT value according to the encoding
+// rules, just like (*Context).Encode does.
+func (ctx *Context) EncodeValue(v *Value) ([]byte, error) {
+	if v.Schema == nil {
+		return nil, syntaxError("value has no schema")
+	}
+	if err := checkSchema(v.Schema, map[*Schema]bool{}); err != nil {
+		return nil, err
+	}
+	raw, err := ctx.encodeSchemaValue(v, v.Schema, nil)
+	if err != nil {
+		return nil, err
+	}
+	return raw.encode()
+}
+
+// encodeSchemaValue encodes a value of a schema. The options of its component
+// are given by opts.
+func (ctx *Context) encodeSchemaValue(v *Value, s *Schema, opts *fieldOptions) (raw *rawValue, err error) {
+	tags, universal, err := getSchemaTags(s, opts)
+	if err != nil {
+		return nil, err
+	}
+	schemaOpts, _ := s.options()
+	indefinite := schemaOpts.indefinite || (opts != nil && opts.indefinite)
+
+	switch s.Kind {
+	case SequenceKind, SetKind:
+		raw, err = ctx.encodeComponents(v, s)
+	case SequenceOfKind, SetOfKind:
+		raw, err = ctx.encodeElements(v, s)
+	case ChoiceKind:
+		if len(v.Children) != 1 {
+			return nil, syntaxError("value of %s must have one alternative", s.TypeName())
+		}
+		child := v.Children[0]
+		i := s.component(child.Name)
+		if i < 0 {
+			return nil, syntaxError("invalid alternative '%s' for %s", child.Name, s.TypeName())
+		}
+		alt := s.Components[i]
+		altOpts, _ := parseOptions(alt.Options)
+		raw, err = ctx.encodeSchemaValue(child, alt.Schema, altOpts)
+	case AnyKind:
+		node, ok := v.Data.(*Node)
+		if !ok || node == nil {
+			return nil, syntaxError("invalid value %T for %s", v.Data, s.TypeName())
+		}
+		raw, err = node.raw(ctx.rules.encoding.IndefiniteLength(true))
+	default:
+		raw, err = ctx.encodeSimpleValue(v, s)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if universal != 0 {
+		// Split long strings in segments
+		if size := ctx.rules.encoding.SegmentSize(); size > 0 {
+			if !raw.Constructed && isStringTag(universal) && len(raw.Content) > size {
+				raw = segmentString(raw, size)
+			}
+		}
+		last := tags[len(tags)-1]
+		raw.Class, raw.Tag = last.class, last.tag
+		raw.Indefinite = raw.Constructed && ctx.rules.encoding.IndefiniteLength(indefinite || raw.Indefinite)
+		tags = tags[:len(tags)-1]
+	}
+
+	// Add the enclosing tags
+	for i := len(tags) - 1; i >= 0; i-- {
+		content, err := raw.encode()
+		if err != nil {
+			return nil, err
+		}
+		raw = &rawValue{Class: tags[i].class, Tag: tags[i].tag, Constructed: true, Content: content}
+		raw.Indefinite = ctx.rules.encoding.IndefiniteLength(indefinite)
+	}
+	return raw, nil
+}
+
+// encodeSimpleValue encodes the content of a value that is neither
+// constructed nor a CHOICE or ANY.
+func (ctx *Context) encodeSimpleValue(v *Value, s *Schema) (*rawValue, error) {
+	data := v.Data
+	if s.Kind == EnumeratedKind {
+		name, _ := data.(string)
+		data = nil
+		for _, e := range s.Enum {
+			if e.Name == name {
+				data = big.NewInt(int64(e.Value))
+			}
+		}
+		if data == nil {
+			return nil, syntaxError("invalid value '%v' for %s", v.Data, s.TypeName())
+		}
+	}
+	value := reflect.ValueOf(data)
+	if !value.IsValid() || value.Type() != schemaGoTypes[s.Kind] ||
+		(value.Kind() == reflect.Ptr && value.IsNil()) {
+		return nil, syntaxError("invalid value %T for %s", v.Data, s.TypeName())
+	}
+	return ctx.encodeValue(value, &fieldOptions{})
+}
+
+// encodeComponents encodes the components of a SEQUENCE or SET value.
+func (ctx *Context) encodeComponents(v *Value, s *Schema) (*rawValue, error) {
+	children := make([]*Value, len(s.Components))
+	for _, child := range v.Children {
+		i := s.component(child.Name)
+		if i < 0 || children[i] != nil {
+			return nil, syntaxError("invalid component '%s' for %s", child.Name, s.TypeName())
+		}
+		children[i] = child
+	}
+
+	values := []*rawValue{}
+	for i, c := range s.Components {
+		opts, _ := parseOptions(c.Options)
+		child := children[i]
+		if opts.defaultValue != nil {
+			omit := ctx.rules.encoding.OmitDefault()
+			if child != nil && omit && c.Schema.isDefault(child, opts) {
+				continue
+			}
+			if child == nil {
+				if omit {
+					continue
+				}
+				def, _ := c.Schema.getDefault(opts)
+				child = &Value{Name: c.Name, Schema: c.Schema, Data: def}
+			}
+		}
+		if child == nil {
+			if opts.optional {
+				continue
+			}
+			return nil, syntaxError("missing component '%s' of %s", c.Name, s.TypeName())
+		}
+		raw, err := ctx.encodeSchemaValue(child, c.Schema, opts)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, raw)
+	}
+	if s.Kind == SetKind && ctx.rules.encoding.SortSet() {
+		sort.Sort(rawValueSlice(values))
+	}
+	content, err := ctx.encodeRawValues(values...)
+	if err != nil {
+		return nil, err
+	}
+	return &rawValue{Constructed: true, Content: content}, nil
+}
+
+// encodeElements encodes the elements of a SEQUENCE OF or SET OF value.
+func (ctx *Context) encodeElements(v *Value, s *Schema) (*rawValue, error) {
+	elements := make([][]byte, len(v.Children))
+	for i, child := range v.Children {
+		raw, err := ctx.encodeSchemaValue(child, s.Element, nil)
+		if err != nil {
+			return nil, err
+		}
+		if elements[i], err = raw.encode(); err != nil {
+			return nil, err
+		}
+	}
+	if s.Kind == SetOfKind && ctx.rules.encoding.SortSet() {
+		sort.Slice(elements, func(i, j int) bool {
+			return bytes.Compare(elements[i], elements[j]) < 0
+		})
+	}
+	return &rawValue{Constructed: true, Content: bytes.Join(elements, nil)}, nil
+}
+
+/*
+ * Decoding
+ */
+
+// DecodeValue parses the given data into a value of a registered schema and
+// returns the remaining bytes.
+//
+// The resulting tree is labelled with the names of the components and their
+// schemas. Missing components with a DEFAULT value are set to it, while
+// missing optional ones are left out. Unknown components of extensible SEQUENCE
+// and SET types are ignored.
+func (ctx *Context) DecodeValue(data []byte, schema string) (v *Value, rest []byte, err error) {
+	s := ctx.schemas[schema]
+	if s == nil {
+		return nil, nil, syntaxError("invalid schema '%s'", schema)
+	}
+	node, err := parseNode(data, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	v, err = ctx.decodeSchemaValue(node, s, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	return v, data[node.Len():], nil
+}
+
+// decodeSchemaValue decodes a node as a value of a schema. The options of its
+// component are given by opts.
+func (ctx *Context) decodeSchemaValue(node *Node, s *Schema, opts *fieldOptions) (*Value, error) {
+	tags, universal, err := getSchemaTags(s, opts)
+	if err != nil {
+		return nil, err
+	}
+	for i, t := range tags {
+		err = ctx.rules.decoding.CheckLength(node.Constructed, node.Indefinite)
+		if err != nil {
+			return nil, err
+		}
+		if node.Class != t.class || node.Tag != t.tag {
+			return nil, parseError("expected tag (%d,%d) but found (%d,%d)",
+				t.class, t.tag, node.Class, node.Tag)
+		}
+		// Remove the enclosing tags
+		if i < len(tags)-1 || universal == 0 {
+			if !node.Constructed || len(node.Children) != 1 {
+				return nil, parseError("explicit tag (%d,%d) must have a single element",
+					node.Class, node.Tag)
+			}
+			node = node.Children[0]
+		}
+	}
+
+	v := &Value{Schema: s}
+	switch s.Kind {
+	case SequenceKind, SetKind, SequenceOfKind, SetOfKind:
+		if !node.Constructed {
+			return nil, parseError("expected constructed element (%d,%d)", node.Class, node.Tag)
+		}
+		if s.Kind == SequenceKind || s.Kind == SetKind {
+			v.Children, err = ctx.decodeComponents(node.Children, s)
+			break
+		}
+		for _, child := range node.Children {
+			elem, err := ctx.decodeSchemaValue(child, s.Element, nil)
+			if err != nil {
+				return nil, err
+			}
+			v.Children = append(v.Children, elem)
+		}
+	case ChoiceKind:
+		for _, alt := range s.Components {
+			altOpts, _ := parseOptions(alt.Options)
+			if matchesSchema(node, alt.Schema, altOpts) {
+				child, err := ctx.decodeSchemaValue(node, alt.Schema, altOpts)
+				if err != nil {
+					return nil, err
+				}
+				child.Name = alt.Name
+				v.Children = []*Value{child}
+				return v, nil
+			}
+		}
+		return nil, parseError("invalid tag (%d,%d) for %s", node.Class, node.Tag, s.TypeName())
+	case AnyKind:
+		err = ctx.rules.decoding.CheckLength(node.Constructed, node.Indefinite)
+		v.Data = node
+	default:
+		v.Data, err = ctx.decodeSimpleValue(node, s)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// decodeSimpleValue decodes the content of a node as a value that is neither
+// constructed nor a CHOICE or ANY.
+func (ctx *Context) decodeSimpleValue(node *Node, s *Schema) (interface{}, error) {
+	goType := schemaGoTypes[s.Kind]
+	elem, err := ctx.getUniversalTag(goType, &fieldOptions{})
+	if err != nil {
+		return nil, err
+	}
+	if node.Constructed && elem.stringTag == 0 {
+		return nil, parseError("constructed element (%d,%d) cannot be decoded as %s",
+			node.Class, node.Tag, s.TypeName())
+	}
+	content, err := ctx.getContent(&rawValue{Constructed: node.Constructed, Content: node.Content}, elem)
+	if err != nil {
+		return nil, err
+	}
+	value := reflect.New(goType).Elem()
+	if err = elem.decoder(content, value); err != nil {
+		return nil, err
+	}
+	if s.Kind != EnumeratedKind {
+		return value.Interface(), nil
+	}
+	n := value.Interface().(*big.Int)
+	if name, ok := s.getEnumName(n.Int64()); n.IsInt64() && ok {
+		return name, nil
+	}
+	return nil, parseError("invalid value %s for %s", n, s.TypeName())
+}
+
+// decodeComponents decodes the components of a SEQUENCE or SET value, which
+// are returned in the order of the schema.
+func (ctx *Context) decodeComponents(children []*Node, s *Schema) ([]*Value, error) {
+	opts := make([]*fieldOptions, len(s.Components))
+	for i, c := range s.Components {
+		opts[i], _ = parseOptions(c.Options)
+	}
+	schemaOpts, _ := s.options()
+	found := make([]*Value, len(s.Components))
+	decode := func(node *Node, i int) (err error) {
+		found[i], err = ctx.decodeSchemaValue(node, s.Components[i].Schema, opts[i])
+		return
+	}
+	unexpected := func(node *Node) error {
+		if schemaOpts.extensible {
+			return nil
+		}
+		return parseError("unexpected element (%d,%d) in %s", node.Class, node.Tag, s.TypeName())
+	}
+
+	if s.Kind == SetKind {
+		// Components of a SET may be in any order, unless the rules are strict
+		for n, node := range children {
+			if n > 0 && ctx.rules.decoding.Strict() {
+				prev := children[n-1]
+				if !isTagLessThan(prev.Class, prev.Tag, node.Class, node.Tag) {
+					return nil, parseError("components of %s must be in ascending order of their tags",
+						s.TypeName())
+				}
+			}
+			i := 0
+			for i < len(s.Components) &&
+				(found[i] != nil || !matchesSchema(node, s.Components[i].Schema, opts[i])) {
+				i++
+			}
+			var err error
+			if i < len(s.Components) {
+				err = decode(node, i)
+			} else {
+				err = unexpected(node)
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+	} else {
+		n := 0
+		for i, c := range s.Components {
+			if n < len(children) && matchesSchema(children[n], c.Schema, opts[i]) {
+				if err := decode(children[n], i); err != nil {
+					return nil, err
+				}
+				n++
+			}
+		}
+		if n < len(children) {
+			if err := unexpected(children[n]); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	values := []*Value{}
+	for i, c := range s.Components {
+		v := found[i]
+		switch {
+		case v != nil:
+		case opts[i].defaultValue != nil:
+			def, err := c.Schema.getDefault(opts[i])
+			if err != nil {
+				return nil, err
+			}
+			v = &Value{Schema: c.Schema, Data: def}
+		case opts[i].optional:
+			continue
+		default:
+			return nil, parseError("missing component '%s' of %s", c.Name, s.TypeName())
+		}
+		v.Name = c.Name
+		values = append(values, v)
+	}
+	return values, nil
+}
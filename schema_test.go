@@ -0,0 +1,156 @@
+package asn1
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+)
+
+// treeSchema is a recursive schema equivalent to the Go type testTree.
+func treeSchema() *Schema {
+	tree := &Schema{Name: "Tree", Kind: SequenceKind}
+	tree.Components = []Component{
+		{Name: "label", Schema: &Schema{Kind: StringKind, Options: "utf8"}},
+		{Name: "weight", Schema: &Schema{Kind: IntegerKind}, Options: "tag:0,default:1"},
+		{Name: "children", Schema: &Schema{Kind: SequenceOfKind, Element: tree}, Options: "tag:1,optional"},
+	}
+	return tree
+}
+
+type testTree struct {
+	Label    string     `asn1:"utf8"`
+	Weight   int        `asn1:"tag:0,default:1"`
+	Children []testTree `asn1:"tag:1,optional"`
+}
+
+func TestSchemaValue(t *testing.T) {
+	ctx := NewContext()
+	if err := ctx.AddSchema(treeSchema()); err != nil {
+		t.Fatal(err)
+	}
+	obj := testTree{"root", 1, []testTree{{"a", 2, nil}, {"b", 1, nil}}}
+	expected, err := ctx.Encode(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v, rest, err := ctx.DecodeValue(expected, "Tree")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rest) > 0 {
+		t.Fatalf("Unexpected remaining bytes: %#v", rest)
+	}
+	children := v.Child("children")
+	if v.Child("label").Data != "root" || v.Child("weight").Data.(*big.Int).Int64() != 1 ||
+		len(children.Children) != 2 || children.Schema.TypeName() != "SEQUENCE OF Tree" ||
+		children.Children[0].Child("weight").Data.(*big.Int).Int64() != 2 ||
+		children.Children[1].Child("children") != nil {
+		t.Fatalf("Invalid decoded value: %+v", v)
+	}
+
+	data, err := ctx.EncodeValue(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !isBytesEqual(data, expected) {
+		t.Fatalf("Failed to encode value.\n Expected: %#v.\n Got:      %#v", expected, data)
+	}
+
+	// Empty components are encoded with their DEFAULT value in BER
+	ctx.SetRules(BER, BER)
+	v = &Value{Schema: ctx.Schema("Tree"), Children: []*Value{{Name: "label", Data: "x"}}}
+	data, err = ctx.EncodeValue(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = []byte{0x30, 0x06, 0x0c, 0x01, 'x', 0x80, 0x01, 0x01}
+	if !isBytesEqual(data, expected) {
+		t.Fatalf("Failed to encode value.\n Expected: %#v.\n Got:      %#v", expected, data)
+	}
+}
+
+func TestSchemaTags(t *testing.T) {
+	ctx := NewContext()
+	// [APPLICATION 3] IMPLICIT INTEGER
+	app := &Schema{Name: "App", Kind: IntegerKind, Options: "tag:3,application"}
+	choice := &Schema{Kind: ChoiceKind, Components: []Component{
+		{Name: "n", Schema: &Schema{Kind: NullKind}},
+		{Name: "b", Schema: &Schema{Kind: BooleanKind}, Options: "tag:2"},
+	}}
+	s := &Schema{Name: "Tagged", Kind: SequenceKind, Components: []Component{
+		// Explicit tag over a tagged type
+		{Name: "a", Schema: app, Options: "tag:0,explicit"},
+		// Implicit tag over a tagged type
+		{Name: "b", Schema: app, Options: "tag:1"},
+		{Name: "c", Schema: choice, Options: "tag:4,explicit"},
+		{Name: "d", Schema: &Schema{Kind: AnyKind}, Options: "optional"},
+	}}
+	if err := ctx.AddSchema(s); err != nil {
+		t.Fatal(err)
+	}
+	data := []byte{0x30, 0x10,
+		0xa0, 0x03, 0x43, 0x01, 0x05,
+		0x81, 0x01, 0x06,
+		0xa4, 0x03, 0x82, 0x01, 0xff,
+		0x04, 0x01, 0x07}
+	v, _, err := ctx.DecodeValue(data, "Tagged")
+	if err != nil {
+		t.Fatal(err)
+	}
+	alt := v.Child("c").Children[0]
+	any, _ := v.Child("d").Data.(*Node)
+	if v.Child("a").Data.(*big.Int).Int64() != 5 || v.Child("b").Data.(*big.Int).Int64() != 6 ||
+		alt.Name != "b" || alt.Data != true || any == nil || any.Tag != tagOctetString {
+		t.Fatalf("Invalid decoded value: %+v", v)
+	}
+	encoded, err := ctx.EncodeValue(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !isBytesEqual(encoded, data) {
+		t.Fatalf("Failed to encode value.\n Expected: %#v.\n Got:      %#v", data, encoded)
+	}
+}
+
+func TestSchemaErrors(t *testing.T) {
+	ctx := NewContext()
+	integer := &Schema{Kind: IntegerKind}
+	invalidSchemas := []*Schema{
+		{Name: "A", Kind: IntegerKind, Options: "optional"},
+		{Name: "B", Kind: BooleanKind, Options: "utf8"},
+		{Name: "C", Kind: ChoiceKind, Options: "tag:1"},
+		{Name: "D", Kind: SequenceOfKind},
+		{Name: "E", Kind: SequenceKind, Components: []Component{{Name: "a", Schema: integer}, {Name: "a", Schema: integer}}},
+		{Name: "F", Kind: SetKind, Components: []Component{{Name: "a", Schema: integer}, {Name: "b", Schema: integer}}},
+		{Name: "G", Kind: SequenceKind, Components: []Component{{Name: "a", Schema: &Schema{Kind: BooleanKind}, Options: "default:1"}}},
+	}
+	for _, s := range invalidSchemas {
+		if err := ctx.AddSchema(s); err == nil {
+			t.Errorf("Schema %s: expected error", s.Name)
+		}
+	}
+
+	if err := ctx.AddSchema(treeSchema()); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := ctx.DecodeValue([]byte{0x02, 0x01, 0x00}, "Other"); err == nil {
+		t.Error("Expected error for an unknown schema")
+	}
+	// Missing label
+	if _, _, err := ctx.DecodeValue([]byte{0x30, 0x03, 0x80, 0x01, 0x02}, "Tree"); err == nil {
+		t.Error("Expected error for a missing component")
+	}
+	invalidValues := []*Value{
+		{Schema: ctx.Schema("Tree")},
+		{Schema: ctx.Schema("Tree"), Children: []*Value{{Name: "label", Data: 1}}},
+		{Schema: ctx.Schema("Tree"), Children: []*Value{{Name: "other", Data: "x"}}},
+	}
+	for _, v := range invalidValues {
+		if _, err := ctx.EncodeValue(v); err == nil {
+			t.Errorf("Value %+v: expected error", v)
+		} else if reflect.TypeOf(err) != reflect.TypeOf(&SyntaxError{}) {
+			t.Errorf("Value %+v: unexpected error %v", v, err)
+		}
+	}
+}
@@ -0,0 +1,413 @@
+// Package syntax parses ASN.1 modules written in the notation defined by
+// X.680 and produces an abstract syntax tree.
+//
+// The following constructions are supported: module headers with tagging
+// defaults and EXTENSIBILITY IMPLIED, EXPORTS and IMPORTS, type and value
+// assignments, the builtin types, tagged types, SEQUENCE, SET and CHOICE types
+// with extension markers and version brackets, named numbers and bits,
+// ENUMERATED types and subtype constraints. Information object classes and
+// parameterized assignments are not supported.
+//
+// Every node of the tree has the position where it was found in the source:
+//
+//	modules, err := syntax.Parse("rfc5280.asn", src)
+//	if err != nil {
+//		// err is an *syntax.Error, like "rfc5280.asn:12:5: expected '}'"
+//	}
+//	for _, a := range modules[0].Assignments {
+//		...
+//	}
+package syntax
+
+import (
+	"fmt"
+	"math/big"
+)
+
+// Pos is a position in the source, starting at line 1, column 1.
+type Pos struct {
+	Line   int
+	Column int
+}
+
+// Position returns the position itself. It's used by the nodes that embed a
+// Pos to implement Node.
+func (p Pos) Position() Pos {
+	return p
+}
+
+func (p Pos) String() string {
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
+
+// Node is any node of the tree.
+type Node interface {
+	Position() Pos
+}
+
+// TagDefault is the tagging mode of a module or of a tagged type.
+type TagDefault int
+
+// Tagging modes
+const (
+	ExplicitTags TagDefault = iota
+	ImplicitTags
+	AutomaticTags
+)
+
+// Module is an ASN.1 module.
+type Module struct {
+	Pos
+	Name string
+	// Definitive identifier of the module, or nil if absent
+	Oid        *ObjectIdentifierValue
+	TagDefault TagDefault
+	// ExtensibilityImplied makes every SEQUENCE, SET, CHOICE and ENUMERATED
+	// type extensible.
+	ExtensibilityImplied bool
+	// ExportAll is set if EXPORTS is absent or if it's "EXPORTS ALL".
+	// Otherwise only the symbols in Exports are exported.
+	ExportAll   bool
+	Exports     []string
+	Imports     []*Import
+	Assignments []Assignment
+}
+
+// Import is a list of symbols imported from a module.
+type Import struct {
+	Pos
+	Symbols []string
+	Module  string
+	// Identifier of the module, or nil if absent
+	Oid *ObjectIdentifierValue
+}
+
+// Assignment is a TypeAssignment or a ValueAssignment.
+type Assignment interface {
+	Node
+	AssignedName() string
+}
+
+// TypeAssignment defines a type, like "Name ::= CHOICE { ... }".
+type TypeAssignment struct {
+	Pos
+	Name string
+	Type Type
+}
+
+// AssignedName returns the name of the type.
+func (a *TypeAssignment) AssignedName() string { return a.Name }
+
+// ValueAssignment defines a value, like "id-ce OBJECT IDENTIFIER ::= { 2 5 29 }".
+type ValueAssignment struct {
+	Pos
+	Name  string
+	Type  Type
+	Value Value
+}
+
+// AssignedName returns the name of the value.
+func (a *ValueAssignment) AssignedName() string { return a.Name }
+
+/*
+ * Types
+ */
+
+// Type is any of the types below.
+type Type interface {
+	Node
+	typeNode()
+}
+
+// BuiltinType is a builtin type without further definitions, like BOOLEAN,
+// NULL, OCTET STRING, OBJECT IDENTIFIER, REAL, the character string types and
+// the time types. Name is the name of the type as written in the source, with
+// a single space between words.
+type BuiltinType struct {
+	Pos
+	Name string
+}
+
+// IntegerType is an INTEGER with optional named numbers.
+type IntegerType struct {
+	Pos
+	NamedNumbers []*NamedNumber
+}
+
+// BitStringType is a BIT STRING with optional named bits.
+type BitStringType struct {
+	Pos
+	NamedBits []*NamedNumber
+}
+
+// EnumeratedType is an ENUMERATED type. Items without an explicit number have
+// a nil Value.
+type EnumeratedType struct {
+	Pos
+	Items      []*NamedNumber
+	Extensible bool
+	Additions  []*NamedNumber
+}
+
+// NamedNumber is a named number of an INTEGER, a named bit of a BIT STRING
+// or an item of an ENUMERATED type. Value is a NumberValue or a
+// ReferenceValue.
+type NamedNumber struct {
+	Pos
+	Name  string
+	Value Value
+}
+
+// SequenceType is a SEQUENCE, or a SET if Set is true.
+type SequenceType struct {
+	Pos
+	Set        bool
+	Components []*Component
+	// Extensible is set if the type has an extension marker. The components
+	// after a second extension marker are kept in Components.
+	Extensible bool
+	Additions  []*Component
+}
+
+// ChoiceType is a CHOICE type.
+type ChoiceType struct {
+	Pos
+	Alternatives []*Component
+	Extensible   bool
+	Additions    []*Component
+}
+
+// Component is a component of a SEQUENCE or SET, or an alternative of a
+// CHOICE. "COMPONENTS OF Type" is represented by a Component with no name and
+// with ComponentsOf set.
+type Component struct {
+	Pos
+	Name     string
+	Type     Type
+	Optional bool
+	// DEFAULT value, or nil if absent
+	Default      Value
+	ComponentsOf bool
+	// Version bracket ("[[ ]]") of extension additions, starting at 1. It's
+	// zero for the other components.
+	Group int
+}
+
+// SequenceOfType is a SEQUENCE OF, or a SET OF if Set is true.
+type SequenceOfType struct {
+	Pos
+	Set bool
+	// Constraint given before OF, like in "SEQUENCE SIZE (1..MAX) OF", or nil
+	Constraint *Constraint
+	// Name of the elements, like in "SEQUENCE OF item Item", or empty
+	ElementName string
+	Element     Type
+}
+
+// TagClass is the class of a tag.
+type TagClass int
+
+// Tag classes
+const (
+	ContextSpecific TagClass = iota
+	Universal
+	Application
+	Private
+)
+
+// TaggedType is a type with a tag, like "[APPLICATION 1] IMPLICIT INTEGER".
+// Mode is ExplicitTags or ImplicitTags when it's given, otherwise it's the tag
+// default of the module.
+type TaggedType struct {
+	Pos
+	Class TagClass
+	// NumberValue or ReferenceValue
+	Number Value
+	Mode   TagDefault
+	Type   Type
+}
+
+// ReferencedType is a reference to a type defined by an assignment.
+type ReferencedType struct {
+	Pos
+	// Module, like in "PKIX1Explicit88.Name", or empty
+	Module string
+	Name   string
+}
+
+// AnyType is the obsolete ANY type, optionally "ANY DEFINED BY field".
+type AnyType struct {
+	Pos
+	DefinedBy string
+}
+
+// ConstrainedType is a type followed by a constraint, like "INTEGER (0..7)".
+type ConstrainedType struct {
+	Pos
+	Type       Type
+	Constraint *Constraint
+}
+
+func (*BuiltinType) typeNode()     {}
+func (*IntegerType) typeNode()     {}
+func (*BitStringType) typeNode()   {}
+func (*EnumeratedType) typeNode()  {}
+func (*SequenceType) typeNode()    {}
+func (*ChoiceType) typeNode()      {}
+func (*SequenceOfType) typeNode()  {}
+func (*TaggedType) typeNode()      {}
+func (*ReferencedType) typeNode()  {}
+func (*AnyType) typeNode()         {}
+func (*ConstrainedType) typeNode() {}
+
+/*
+ * Constraints
+ */
+
+// Constraint is a subtype constraint. The values it accepts are the union of
+// its Elements. Extensible is set if it has an extension marker, which may be
+// followed by additional elements.
+type Constraint struct {
+	Pos
+	Elements   []ConstraintElement
+	Extensible bool
+	Additions  []ConstraintElement
+}
+
+// ConstraintElement is any of the constraint elements below.
+type ConstraintElement interface {
+	Node
+	constraintNode()
+}
+
+// SingleValue accepts a single value, like in "INTEGER (5)".
+type SingleValue struct {
+	Pos
+	Value Value
+}
+
+// ValueRange accepts a range of values, like "0..<10". Lower and Upper are
+// nil for MIN and MAX.
+type ValueRange struct {
+	Pos
+	Lower          Value
+	LowerExclusive bool
+	Upper          Value
+	UpperExclusive bool
+}
+
+// SizeConstraint constrains the size of strings and lists, like "SIZE (1..64)".
+type SizeConstraint struct {
+	Pos
+	Constraint *Constraint
+}
+
+// RawConstraint is a constraint element that is not interpreted, like
+// permitted alphabets, inner subtyping or intersections. Text has the tokens
+// of the element separated by spaces.
+type RawConstraint struct {
+	Pos
+	Text string
+}
+
+func (*SingleValue) constraintNode()    {}
+func (*ValueRange) constraintNode()     {}
+func (*SizeConstraint) constraintNode() {}
+func (*RawConstraint) constraintNode()  {}
+
+/*
+ * Values
+ */
+
+// Value is any of the values below.
+type Value interface {
+	Node
+	valueNode()
+}
+
+// NumberValue is an integer number.
+type NumberValue struct {
+	Pos
+	Value *big.Int
+}
+
+// BooleanValue is TRUE or FALSE.
+type BooleanValue struct {
+	Pos
+	Value bool
+}
+
+// NullValue is NULL.
+type NullValue struct {
+	Pos
+}
+
+// StringValue is a character string ("text"), a binary string ('0101'B) or a
+// hexadecimal string ('0AFF'H). Kind is 'C', 'B' or 'H'. The quotes and the
+// suffix are not included in Text.
+type StringValue struct {
+	Pos
+	Kind byte
+	Text string
+}
+
+// ReferenceValue is a reference to a value defined by an assignment, or an
+// identifier of a named number or an ENUMERATED item.
+type ReferenceValue struct {
+	Pos
+	// Module, like in "PKIX1Explicit88.id-pkix", or empty
+	Module string
+	Name   string
+}
+
+// ObjectIdentifierValue is an OBJECT IDENTIFIER value, like
+// "{ iso(1) member-body(2) 840 }" or "{ id-pkix 1 }".
+type ObjectIdentifierValue struct {
+	Pos
+	Components []*OidComponent
+}
+
+// OidComponent is a component of an OBJECT IDENTIFIER value. It has a name, a
+// number or both. A component with a name only is a reference to a value.
+type OidComponent struct {
+	Pos
+	Name   string
+	Number *big.Int
+}
+
+// SequenceValue is a value of a SEQUENCE or SET, like "{ a 1, b TRUE }".
+type SequenceValue struct {
+	Pos
+	Components []*NamedValue
+}
+
+// NamedValue is a component of a SequenceValue.
+type NamedValue struct {
+	Pos
+	Name  string
+	Value Value
+}
+
+// ListValue is a value of a SEQUENCE OF or SET OF, like "{ 1, 2, 3 }". The
+// empty value "{ }" is also represented by a ListValue.
+type ListValue struct {
+	Pos
+	Values []Value
+}
+
+// ChoiceValue is a value of a CHOICE, like "text : "abc"".
+type ChoiceValue struct {
+	Pos
+	Alternative string
+	Value       Value
+}
+
+func (*NumberValue) valueNode()           {}
+func (*BooleanValue) valueNode()          {}
+func (*NullValue) valueNode()             {}
+func (*StringValue) valueNode()           {}
+func (*ReferenceValue) valueNode()        {}
+func (*ObjectIdentifierValue) valueNode() {}
+func (*SequenceValue) valueNode()         {}
+func (*ListValue) valueNode()             {}
+func (*ChoiceValue) valueNode()           {}
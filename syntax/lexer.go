@@ -0,0 +1,264 @@
+package syntax
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// Error is a syntax error found in a module.
+type Error struct {
+	Filename string
+	Pos      Pos
+	Msg      string
+}
+
+func (e *Error) Error() string {
+	if e.Filename == "" {
+		return fmt.Sprintf("%s: %s", e.Pos, e.Msg)
+	}
+	return fmt.Sprintf("%s:%s: %s", e.Filename, e.Pos, e.Msg)
+}
+
+// tokenKind is the kind of a token.
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	// References, identifiers and keywords
+	tokenWord
+	tokenNumber
+	// Character, binary and hexadecimal strings
+	tokenCString
+	tokenBString
+	tokenHString
+	tokenSymbol
+)
+
+// token is a lexical item. The text of strings has no quotes and no suffix.
+type token struct {
+	kind tokenKind
+	text string
+	pos  Pos
+}
+
+// String returns a description of the token used in error messages.
+func (t token) String() string {
+	switch t.kind {
+	case tokenEOF:
+		return "end of file"
+	case tokenCString:
+		return fmt.Sprintf("string \"%s\"", t.text)
+	case tokenBString, tokenHString:
+		return fmt.Sprintf("string '%s'", t.text)
+	}
+	return fmt.Sprintf("'%s'", t.text)
+}
+
+// symbols are the lexical items that are not words, numbers or strings. Longer
+// symbols come first.
+var symbols = []string{
+	"::=", "...", "..", "[[", "]]",
+	"{", "}", "(", ")", "[", "]", ",", ".", ";", ":", "|", "^", "<", ">",
+	"@", "!", "-", "&",
+}
+
+// lexer splits the source in tokens.
+type lexer struct {
+	filename string
+	src      []byte
+	offset   int
+	pos      Pos
+}
+
+// tokenize returns all the tokens in the source, followed by a tokenEOF.
+func tokenize(filename string, src []byte) ([]token, error) {
+	l := lexer{filename: filename, src: src, pos: Pos{1, 1}}
+	tokens := []token{}
+	for {
+		t, err := l.next()
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, t)
+		if t.kind == tokenEOF {
+			return tokens, nil
+		}
+	}
+}
+
+// errorf returns an Error at the given position.
+func (l *lexer) errorf(pos Pos, format string, args ...interface{}) error {
+	return &Error{l.filename, pos, fmt.Sprintf(format, args...)}
+}
+
+// peek returns the byte at an offset from the current one, or 0 after the end.
+func (l *lexer) peek(i int) byte {
+	if l.offset+i >= len(l.src) {
+		return 0
+	}
+	return l.src[l.offset+i]
+}
+
+// advance moves n bytes forward, updating the position.
+func (l *lexer) advance(n int) {
+	for ; n > 0 && l.offset < len(l.src); n-- {
+		if l.src[l.offset] == '\n' {
+			l.pos.Line++
+			l.pos.Column = 1
+		} else {
+			l.pos.Column++
+		}
+		l.offset++
+	}
+}
+
+// skipSpaces skips white space and comments. A "--" comment ends at the end
+// of the line or at the next "--" and a "/*" comment may be nested.
+func (l *lexer) skipSpaces() error {
+	for l.offset < len(l.src) {
+		switch c := l.peek(0); {
+		case isSpace(c):
+			l.advance(1)
+		case c == '-' && l.peek(1) == '-':
+			l.advance(2)
+			for l.offset < len(l.src) && l.peek(0) != '\n' && l.peek(0) != '\r' {
+				if l.peek(0) == '-' && l.peek(1) == '-' {
+					l.advance(2)
+					break
+				}
+				l.advance(1)
+			}
+		case c == '/' && l.peek(1) == '*':
+			pos := l.pos
+			depth := 0
+			for {
+				if l.offset >= len(l.src) {
+					return l.errorf(pos, "unterminated comment")
+				}
+				if l.peek(0) == '/' && l.peek(1) == '*' {
+					depth++
+					l.advance(2)
+				} else if l.peek(0) == '*' && l.peek(1) == '/' {
+					depth--
+					l.advance(2)
+					if depth == 0 {
+						break
+					}
+				} else {
+					l.advance(1)
+				}
+			}
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+// next returns the next token.
+func (l *lexer) next() (token, error) {
+	if err := l.skipSpaces(); err != nil {
+		return token{}, err
+	}
+	pos := l.pos
+	start := l.offset
+	if l.offset >= len(l.src) {
+		return token{tokenEOF, "", pos}, nil
+	}
+
+	c := l.peek(0)
+	switch {
+	case isLetter(c):
+		// Hyphens are allowed, but not at the end nor followed by another one
+		n := 1
+		for isLetter(l.peek(n)) || isDigit(l.peek(n)) ||
+			(l.peek(n) == '-' && l.peek(n+1) != '-' &&
+				(isLetter(l.peek(n+1)) || isDigit(l.peek(n+1)))) {
+			n++
+		}
+		l.advance(n)
+		return token{tokenWord, string(l.src[start:l.offset]), pos}, nil
+
+	case isDigit(c):
+		n := 1
+		for isDigit(l.peek(n)) {
+			n++
+		}
+		l.advance(n)
+		return token{tokenNumber, string(l.src[start:l.offset]), pos}, nil
+
+	case c == '"':
+		// Quotes are escaped by doubling them
+		text := []byte{}
+		l.advance(1)
+		for {
+			if l.offset >= len(l.src) {
+				return token{}, l.errorf(pos, "unterminated string")
+			}
+			if l.peek(0) == '"' {
+				if l.peek(1) != '"' {
+					l.advance(1)
+					break
+				}
+				l.advance(1)
+			}
+			text = append(text, l.peek(0))
+			l.advance(1)
+		}
+		return token{tokenCString, string(text), pos}, nil
+
+	case c == '\'':
+		// White space is not significant in binary and hexadecimal strings
+		text := []byte{}
+		l.advance(1)
+		for l.peek(0) != '\'' {
+			if l.offset >= len(l.src) {
+				return token{}, l.errorf(pos, "unterminated string")
+			}
+			if !isSpace(l.peek(0)) {
+				text = append(text, l.peek(0))
+			}
+			l.advance(1)
+		}
+		l.advance(1)
+		switch l.peek(0) {
+		case 'B':
+			for _, b := range text {
+				if b != '0' && b != '1' {
+					return token{}, l.errorf(pos, "invalid binary string '%s'", text)
+				}
+			}
+			l.advance(1)
+			return token{tokenBString, string(text), pos}, nil
+		case 'H':
+			for _, b := range text {
+				if !isDigit(b) && (b < 'A' || b > 'F') {
+					return token{}, l.errorf(pos, "invalid hexadecimal string '%s'", text)
+				}
+			}
+			l.advance(1)
+			return token{tokenHString, string(text), pos}, nil
+		}
+		return token{}, l.errorf(pos, "string '%s' must be followed by B or H", text)
+	}
+
+	for _, symbol := range symbols {
+		if bytes.HasPrefix(l.src[l.offset:], []byte(symbol)) {
+			l.advance(len(symbol))
+			return token{tokenSymbol, symbol, pos}, nil
+		}
+	}
+	return token{}, l.errorf(pos, "unexpected character '%c'", c)
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\v' || c == '\f'
+}
+
+func isLetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
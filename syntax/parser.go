@@ -0,0 +1,1111 @@
+package syntax
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// reservedWords are the reserved words of X.680, plus the ones of the old
+// X.208 still found in many modules.
+var reservedWords = map[string]bool{}
+
+func init() {
+	words := `ABSENT ABSTRACT-SYNTAX ALL ANY APPLICATION AUTOMATIC BEGIN BIT
+		BMPString BOOLEAN BY CHARACTER CHOICE CLASS COMPONENT COMPONENTS
+		CONSTRAINED CONTAINING DATE DATE-TIME DEFAULT DEFINED DEFINITIONS
+		DURATION EMBEDDED ENCODED ENCODING-CONTROL END ENUMERATED EXCEPT
+		EXPLICIT EXPORTS EXTENSIBILITY EXTERNAL FALSE FROM GeneralizedTime
+		GeneralString GraphicString IA5String IDENTIFIER IMPLICIT IMPLIED
+		IMPORTS INCLUDES INSTANCE INSTRUCTIONS INTEGER INTERSECTION
+		ISO646String MAX MIN MINUS-INFINITY NOT-A-NUMBER NULL NumericString
+		OBJECT ObjectDescriptor OCTET OF OID-IRI OPTIONAL PATTERN PDV
+		PLUS-INFINITY PRESENT PrintableString PRIVATE REAL RELATIVE-OID
+		RELATIVE-OID-IRI SEQUENCE SET SETTINGS SIZE STRING SYNTAX T61String
+		TAGS TeletexString TIME TIME-OF-DAY TRUE TYPE-IDENTIFIER UNION UNIQUE
+		UNIVERSAL UniversalString UTCTime UTF8String VideotexString
+		VisibleString WITH`
+	for _, word := range strings.Fields(words) {
+		reservedWords[word] = true
+	}
+}
+
+// builtinTypes are the types represented by a BuiltinType, indexed by their
+// first word. The value is the second word, if any.
+var builtinTypes = map[string]string{
+	"BOOLEAN": "", "NULL": "", "REAL": "", "EXTERNAL": "",
+	"RELATIVE-OID": "", "OID-IRI": "", "RELATIVE-OID-IRI": "",
+	"UTCTime": "", "GeneralizedTime": "", "ObjectDescriptor": "",
+	"TIME": "", "DATE": "", "TIME-OF-DAY": "", "DATE-TIME": "", "DURATION": "",
+	"UTF8String": "", "NumericString": "", "PrintableString": "",
+	"TeletexString": "", "T61String": "", "VideotexString": "",
+	"IA5String": "", "GraphicString": "", "VisibleString": "",
+	"ISO646String": "", "GeneralString": "", "UniversalString": "",
+	"BMPString": "",
+	"OCTET":     "STRING",
+	"OBJECT":    "IDENTIFIER",
+	"EMBEDDED":  "PDV",
+	"CHARACTER": "STRING",
+}
+
+// Parse parses the modules in src. The file name is only used in error
+// messages and it may be empty. The first error found is returned as an
+// *Error.
+func Parse(filename string, src []byte) ([]*Module, error) {
+	tokens, err := tokenize(filename, src)
+	if err != nil {
+		return nil, err
+	}
+	p := parser{filename: filename, tokens: tokens}
+	p.tok = tokens[0]
+	modules := []*Module{}
+	for p.tok.kind != tokenEOF {
+		m, err := p.parseModule()
+		if err != nil {
+			return nil, err
+		}
+		modules = append(modules, m)
+	}
+	if len(modules) == 0 {
+		return nil, p.errorf(p.tok.pos, "no module found")
+	}
+	return modules, nil
+}
+
+// parser is a recursive descent parser. Each parse method starts at the
+// current token and stops at the first token after the construction.
+type parser struct {
+	filename string
+	tokens   []token
+	index    int
+	tok      token
+	// Module being parsed
+	module *Module
+}
+
+// errorf returns an Error at the given position.
+func (p *parser) errorf(pos Pos, format string, args ...interface{}) error {
+	return &Error{p.filename, pos, fmt.Sprintf(format, args...)}
+}
+
+// unexpected returns an error for the current token.
+func (p *parser) unexpected(expected string) error {
+	return p.errorf(p.tok.pos, "expected %s, found %s", expected, p.tok)
+}
+
+// next moves to the next token.
+func (p *parser) next() {
+	if p.index < len(p.tokens)-1 {
+		p.index++
+		p.tok = p.tokens[p.index]
+	}
+}
+
+// peek returns the token at an offset from the current one.
+func (p *parser) peek(i int) token {
+	if p.index+i >= len(p.tokens) {
+		return p.tokens[len(p.tokens)-1]
+	}
+	return p.tokens[p.index+i]
+}
+
+// is checks if the current token is the given word or symbol.
+func (p *parser) is(text string) bool {
+	return isToken(p.tok, text)
+}
+
+// accept moves to the next token if the current one is the given word or
+// symbol.
+func (p *parser) accept(text string) bool {
+	if p.is(text) {
+		p.next()
+		return true
+	}
+	return false
+}
+
+// expect moves to the next token if the current one is the given word or
+// symbol, otherwise it returns an error.
+func (p *parser) expect(text string) error {
+	if !p.accept(text) {
+		return p.unexpected("'" + text + "'")
+	}
+	return nil
+}
+
+// expectTypeReference returns the current token if it's a type reference.
+func (p *parser) expectTypeReference() (token, error) {
+	t := p.tok
+	if !isTypeReference(t) {
+		return t, p.unexpected("type reference")
+	}
+	p.next()
+	return t, nil
+}
+
+// expectIdentifier returns the current token if it's an identifier or a value
+// reference.
+func (p *parser) expectIdentifier() (token, error) {
+	t := p.tok
+	if !isIdentifier(t) {
+		return t, p.unexpected("identifier")
+	}
+	p.next()
+	return t, nil
+}
+
+func isToken(t token, text string) bool {
+	return (t.kind == tokenWord || t.kind == tokenSymbol) && t.text == text
+}
+
+// isTypeReference checks if a token is a reference to a type or a module,
+// which starts with an upper case letter.
+func isTypeReference(t token) bool {
+	return t.kind == tokenWord && t.text[0] >= 'A' && t.text[0] <= 'Z' && !reservedWords[t.text]
+}
+
+// isIdentifier checks if a token is an identifier or a reference to a value,
+// which start with a lower case letter.
+func isIdentifier(t token) bool {
+	return t.kind == tokenWord && t.text[0] >= 'a' && t.text[0] <= 'z'
+}
+
+// tokenText returns a token as written in the source.
+func tokenText(t token) string {
+	switch t.kind {
+	case tokenCString:
+		return `"` + strings.Replace(t.text, `"`, `""`, -1) + `"`
+	case tokenBString:
+		return "'" + t.text + "'B"
+	case tokenHString:
+		return "'" + t.text + "'H"
+	}
+	return t.text
+}
+
+// skipUntil skips tokens until one of the given words or symbols is found
+// outside of brackets.
+func (p *parser) skipUntil(stops ...string) error {
+	depth := 0
+	for {
+		if p.tok.kind == tokenEOF {
+			return p.unexpected("'" + strings.Join(stops, "' or '") + "'")
+		}
+		if depth == 0 {
+			for _, stop := range stops {
+				if p.is(stop) {
+					return nil
+				}
+			}
+		}
+		switch {
+		case p.is("{"), p.is("("), p.is("["), p.is("[["):
+			depth++
+		case p.is("}"), p.is(")"), p.is("]"), p.is("]]"):
+			depth--
+		}
+		p.next()
+	}
+}
+
+// skipException skips an exception specification, like "! 1".
+func (p *parser) skipException() error {
+	if p.accept("!") {
+		return p.skipUntil(",", "}", ")", "]]")
+	}
+	return nil
+}
+
+/*
+ * Modules
+ */
+
+// parseModule parses a module definition.
+func (p *parser) parseModule() (*Module, error) {
+	name, err := p.expectTypeReference()
+	if err != nil {
+		return nil, err
+	}
+	m := &Module{Pos: name.pos, Name: name.text, ExportAll: true,
+		Exports: []string{}, Imports: []*Import{}, Assignments: []Assignment{}}
+	p.module = m
+	if p.is("{") {
+		if m.Oid, err = p.parseOidValue(); err != nil {
+			return nil, err
+		}
+	}
+	// IRI value
+	if p.tok.kind == tokenCString {
+		p.next()
+	}
+	if err = p.expect("DEFINITIONS"); err != nil {
+		return nil, err
+	}
+	if p.is("EXPLICIT") || p.is("IMPLICIT") || p.is("AUTOMATIC") {
+		m.TagDefault = map[string]TagDefault{"EXPLICIT": ExplicitTags,
+			"IMPLICIT": ImplicitTags, "AUTOMATIC": AutomaticTags}[p.tok.text]
+		p.next()
+		if err = p.expect("TAGS"); err != nil {
+			return nil, err
+		}
+	}
+	if p.accept("EXTENSIBILITY") {
+		if err = p.expect("IMPLIED"); err != nil {
+			return nil, err
+		}
+		m.ExtensibilityImplied = true
+	}
+	if err = p.expect("::="); err != nil {
+		return nil, err
+	}
+	if err = p.expect("BEGIN"); err != nil {
+		return nil, err
+	}
+
+	if p.accept("EXPORTS") {
+		if !p.accept("ALL") {
+			m.ExportAll = false
+			if !p.is(";") {
+				if m.Exports, err = p.parseSymbols(); err != nil {
+					return nil, err
+				}
+			}
+		}
+		if err = p.expect(";"); err != nil {
+			return nil, err
+		}
+	}
+	if p.accept("IMPORTS") {
+		if m.Imports, err = p.parseImports(); err != nil {
+			return nil, err
+		}
+	}
+
+	for !p.is("END") {
+		a, err := p.parseAssignment()
+		if err != nil {
+			return nil, err
+		}
+		m.Assignments = append(m.Assignments, a)
+	}
+	p.next()
+	return m, nil
+}
+
+// parseSymbols parses a list of exported or imported symbols. The braces of
+// parameterized references, like "Name{}", are ignored.
+func (p *parser) parseSymbols() ([]string, error) {
+	symbols := []string{}
+	for {
+		if p.tok.kind != tokenWord || reservedWords[p.tok.text] {
+			return nil, p.unexpected("symbol")
+		}
+		symbols = append(symbols, p.tok.text)
+		p.next()
+		if p.accept("{") {
+			if err := p.expect("}"); err != nil {
+				return nil, err
+			}
+		}
+		if !p.accept(",") {
+			return symbols, nil
+		}
+	}
+}
+
+// parseImports parses the symbols imported from each module, until the
+// semicolon.
+func (p *parser) parseImports() ([]*Import, error) {
+	imports := []*Import{}
+	for !p.accept(";") {
+		imp := &Import{Pos: p.tok.pos}
+		var err error
+		if imp.Symbols, err = p.parseSymbols(); err != nil {
+			return nil, err
+		}
+		if err = p.expect("FROM"); err != nil {
+			return nil, err
+		}
+		name, err := p.expectTypeReference()
+		if err != nil {
+			return nil, err
+		}
+		imp.Module = name.text
+		if p.is("{") {
+			if imp.Oid, err = p.parseOidValue(); err != nil {
+				return nil, err
+			}
+		} else if isIdentifier(p.tok) && !isToken(p.peek(1), ",") && !isToken(p.peek(1), "FROM") {
+			// The module is identified by a value reference, which is not
+			// followed by a comma or FROM like the symbols of the next module
+			p.next()
+		}
+		if p.accept("WITH") {
+			if !p.accept("SUCCESSORS") && !p.accept("DESCENDANTS") {
+				return nil, p.unexpected("'SUCCESSORS' or 'DESCENDANTS'")
+			}
+		}
+		imports = append(imports, imp)
+	}
+	return imports, nil
+}
+
+// parseAssignment parses a type or value assignment.
+func (p *parser) parseAssignment() (Assignment, error) {
+	name := p.tok
+	if isToken(p.peek(1), "{") {
+		return nil, p.errorf(name.pos, "parameterized assignments are not supported")
+	}
+	switch {
+	case isTypeReference(name):
+		p.next()
+		if err := p.expect("::="); err != nil {
+			return nil, err
+		}
+		if p.is("CLASS") {
+			return nil, p.errorf(p.tok.pos, "information object classes are not supported")
+		}
+		typ, err := p.parseType()
+		if err != nil {
+			return nil, err
+		}
+		return &TypeAssignment{name.pos, name.text, typ}, nil
+
+	case isIdentifier(name):
+		p.next()
+		typ, err := p.parseType()
+		if err != nil {
+			return nil, err
+		}
+		if err = p.expect("::="); err != nil {
+			return nil, err
+		}
+		value, err := p.parseValue(typ)
+		if err != nil {
+			return nil, err
+		}
+		return &ValueAssignment{name.pos, name.text, typ, value}, nil
+	}
+	return nil, p.unexpected("assignment or 'END'")
+}
+
+/*
+ * Types
+ */
+
+// parseType parses a type followed by any number of constraints.
+func (p *parser) parseType() (Type, error) {
+	pos := p.tok.pos
+	typ, err := p.parseUnconstrainedType()
+	if err != nil {
+		return nil, err
+	}
+	for p.is("(") {
+		constraint, err := p.parseConstraint()
+		if err != nil {
+			return nil, err
+		}
+		typ = &ConstrainedType{pos, typ, constraint}
+	}
+	return typ, nil
+}
+
+// parseUnconstrainedType parses a type without its constraints.
+func (p *parser) parseUnconstrainedType() (Type, error) {
+	t := p.tok
+	if second, ok := builtinTypes[t.text]; ok && t.kind == tokenWord {
+		p.next()
+		if second == "" {
+			return &BuiltinType{t.pos, t.text}, nil
+		}
+		if err := p.expect(second); err != nil {
+			return nil, err
+		}
+		return &BuiltinType{t.pos, t.text + " " + second}, nil
+	}
+
+	switch {
+	case p.is("["):
+		return p.parseTaggedType()
+
+	case p.is("INTEGER"):
+		p.next()
+		typ := &IntegerType{Pos: t.pos, NamedNumbers: []*NamedNumber{}}
+		if p.is("{") {
+			var err error
+			if typ.NamedNumbers, err = p.parseNamedNumbers(); err != nil {
+				return nil, err
+			}
+		}
+		return typ, nil
+
+	case p.is("BIT"):
+		p.next()
+		if err := p.expect("STRING"); err != nil {
+			return nil, err
+		}
+		typ := &BitStringType{Pos: t.pos, NamedBits: []*NamedNumber{}}
+		if p.is("{") {
+			var err error
+			if typ.NamedBits, err = p.parseNamedNumbers(); err != nil {
+				return nil, err
+			}
+		}
+		return typ, nil
+
+	case p.is("ENUMERATED"):
+		p.next()
+		return p.parseEnumerated(t.pos)
+
+	case p.is("SEQUENCE"), p.is("SET"):
+		p.next()
+		if !p.is("{") {
+			return p.parseSequenceOf(t.pos, t.text == "SET")
+		}
+		components, additions, extensible, err := p.parseComponents(false)
+		if err != nil {
+			return nil, err
+		}
+		return &SequenceType{t.pos, t.text == "SET", components,
+			extensible || p.module.ExtensibilityImplied, additions}, nil
+
+	case p.is("CHOICE"):
+		p.next()
+		if !p.is("{") {
+			return nil, p.unexpected("'{'")
+		}
+		alternatives, additions, extensible, err := p.parseComponents(true)
+		if err != nil {
+			return nil, err
+		}
+		return &ChoiceType{t.pos, alternatives,
+			extensible || p.module.ExtensibilityImplied, additions}, nil
+
+	case p.is("ANY"):
+		p.next()
+		typ := &AnyType{Pos: t.pos}
+		if p.accept("DEFINED") {
+			if err := p.expect("BY"); err != nil {
+				return nil, err
+			}
+			field, err := p.expectIdentifier()
+			if err != nil {
+				return nil, err
+			}
+			typ.DefinedBy = field.text
+		}
+		return typ, nil
+
+	case isTypeReference(t):
+		p.next()
+		typ := &ReferencedType{Pos: t.pos, Name: t.text}
+		if p.is(".") && isTypeReference(p.peek(1)) {
+			p.next()
+			typ.Module, typ.Name = t.text, p.tok.text
+			p.next()
+		}
+		if p.is("{") {
+			return nil, p.errorf(p.tok.pos, "parameterized types are not supported")
+		}
+		if p.is(".") {
+			return nil, p.errorf(p.tok.pos, "information object classes are not supported")
+		}
+		return typ, nil
+	}
+	return nil, p.unexpected("type")
+}
+
+// parseTaggedType parses a tag followed by a type.
+func (p *parser) parseTaggedType() (Type, error) {
+	typ := &TaggedType{Pos: p.tok.pos, Mode: p.module.TagDefault}
+	p.next()
+	switch {
+	case p.accept("UNIVERSAL"):
+		typ.Class = Universal
+	case p.accept("APPLICATION"):
+		typ.Class = Application
+	case p.accept("PRIVATE"):
+		typ.Class = Private
+	}
+	switch {
+	case p.tok.kind == tokenNumber:
+		typ.Number = p.parseNumber(p.tok.pos, false)
+	case isIdentifier(p.tok):
+		typ.Number = &ReferenceValue{Pos: p.tok.pos, Name: p.tok.text}
+		p.next()
+	default:
+		return nil, p.unexpected("tag number")
+	}
+	if err := p.expect("]"); err != nil {
+		return nil, err
+	}
+	switch {
+	case p.accept("IMPLICIT"):
+		typ.Mode = ImplicitTags
+	case p.accept("EXPLICIT"):
+		typ.Mode = ExplicitTags
+	}
+	var err error
+	typ.Type, err = p.parseType()
+	return typ, err
+}
+
+// parseSequenceOf parses a SEQUENCE OF or SET OF after the first word.
+func (p *parser) parseSequenceOf(pos Pos, set bool) (Type, error) {
+	typ := &SequenceOfType{Pos: pos, Set: set}
+	switch {
+	case p.is("SIZE"):
+		sizePos := p.tok.pos
+		p.next()
+		inner, err := p.parseConstraint()
+		if err != nil {
+			return nil, err
+		}
+		typ.Constraint = &Constraint{Pos: sizePos,
+			Elements: []ConstraintElement{&SizeConstraint{sizePos, inner}}}
+	case p.is("("):
+		var err error
+		if typ.Constraint, err = p.parseConstraint(); err != nil {
+			return nil, err
+		}
+	}
+	if err := p.expect("OF"); err != nil {
+		return nil, err
+	}
+	if isIdentifier(p.tok) {
+		typ.ElementName = p.tok.text
+		p.next()
+	}
+	var err error
+	typ.Element, err = p.parseType()
+	return typ, err
+}
+
+// parseComponents parses the components of a SEQUENCE or SET, or the
+// alternatives of a CHOICE, including the braces.
+func (p *parser) parseComponents(choice bool) (components, additions []*Component, extensible bool, err error) {
+	components = []*Component{}
+	additions = []*Component{}
+	if err = p.expect("{"); err != nil {
+		return
+	}
+	markers := 0
+	group := 0
+	for !p.is("}") {
+		switch {
+		case p.is("..."):
+			if markers == 2 {
+				return nil, nil, false, p.errorf(p.tok.pos, "too many extension markers")
+			}
+			p.next()
+			if err = p.skipException(); err != nil {
+				return
+			}
+			markers++
+			extensible = true
+
+		case p.is("[["):
+			if markers != 1 {
+				return nil, nil, false, p.errorf(p.tok.pos, "version brackets outside of the extension additions")
+			}
+			p.next()
+			group++
+			if p.tok.kind == tokenNumber && isToken(p.peek(1), ":") {
+				p.next()
+				p.next()
+			}
+			for {
+				var c *Component
+				if c, err = p.parseComponent(choice); err != nil {
+					return
+				}
+				c.Group = group
+				additions = append(additions, c)
+				if !p.accept(",") {
+					break
+				}
+			}
+			if err = p.expect("]]"); err != nil {
+				return
+			}
+
+		default:
+			var c *Component
+			if c, err = p.parseComponent(choice); err != nil {
+				return
+			}
+			if markers == 1 {
+				additions = append(additions, c)
+			} else {
+				components = append(components, c)
+			}
+		}
+		if !p.accept(",") {
+			break
+		}
+	}
+	err = p.expect("}")
+	return
+}
+
+// parseComponent parses a component of a SEQUENCE or SET, or an alternative
+// of a CHOICE.
+func (p *parser) parseComponent(choice bool) (*Component, error) {
+	c := &Component{Pos: p.tok.pos}
+	var err error
+	if !choice && p.accept("COMPONENTS") {
+		if err = p.expect("OF"); err != nil {
+			return nil, err
+		}
+		c.ComponentsOf = true
+		c.Type, err = p.parseType()
+		return c, err
+	}
+	name, err := p.expectIdentifier()
+	if err != nil {
+		return nil, err
+	}
+	c.Name = name.text
+	if c.Type, err = p.parseType(); err != nil {
+		return nil, err
+	}
+	if !choice {
+		switch {
+		case p.accept("OPTIONAL"):
+			c.Optional = true
+		case p.accept("DEFAULT"):
+			c.Default, err = p.parseValue(c.Type)
+		}
+	}
+	return c, err
+}
+
+// parseEnumerated parses the items of an ENUMERATED type.
+func (p *parser) parseEnumerated(pos Pos) (Type, error) {
+	typ := &EnumeratedType{Pos: pos, Items: []*NamedNumber{}, Additions: []*NamedNumber{},
+		Extensible: p.module.ExtensibilityImplied}
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	markers := 0
+	for {
+		if p.is("...") {
+			if markers == 1 {
+				return nil, p.errorf(p.tok.pos, "too many extension markers")
+			}
+			p.next()
+			if err := p.skipException(); err != nil {
+				return nil, err
+			}
+			markers++
+			typ.Extensible = true
+		} else {
+			item, err := p.parseNamedNumber(false)
+			if err != nil {
+				return nil, err
+			}
+			if markers == 0 {
+				typ.Items = append(typ.Items, item)
+			} else {
+				typ.Additions = append(typ.Additions, item)
+			}
+		}
+		if !p.accept(",") {
+			break
+		}
+	}
+	if err := p.expect("}"); err != nil {
+		return nil, err
+	}
+	return typ, nil
+}
+
+// parseNamedNumbers parses the named numbers of an INTEGER or the named bits of
+// a BIT STRING, including the braces.
+func (p *parser) parseNamedNumbers() ([]*NamedNumber, error) {
+	numbers := []*NamedNumber{}
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	for {
+		number, err := p.parseNamedNumber(true)
+		if err != nil {
+			return nil, err
+		}
+		numbers = append(numbers, number)
+		if !p.accept(",") {
+			break
+		}
+	}
+	if err := p.expect("}"); err != nil {
+		return nil, err
+	}
+	return numbers, nil
+}
+
+// parseNamedNumber parses a name followed by a number or a value reference in
+// parentheses, which may be optional.
+func (p *parser) parseNamedNumber(required bool) (*NamedNumber, error) {
+	name, err := p.expectIdentifier()
+	if err != nil {
+		return nil, err
+	}
+	number := &NamedNumber{Pos: name.pos, Name: name.text}
+	if !p.accept("(") {
+		if required {
+			return nil, p.unexpected("'('")
+		}
+		return number, nil
+	}
+	switch {
+	case p.tok.kind == tokenNumber || p.is("-"):
+		pos := p.tok.pos
+		negative := p.accept("-")
+		if p.tok.kind != tokenNumber {
+			return nil, p.unexpected("number")
+		}
+		number.Value = p.parseNumber(pos, negative)
+	case isIdentifier(p.tok):
+		number.Value = &ReferenceValue{Pos: p.tok.pos, Name: p.tok.text}
+		p.next()
+	default:
+		return nil, p.unexpected("number")
+	}
+	if err = p.expect(")"); err != nil {
+		return nil, err
+	}
+	return number, nil
+}
+
+/*
+ * Constraints
+ */
+
+// parseConstraint parses a constraint, including the parentheses.
+func (p *parser) parseConstraint() (*Constraint, error) {
+	c := &Constraint{Pos: p.tok.pos, Elements: []ConstraintElement{},
+		Additions: []ConstraintElement{}}
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	var err error
+	root := !p.is("...")
+	if root {
+		if c.Elements, err = p.parseElementSet(); err != nil {
+			return nil, err
+		}
+	}
+	if !root || p.accept(",") {
+		if err = p.expect("..."); err != nil {
+			return nil, err
+		}
+		c.Extensible = true
+		if p.accept(",") {
+			if c.Additions, err = p.parseElementSet(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err = p.skipException(); err != nil {
+		return nil, err
+	}
+	if err = p.expect(")"); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// parseElementSet parses the union of constraint elements.
+func (p *parser) parseElementSet() ([]ConstraintElement, error) {
+	elements := []ConstraintElement{}
+	for {
+		start := p.index
+		element, err := p.parseElement()
+		if err != nil {
+			return nil, err
+		}
+		if p.is("^") || p.is("INTERSECTION") || p.is("EXCEPT") {
+			if err = p.skipElement(); err != nil {
+				return nil, err
+			}
+			element = p.getRawConstraint(start)
+		}
+		elements = append(elements, element)
+		if !p.accept("|") && !p.accept("UNION") {
+			return elements, nil
+		}
+	}
+}
+
+// parseElement parses a constraint element. Sizes, single values and ranges
+// are interpreted and the other elements are returned as RawConstraint.
+func (p *parser) parseElement() (ConstraintElement, error) {
+	pos := p.tok.pos
+	start := p.index
+	switch {
+	case p.accept("SIZE"):
+		inner, err := p.parseConstraint()
+		if err != nil {
+			return nil, err
+		}
+		return &SizeConstraint{pos, inner}, nil
+
+	case p.tok.kind == tokenNumber, p.tok.kind == tokenCString, p.is("-"),
+		p.is("MIN"), p.is("MAX"), p.is("TRUE"), p.is("FALSE"),
+		isIdentifier(p.tok) && !isToken(p.peek(1), ":"):
+		var lower Value
+		if !p.accept("MIN") {
+			var err error
+			if lower, err = p.parseValue(nil); err != nil {
+				return nil, err
+			}
+		}
+		lowerExclusive := p.accept("<")
+		if !p.accept("..") {
+			if lower == nil || lowerExclusive {
+				return nil, p.unexpected("'..'")
+			}
+			return &SingleValue{pos, lower}, nil
+		}
+		r := &ValueRange{Pos: pos, Lower: lower, LowerExclusive: lowerExclusive}
+		r.UpperExclusive = p.accept("<")
+		if !p.accept("MAX") {
+			var err error
+			if r.Upper, err = p.parseValue(nil); err != nil {
+				return nil, err
+			}
+		}
+		return r, nil
+	}
+
+	if err := p.skipElement(); err != nil {
+		return nil, err
+	}
+	if p.index == start {
+		return nil, p.unexpected("constraint")
+	}
+	return p.getRawConstraint(start), nil
+}
+
+// skipElement skips tokens until the end of a constraint element.
+func (p *parser) skipElement() error {
+	return p.skipUntil("|", "UNION", ",", ")", "!")
+}
+
+// getRawConstraint returns the tokens from start to the current one as a
+// RawConstraint.
+func (p *parser) getRawConstraint(start int) *RawConstraint {
+	texts := []string{}
+	for _, t := range p.tokens[start:p.index] {
+		texts = append(texts, tokenText(t))
+	}
+	return &RawConstraint{p.tokens[start].pos, strings.Join(texts, " ")}
+}
+
+/*
+ * Values
+ */
+
+// getUnderlyingType returns a type without its tags and constraints.
+func getUnderlyingType(typ Type) Type {
+	for {
+		switch t := typ.(type) {
+		case *TaggedType:
+			typ = t.Type
+		case *ConstrainedType:
+			typ = t.Type
+		default:
+			return typ
+		}
+	}
+}
+
+// getComponentType returns the type of the component of a SEQUENCE, SET or
+// CHOICE with the given name, or nil if it's not found.
+func getComponentType(typ Type, name string) Type {
+	var components []*Component
+	switch t := getUnderlyingType(typ).(type) {
+	case *SequenceType:
+		components = append(t.Components, t.Additions...)
+	case *ChoiceType:
+		components = append(t.Alternatives, t.Additions...)
+	}
+	for _, c := range components {
+		if c.Name == name {
+			return c.Type
+		}
+	}
+	return nil
+}
+
+// parseValue parses a value. The type of the value is used to tell OBJECT
+// IDENTIFIER values apart from the other values in braces and it may be nil
+// when it's unknown.
+func (p *parser) parseValue(typ Type) (Value, error) {
+	t := p.tok
+	switch {
+	case t.kind == tokenNumber:
+		return p.parseNumber(t.pos, false), nil
+	case p.is("-"):
+		p.next()
+		if p.tok.kind != tokenNumber {
+			return nil, p.unexpected("number")
+		}
+		return p.parseNumber(t.pos, true), nil
+	case t.kind == tokenCString:
+		p.next()
+		return &StringValue{t.pos, 'C', t.text}, nil
+	case t.kind == tokenBString:
+		p.next()
+		return &StringValue{t.pos, 'B', t.text}, nil
+	case t.kind == tokenHString:
+		p.next()
+		return &StringValue{t.pos, 'H', t.text}, nil
+	case p.is("TRUE"), p.is("FALSE"):
+		p.next()
+		return &BooleanValue{t.pos, t.text == "TRUE"}, nil
+	case p.is("NULL"):
+		p.next()
+		return &NullValue{t.pos}, nil
+	case p.is("{"):
+		return p.parseBracedValue(typ)
+	case isIdentifier(t):
+		p.next()
+		if !p.accept(":") {
+			return &ReferenceValue{Pos: t.pos, Name: t.text}, nil
+		}
+		value, err := p.parseValue(getComponentType(typ, t.text))
+		if err != nil {
+			return nil, err
+		}
+		return &ChoiceValue{t.pos, t.text, value}, nil
+	case isTypeReference(t) && isToken(p.peek(1), ".") && isIdentifier(p.peek(2)):
+		p.next()
+		p.next()
+		name := p.tok.text
+		p.next()
+		return &ReferenceValue{t.pos, t.text, name}, nil
+	}
+	return nil, p.unexpected("value")
+}
+
+// parseNumber parses the current token as a number. The position is the one
+// of the minus sign, if any.
+func (p *parser) parseNumber(pos Pos, negative bool) *NumberValue {
+	n, _ := new(big.Int).SetString(p.tok.text, 10)
+	if negative {
+		n.Neg(n)
+	}
+	p.next()
+	return &NumberValue{pos, n}
+}
+
+// parseBracedValue parses a value in braces. Without a known type, the value
+// is an OBJECT IDENTIFIER if it has no commas and only numbers and names,
+// like "{ id-pkix 1 }" or "{ iso(1) 2 }".
+func (p *parser) parseBracedValue(typ Type) (Value, error) {
+	switch t := getUnderlyingType(typ).(type) {
+	case *BuiltinType:
+		if t.Name == "OBJECT IDENTIFIER" || t.Name == "RELATIVE-OID" {
+			return p.parseOidValue()
+		}
+	case *SequenceType, *SequenceOfType, *BitStringType:
+	default:
+		if p.isOidValue() {
+			return p.parseOidValue()
+		}
+	}
+
+	pos := p.tok.pos
+	p.next()
+	_, sequence := getUnderlyingType(typ).(*SequenceType)
+	named := isIdentifier(p.tok) && !isToken(p.peek(1), ",") &&
+		!isToken(p.peek(1), "}") && !isToken(p.peek(1), ":")
+	if named || (sequence && p.is("}")) {
+		value := &SequenceValue{Pos: pos, Components: []*NamedValue{}}
+		for !p.is("}") {
+			name, err := p.expectIdentifier()
+			if err != nil {
+				return nil, err
+			}
+			component, err := p.parseValue(getComponentType(typ, name.text))
+			if err != nil {
+				return nil, err
+			}
+			value.Components = append(value.Components, &NamedValue{name.pos, name.text, component})
+			if !p.accept(",") {
+				break
+			}
+		}
+		if err := p.expect("}"); err != nil {
+			return nil, err
+		}
+		return value, nil
+	}
+
+	var elemType Type
+	if t, ok := getUnderlyingType(typ).(*SequenceOfType); ok {
+		elemType = t.Element
+	}
+	value := &ListValue{Pos: pos, Values: []Value{}}
+	for !p.is("}") {
+		elem, err := p.parseValue(elemType)
+		if err != nil {
+			return nil, err
+		}
+		value.Values = append(value.Values, elem)
+		if !p.accept(",") {
+			break
+		}
+	}
+	if err := p.expect("}"); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// isOidValue checks if the braces at the current token look like an OBJECT
+// IDENTIFIER value.
+func (p *parser) isOidValue() bool {
+	for i := 1; ; i++ {
+		t := p.peek(i)
+		switch {
+		case isToken(t, "}"):
+			return i > 1
+		case t.kind == tokenNumber, isIdentifier(t), isToken(t, "("), isToken(t, ")"):
+		default:
+			return false
+		}
+	}
+}
+
+// parseOidValue parses an OBJECT IDENTIFIER value, including the braces.
+func (p *parser) parseOidValue() (*ObjectIdentifierValue, error) {
+	value := &ObjectIdentifierValue{Pos: p.tok.pos, Components: []*OidComponent{}}
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	for !p.accept("}") {
+		c := &OidComponent{Pos: p.tok.pos}
+		switch {
+		case p.tok.kind == tokenNumber:
+			c.Number = p.parseNumber(c.Pos, false).Value
+		case isIdentifier(p.tok):
+			c.Name = p.tok.text
+			p.next()
+			if p.accept("(") {
+				if p.tok.kind != tokenNumber {
+					return nil, p.unexpected("number")
+				}
+				c.Number = p.parseNumber(c.Pos, false).Value
+				if err := p.expect(")"); err != nil {
+					return nil, err
+				}
+			}
+		default:
+			return nil, p.unexpected("object identifier component")
+		}
+		value.Components = append(value.Components, c)
+	}
+	return value, nil
+}
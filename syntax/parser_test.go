@@ -0,0 +1,168 @@
+package syntax
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+)
+
+const testModule = `
+-- A module with most of the supported constructions
+Test-Module { iso(1) 3 6 1 4 1 99 test(1) }
+DEFINITIONS IMPLICIT TAGS EXTENSIBILITY IMPLIED ::= BEGIN
+
+EXPORTS Record, id-test;
+IMPORTS Name, id-pkix FROM PKIX1Explicit88 { 1 3 6 1 5 5 7 0 18 }
+        Other FROM Other-Module other-module-id
+        Last FROM Last-Module;
+
+id-test OBJECT IDENTIFIER ::= { id-pkix 99 }
+maxItems INTEGER ::= 64
+
+Record ::= [APPLICATION 1] SEQUENCE {
+    version   [0] EXPLICIT Version DEFAULT v1,
+    id        INTEGER (0..maxItems),
+    items     SEQUENCE SIZE (1..MAX) OF item Item OPTIONAL,
+    name      PKIX1Explicit88.Name,
+    COMPONENTS OF Other,
+    ...,
+    [[ 2: extra BOOLEAN DEFAULT FALSE ]],
+    flags     BIT STRING { a(0), b(1) } /* nested /* comment */ */
+}
+
+Version ::= INTEGER { v1(0), v2(1) }
+Color ::= ENUMERATED { red, green(5), ..., blue }
+Item ::= CHOICE { text UTF8String (SIZE (1..10), ...), any ANY DEFINED BY id }
+Code ::= IA5String (FROM ("A".."Z") ^ SIZE (2) | "x")
+default Record ::= { version v2, id -1, flags 'A0'H }
+END
+`
+
+func TestParse(t *testing.T) {
+	modules, err := Parse("test.asn", []byte(testModule))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(modules) != 1 {
+		t.Fatalf("Expected 1 module, got %d", len(modules))
+	}
+	m := modules[0]
+	if m.Name != "Test-Module" || m.Pos != (Pos{3, 1}) || len(m.Oid.Components) != 8 ||
+		m.TagDefault != ImplicitTags || !m.ExtensibilityImplied || m.ExportAll ||
+		!reflect.DeepEqual(m.Exports, []string{"Record", "id-test"}) {
+		t.Fatalf("Invalid module header: %+v", m)
+	}
+	if len(m.Imports) != 3 || m.Imports[0].Module != "PKIX1Explicit88" ||
+		!reflect.DeepEqual(m.Imports[0].Symbols, []string{"Name", "id-pkix"}) ||
+		m.Imports[1].Module != "Other-Module" || m.Imports[1].Oid != nil ||
+		!reflect.DeepEqual(m.Imports[2].Symbols, []string{"Last"}) {
+		t.Fatalf("Invalid imports: %+v", m.Imports)
+	}
+	names := []string{}
+	for _, a := range m.Assignments {
+		names = append(names, a.AssignedName())
+	}
+	expectedNames := []string{"id-test", "maxItems", "Record", "Version", "Color", "Item", "Code", "default"}
+	if !reflect.DeepEqual(names, expectedNames) {
+		t.Fatalf("Invalid assignments: %v", names)
+	}
+
+	oid := m.Assignments[0].(*ValueAssignment).Value.(*ObjectIdentifierValue)
+	if len(oid.Components) != 2 || oid.Components[0].Name != "id-pkix" ||
+		oid.Components[1].Number.Int64() != 99 || oid.Pos != (Pos{11, 31}) {
+		t.Fatalf("Invalid OID value: %+v", oid)
+	}
+
+	// Record
+	tagged := m.Assignments[2].(*TypeAssignment).Type.(*TaggedType)
+	if tagged.Class != Application || tagged.Mode != ImplicitTags {
+		t.Fatalf("Invalid tag: %+v", tagged)
+	}
+	seq := tagged.Type.(*SequenceType)
+	if len(seq.Components) != 5 || len(seq.Additions) != 2 || !seq.Extensible {
+		t.Fatalf("Invalid SEQUENCE: %+v", seq)
+	}
+	version := seq.Components[0]
+	if version.Type.(*TaggedType).Mode != ExplicitTags ||
+		version.Default.(*ReferenceValue).Name != "v1" || version.Pos != (Pos{15, 5}) {
+		t.Fatalf("Invalid version: %+v", version)
+	}
+	r := seq.Components[1].Type.(*ConstrainedType).Constraint.Elements[0].(*ValueRange)
+	if r.Lower.(*NumberValue).Value.Int64() != 0 || r.Upper.(*ReferenceValue).Name != "maxItems" {
+		t.Fatalf("Invalid range: %+v", r)
+	}
+	items := seq.Components[2]
+	of := items.Type.(*SequenceOfType)
+	size := of.Constraint.Elements[0].(*SizeConstraint).Constraint.Elements[0].(*ValueRange)
+	if !items.Optional || of.ElementName != "item" || size.Upper != nil {
+		t.Fatalf("Invalid items: %+v", items)
+	}
+	if name := seq.Components[3].Type.(*ReferencedType); name.Module != "PKIX1Explicit88" || name.Name != "Name" {
+		t.Fatalf("Invalid name: %+v", name)
+	}
+	if !seq.Components[4].ComponentsOf || seq.Additions[1].Name != "flags" ||
+		seq.Additions[1].Group != 0 {
+		t.Fatalf("Invalid components: %+v", seq.Components)
+	}
+	if extra := seq.Additions[0]; extra.Name != "extra" || extra.Group != 1 ||
+		extra.Default.(*BooleanValue).Value {
+		t.Fatalf("Invalid extension addition: %+v", extra)
+	}
+
+	color := m.Assignments[4].(*TypeAssignment).Type.(*EnumeratedType)
+	if len(color.Items) != 2 || color.Items[0].Value != nil ||
+		color.Items[1].Value.(*NumberValue).Value.Int64() != 5 ||
+		len(color.Additions) != 1 || color.Additions[0].Name != "blue" {
+		t.Fatalf("Invalid ENUMERATED: %+v", color)
+	}
+
+	choice := m.Assignments[5].(*TypeAssignment).Type.(*ChoiceType)
+	text := choice.Alternatives[0].Type.(*ConstrainedType)
+	if text.Type.(*BuiltinType).Name != "UTF8String" || !text.Constraint.Extensible ||
+		choice.Alternatives[1].Type.(*AnyType).DefinedBy != "id" {
+		t.Fatalf("Invalid CHOICE: %+v", choice)
+	}
+
+	code := m.Assignments[6].(*TypeAssignment).Type.(*ConstrainedType).Constraint
+	if len(code.Elements) != 2 ||
+		code.Elements[0].(*RawConstraint).Text != `FROM ( "A" .. "Z" ) ^ SIZE ( 2 )` ||
+		code.Elements[1].(*SingleValue).Value.(*StringValue).Text != "x" {
+		t.Fatalf("Invalid constraint: %+v", code.Elements)
+	}
+
+	value := m.Assignments[7].(*ValueAssignment).Value.(*SequenceValue)
+	expected := &SequenceValue{Pos{29, 20}, []*NamedValue{
+		{Pos{29, 22}, "version", &ReferenceValue{Pos{29, 30}, "", "v2"}},
+		{Pos{29, 34}, "id", &NumberValue{Pos{29, 37}, big.NewInt(-1)}},
+		{Pos{29, 41}, "flags", &StringValue{Pos{29, 47}, 'H', "A0"}},
+	}}
+	if !reflect.DeepEqual(value, expected) {
+		t.Fatalf("Invalid value: %+v", value)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	testCases := []struct {
+		src      string
+		expected string
+	}{
+		{"", "test.asn:1:1: no module found"},
+		{"M DEFINITIONS ::= BEGIN A ::= SEQUENCE { a INTEGER ", "test.asn:1:52: expected '}', found end of file"},
+		{"M DEFINITIONS ::= BEGIN A ::= INTEGER { a } END", "test.asn:1:43: expected '(', found '}'"},
+		{"M DEFINITIONS ::= BEGIN A ::= CLASS { } END", "test.asn:1:31: information object classes are not supported"},
+		{"M DEFINITIONS ::= BEGIN A{T} ::= T END", "test.asn:1:25: parameterized assignments are not supported"},
+		{"M DEFINITIONS ::= BEGIN\n A ::= '012'B END", "test.asn:2:8: invalid binary string '012'"},
+		{"M DEFINITIONS ::= BEGIN /* END", "test.asn:1:25: unterminated comment"},
+		{"M DEFINITIONS ::= BEGIN A ::= SET { ..., ..., ... } END", "test.asn:1:47: too many extension markers"},
+	}
+	for _, test := range testCases {
+		_, err := Parse("test.asn", []byte(test.src))
+		if err == nil {
+			t.Errorf("%q: expected error %q", test.src, test.expected)
+			continue
+		}
+		if _, ok := err.(*Error); !ok || err.Error() != test.expected {
+			t.Errorf("%q:\nExpected: %s\nGot:      %s", test.src, test.expected, err)
+		}
+	}
+}
@@ -0,0 +1,323 @@
+package asn1
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// TraceEntry describes the element that holds the value of a Go field.
+type TraceEntry struct {
+	// Offset of the element in the encoded data
+	Offset int
+	// Number of identifier and length octets
+	HeaderLen int
+	// Number of content octets, excluding the end-of-contents octets
+	Length int
+	// Indefinite is set if the element uses the indefinite length form
+	Indefinite bool
+	// Path of the Go field, like "Record.Items[0].Flag"
+	Path string
+	// Options applied to the value. For CHOICE fields, these are the options
+	// of the chosen alternative.
+	Options string
+	// Identifier of the chosen alternative of CHOICE fields
+	Choice string
+}
+
+// End returns the offset that follows the element.
+func (e TraceEntry) End() int {
+	end := e.Offset + e.HeaderLen + e.Length
+	if e.Indefinite {
+		end += 2
+	}
+	return end
+}
+
+// Trace is a source map with the elements produced by each Go value, in the
+// order they appear in the encoded data. Enclosing elements come before the
+// elements they contain.
+type Trace []TraceEntry
+
+// Find returns the entries whose elements contain the octet at the given
+// offset, from the outermost to the innermost one.
+func (t Trace) Find(offset int) []TraceEntry {
+	entries := []TraceEntry{}
+	for _, e := range t {
+		if offset >= e.Offset && offset < e.End() {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+// Hex returns the encoded data as annotated hexadecimal. Each line shows the
+// offset of an element, its header octets and the content of primitive
+// elements, followed by the Go field that produced it:
+//
+//	0: 30 07           Record
+//	2:   02 01 07      Record.Id
+//	5:   A1 02         Record.Value (text) [tag:1,explicit]
+//	7:     0C 00
+//
+// Elements that were not produced by a field of their own, like the inner
+// element of an explicit tag, have no annotation.
+func (t Trace) Hex(data []byte) (string, error) {
+	entries := map[int]TraceEntry{}
+	for _, e := range t {
+		entries[e.Offset] = e
+	}
+	lines := [][2]string{}
+	var addLines func(node *Node, depth int)
+	addLines = func(node *Node, depth int) {
+		start := node.Offset
+		octets := data[start : start+node.HeaderLen]
+		if !node.Constructed {
+			octets = data[start : start+node.HeaderLen+len(node.Content)]
+		}
+		hex := fmt.Sprintf("%5d: %s% X", node.Offset, strings.Repeat("  ", depth), octets)
+		note := ""
+		if e, ok := entries[node.Offset]; ok {
+			note = e.Path
+			if e.Choice != "" {
+				note += " (" + e.Choice + ")"
+			}
+			if e.Options != "" {
+				note += " [" + e.Options + "]"
+			}
+		}
+		lines = append(lines, [2]string{hex, note})
+		for _, child := range node.Children {
+			addLines(child, depth+1)
+		}
+		if node.Indefinite {
+			eoc := node.Offset + node.HeaderLen + len(node.Content)
+			lines = append(lines, [2]string{
+				fmt.Sprintf("%5d: %s00 00", eoc, strings.Repeat("  ", depth+1)), ""})
+		}
+	}
+	for offset := 0; offset < len(data); {
+		node, err := parseNode(data[offset:], offset)
+		if err != nil {
+			return "", err
+		}
+		addLines(node, 0)
+		offset += node.Len()
+	}
+
+	width := 0
+	for _, line := range lines {
+		if len(line[0]) > width {
+			width = len(line[0])
+		}
+	}
+	buf := bytes.Buffer{}
+	for _, line := range lines {
+		if line[1] == "" {
+			buf.WriteString(line[0] + "\n")
+		} else {
+			fmt.Fprintf(&buf, "%-*s  %s\n", width, line[0], line[1])
+		}
+	}
+	return buf.String(), nil
+}
+
+// EncodeWithTrace works similarly to EncodeWithOptions, but it also returns a
+// Trace that maps the encoded elements to the Go fields that produced them.
+// It's useful to find which field produced an octet rejected by a peer:
+//
+//	data, trace, err := ctx.EncodeWithTrace(msg, "")
+//	...
+//	for _, e := range trace.Find(offset) {
+//		fmt.Println(e.Path, e.Options)
+//	}
+//
+// The root value is identified by its type name. Fields are separated by "."
+// and the elements of slices and arrays are identified by their index.
+func (ctx *Context) EncodeWithTrace(obj interface{}, options string) ([]byte, Trace, error) {
+	opts, err := parseOptions(options)
+	if err != nil || opts == nil {
+		return nil, nil, err
+	}
+	c := *ctx
+	c.trace = true
+	value := reflect.ValueOf(obj)
+	raw, err := c.encode(value, opts)
+	if err != nil || raw == nil {
+		return nil, nil, err
+	}
+	data, err := raw.encode()
+	if err != nil {
+		return nil, nil, err
+	}
+	raw.trace.setField(getActualType(value).Type().Name(), options)
+	return data, raw.getTrace(0), nil
+}
+
+// DecodeWithTrace works similarly to DecodeWithOptions, but it also returns a
+// Trace with the offset, header length and content length of the element of
+// each decoded Go field. It's useful to highlight the octets of a field:
+//
+//	rest, trace, err := ctx.DecodeWithTrace(data, &msg, "")
+//	...
+//	for _, e := range trace {
+//		fmt.Println(e.Path, data[e.Offset:e.End()])
+//	}
+//
+// Fields are identified as in EncodeWithTrace(). Explicit tags, choices and
+// the elements of SEQUENCE OF values are followed, and fields that are not
+// found in data have no entry.
+func (ctx *Context) DecodeWithTrace(data []byte, obj interface{}, options string) (rest []byte, trace Trace, err error) {
+	name := ""
+	if value := getActualType(reflect.ValueOf(obj)); value.IsValid() {
+		name = value.Type().Name()
+	}
+	c := *ctx
+	c.decodeTrace = &decodeTrace{end: len(data), index: -1}
+	c.decodeTrace.setNext(name, options)
+	rest, err = c.DecodeWithOptions(data, obj, options)
+	if err != nil {
+		return nil, nil, err
+	}
+	// Components of SETs are decoded in the order of their tags
+	trace = c.decodeTrace.entries
+	sort.SliceStable(trace, func(i, j int) bool {
+		return trace[i].Offset < trace[j].Offset
+	})
+	return rest, trace, nil
+}
+
+// rawTrace is the part of a Trace recorded along with a raw value.
+type rawTrace struct {
+	// Entry of the value. Encoded values have no position until they are
+	// joined, and decoded values have no path until they are matched to a
+	// field.
+	entry TraceEntry
+	// Entries of the values in the content, with offsets relative to the
+	// start of the content and paths relative to the value
+	children Trace
+}
+
+// setField sets the path and the options of the field that holds the value.
+// The options of the chosen alternative are kept for CHOICE fields.
+func (t *rawTrace) setField(path, options string) {
+	t.entry.Path = path
+	if t.entry.Choice == "" {
+		t.entry.Options = options
+	}
+}
+
+// getTrace returns the entry of a traced raw value encoded at offset,
+// followed by the entries of its content.
+func (raw *rawValue) getTrace(offset int) Trace {
+	identifier, _ := encodeIdentifier(raw)
+	headerLen := len(identifier) + 1
+	if !raw.Indefinite {
+		headerLen = len(identifier) + len(encodeLength(uint(len(raw.Content))))
+	}
+	entry := raw.trace.entry
+	entry.Offset = offset
+	entry.HeaderLen = headerLen
+	entry.Length = len(raw.Content)
+	entry.Indefinite = raw.Indefinite
+	trace := Trace{entry}
+	for _, child := range raw.trace.children {
+		child.Offset += offset + headerLen
+		child.Path = entry.Path + child.Path
+		trace = append(trace, child)
+	}
+	return trace
+}
+
+// joinRawValues encodes raw values in sequence. It also returns the entries
+// of the traced values, with offsets relative to the returned content.
+func joinRawValues(values []*rawValue) ([]byte, Trace, error) {
+	content := []byte{}
+	var trace Trace
+	for _, raw := range values {
+		buf, err := raw.encode()
+		if err != nil {
+			return nil, nil, err
+		}
+		if raw != nil && raw.trace != nil {
+			trace = append(trace, raw.getTrace(len(content))...)
+		}
+		content = append(content, buf...)
+	}
+	return content, trace, nil
+}
+
+// decodeTrace records the elements of the decoded values, see
+// DecodeWithTrace. Its methods do nothing when it's nil, which is the case
+// when decoding without a Trace.
+type decodeTrace struct {
+	entries Trace
+	// Offset that follows the data being read
+	end int
+	// Index of the entry of the value being decoded, or -1 for none
+	index int
+	// Entry of the next value, which is a field or an element of a SEQUENCE
+	// OF, with a path relative to the value being decoded
+	next *TraceEntry
+}
+
+// locate sets the position of a raw value read from a buffer, given the
+// number of octets that remained in the buffer before and after reading it.
+func (t *decodeTrace) locate(raw *rawValue, before, after int) {
+	if t == nil {
+		return
+	}
+	headerLen := before - after - len(raw.Content)
+	if raw.Indefinite {
+		headerLen -= 2
+	}
+	raw.trace = &rawTrace{entry: TraceEntry{
+		Offset:     t.end - before,
+		HeaderLen:  headerLen,
+		Length:     len(raw.Content),
+		Indefinite: raw.Indefinite,
+	}}
+}
+
+// setNext sets the path and the options of the next value that is entered.
+func (t *decodeTrace) setNext(path, options string) {
+	if t == nil {
+		return
+	}
+	t.next = &TraceEntry{Path: path, Options: options}
+}
+
+// setChoice sets the chosen alternative of the value being decoded.
+func (t *decodeTrace) setChoice(choice, options string) {
+	if t == nil || t.index < 0 {
+		return
+	}
+	t.entries[t.index].Choice = choice
+	t.entries[t.index].Options = options
+}
+
+// enter makes the content of a raw value the data being read, adding an entry
+// for it if it's the next value. It returns the function that restores the
+// previous state once the content is decoded.
+func (t *decodeTrace) enter(raw *rawValue) func() {
+	if t == nil {
+		return func() {}
+	}
+	end, index := t.end, t.index
+	entry := raw.trace.entry
+	t.end = entry.Offset + entry.HeaderLen + entry.Length
+	if t.next != nil {
+		entry.Path, entry.Options = t.next.Path, t.next.Options
+		if t.index >= 0 {
+			entry.Path = t.entries[t.index].Path + entry.Path
+		}
+		t.entries = append(t.entries, entry)
+		t.index = len(t.entries) - 1
+		t.next = nil
+	}
+	return func() {
+		t.end, t.index = end, index
+	}
+}
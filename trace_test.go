@@ -0,0 +1,158 @@
+package asn1
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEncodeWithTrace(t *testing.T) {
+	type Item struct {
+		Flag bool
+	}
+	type Record struct {
+		Id    int
+		Name  string      `asn1:"optional,utf8"`
+		Value interface{} `asn1:"choice:value"`
+		Items []Item      `asn1:"tag:2,explicit"`
+	}
+	ctx := NewContext()
+	ctx.AddChoice("value", []Choice{
+		{reflect.TypeOf(""), "tag:1,explicit,utf8,name:text"},
+		{reflect.TypeOf(int(0)), "tag:3"},
+	})
+	data, trace, err := ctx.EncodeWithTrace(&Record{Id: 7, Value: "abc", Items: []Item{{true}}}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hex, err := trace.Hex(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "" +
+		"    0: 30 13               Record\n" +
+		"    2:   02 01 07          Record.Id\n" +
+		"    5:   A1 05             Record.Value (text) [tag:1,explicit,utf8,name:text]\n" +
+		"    7:     0C 03 61 62 63\n" +
+		"   12:   A2 07             Record.Items [tag:2,explicit]\n" +
+		"   14:     30 05\n" +
+		"   16:       30 03         Record.Items[0]\n" +
+		"   18:         01 01 FF    Record.Items[0].Flag\n"
+	if hex != expected {
+		t.Fatalf("Unexpected trace.\n Expected:\n%s\n Got:\n%s", expected, hex)
+	}
+
+	// The fields that produced the boolean value
+	paths := []string{}
+	for _, e := range trace.Find(20) {
+		paths = append(paths, e.Path)
+	}
+	checkEqual(t, paths, []string{"Record", "Record.Items", "Record.Items[0]", "Record.Items[0].Flag"})
+
+	// Indefinite lengths
+	ctx.SetRules(CER, nil)
+	data, trace, err = ctx.EncodeWithTrace(Record{Id: 7, Value: 5}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hex, err = trace.Hex(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = "" +
+		"    0: 30 80        Record\n" +
+		"    2:   02 01 07   Record.Id\n" +
+		"    5:   83 01 05   Record.Value (INTEGER) [tag:3]\n" +
+		"    8:   A2 80      Record.Items [tag:2,explicit]\n" +
+		"   10:     30 80\n" +
+		"   12:       00 00\n" +
+		"   14:     00 00\n" +
+		"   16:   00 00\n"
+	if hex != expected {
+		t.Fatalf("Unexpected trace.\n Expected:\n%s\n Got:\n%s", expected, hex)
+	}
+	checkEqual(t, trace[0].End(), len(data))
+
+	// Omitted optional fields do not shift the fields that follow them
+	type Optional struct {
+		A int `asn1:"optional"`
+		B int
+		C []int `asn1:"set,elem:tag:0"`
+	}
+	data, trace, err = NewContext().EncodeWithTrace(Optional{B: 5, C: []int{2, 1}}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkEqual(t, trace, Trace{
+		{Offset: 0, HeaderLen: 2, Length: 11, Path: "Optional"},
+		{Offset: 2, HeaderLen: 2, Length: 1, Path: "Optional.B"},
+		{Offset: 5, HeaderLen: 2, Length: 6, Path: "Optional.C", Options: "set,elem:tag:0"},
+		{Offset: 7, HeaderLen: 2, Length: 1, Path: "Optional.C[1]", Options: "tag:0"},
+		{Offset: 10, HeaderLen: 2, Length: 1, Path: "Optional.C[0]", Options: "tag:0"},
+	})
+	checkEqual(t, trace[0].End(), len(data))
+}
+
+func TestDecodeWithTrace(t *testing.T) {
+	type Record struct {
+		Id     int
+		Values []int       `asn1:"tag:0,explicit"`
+		Value  interface{} `asn1:"choice:value"`
+		Extra  int         `asn1:"optional"`
+	}
+	ctx := NewContext()
+	ctx.AddChoice("value", []Choice{
+		{reflect.TypeOf(""), "tag:1,explicit,utf8,name:text"},
+		{reflect.TypeOf(int(0)), "tag:3"},
+	})
+	data := []byte{
+		// SEQ INDEFINITE
+		0x30, 0x80,
+		// INTEGER 7
+		0x02, 0x01, 0x07,
+		// [0] { SEQ { INTEGER 1, INTEGER 2 } }
+		0xa0, 0x08, 0x30, 0x06, 0x02, 0x01, 0x01, 0x02, 0x01, 0x02,
+		// [1] { UTF8String "a" }
+		0xa1, 0x03, 0x0c, 0x01, 0x61,
+		// EOC
+		0x00, 0x00,
+		// Next element
+		0x05, 0x00,
+	}
+	var record Record
+	rest, trace, err := ctx.DecodeWithTrace(data, &record, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkEqual(t, rest, []byte{0x05, 0x00})
+	checkEqual(t, record.Value, "a")
+	checkEqual(t, trace, Trace{
+		{Offset: 0, HeaderLen: 2, Length: 18, Indefinite: true, Path: "Record"},
+		{Offset: 2, HeaderLen: 2, Length: 1, Path: "Record.Id"},
+		{Offset: 5, HeaderLen: 2, Length: 8, Path: "Record.Values", Options: "tag:0,explicit"},
+		{Offset: 9, HeaderLen: 2, Length: 1, Path: "Record.Values[0]"},
+		{Offset: 12, HeaderLen: 2, Length: 1, Path: "Record.Values[1]"},
+		{Offset: 15, HeaderLen: 2, Length: 3, Path: "Record.Value",
+			Options: "tag:1,explicit,utf8,name:text", Choice: "text"},
+	})
+	checkEqual(t, trace[0].End(), 22)
+
+	// Components of SETs out of order and choices in SEQUENCE OF elements
+	type Set struct {
+		A int           `asn1:"tag:0"`
+		B []interface{} `asn1:"tag:1,elem:choice:value"`
+	}
+	data = []byte{0x31, 0x0a, 0xa1, 0x05, 0xa1, 0x03, 0x0c, 0x01, 0x62, 0x80, 0x01, 0x01}
+	var set Set
+	_, trace, err = ctx.DecodeWithTrace(data, &set, "set")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkEqual(t, set.B, []interface{}{"b"})
+	checkEqual(t, trace, Trace{
+		{Offset: 0, HeaderLen: 2, Length: 10, Path: "Set", Options: "set"},
+		{Offset: 2, HeaderLen: 2, Length: 5, Path: "Set.B", Options: "tag:1,elem:choice:value"},
+		{Offset: 4, HeaderLen: 2, Length: 3, Path: "Set.B[0]",
+			Options: "tag:1,explicit,utf8,name:text", Choice: "text"},
+		{Offset: 9, HeaderLen: 2, Length: 1, Path: "Set.A", Options: "tag:0"},
+	})
+}
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"math/big"
 	"reflect"
+	"strconv"
+	"strings"
 )
 
 // Pre-calculated types for convenience
@@ -37,7 +39,10 @@ func (ctx *Context) encodeBool(value reflect.Value) ([]byte, error) {
 
 func (ctx *Context) decodeBool(data []byte, value reflect.Value) error {
 	// TODO check value type
-	if !ctx.der.decoding {
+	if len(data) == 0 {
+		return parseError("invalid bool value")
+	}
+	if !ctx.rules.decoding.Strict() {
 		boolValue := parseBigInt(data).Cmp(big.NewInt(0)) != 0
 		value.SetBool(boolValue)
 		return nil
@@ -185,12 +190,13 @@ func (ctx *Context) encodeOctetString(value reflect.Value) ([]byte, error) {
 		// Invalid type or element type
 		return nil, wrongType("array or slice of bytes", value)
 	}
+	// Bytes and Uint also accept named types, like "type Imsi []byte"
 	if kind == reflect.Slice {
-		return value.Interface().([]byte), nil
+		return value.Bytes(), nil
 	}
 	data := make([]byte, value.Len())
 	for i := 0; i < value.Len(); i++ {
-		data[i] = value.Index(i).Interface().(byte)
+		data[i] = byte(value.Index(i).Uint())
 	}
 	return data, nil
 }
@@ -209,13 +215,13 @@ func (ctx *Context) decodeOctetString(data []byte, value reflect.Value) error {
 			t := fmt.Sprintf("[%d]uint8", value.Len())
 			return wrongType(t, value)
 		}
-		// Get reference to the array as a slice
-		dest := value.Slice(0, value.Len()).Interface().([]byte)
 		// Copy data
-		copy(dest, data)
+		for i, b := range data {
+			value.Index(i).SetUint(uint64(b))
+		}
 	} else {
 		// Set value with a copy of the array data
-		value.Set(reflect.ValueOf(append([]byte{}, data...)))
+		value.SetBytes(append([]byte{}, data...))
 	}
 	return nil
 }
@@ -338,6 +344,24 @@ func (oid Oid) String() string {
 	return s
 }
 
+// parseOid parses the dotted representation of an Oid. A leading dot is
+// optional.
+func parseOid(s string) (Oid, error) {
+	s = strings.TrimPrefix(s, ".")
+	if s == "" {
+		return Oid{}, nil
+	}
+	var oid Oid
+	for _, part := range strings.Split(s, ".") {
+		n, err := strconv.ParseUint(part, 10, 0)
+		if err != nil {
+			return nil, parseError("invalid OBJECT IDENTIFIER '%s'", s)
+		}
+		oid = append(oid, uint(n))
+	}
+	return oid, nil
+}
+
 func (ctx *Context) encodeOid(value reflect.Value) ([]byte, error) {
 	// Check values
 	oid, ok := value.Interface().(Oid)
@@ -422,7 +446,7 @@ func (ctx *Context) decodeNull(data []byte, value reflect.Value) error {
 // getActualType recursively gets the underlying type of Interfaces and Pointers.
 func getActualType(value reflect.Value) reflect.Value {
 	for {
-		if value.Type() == bigIntType {
+		if !value.IsValid() || value.Type() == bigIntType {
 			return value
 		}
 		switch value.Kind() {
@@ -435,12 +459,22 @@ func getActualType(value reflect.Value) reflect.Value {
 }
 
 func checkInt(ctx *Context, data []byte) error {
-	if ctx.der.decoding {
-		if len(data) >= 2 {
-			if data[0] == 0xff || data[0] == 0x00 {
-				if data[0]&0x80 == data[1]&0x80 {
-					return parseError("integer not encoded in the short form")
-				}
+	if len(data) == 0 {
+		return parseError("empty integer")
+	}
+	if ctx.rules.decoding.Strict() {
+		return checkMinimalInt(data)
+	}
+	return nil
+}
+
+// checkMinimalInt checks if a two's complement integer uses the minimum number
+// of octets.
+func checkMinimalInt(data []byte) error {
+	if len(data) >= 2 {
+		if data[0] == 0xff || data[0] == 0x00 {
+			if data[0]&0x80 == data[1]&0x80 {
+				return parseError("integer not encoded in the short form")
 			}
 		}
 	}
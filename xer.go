@@ -0,0 +1,679 @@
+package asn1
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/xml"
+	"io"
+	"math/big"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// EncodeXer returns the Basic XML Encoding Rules (X.693) encoding of obj using
+// additional options. The output is indented to make it easier to read and
+// edit.
+//
+// Elements are named after the Go struct fields, or after the option "name"
+// when it's given. The root element and the elements of a SEQUENCE OF are named
+// after their Go types. Values are represented as follows:
+//
+//	ASN.1 type             | XER representation
+//	-----------------------|-------------------------------------------
+//	BOOLEAN                | <true/> or <false/>
+//	INTEGER                | decimal number
+//	ENUMERATED             | empty element named after the value
+//	OCTET STRING           | hexadecimal digits
+//	BIT STRING             | binary digits ("0" and "1")
+//	OBJECT IDENTIFIER      | dotted numbers ("1.2.840")
+//	NULL                   | empty content
+//	Character strings      | text
+//	SEQUENCE and SET       | one element per component
+//	CHOICE                 | element named after the chosen alternative
+//
+// CHOICE alternatives are named after the option "name" given in
+// (*Context).AddChoice() or after their Go type.
+func (ctx *Context) EncodeXer(obj interface{}, options string) ([]byte, error) {
+	return ctx.encodeXer(obj, options, false)
+}
+
+// EncodeCxer returns the Canonical XML Encoding Rules (X.693) encoding of obj
+// using additional options. Unlike EncodeXer, no white space is added and the
+// elements of a SET OF are sorted. In both variants, components equal to their
+// DEFAULT value are omitted.
+//
+// See (*Context).EncodeXer() for further details.
+func (ctx *Context) EncodeCxer(obj interface{}, options string) ([]byte, error) {
+	return ctx.encodeXer(obj, options, true)
+}
+
+// DecodeXer parses the given Basic or Canonical XER data into obj using
+// additional options. The argument obj should be a reference to the value that
+// will hold the parsed data.
+//
+// Besides the representations produced by EncodeXer, booleans and enumerated
+// values are also accepted as text (ie: <flag>true</flag>).
+//
+// See (*Context).EncodeXer() for further details.
+func (ctx *Context) DecodeXer(data []byte, obj interface{}, options string) (rest []byte, err error) {
+	opts, err := parseOptions(options)
+	if err != nil {
+		return nil, err
+	}
+	// Return nil if the ignore tag is given
+	if opts == nil {
+		return
+	}
+
+	value := reflect.ValueOf(obj)
+	switch value.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		value = value.Elem()
+	}
+
+	if !value.CanSet() {
+		return nil, syntaxError("go type '%s' is read-only", value.Type())
+	}
+
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	elem, err := parseXerElement(decoder)
+	if err != nil {
+		return nil, err
+	}
+	name := ctx.getXerTypeName(value.Type(), opts)
+	if elem.name != name {
+		return nil, parseError("expected element <%s> but found <%s>", name, elem.name)
+	}
+	err = ctx.decodeXer(elem, value, opts)
+	if err != nil {
+		return nil, err
+	}
+	return data[decoder.InputOffset():], nil
+}
+
+func (ctx *Context) encodeXer(obj interface{}, options string, canonical bool) ([]byte, error) {
+	opts, err := parseOptions(options)
+	if err != nil {
+		return nil, err
+	}
+	// Return nil if the ignore tag is given
+	if opts == nil {
+		return nil, nil
+	}
+
+	value := getActualType(reflect.ValueOf(obj))
+	if !value.IsValid() {
+		return nil, syntaxError("nil value cannot be encoded")
+	}
+	e := &xerEncoder{ctx: ctx, canonical: canonical}
+	name := ctx.getXerTypeName(value.Type(), opts)
+	if err = e.encodeElement(name, value, opts); err != nil {
+		return nil, err
+	}
+	if !canonical {
+		e.buf.WriteByte('\n')
+	}
+	return e.buf.Bytes(), nil
+}
+
+// getXerTypeName returns the name used for elements that are not struct
+// fields, such as the root element and the elements of a SEQUENCE OF.
+func (ctx *Context) getXerTypeName(objType reflect.Type, opts *fieldOptions) string {
+	if opts.name != nil {
+		return *opts.name
+	}
+	for objType.Kind() == reflect.Ptr && objType != bigIntType {
+		objType = objType.Elem()
+	}
+	switch objType {
+	case bigIntType:
+		return "INTEGER"
+	case bitStringType:
+		return "BIT_STRING"
+	case oidType:
+		return "OBJECT_IDENTIFIER"
+	case nullType:
+		return "NULL"
+	}
+	if objType.Name() != "" && objType.PkgPath() != "" {
+		return objType.Name()
+	}
+	switch objType.Kind() {
+	case reflect.Bool:
+		return "BOOLEAN"
+	case reflect.String:
+		if opts.stringTag != nil {
+			return xerStringTypeNames[*opts.stringTag]
+		}
+		return "OCTET_STRING"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if opts.enum != nil {
+			return "ENUMERATED"
+		}
+		return "INTEGER"
+	case reflect.Struct:
+		if opts.set {
+			return "SET"
+		}
+		return "SEQUENCE"
+	case reflect.Array, reflect.Slice:
+		if objType.Elem().Kind() == reflect.Uint8 {
+			return "OCTET_STRING"
+		}
+		if opts.set {
+			return "SET_OF"
+		}
+		return "SEQUENCE_OF"
+	}
+	return objType.Name()
+}
+
+var xerStringTypeNames = map[int]string{
+	tagUtf8String:      "UTF8String",
+	tagNumericString:   "NumericString",
+	tagPrintableString: "PrintableString",
+	tagIA5String:       "IA5String",
+	tagVisibleString:   "VisibleString",
+}
+
+// getFieldIdentifier returns the identifier of a struct field, used by textual
+// encoding rules as element or member name.
+func getFieldIdentifier(field structField) string {
+	if field.opts.name != nil {
+		return *field.opts.name
+	}
+	return field.Name
+}
+
+// getChoiceIdentifier returns the identifier of a CHOICE alternative.
+func (ctx *Context) getChoiceIdentifier(entry choiceEntry) string {
+	return ctx.getXerTypeName(entry.typ, entry.opts)
+}
+
+// isXerValueList checks if values of a type are represented as empty
+// elements, which are not wrapped when used in a SEQUENCE OF.
+func isXerValueList(objType reflect.Type, opts *fieldOptions) bool {
+	return objType.Kind() == reflect.Bool || opts.enum != nil
+}
+
+/*
+ * Encoder
+ */
+
+type xerEncoder struct {
+	buf       bytes.Buffer
+	ctx       *Context
+	canonical bool
+	depth     int
+}
+
+// indent adds a new line and the indentation of the current depth.
+func (e *xerEncoder) indent() {
+	if !e.canonical {
+		e.buf.WriteByte('\n')
+		e.buf.WriteString(strings.Repeat("  ", e.depth))
+	}
+}
+
+// encodeElement encodes a value enclosed in an element with the given name.
+func (e *xerEncoder) encodeElement(name string, value reflect.Value, opts *fieldOptions) error {
+	content := xerEncoder{ctx: e.ctx, canonical: e.canonical, depth: e.depth + 1}
+	nested, err := content.encodeValue(value, opts)
+	if err != nil {
+		return err
+	}
+	if content.buf.Len() == 0 {
+		e.buf.WriteString("<" + name + "/>")
+		return nil
+	}
+	e.buf.WriteString("<" + name + ">")
+	e.buf.Write(content.buf.Bytes())
+	if nested {
+		e.indent()
+	}
+	e.buf.WriteString("</" + name + ">")
+	return nil
+}
+
+// encodeValue encodes the content of an element. It returns true if the
+// content is made of nested elements.
+func (e *xerEncoder) encodeValue(value reflect.Value, opts *fieldOptions) (nested bool, err error) {
+
+	if opts.choice != nil {
+		return true, e.encodeChoice(value, opts)
+	}
+
+	// Skip the interface type
+	value = getActualType(value)
+	if !value.IsValid() {
+		return false, syntaxError("nil value cannot be encoded")
+	}
+
+	// Special types:
+	switch value.Type() {
+	case bigIntType:
+		e.buf.WriteString(getBigInt(value).String())
+		return false, nil
+	case bitStringType:
+		bs := value.Interface().(BitString)
+		for i := 0; i < bs.BitLength; i++ {
+			e.buf.WriteString(strconv.Itoa(bs.At(i)))
+		}
+		return false, nil
+	case oidType:
+		e.buf.WriteString(strings.TrimPrefix(value.Interface().(Oid).String(), "."))
+		return false, nil
+	case nullType:
+		return false, nil
+	}
+
+	// Generic types:
+	switch value.Kind() {
+	case reflect.Bool:
+		e.buf.WriteString("<" + strconv.FormatBool(value.Bool()) + "/>")
+		return false, nil
+
+	case reflect.String:
+		if opts.stringTag == nil {
+			e.buf.WriteString(strings.ToUpper(hex.EncodeToString([]byte(value.String()))))
+			return false, nil
+		}
+		return false, xml.EscapeText(&e.buf, []byte(value.String()))
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if opts.enum != nil {
+			name, err := e.ctx.getEnumName(*opts.enum, getBigInt(value))
+			if err != nil {
+				return false, err
+			}
+			e.buf.WriteString("<" + name + "/>")
+			return false, nil
+		}
+		e.buf.WriteString(getBigInt(value).String())
+		return false, nil
+
+	case reflect.Struct:
+		return true, e.encodeStruct(value, opts)
+
+	case reflect.Array, reflect.Slice:
+		if value.Type().Elem().Kind() == reflect.Uint8 {
+			data, err := e.ctx.encodeOctetString(value)
+			if err != nil {
+				return false, err
+			}
+			e.buf.WriteString(strings.ToUpper(hex.EncodeToString(data)))
+			return false, nil
+		}
+		return true, e.encodeSequenceOf(value, opts)
+	}
+	return false, syntaxError("invalid Go type: %s", value.Type())
+}
+
+func (e *xerEncoder) encodeStruct(value reflect.Value, opts *fieldOptions) error {
+	root, additions, err := e.ctx.getComponents(value.Type(), opts.set)
+	if err != nil {
+		return err
+	}
+	for _, field := range append(root, additions...) {
+		fieldValue := value.Field(field.Index[0])
+		if isAbsent(fieldValue, field.opts) {
+			continue
+		}
+		e.indent()
+		if err := e.encodeElement(getFieldIdentifier(field), fieldValue, field.opts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *xerEncoder) encodeSequenceOf(value reflect.Value, opts *fieldOptions) error {
+	elemType := value.Type().Elem()
+	elemOpts := opts.elemOptions()
+	valueList := isXerValueList(elemType, elemOpts)
+	name := e.ctx.getXerTypeName(elemType, elemOpts)
+
+	items := make([][]byte, value.Len())
+	for i := range items {
+		item := xerEncoder{ctx: e.ctx, canonical: e.canonical, depth: e.depth}
+		var err error
+		if valueList {
+			_, err = item.encodeValue(value.Index(i), elemOpts)
+		} else {
+			err = item.encodeElement(name, value.Index(i), elemOpts)
+		}
+		if err != nil {
+			return err
+		}
+		items[i] = item.buf.Bytes()
+	}
+	// Canonical order of SET OF
+	if e.canonical && opts.set {
+		sort.Slice(items, func(i, j int) bool {
+			return bytes.Compare(items[i], items[j]) < 0
+		})
+	}
+	for _, item := range items {
+		e.indent()
+		e.buf.Write(item)
+	}
+	return nil
+}
+
+func (e *xerEncoder) encodeChoice(value reflect.Value, opts *fieldOptions) error {
+	value = getActualType(value)
+	if !value.IsValid() {
+		return syntaxError("nil value for choice '%s'", *opts.choice)
+	}
+	entry, err := e.ctx.getChoiceByType(*opts.choice, value.Type())
+	if err != nil {
+		return err
+	}
+	e.indent()
+	return e.encodeElement(e.ctx.getChoiceIdentifier(entry), value, entry.opts)
+}
+
+/*
+ * Decoder
+ */
+
+// xerElement is a parsed XML element.
+type xerElement struct {
+	name     string
+	text     string
+	children []*xerElement
+}
+
+// parseXerElement reads the next element, skipping comments, processing
+// instructions and white space.
+func parseXerElement(decoder *xml.Decoder) (*xerElement, error) {
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return nil, parseError("missing XML element")
+		}
+		if err != nil {
+			return nil, parseError("invalid XML: %s", err)
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			return parseXerContent(decoder, t)
+		case xml.CharData:
+			if len(bytes.TrimSpace(t)) > 0 {
+				return nil, parseError("unexpected XML text: %q", string(t))
+			}
+		case xml.EndElement:
+			return nil, parseError("unexpected XML end element </%s>", t.Name.Local)
+		}
+	}
+}
+
+// parseXerContent reads the content of an element up to its end.
+func parseXerContent(decoder *xml.Decoder, start xml.StartElement) (*xerElement, error) {
+	elem := &xerElement{name: start.Name.Local}
+	text := bytes.Buffer{}
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, parseError("invalid XML: %s", err)
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			child, err := parseXerContent(decoder, t)
+			if err != nil {
+				return nil, err
+			}
+			elem.children = append(elem.children, child)
+		case xml.CharData:
+			text.Write(t)
+		case xml.EndElement:
+			elem.text = text.String()
+			if len(elem.children) > 0 && strings.TrimSpace(elem.text) != "" {
+				return nil, parseError("unexpected text in element <%s>", elem.name)
+			}
+			return elem, nil
+		}
+	}
+}
+
+// Main XER decode function
+func (ctx *Context) decodeXer(elem *xerElement, value reflect.Value, opts *fieldOptions) error {
+
+	if opts.choice != nil {
+		return ctx.decodeXerChoice(elem, value, opts)
+	}
+
+	// Allocate pointers
+	if value.Kind() == reflect.Ptr && value.Type() != bigIntType {
+		ptr := reflect.New(value.Type().Elem())
+		if err := ctx.decodeXer(elem, ptr.Elem(), opts); err != nil {
+			return err
+		}
+		value.Set(ptr)
+		return nil
+	}
+
+	text := strings.TrimSpace(elem.text)
+
+	// Special types:
+	switch value.Type() {
+	case bigIntType:
+		n, ok := new(big.Int).SetString(text, 10)
+		if !ok {
+			return parseError("invalid integer value in <%s>", elem.name)
+		}
+		value.Set(reflect.ValueOf(n))
+		return nil
+	case bitStringType:
+		return decodeXerBitString(text, value)
+	case oidType:
+		oid, err := parseOid(text)
+		if err != nil {
+			return err
+		}
+		value.Set(reflect.ValueOf(oid))
+		return nil
+	case nullType:
+		if text != "" || len(elem.children) > 0 {
+			return parseError("unexpected content for NULL in <%s>", elem.name)
+		}
+		return nil
+	}
+
+	// Generic types:
+	switch value.Kind() {
+	case reflect.Bool:
+		name, err := getXerValueName(elem)
+		if err != nil {
+			return err
+		}
+		switch name {
+		case "true", "1":
+			value.SetBool(true)
+		case "false", "0":
+			value.SetBool(false)
+		default:
+			return parseError("invalid boolean value '%s' in <%s>", name, elem.name)
+		}
+		return nil
+
+	case reflect.String:
+		if opts.stringTag == nil {
+			data, err := decodeXerHex(text)
+			if err != nil {
+				return err
+			}
+			value.SetString(string(data))
+			return nil
+		}
+		value.SetString(elem.text)
+		return nil
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if opts.enum != nil {
+			name, err := getXerValueName(elem)
+			if err != nil {
+				return err
+			}
+			n, err := ctx.getEnumValue(*opts.enum, name)
+			if err != nil {
+				return err
+			}
+			return setBigInt(value, n)
+		}
+		n, ok := new(big.Int).SetString(text, 10)
+		if !ok {
+			return parseError("invalid integer value in <%s>", elem.name)
+		}
+		return setBigInt(value, n)
+
+	case reflect.Struct:
+		return ctx.decodeXerStruct(elem, value, opts)
+
+	case reflect.Array, reflect.Slice:
+		if value.Type().Elem().Kind() == reflect.Uint8 {
+			data, err := decodeXerHex(text)
+			if err != nil {
+				return err
+			}
+			return ctx.decodeOctetString(data, value)
+		}
+		return ctx.decodeXerSequenceOf(elem, value, opts)
+	}
+	return syntaxError("invalid Go type: %s", value.Type())
+}
+
+func (ctx *Context) decodeXerStruct(elem *xerElement, value reflect.Value, opts *fieldOptions) error {
+	fields, err := getStructFields(value.Type())
+	if err != nil {
+		return err
+	}
+	found := make([]bool, len(fields))
+	for _, child := range elem.children {
+		matched := false
+		for i, field := range fields {
+			if !found[i] && getFieldIdentifier(field) == child.name {
+				if err := ctx.decodeXer(child, value.Field(field.Index[0]), field.opts); err != nil {
+					return err
+				}
+				found[i], matched = true, true
+				break
+			}
+		}
+		if !matched && !opts.extensible {
+			return parseError("unexpected element <%s> in <%s>", child.name, elem.name)
+		}
+	}
+	for i, field := range fields {
+		if found[i] {
+			continue
+		}
+		if field.opts.defaultValue != nil {
+			if err := ctx.setDefaultValue(value.Field(field.Index[0]), field.opts); err != nil {
+				return err
+			}
+			continue
+		}
+		if !field.opts.optional && !field.opts.addition {
+			return parseError("missing element <%s> in <%s>", getFieldIdentifier(field), elem.name)
+		}
+	}
+	return nil
+}
+
+func (ctx *Context) decodeXerSequenceOf(elem *xerElement, value reflect.Value, opts *fieldOptions) error {
+	elemType := value.Type().Elem()
+	elemOpts := opts.elemOptions()
+	valueList := isXerValueList(elemType, elemOpts)
+	name := ctx.getXerTypeName(elemType, elemOpts)
+
+	slice := reflect.MakeSlice(reflect.SliceOf(elemType), 0, len(elem.children))
+	for _, child := range elem.children {
+		item := reflect.New(elemType).Elem()
+		if valueList {
+			// The child itself is the value
+			child = &xerElement{name: name, children: []*xerElement{child}}
+		} else if child.name != name {
+			return parseError("expected element <%s> but found <%s>", name, child.name)
+		}
+		if err := ctx.decodeXer(child, item, elemOpts); err != nil {
+			return err
+		}
+		slice = reflect.Append(slice, item)
+	}
+	if value.Kind() == reflect.Slice {
+		value.Set(slice)
+		return nil
+	}
+	if slice.Len() != value.Len() {
+		return parseError("expected %d elements but found %d", value.Len(), slice.Len())
+	}
+	reflect.Copy(value, slice)
+	return nil
+}
+
+func (ctx *Context) decodeXerChoice(elem *xerElement, value reflect.Value, opts *fieldOptions) error {
+	if len(elem.children) != 1 {
+		return parseError("expected one alternative for choice in <%s>", elem.name)
+	}
+	child := elem.children[0]
+	entries, err := ctx.getChoices(*opts.choice)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if ctx.getChoiceIdentifier(entry) == child.name {
+			nestedValue := reflect.New(entry.typ).Elem()
+			if err := ctx.decodeXer(child, nestedValue, entry.opts); err != nil {
+				return err
+			}
+			value.Set(nestedValue)
+			return nil
+		}
+	}
+	return parseError("invalid alternative <%s> for choice '%s'", child.name, *opts.choice)
+}
+
+// getXerValueName returns the name of the empty element used as value, or the
+// text of the element.
+func getXerValueName(elem *xerElement) (string, error) {
+	switch len(elem.children) {
+	case 0:
+		return strings.TrimSpace(elem.text), nil
+	case 1:
+		child := elem.children[0]
+		if len(child.children) == 0 && strings.TrimSpace(child.text) == "" {
+			return child.name, nil
+		}
+	}
+	return "", parseError("invalid value in <%s>", elem.name)
+}
+
+func decodeXerHex(text string) ([]byte, error) {
+	data, err := hex.DecodeString(strings.Join(strings.Fields(text), ""))
+	if err != nil {
+		return nil, parseError("invalid hexadecimal value: %s", err)
+	}
+	return data, nil
+}
+
+func decodeXerBitString(text string, value reflect.Value) error {
+	text = strings.Join(strings.Fields(text), "")
+	bs := BitString{Bytes: make([]byte, (len(text)+7)/8), BitLength: len(text)}
+	for i, c := range text {
+		switch c {
+		case '1':
+			bs.Bytes[i/8] |= 0x80 >> uint(i%8)
+		case '0':
+		default:
+			return parseError("invalid BIT STRING value: %s", text)
+		}
+	}
+	value.Set(reflect.ValueOf(bs))
+	return nil
+}
@@ -0,0 +1,147 @@
+package asn1
+
+import (
+	"reflect"
+	"testing"
+)
+
+// testXer encodes an object using both XER variants, compares with the
+// expected text and then decodes the result back.
+func testXer(t *testing.T, ctx *Context, options string, value interface{}, basic, canonical string) {
+	variants := []struct {
+		name     string
+		expected string
+		encode   func(interface{}, string) ([]byte, error)
+	}{
+		{"XER", basic, ctx.EncodeXer},
+		{"CXER", canonical, ctx.EncodeCxer},
+	}
+	for _, v := range variants {
+		data, err := v.encode(value, options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v.expected != "" && string(data) != v.expected {
+			t.Fatalf("Failed to encode %s \"%v\".\n Expected: %q.\n Got:      %q",
+				v.name, value, v.expected, data)
+		}
+		decoded := reflect.New(reflect.TypeOf(value))
+		rest, err := ctx.DecodeXer(data, decoded.Interface(), options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(rest) > 0 && v.name == "CXER" {
+			t.Fatalf("Unexpected remaining bytes when decoding %s \"%v\": %q\n",
+				v.name, value, rest)
+		}
+		checkEqual(t, decoded.Elem().Interface(), value)
+	}
+}
+
+func TestXerSimpleTypes(t *testing.T) {
+	ctx := NewContext()
+	testXer(t, ctx, "", 10, "<INTEGER>10</INTEGER>\n", "<INTEGER>10</INTEGER>")
+	testXer(t, ctx, "", true, "<BOOLEAN><true/></BOOLEAN>\n", "<BOOLEAN><true/></BOOLEAN>")
+	testXer(t, ctx, "", []byte{0x0a, 0xff}, "", "<OCTET_STRING>0AFF</OCTET_STRING>")
+	testXer(t, ctx, "utf8", "a<b", "", "<UTF8String>a&lt;b</UTF8String>")
+	testXer(t, ctx, "", BitString{[]byte{0xa0}, 3}, "", "<BIT_STRING>101</BIT_STRING>")
+	testXer(t, ctx, "", Oid{1, 2, 840}, "", "<OBJECT_IDENTIFIER>1.2.840</OBJECT_IDENTIFIER>")
+	testXer(t, ctx, "", Null{}, "", "<NULL/>")
+	testXer(t, ctx, "name:Age", 3, "", "<Age>3</Age>")
+}
+
+func TestXerSequence(t *testing.T) {
+	type Point struct {
+		X int
+		Y int `asn1:"default:1"`
+	}
+	type Shape struct {
+		Name   string  `asn1:"ia5,name:name"`
+		Points []Point `asn1:"name:points"`
+		Closed bool    `asn1:"optional"`
+	}
+	ctx := NewContext()
+	testXer(t, ctx, "", Shape{"line", []Point{{0, 1}, {3, 4}}, true},
+		"<Shape>\n"+
+			"  <name>line</name>\n"+
+			"  <points>\n"+
+			"    <Point>\n"+
+			"      <X>0</X>\n"+
+			"    </Point>\n"+
+			"    <Point>\n"+
+			"      <X>3</X>\n"+
+			"      <Y>4</Y>\n"+
+			"    </Point>\n"+
+			"  </points>\n"+
+			"  <Closed><true/></Closed>\n"+
+			"</Shape>\n",
+		"<Shape><name>line</name><points><Point><X>0</X></Point>"+
+			"<Point><X>3</X><Y>4</Y></Point></points><Closed><true/></Closed></Shape>")
+
+	// Unknown elements are rejected unless the type is extensible
+	data := []byte("<Point><X>1</X><Z>2</Z></Point>")
+	p := Point{}
+	if _, err := ctx.DecodeXer(data, &p, ""); err == nil {
+		t.Fatal("Decoding an unknown element should have failed.")
+	}
+	if _, err := ctx.DecodeXer(data, &p, "extensible"); err != nil {
+		t.Fatal(err)
+	}
+	checkEqual(t, p, Point{1, 1})
+
+	// Missing mandatory element
+	if _, err := ctx.DecodeXer([]byte("<Point/>"), &p, ""); err == nil {
+		t.Fatal("Decoding a missing element should have failed.")
+	}
+}
+
+func TestXerSetOf(t *testing.T) {
+	ctx := NewContext()
+	testXer(t, ctx, "set", []int{1, 2, 3}, "", "<SET_OF><INTEGER>1</INTEGER>"+
+		"<INTEGER>2</INTEGER><INTEGER>3</INTEGER></SET_OF>")
+
+	// The canonical variant sorts the encoded elements
+	data, err := ctx.EncodeCxer([]int{3, 1, 2}, "set")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "<SET_OF><INTEGER>1</INTEGER><INTEGER>2</INTEGER><INTEGER>3</INTEGER></SET_OF>"
+	if string(data) != expected {
+		t.Fatalf("Failed to sort SET OF.\n Expected: %q.\n Got:      %q", expected, data)
+	}
+}
+
+func TestXerChoiceEnum(t *testing.T) {
+	type Color int
+	type Msg struct {
+		Color Color       `asn1:"enum:color"`
+		Value interface{} `asn1:"choice:value"`
+	}
+	ctx := NewContext()
+	ctx.AddEnum("color", []Enum{
+		{Name: "red", Value: 0},
+		{Name: "green", Value: 1},
+	})
+	ctx.AddChoice("value", []Choice{
+		{reflect.TypeOf(""), "tag:1,utf8,name:text"},
+		{reflect.TypeOf(int(0)), "tag:0,name:number"},
+	})
+	testXer(t, ctx, "", Msg{1, "hi"},
+		"<Msg>\n"+
+			"  <Color><green/></Color>\n"+
+			"  <Value>\n"+
+			"    <text>hi</text>\n"+
+			"  </Value>\n"+
+			"</Msg>\n",
+		"<Msg><Color><green/></Color><Value><text>hi</text></Value></Msg>")
+	testXer(t, ctx, "", Msg{0, 5}, "",
+		"<Msg><Color><red/></Color><Value><number>5</number></Value></Msg>")
+
+	// Values as text
+	msg := Msg{}
+	data := []byte("<Msg><Color>green</Color><Value><number>7</number></Value></Msg>")
+	if _, err := ctx.DecodeXer(data, &msg, ""); err != nil {
+		t.Fatal(err)
+	}
+	checkEqual(t, msg, Msg{1, 7})
+}